@@ -2,4 +2,4 @@
 
 mkdir -p output/bin
 go build -o output/bin/monoservice ./cmd/main.go
-go build -o output/bin ./test/driver.go
+go build -o output/bin/mono ./cmd/mono
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/dianpeng/mono-service/pl"
+)
+
+func init() {
+	addCommand("fmt", "reformat PL source code into canonical style", runFmt)
+}
+
+func isPLFile(path string) bool {
+	switch filepath.Ext(path) {
+	case ".pl", ".m":
+		return true
+	default:
+		return false
+	}
+}
+
+// expand the command line arguments into list of PL files, a directory is
+// walked recursively
+func collectPLFile(args []string) ([]string, error) {
+	out := []string{}
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			out = append(out, arg)
+			continue
+		}
+
+		err = filepath.WalkDir(arg, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && isPLFile(path) {
+				out = append(out, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func runFmt(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	check := flags.Bool("check", false, "list files whose formatting differs, exit with 1 if any")
+	inplace := flags.Bool("inplace", false, "write result back to the source file instead of stdout")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: mono fmt [-check|-inplace] [path ...]\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if *check && *inplace {
+		fmt.Fprintf(os.Stderr, "-check and -inplace cannot be used together\n")
+		return 2
+	}
+
+	// formatting stdin to stdout
+	if flags.NArg() == 0 {
+		src, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+			return 1
+		}
+		output, err := pl.Format(string(src))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "<stdin>: %s\n", err.Error())
+			return 1
+		}
+		if *check {
+			if output != string(src) {
+				fmt.Println("<stdin>")
+				return 1
+			}
+			return 0
+		}
+		os.Stdout.WriteString(output)
+		return 0
+	}
+
+	files, err := collectPLFile(flags.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		return 1
+	}
+
+	status := 0
+	for _, path := range files {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+			status = 1
+			continue
+		}
+		output, err := pl.Format(string(src))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", path, err.Error())
+			status = 1
+			continue
+		}
+
+		switch {
+		case *check:
+			if output != string(src) {
+				fmt.Println(path)
+				status = 1
+			}
+			break
+
+		case *inplace:
+			if output != string(src) {
+				if err := os.WriteFile(path, []byte(output), 0644); err != nil {
+					fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+					status = 1
+				}
+			}
+			break
+
+		default:
+			os.Stdout.WriteString(output)
+			break
+		}
+	}
+	return status
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	_ "github.com/dianpeng/mono-service/http/prelude"
+	"github.com/dianpeng/mono-service/http/vhost"
+	"github.com/dianpeng/mono-service/pl"
+)
+
+func init() {
+	addCommand("lint", "run static analysis against PL source code", runLint)
+}
+
+// lint a single file. Without root the file is compiled the same as the test
+// driver, ie import is resolved against the working directory, and a http
+// service uses its own directory as the manifest root. Otherwise the import is
+// resolved inside of the root, which is how manifest loads the http service
+func lintFile(path string, root string, http bool) ([]pl.LintDiag, error) {
+	var fsp fs.FS
+	name := path
+
+	if root != "" {
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return nil, err
+		}
+		fsp = os.DirFS(root)
+		name = filepath.ToSlash(rel)
+	} else if http {
+		// same as the manifest created from local directory, whose root is the
+		// directory of its main file
+		fsp = os.DirFS(filepath.Dir(path))
+		name = filepath.Base(path)
+	}
+
+	if http {
+		return vhost.LintService(name, fsp)
+	}
+
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m, err := pl.CompileModule(string(src), fsp)
+	if err != nil {
+		return nil, err
+	}
+	return pl.Lint(m, nil), nil
+}
+
+func runLint(args []string) int {
+	flags := flag.NewFlagSet("lint", flag.ExitOnError)
+	http := flags.Bool("http", false, "treat the file as http service and check it against the http host")
+	root := flags.String("root", "", "directory the import is resolved against, ie the manifest directory")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: mono lint [-http] [-root dir] path ...\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	files, err := collectPLFile(flags.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		return 1
+	}
+
+	ret := 0
+	for _, f := range files {
+		diag, err := lintFile(f, *root, *http)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", f, err.Error())
+			ret = 1
+		}
+		for _, d := range diag {
+			fmt.Printf("%s:%s\n", f, d.String())
+			if d.IsError() {
+				ret = 1
+			}
+		}
+	}
+	return ret
+}
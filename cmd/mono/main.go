@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+// mono is the command line toolbox for working with PL scripts, each tool is
+// a sub command with its own flags, ie mono fmt -check ./sample
+
+type command struct {
+	usage string
+	run   func([]string) int
+}
+
+var commandList = map[string]command{}
+
+func addCommand(name string, usage string, run func([]string) int) {
+	commandList[name] = command{
+		usage: usage,
+		run:   run,
+	}
+}
+
+func printHelp() {
+	fmt.Fprintf(os.Stderr, "usage: mono <command> [arguments]\n\ncommands:\n")
+	names := []string{}
+	for name := range commandList {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n", name, commandList[name].usage)
+	}
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		printHelp()
+		os.Exit(2)
+	}
+
+	cmd, ok := commandList[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n", os.Args[1])
+		printHelp()
+		os.Exit(2)
+	}
+
+	os.Exit(cmd.run(os.Args[2:]))
+}
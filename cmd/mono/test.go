@@ -0,0 +1,128 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/dianpeng/mono-service/pl"
+	"github.com/dianpeng/mono-service/test"
+)
+
+func init() {
+	addCommand("test", "run test rules and functions of PL source code", runTest)
+}
+
+func runTest(args []string) int {
+	flags := flag.NewFlagSet("test", flag.ExitOnError)
+	format := flags.String("format", "tap", "report format, tap or junit")
+	output := flags.String("o", "", "write the report into the file instead of stdout")
+	run := flags.String("run", "", "only run tests whose name matches the regexp")
+	coverProfile := flags.String("coverprofile", "", "write the line coverage as LCOV into the file")
+	coverHTML := flags.String("coverhtml", "", "write the line coverage as HTML report into the file")
+	optimize := flags.Bool("O", false, "run tests against the optimized bytecode")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: mono test [-format tap|junit] [-o file] [-run regexp] [-coverprofile file] [-coverhtml file] [-O] path ...\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	var write func(io.Writer, []test.Result) error
+	switch *format {
+	case "tap":
+		write = test.WriteTAP
+	case "junit":
+		write = test.WriteJUnit
+	default:
+		fmt.Fprintf(os.Stderr, "unknown format: %s\n", *format)
+		return 2
+	}
+
+	runner := &test.Runner{}
+	if *run != "" {
+		re, err := regexp.Compile(*run)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid -run: %s\n", err.Error())
+			return 2
+		}
+		runner.Filter = re
+	}
+	if *optimize {
+		runner.Optimize = pl.OptAll
+	}
+	if *coverProfile != "" || *coverHTML != "" {
+		runner.Coverage = pl.NewCoverage()
+		runner.Coverage.Enable(true)
+	}
+
+	files, err := collectPLFile(flags.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		return 1
+	}
+
+	result := []test.Result{}
+	ret := 0
+	for _, f := range files {
+		// module file cannot be compiled on its own, its tests are discovered
+		// through the file that imports it
+		if filepath.Ext(f) == ".m" {
+			continue
+		}
+		r := runner.RunFile(f)
+		if r.Failed() != 0 {
+			ret = 1
+		}
+		result = append(result, r)
+	}
+
+	out := io.Writer(os.Stdout)
+	if *output != "" {
+		fd, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+			return 1
+		}
+		defer fd.Close()
+		out = fd
+	}
+
+	if err := write(out, result); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		return 1
+	}
+
+	if *coverProfile != "" {
+		if err := writeCoverage(*coverProfile, runner.Coverage.WriteLCOV); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+			return 1
+		}
+	}
+	if *coverHTML != "" {
+		if err := writeCoverage(*coverHTML, runner.Coverage.WriteHTML); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+			return 1
+		}
+	}
+	return ret
+}
+
+func writeCoverage(path string, write func(io.Writer) error) error {
+	fd, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(fd); err != nil {
+		fd.Close()
+		return err
+	}
+	return fd.Close()
+}
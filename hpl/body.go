@@ -40,7 +40,7 @@ func (h *Body) SetBuffer(data []byte) {
 }
 
 func (h *Body) Index(name pl.Val) (pl.Val, error) {
-	if name.Type != pl.ValStr {
+	if name.Type() != pl.ValStr {
 		return pl.NewValNull(), fmt.Errorf("http.body invalid field index")
 	}
 
@@ -58,7 +58,7 @@ func (h *Body) Dot(name string) (pl.Val, error) {
 }
 
 func (h *Body) IndexSet(name pl.Val, val pl.Val) error {
-	if name.Type != pl.ValStr {
+	if name.Type() != pl.ValStr {
 		return fmt.Errorf("http.body invalid field index")
 	}
 
@@ -72,7 +72,7 @@ func (h *Body) IndexSet(name pl.Val, val pl.Val) error {
 			return nil
 		}
 
-		if val.Type == pl.ValStr {
+		if val.Type() == pl.ValStr {
 			h.streamVal = NewReadableStreamValFromString(val.String())
 			s, ok := h.streamVal.Usr().(*ReadableStream)
 			must(ok, "must be readablestream")
@@ -80,6 +80,14 @@ func (h *Body) IndexSet(name pl.Val, val pl.Val) error {
 			return nil
 		}
 
+		if val.Type() == pl.ValBytes {
+			h.streamVal = NewReadableStreamValFromBuffer(val.Bytes())
+			s, ok := h.streamVal.Usr().(*ReadableStream)
+			must(ok, "must be readablestream")
+			h.stream = s
+			return nil
+		}
+
 		return fmt.Errorf("http.body.stream assignment value type invalid")
 	default:
 		break
@@ -105,6 +113,7 @@ func (h *Body) ToString() (string, error) {
 
 var (
 	methodProtoString = pl.MustNewFuncProto("http.body.string", "%0")
+	methodProtoBytes  = pl.MustNewFuncProto("http.body.bytes", "%0")
 )
 
 func (h *Body) Method(name string, arg []pl.Val) (pl.Val, error) {
@@ -120,6 +129,17 @@ func (h *Body) Method(name string, arg []pl.Val) (pl.Val, error) {
 		}
 		return pl.NewValStr(str), nil
 
+	case "bytes":
+		_, err := methodProtoBytes.Check(arg)
+		if err != nil {
+			return pl.NewValNull(), err
+		}
+		b, err := h.stream.ConsumeAsBytes()
+		if err != nil {
+			return pl.NewValNull(), err
+		}
+		return pl.NewValBytes(append([]byte{}, b...)), nil
+
 	default:
 		break
 	}
@@ -180,9 +200,11 @@ func NewBodyValFromBuffer(data []byte) pl.Val {
 }
 
 func NewBodyValFromVal(v pl.Val) (pl.Val, error) {
-	switch v.Type {
+	switch v.Type() {
 	case pl.ValStr:
 		return NewBodyValFromString(v.String()), nil
+	case pl.ValBytes:
+		return NewBodyValFromBuffer(v.Bytes()), nil
 	default:
 		if ValIsReadableStream(v) {
 			x, _ := v.Usr().(*ReadableStream)
@@ -111,7 +111,7 @@ func FnHttpPost(factory HttpClientFactory, argument []pl.Val) (pl.Val, error) {
 		return pl.NewValNull(), fmt.Errorf("http::post cannot create request: %s", err.Error())
 	}
 
-	if headerval.Type != pl.ValNull {
+	if headerval.Type() != pl.ValNull {
 		hdr, _ := headerval.Usr().(*Header)
 		hreq.Header = hdr.HttpHeader()
 	}
@@ -129,47 +129,54 @@ func FnHttpPost(factory HttpClientFactory, argument []pl.Val) (pl.Val, error) {
 	return NewResponseVal(resp), nil
 }
 
-func FnHttpDo(factory HttpClientFactory, argument []pl.Val) (pl.Val, error) {
-	asize, err := fnProtoHttpDo.Check(argument)
-	if err != nil {
-		return pl.NewValNull(), err
-	}
-
-	var req *http.Request
-
-	if asize > 1 {
+// create the request object from the argument of http::do style function, ie
+// either a http.request object or url, method, [header], [body]
+func newHttpDoRequest(fn string, argument []pl.Val, asize int) (*http.Request, error) {
+	if asize > 1 && argument[0].Type() == pl.ValStr {
 		url := argument[0].String()
 		method := argument[1].String()
 
 		var body io.Reader
 		body = http.NoBody
 
-		if asize == 4 && argument[3].Type != pl.ValNull {
+		if asize >= 4 && argument[3].Type() != pl.ValNull {
 			bodyval, err := NewBodyValFromVal(argument[3])
 			if err != nil {
-				return pl.NewValNull(), fmt.Errorf("http::do cannot create body: %s", err.Error())
+				return nil, fmt.Errorf("%s cannot create body: %s", fn, err.Error())
 			}
 			b, _ := bodyval.Usr().(*Body)
 			body = b.Stream().Stream
 		}
 
-		if hreq, err := http.NewRequest(method, url, body); err != nil {
-			return pl.NewValNull(), fmt.Errorf("http::do cannot create request: %s", err.Error())
-		} else {
-			req = hreq
+		req, err := http.NewRequest(method, url, body)
+		if err != nil {
+			return nil, fmt.Errorf("%s cannot create request: %s", fn, err.Error())
 		}
 
-		if asize >= 3 && argument[3].Type != pl.ValNull {
+		if asize >= 3 && argument[2].Type() != pl.ValNull {
 			hdrval, err := NewHeaderValFromVal(argument[2])
 			if err != nil {
-				return pl.NewValNull(), fmt.Errorf("http::do cannot create header: %s", err.Error())
+				return nil, fmt.Errorf("%s cannot create header: %s", fn, err.Error())
 			}
 			b, _ := hdrval.Usr().(*Header)
 			req.Header = b.HttpHeader()
 		}
-	} else {
-		hreq, _ := argument[0].Usr().(*Request)
-		req = hreq.HttpRequest()
+		return req, nil
+	}
+
+	hreq, _ := argument[0].Usr().(*Request)
+	return hreq.HttpRequest(), nil
+}
+
+func FnHttpDo(factory HttpClientFactory, argument []pl.Val) (pl.Val, error) {
+	asize, err := fnProtoHttpDo.Check(argument)
+	if err != nil {
+		return pl.NewValNull(), err
+	}
+
+	req, err := newHttpDoRequest("http::do", argument, asize)
+	if err != nil {
+		return pl.NewValNull(), err
 	}
 
 	client, err := factory.GetHttpClient(req.URL.String())
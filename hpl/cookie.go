@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/dianpeng/mono-service/pl"
 	"net/http"
+	"time"
 )
 
 type cookie struct {
@@ -47,7 +48,14 @@ func (c *cookie) Dot(key string) (pl.Val, error) {
 		return pl.NewValStr(c.c.Path), nil
 	case "domain":
 		return pl.NewValStr(c.c.Domain), nil
+	// expires as time value, null when the cookie does not have a valid expires
+	// attribute. The raw attribute is available via rawExpires
 	case "expireString":
+		if c.c.Expires.IsZero() {
+			return pl.NewValNull(), nil
+		}
+		return pl.NewValTime(c.c.Expires), nil
+	case "rawExpires":
 		return pl.NewValStr(c.c.RawExpires), nil
 
 	case "maxAge":
@@ -122,6 +130,28 @@ func (c *cookie) DotSet(key string, val pl.Val) error {
 		}
 		break
 
+	// accept time value or HTTP-date string, null clears the attribute
+	case "expireString":
+		if pl.ValIsTime(val) {
+			t, _ := val.Usr().(*pl.Time)
+			c.c.Expires = t.Time()
+		} else if val.IsString() {
+			t, err := http.ParseTime(val.String())
+			if err != nil {
+				return fmt.Errorf("%s's field 'expireString' is not a valid HTTP-date: %s", c.Id(), err.Error())
+			}
+			c.c.Expires = t
+		} else if val.IsNull() {
+			c.c.Expires = time.Time{}
+		} else {
+			return fmt.Errorf("%s's field 'expireString' must set with value of type time or string", c.Id())
+		}
+		c.c.RawExpires = ""
+		if !c.c.Expires.IsZero() {
+			c.c.RawExpires = pl.HttpDate(c.c.Expires)
+		}
+		break
+
 	case "maxAge":
 		if val.IsInt() {
 			c.c.MaxAge = int(val.Int())
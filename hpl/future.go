@@ -0,0 +1,398 @@
+package hpl
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/dianpeng/mono-service/pl"
+)
+
+// http::async issues the request in background goroutine and returns a future
+// object immediately. Script can wait for the future individually or use the
+// combinators http::all/any/race to wait for a group of futures.
+//
+// The timeout can be specified per request as the last argument of http::async
+// and for a group as the last argument of the combinator. Timeout can be either
+// an integer which is in milliseconds or a duration object
+
+var (
+	fnProtoHttpAsync = pl.MustNewModFuncProto("http", "async",
+		"{%U['http.request']}"+ /* just request */
+			"{%U['http.request'](%d|%U['duration'])}"+ /* request, timeout */
+			"{%s%s}"+ /* url, method */
+			"{%s%s%a}"+ /* url, method, header */
+			"{%s%s%a%a}"+ /* url, method, header, body */
+			"{%s%s%a%a(%d|%U['duration'])}", /* url, method, header, body, timeout */
+	)
+
+	fnProtoHttpAll  = pl.MustNewModFuncProto("http", "all", "{%l}{%l(%d|%U['duration'])}")
+	fnProtoHttpAny  = pl.MustNewModFuncProto("http", "any", "{%l}{%l(%d|%U['duration'])}")
+	fnProtoHttpRace = pl.MustNewModFuncProto("http", "race", "{%l}{%l(%d|%U['duration'])}")
+
+	mpFutureWait   = pl.MustNewFuncProto("http.future.wait", "%0")
+	mpFutureDone   = pl.MustNewFuncProto("http.future.done", "%0")
+	mpFutureCancel = pl.MustNewFuncProto("http.future.cancel", "%0")
+)
+
+type Future struct {
+	url    string
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	// written by the background goroutine before done is closed
+	resp *http.Response
+	err  error
+
+	// converted result, only touched by the script side
+	result pl.Val
+}
+
+func ValIsHttpFuture(a pl.Val) bool {
+	return a.Id() == HttpFutureTypeId
+}
+
+func newFuture(client HttpClient, req *http.Request, timeout time.Duration) *Future {
+	var ctx context.Context
+	var cancel context.CancelFunc
+
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(req.Context(), timeout)
+	} else {
+		ctx, cancel = context.WithCancel(req.Context())
+	}
+
+	f := &Future{
+		url:    req.URL.String(),
+		cancel: cancel,
+		done:   make(chan struct{}),
+		result: pl.NewValNull(),
+	}
+
+	go func() {
+		defer close(f.done)
+		resp, err := client.Do(req.WithContext(ctx))
+		if err == nil && ctx.Err() != nil {
+			// cancelled while the response is been delivered, nobody is going to
+			// read the body
+			resp.Body.Close()
+			resp, err = nil, ctx.Err()
+		}
+		f.resp = resp
+		f.err = err
+	}()
+	return f
+}
+
+func (f *Future) IsDone() bool {
+	select {
+	case <-f.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// cancel the pending request, noop if the future is already settled
+func (f *Future) Cancel() {
+	if !f.IsDone() {
+		f.cancel()
+	}
+}
+
+// block until the future is settled without touching the result
+func (f *Future) Settle() {
+	<-f.done
+}
+
+// block until the future is settled and returns the response object
+func (f *Future) Wait() (pl.Val, error) {
+	<-f.done
+	if f.err != nil {
+		return pl.NewValNull(), fmt.Errorf("http::async %s failed: %s", f.url, f.err.Error())
+	}
+	if f.result.IsNull() {
+		f.result = NewResponseVal(f.resp)
+	}
+	return f.result, nil
+}
+
+func (f *Future) Index(name pl.Val) (pl.Val, error) {
+	if !name.IsString() {
+		return pl.NewValNull(), fmt.Errorf("http.future index, invalid key type")
+	}
+	return f.Dot(name.String())
+}
+
+func (f *Future) IndexSet(_ pl.Val, _ pl.Val) error {
+	return fmt.Errorf("http.future index set, unsupported operation")
+}
+
+func (f *Future) Dot(name string) (pl.Val, error) {
+	switch name {
+	case "url":
+		return pl.NewValStr(f.url), nil
+	case "error":
+		if f.IsDone() && f.err != nil {
+			return pl.NewValStr(f.err.Error()), nil
+		}
+		return pl.NewValNull(), nil
+	default:
+		return pl.NewValNull(), fmt.Errorf("http.future, unknown field: %s", name)
+	}
+}
+
+func (f *Future) DotSet(key string, _ pl.Val) error {
+	return fmt.Errorf("http.future set, unknown field: %s", key)
+}
+
+func (f *Future) Method(name string, args []pl.Val) (pl.Val, error) {
+	switch name {
+	case "wait":
+		if _, err := mpFutureWait.Check(args); err != nil {
+			return pl.NewValNull(), err
+		}
+		return f.Wait()
+
+	case "done":
+		if _, err := mpFutureDone.Check(args); err != nil {
+			return pl.NewValNull(), err
+		}
+		return pl.NewValBool(f.IsDone()), nil
+
+	case "cancel":
+		if _, err := mpFutureCancel.Check(args); err != nil {
+			return pl.NewValNull(), err
+		}
+		f.Cancel()
+		return pl.NewValNull(), nil
+
+	default:
+		return pl.NewValNull(), fmt.Errorf("http.future method %s is unknown", name)
+	}
+}
+
+func (f *Future) ToString() (string, error) {
+	return f.Info(), nil
+}
+
+func (f *Future) ToJSON() (pl.Val, error) {
+	return pl.MarshalVal(
+		map[string]interface{}{
+			"url":  f.url,
+			"done": f.IsDone(),
+		},
+	)
+}
+
+func (f *Future) Id() string {
+	return HttpFutureTypeId
+}
+
+func (f *Future) Info() string {
+	return fmt.Sprintf("[%s: %s]", HttpFutureTypeId, f.url)
+}
+
+func (f *Future) ToNative() interface{} {
+	return f
+}
+
+func (f *Future) IsThreadSafe() bool {
+	return false
+}
+
+func (f *Future) NewIterator() (pl.Iter, error) {
+	return nil, fmt.Errorf("http.future does not support iterator")
+}
+
+func NewFutureVal(f *Future) pl.Val {
+	return pl.NewValUsr(f)
+}
+
+func toTimeout(v pl.Val) time.Duration {
+	if v.IsInt() {
+		return time.Duration(v.Int()) * time.Millisecond
+	}
+	d, _ := v.Usr().(*pl.Duration)
+	return d.Duration()
+}
+
+// http::async, returns the future object. The future is also returned as Go
+// object so the caller is able to track the pending requests
+func FnHttpAsync(factory HttpClientFactory, argument []pl.Val) (pl.Val, *Future, error) {
+	asize, err := fnProtoHttpAsync.Check(argument)
+	if err != nil {
+		return pl.NewValNull(), nil, err
+	}
+
+	req, err := newHttpDoRequest("http::async", argument, asize)
+	if err != nil {
+		return pl.NewValNull(), nil, err
+	}
+
+	timeout := time.Duration(0)
+	if (asize == 2 && argument[0].Type() == pl.ValUsr) || asize == 5 {
+		timeout = toTimeout(argument[asize-1])
+	}
+
+	client, err := factory.GetHttpClient(req.URL.String())
+	if err != nil {
+		return pl.NewValNull(), nil, fmt.Errorf("http::async cannot create client: %s", err.Error())
+	}
+
+	f := newFuture(client, req, timeout)
+	return NewFutureVal(f), f, nil
+}
+
+func toFutureList(fn string, argument []pl.Val) ([]*Future, time.Duration, error) {
+	l := argument[0].List()
+	o := make([]*Future, 0, l.Length())
+
+	for idx, v := range l.Data {
+		if !ValIsHttpFuture(v) {
+			return nil, 0, fmt.Errorf("%s: element %d is not http.future", fn, idx)
+		}
+		f, _ := v.Usr().(*Future)
+		o = append(o, f)
+	}
+
+	timeout := time.Duration(0)
+	if len(argument) == 2 {
+		timeout = toTimeout(argument[1])
+	}
+	return o, timeout, nil
+}
+
+// wait for the futures been settled one by one in completion order until the
+// visitor returns true. Once the wait finishes, all the pending futures are
+// cancelled
+func waitFutures(fn string, list []*Future, timeout time.Duration,
+	visitor func(*Future) bool) error {
+
+	ch := make(chan *Future, len(list))
+	for _, f := range list {
+		go func(f *Future) {
+			<-f.done
+			ch <- f
+		}(f)
+	}
+
+	defer func() {
+		for _, f := range list {
+			f.Cancel()
+		}
+	}()
+
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	for i := 0; i < len(list); i++ {
+		select {
+		case f := <-ch:
+			if visitor(f) {
+				return nil
+			}
+		case <-deadline:
+			return fmt.Errorf("%s: timeout after %s", fn, timeout)
+		}
+	}
+	return nil
+}
+
+// http::all, wait for all the futures and returns list of responses in the
+// same order of the input. Any failure aborts the whole group
+func FnHttpAll(argument []pl.Val) (pl.Val, error) {
+	if _, err := fnProtoHttpAll.Check(argument); err != nil {
+		return pl.NewValNull(), err
+	}
+	list, timeout, err := toFutureList("http::all", argument)
+	if err != nil {
+		return pl.NewValNull(), err
+	}
+
+	var failed *Future
+	if err := waitFutures("http::all", list, timeout, func(f *Future) bool {
+		if f.err != nil {
+			failed = f
+			return true
+		}
+		return false
+	}); err != nil {
+		return pl.NewValNull(), err
+	}
+	if failed != nil {
+		_, err := failed.Wait()
+		return pl.NewValNull(), err
+	}
+
+	o := pl.NewValList()
+	for _, f := range list {
+		r, _ := f.Wait()
+		o.AddList(r)
+	}
+	return o, nil
+}
+
+// http::any, returns the first successful response. Only fails when all the
+// futures fail
+func FnHttpAny(argument []pl.Val) (pl.Val, error) {
+	if _, err := fnProtoHttpAny.Check(argument); err != nil {
+		return pl.NewValNull(), err
+	}
+	list, timeout, err := toFutureList("http::any", argument)
+	if err != nil {
+		return pl.NewValNull(), err
+	}
+
+	var winner *Future
+	var lastErr *Future
+	if err := waitFutures("http::any", list, timeout, func(f *Future) bool {
+		if f.err == nil {
+			winner = f
+			return true
+		}
+		lastErr = f
+		return false
+	}); err != nil {
+		return pl.NewValNull(), err
+	}
+
+	if winner != nil {
+		return winner.Wait()
+	}
+	if lastErr != nil {
+		_, err := lastErr.Wait()
+		return pl.NewValNull(), fmt.Errorf("http::any: all requests failed, last error: %s", err.Error())
+	}
+	return pl.NewValNull(), fmt.Errorf("http::any: empty future list")
+}
+
+// http::race, returns the result of the first settled future regardless it is
+// a success or failure
+func FnHttpRace(argument []pl.Val) (pl.Val, error) {
+	if _, err := fnProtoHttpRace.Check(argument); err != nil {
+		return pl.NewValNull(), err
+	}
+	list, timeout, err := toFutureList("http::race", argument)
+	if err != nil {
+		return pl.NewValNull(), err
+	}
+
+	var winner *Future
+	if err := waitFutures("http::race", list, timeout, func(f *Future) bool {
+		winner = f
+		return true
+	}); err != nil {
+		return pl.NewValNull(), err
+	}
+
+	if winner == nil {
+		return pl.NewValNull(), fmt.Errorf("http::race: empty future list")
+	}
+	return winner.Wait()
+}
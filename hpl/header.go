@@ -83,7 +83,7 @@ func (h *Header) Length() int {
 }
 
 func (h *Header) Index(key pl.Val) (pl.Val, error) {
-	if key.Type != pl.ValStr {
+	if key.Type() != pl.ValStr {
 		return pl.NewValNull(), fmt.Errorf("invalid index for http.header")
 	}
 	return pl.NewValStr(h.header.Get(key.String())), nil
@@ -94,7 +94,7 @@ func (h *Header) Dot(key string) (pl.Val, error) {
 }
 
 func (h *Header) IndexSet(key pl.Val, val pl.Val) error {
-	if key.Type == pl.ValStr {
+	if key.Type() == pl.ValStr {
 		return h.DotSet(key.String(), val)
 	}
 	return fmt.Errorf("invalid index type for http.header")
@@ -133,68 +133,77 @@ var (
 	methodProtoHeaderLength         = pl.MustNewFuncProto("http.header.length", "%0")
 )
 
-func (h *Header) Method(name string, arg []pl.Val) (pl.Val, error) {
-	switch name {
-	case "has":
+// dispatch table of http.header, the header field is accessed via arbitrary
+// dot name so only the methods are registered
+var headerDispatch = pl.NewDispatchTable().
+	AddMethod("has", func(u pl.Usr, arg []pl.Val) (pl.Val, error) {
 		if _, err := methodProtoHeaderHas.Check(arg); err != nil {
 			return pl.NewValNull(), err
 		}
-		return pl.NewValBool(h.Has(arg[0].String())), nil
-
-	case "getFirst":
+		return pl.NewValBool(u.(*Header).Has(arg[0].String())), nil
+	}).
+	AddMethod("getFirst", func(u pl.Usr, arg []pl.Val) (pl.Val, error) {
 		if _, err := methodProtoHeaderGetFirst.Check(arg); err != nil {
 			return pl.NewValNull(), err
 		}
-		return pl.NewValStr(h.GetFirst(arg[0].String())), nil
-
-	case "get":
+		return pl.NewValStr(u.(*Header).GetFirst(arg[0].String())), nil
+	}).
+	AddMethod("get", func(u pl.Usr, arg []pl.Val) (pl.Val, error) {
 		if _, err := methodProtoHeaderGet.Check(arg); err != nil {
 			return pl.NewValNull(), err
 		}
-
-		return pl.NewValStr(h.Get(arg[0].String(), arg[1].String())), nil
-
-	case "getByFilter":
+		return pl.NewValStr(u.(*Header).Get(arg[0].String(), arg[1].String())), nil
+	}).
+	AddMethod("getByFilter", func(u pl.Usr, arg []pl.Val) (pl.Val, error) {
 		if _, err := methodProtoHeaderGetByFilter.Check(arg); err != nil {
 			return pl.NewValNull(), err
 		}
-
-		return pl.NewValStr(h.GetByFilter(arg[0], arg[1].String())), nil
-
-	case "delete":
+		return pl.NewValStr(u.(*Header).GetByFilter(arg[0], arg[1].String())), nil
+	}).
+	AddMethod("delete", func(u pl.Usr, arg []pl.Val) (pl.Val, error) {
 		if _, err := methodProtoHeaderDelete.Check(arg); err != nil {
 			return pl.NewValNull(), err
 		}
-		h.Delete(arg[0].String())
+		u.(*Header).Delete(arg[0].String())
 		return pl.NewValNull(), nil
-
-	case "deleteByFilter":
+	}).
+	AddMethod("deleteByFilter", func(u pl.Usr, arg []pl.Val) (pl.Val, error) {
 		if _, err := methodProtoHeaderDeleteByFilter.Check(arg); err != nil {
 			return pl.NewValNull(), err
 		}
-		return pl.NewValInt(h.DeleteByFilter(arg[0])), nil
-
-	case "set":
+		return pl.NewValInt(u.(*Header).DeleteByFilter(arg[0])), nil
+	}).
+	AddMethod("set", func(u pl.Usr, arg []pl.Val) (pl.Val, error) {
 		if _, err := methodProtoHeaderSet.Check(arg); err != nil {
 			return pl.NewValNull(), err
 		}
-		h.Set(arg[0].String(), arg[1].String())
+		u.(*Header).Set(arg[0].String(), arg[1].String())
 		return pl.NewValNull(), nil
-
-	case "add":
+	}).
+	AddMethod("add", func(u pl.Usr, arg []pl.Val) (pl.Val, error) {
 		if _, err := methodProtoHeaderAdd.Check(arg); err != nil {
 			return pl.NewValNull(), err
 		}
-		h.Add(arg[0].String(), arg[1].String())
+		u.(*Header).Add(arg[0].String(), arg[1].String())
 		return pl.NewValNull(), nil
-
-	case "length":
+	}).
+	AddMethod("length", func(u pl.Usr, arg []pl.Val) (pl.Val, error) {
 		if _, err := methodProtoHeaderLength.Check(arg); err != nil {
 			return pl.NewValNull(), err
 		}
-		return pl.NewValInt(h.Length()), nil
-	default:
-		break
+		return pl.NewValInt(u.(*Header).Length()), nil
+	})
+
+// all the method names of http.header, used by static analysis
+var HttpHeaderMethodList = headerDispatch.MethodList()
+
+func (h *Header) Dispatch() *pl.DispatchTable {
+	return headerDispatch
+}
+
+func (h *Header) Method(name string, arg []pl.Val) (pl.Val, error) {
+	if fn, ok := headerDispatch.LookupMethod(name); ok {
+		return fn(h, arg)
 	}
 	return pl.NewValNull(), fmt.Errorf("method: http.header:%s is unknown", name)
 }
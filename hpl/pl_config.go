@@ -219,3 +219,17 @@ func (p *PLConfig) At(index int) pl.Val {
 
 	return p.args[index]
 }
+
+// Unmarshal all the arguments into the struct pointed by ptr, see
+// pl.UnmarshalArgs. The closure argument is evaluated before unmarshal
+func (p *PLConfig) Unmarshal(ptr interface{}) error {
+	args := make([]pl.Val, 0, len(p.args))
+	for idx, a := range p.args {
+		v, err := p.tryeval(a)
+		if err != nil {
+			return fmt.Errorf("%d'th elements evaluation error: %s", idx, err.Error())
+		}
+		args = append(args, v)
+	}
+	return pl.UnmarshalArgs(args, ptr)
+}
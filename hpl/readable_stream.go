@@ -78,6 +78,10 @@ func (h *ReadableStream) ConsumeAsString() (string, error) {
 	return string(b), nil
 }
 
+func (h *ReadableStream) ConsumeAsBytes() ([]byte, error) {
+	return h.readAll()
+}
+
 func (h *ReadableStream) readAll() ([]byte, error) {
 	defer h.Close()
 	if h.hasCache {
@@ -137,7 +141,7 @@ func (h *ReadableStream) SetString(data string) {
 }
 
 func (h *ReadableStream) Index(name pl.Val) (pl.Val, error) {
-	if name.Type != pl.ValStr {
+	if name.Type() != pl.ValStr {
 		return pl.NewValNull(), fmt.Errorf("invalid index, .readablestream field name must be string")
 	}
 	switch name.String() {
@@ -192,6 +196,9 @@ var (
 	methodProtoReadableStreamCacheString    = pl.MustNewFuncProto(".readablestream.cacheString", "%0")
 	methodProtoReadableStreamTryCacheString = pl.MustNewFuncProto(".readablestream.tryCacheString", "%0")
 	methodProtoReadableStreamAsString       = pl.MustNewFuncProto(".readablestream.string", "%0")
+	methodProtoReadableStreamCacheBytes     = pl.MustNewFuncProto(".readablestream.cacheBytes", "%0")
+	methodProtoReadableStreamTryCacheBytes  = pl.MustNewFuncProto(".readablestream.tryCacheBytes", "%0")
+	methodProtoReadableStreamAsBytes        = pl.MustNewFuncProto(".readablestream.bytes", "%0")
 	methodProtoReadableStreamClose          = pl.MustNewFuncProto(".readablestream.close", "%0")
 )
 
@@ -227,6 +234,39 @@ func (h *ReadableStream) Method(name string, arg []pl.Val) (pl.Val, error) {
 		} else {
 			return pl.NewValStr(s), nil
 		}
+
+	// bytes version of the above APIs, the content is not converted to string.
+	// Notes the cache buffer is copied out since the stream owns it
+	case "cacheBytes":
+		if _, err := methodProtoReadableStreamCacheBytes.Check(arg); err != nil {
+			return pl.NewValNull(), err
+		}
+		if b, err := h.CacheBuffer(); err != nil {
+			return pl.NewValNull(), err
+		} else {
+			return pl.NewValBytes(append([]byte{}, b...)), nil
+		}
+	case "tryCacheBytes":
+		if _, err := methodProtoReadableStreamTryCacheBytes.Check(arg); err != nil {
+			return pl.NewValNull(), err
+		}
+		b, ok := h.TryCacheBuffer()
+		if !ok {
+			return pl.NewValNull(), nil
+		} else {
+			return pl.NewValBytes(append([]byte{}, b...)), nil
+		}
+	case "bytes":
+		if _, err := methodProtoReadableStreamAsBytes.Check(arg); err != nil {
+			return pl.NewValNull(), err
+		}
+		b, err := h.ConsumeAsBytes()
+		if err != nil {
+			return pl.NewValNull(), err
+		} else {
+			return pl.NewValBytes(append([]byte{}, b...)), nil
+		}
+
 	case "close":
 		if _, err := methodProtoReadableStreamClose.Check(arg); err != nil {
 			return pl.NewValNull(), err
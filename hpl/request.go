@@ -26,7 +26,7 @@ func (r *Request) HttpRequest() *http.Request {
 }
 
 func (r *Request) setUrl(v pl.Val) error {
-	switch v.Type {
+	switch v.Type() {
 	case pl.ValStr:
 		u, err := url.Parse(v.String())
 		if err != nil {
@@ -60,7 +60,7 @@ func (r *Request) setBody(v pl.Val) error {
 		return nil
 	}
 
-	if v.Type == pl.ValStr {
+	if v.Type() == pl.ValStr {
 		r.body = NewBodyValFromString(v.String())
 		return nil
 	}
@@ -81,74 +81,130 @@ func (h *Request) isChunked() bool {
 	return false
 }
 
-func (h *Request) Index(key pl.Val) (pl.Val, error) {
-	if key.Type != pl.ValStr {
-		return pl.NewValNull(), fmt.Errorf("invalid index, request's component must be string")
+func requestField(fn func(*Request) pl.Val) pl.DotFn {
+	return func(u pl.Usr) (pl.Val, error) {
+		return fn(u.(*Request)), nil
 	}
+}
+
+var (
+	methodProtoRequestCookie    = pl.MustNewFuncProto("http.request.cookie", "%s")
+	methodProtoRequestAllCookie = pl.MustNewFuncProto("http.request.allCookie", "%0")
+)
 
-	switch key.String() {
-	case "header":
-		return h.header, nil
-	case "method":
-		return pl.NewValStr(h.request.Method), nil
-	case "proto":
-		return pl.NewValStr(h.request.Proto), nil
-	case "protoMajor":
-		return pl.NewValInt(h.request.ProtoMajor), nil
-	case "protoMinor":
-		return pl.NewValInt(h.request.ProtoMinor), nil
-
-	case "body":
-		return h.body, nil
+// dispatch table of http.request, property and method are looked up via the
+// table so the VM can use inline cache to access them
+var requestDispatch = pl.NewDispatchTable().
+	AddDot("header", requestField(func(h *Request) pl.Val {
+		return h.header
+	})).
+	AddDot("method", requestField(func(h *Request) pl.Val {
+		return pl.NewValStr(h.request.Method)
+	})).
+	AddDot("proto", requestField(func(h *Request) pl.Val {
+		return pl.NewValStr(h.request.Proto)
+	})).
+	AddDot("protoMajor", requestField(func(h *Request) pl.Val {
+		return pl.NewValInt(h.request.ProtoMajor)
+	})).
+	AddDot("protoMinor", requestField(func(h *Request) pl.Val {
+		return pl.NewValInt(h.request.ProtoMinor)
+	})).
+	AddDot("body", requestField(func(h *Request) pl.Val {
+		return h.body
+	})).
 
 	// URI related
-	case "requestURI":
-		return pl.NewValStr(h.request.RequestURI), nil
-	case "url":
-		return h.url, nil
+	AddDot("requestURI", requestField(func(h *Request) pl.Val {
+		return pl.NewValStr(h.request.RequestURI)
+	})).
+	AddDot("url", requestField(func(h *Request) pl.Val {
+		return h.url
+	})).
 
 	// request related special information
-	case "host":
-		return pl.NewValStr(h.request.Host), nil
-	case "remoteAddr":
-		return pl.NewValStr(h.request.RemoteAddr), nil
+	AddDot("host", requestField(func(h *Request) pl.Val {
+		return pl.NewValStr(h.request.Host)
+	})).
+	AddDot("remoteAddr", requestField(func(h *Request) pl.Val {
+		return pl.NewValStr(h.request.RemoteAddr)
+	})).
 
 	// tls information
-	case "isTLS":
-		return pl.NewValBool(h.isTLS()), nil
-	case "tls":
-		return h.tls, nil
-
-		// Special header and other information
-	case "contentLength":
+	AddDot("isTLS", requestField(func(h *Request) pl.Val {
+		return pl.NewValBool(h.isTLS())
+	})).
+	AddDot("tls", requestField(func(h *Request) pl.Val {
+		return h.tls
+	})).
+
+	// Special header and other information
+	AddDot("contentLength", requestField(func(h *Request) pl.Val {
 		if h.request.ContentLength >= 0 {
-			return pl.NewValInt(int(h.request.ContentLength)), nil
-		} else {
+			return pl.NewValInt(int(h.request.ContentLength))
+		}
+		return pl.NewValNull()
+	})).
+	AddDot("transferEncoding", requestField(func(h *Request) pl.Val {
+		return pl.NewValBool(h.isChunked())
+	})).
+	AddDot("referer", requestField(func(h *Request) pl.Val {
+		return pl.NewValStr(h.request.Referer())
+	})).
+	AddDot("userAgent", requestField(func(h *Request) pl.Val {
+		return pl.NewValStr(h.request.UserAgent())
+	})).
+	AddDotSet("header", func(u pl.Usr, val pl.Val) error {
+		return u.(*Request).setHeader(val)
+	}).
+	AddDotSet("url", func(u pl.Usr, val pl.Val) error {
+		return u.(*Request).setUrl(val)
+	}).
+	AddDotSet("body", func(u pl.Usr, val pl.Val) error {
+		return u.(*Request).setBody(val)
+	}).
+	AddMethod("cookie", func(u pl.Usr, args []pl.Val) (pl.Val, error) {
+		if _, err := methodProtoRequestCookie.Check(args); err != nil {
+			return pl.NewValNull(), err
+		}
+		if c, err := u.(*Request).request.Cookie(args[0].String()); err != nil {
 			return pl.NewValNull(), nil
+		} else {
+			return NewCookieVal(c), nil
 		}
+	}).
+	AddMethod("allCookie", func(u pl.Usr, args []pl.Val) (pl.Val, error) {
+		if _, err := methodProtoRequestAllCookie.Check(args); err != nil {
+			return pl.NewValNull(), err
+		}
+		clist := u.(*Request).request.Cookies()
+		o := pl.NewValList()
+		for _, c := range clist {
+			o.AddList(NewCookieVal(c))
+		}
+		return o, nil
+	})
 
-	case "transferEncoding":
-		return pl.NewValBool(h.isChunked()), nil
-
-	case "referer":
-		return pl.NewValStr(h.request.Referer()), nil
-
-	case "userAgent":
-		return pl.NewValStr(h.request.UserAgent()), nil
+func (h *Request) Dispatch() *pl.DispatchTable {
+	return requestDispatch
+}
 
-	default:
-		break
+func (h *Request) Index(key pl.Val) (pl.Val, error) {
+	if key.Type() != pl.ValStr {
+		return pl.NewValNull(), fmt.Errorf("invalid index, request's component must be string")
 	}
-
-	return pl.NewValNull(), fmt.Errorf("unknown field name %s for request", key.String())
+	return h.Dot(key.String())
 }
 
 func (h *Request) Dot(name string) (pl.Val, error) {
-	return h.Index(pl.NewValStr(name))
+	if fn, ok := requestDispatch.LookupDot(name); ok {
+		return fn(h)
+	}
+	return pl.NewValNull(), fmt.Errorf("unknown field name %s for request", name)
 }
 
 func (h *Request) IndexSet(key pl.Val, val pl.Val) error {
-	if key.Type == pl.ValStr {
+	if key.Type() == pl.ValStr {
 		return h.DotSet(key.String(), val)
 	} else {
 		return fmt.Errorf("http.request index set, invalid key type")
@@ -156,21 +212,10 @@ func (h *Request) IndexSet(key pl.Val, val pl.Val) error {
 }
 
 func (h *Request) DotSet(key string, val pl.Val) error {
-	switch key {
-	case "header":
-		return h.setHeader(val)
-
-	case "url":
-		return h.setUrl(val)
-
-	case "body":
-		return h.setBody(val)
-
-	default:
-		return fmt.Errorf("http.request set, unknown field: %s", key)
+	if fn, ok := requestDispatch.LookupDotSet(key); ok {
+		return fn(h, val)
 	}
-
-	return nil
+	return fmt.Errorf("http.request set, unknown field: %s", key)
 }
 
 func (h *Request) ToString() (string, error) {
@@ -193,36 +238,9 @@ func (h *Request) ToJSON() (pl.Val, error) {
 	)
 }
 
-var (
-	methodProtoRequestCookie    = pl.MustNewFuncProto("http.request.cookie", "%s")
-	methodProtoRequestAllCookie = pl.MustNewFuncProto("http.request.allCookie", "%0")
-)
-
 func (h *Request) Method(name string, args []pl.Val) (pl.Val, error) {
-	switch name {
-	case "cookie":
-		if _, err := methodProtoRequestCookie.Check(args); err != nil {
-			return pl.NewValNull(), err
-		}
-		if c, err := h.request.Cookie(args[0].String()); err != nil {
-			return pl.NewValNull(), nil
-		} else {
-			return NewCookieVal(c), nil
-		}
-
-	case "allCookie":
-		if _, err := methodProtoRequestAllCookie.Check(args); err != nil {
-			return pl.NewValNull(), err
-		}
-		clist := h.request.Cookies()
-		o := pl.NewValList()
-		for _, c := range clist {
-			o.AddList(NewCookieVal(c))
-		}
-		return o, nil
-
-	default:
-		break
+	if fn, ok := requestDispatch.LookupMethod(name); ok {
+		return fn(h, args)
 	}
 	return pl.NewValNull(), fmt.Errorf("method: http.request:%s is unknown", name)
 }
@@ -265,9 +283,11 @@ func NewRequestValFromVal(
 	body pl.Val,
 ) (pl.Val, error) {
 
-	switch body.Type {
+	switch body.Type() {
 	case pl.ValStr:
 		return NewRequestValFromString(method, url, body.String())
+	case pl.ValBytes:
+		return NewRequestValFromBuffer(method, url, body.Bytes())
 	default:
 		if ValIsHttpBody(body) {
 			b, _ := body.Usr().(*Body)
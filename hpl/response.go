@@ -44,7 +44,7 @@ func (r *Response) setBody(v pl.Val) error {
 		return nil
 	}
 
-	if v.Type == pl.ValStr {
+	if v.Type() == pl.ValStr {
 		r.body = NewBodyValFromString(v.String())
 		return nil
 	}
@@ -53,7 +53,7 @@ func (r *Response) setBody(v pl.Val) error {
 }
 
 func (h *Response) Index(name pl.Val) (pl.Val, error) {
-	if name.Type != pl.ValStr {
+	if name.Type() != pl.ValStr {
 		return pl.NewValNull(), fmt.Errorf("invalid index, name must be string")
 	}
 
@@ -95,7 +95,7 @@ func (h *Response) Index(name pl.Val) (pl.Val, error) {
 }
 
 func (h *Response) IndexSet(key pl.Val, value pl.Val) error {
-	if key.Type != pl.ValStr {
+	if key.Type() != pl.ValStr {
 		return fmt.Errorf("http.response index set, invalid key type")
 	}
 	return h.DotSet(key.String(), value)
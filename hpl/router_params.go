@@ -11,7 +11,7 @@ type RouterParams struct {
 }
 
 func (h *RouterParams) Index(name pl.Val) (pl.Val, error) {
-	if name.Type != pl.ValStr {
+	if name.Type() != pl.ValStr {
 		return pl.NewValNull(), fmt.Errorf("invalid index, http.router's field must be string")
 	}
 
@@ -23,7 +23,7 @@ func (h *RouterParams) Dot(name string) (pl.Val, error) {
 }
 
 func (h *RouterParams) IndexSet(name pl.Val, value pl.Val) error {
-	if name.Type != pl.ValStr {
+	if name.Type() != pl.ValStr {
 		return fmt.Errorf("invalid index, http.router.params'key field must be string")
 	}
 	return h.DotSet(name.String(), value)
@@ -28,7 +28,7 @@ func (h *Url) URL() *url.URL {
 }
 
 func (h *Url) Index(key pl.Val) (pl.Val, error) {
-	if key.Type != pl.ValStr {
+	if key.Type() != pl.ValStr {
 		return pl.NewValNull(), fmt.Errorf("invalid index, URL name must be string")
 	}
 
@@ -61,7 +61,7 @@ func (h *Url) Index(key pl.Val) (pl.Val, error) {
 }
 
 func (h *Url) IndexSet(key pl.Val, val pl.Val) error {
-	if key.Type == pl.ValStr {
+	if key.Type() == pl.ValStr {
 		return h.DotSet(key.String(), val)
 	} else {
 		return fmt.Errorf(".url index set type must be string")
@@ -153,7 +153,7 @@ func (h *UrlSearch) Append(key string, value string) {
 }
 
 func (h *UrlSearch) Index(key pl.Val) (pl.Val, error) {
-	if key.Type != pl.ValStr {
+	if key.Type() != pl.ValStr {
 		return pl.NewValNull(), fmt.Errorf("invalid index, UrlSearch index " +
 			"key must be string")
 	}
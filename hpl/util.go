@@ -21,7 +21,7 @@ import (
 type httpHeaderFilterFunc = func(string, []string, http.Header) bool
 
 func httpHeaderFilter(hdr http.Header, key_pattern pl.Val, filter httpHeaderFilterFunc) int {
-	if key_pattern.Type == pl.ValStr {
+	if key_pattern.Type() == pl.ValStr {
 		k := key_pattern.String()
 		m := util.ToMatcher(k)
 		cnt := 0
@@ -37,7 +37,7 @@ func httpHeaderFilter(hdr http.Header, key_pattern pl.Val, filter httpHeaderFilt
 		}
 
 		return cnt
-	} else if key_pattern.Type == pl.ValRegexp {
+	} else if key_pattern.Type() == pl.ValRegexp {
 		cnt := 0
 		for key, val := range hdr {
 			lkey := strings.ToLower(key)
@@ -67,17 +67,17 @@ func httpHeaderDelete(hdr http.Header, key_pattern pl.Val) int {
 }
 
 func foreachHeaderKV(arg pl.Val, fn func(key string, val string)) bool {
-	switch arg.Type {
+	switch arg.Type() {
 	case pl.ValList:
 		for _, v := range arg.List().Data {
-			if v.Type == pl.ValPair && v.Pair().First.Type == pl.ValStr && v.Pair().Second.Type == pl.ValStr {
+			if v.Type() == pl.ValPair && v.Pair().First.Type() == pl.ValStr && v.Pair().Second.Type() == pl.ValStr {
 				fn(v.Pair().First.String(), v.Pair().Second.String())
 			}
 		}
 		return true
 
 	case pl.ValPair:
-		if arg.Pair().First.Type == pl.ValStr && arg.Pair().Second.Type == pl.ValStr {
+		if arg.Pair().First.Type() == pl.ValStr && arg.Pair().Second.Type() == pl.ValStr {
 			fn(arg.Pair().First.String(), arg.Pair().Second.String())
 		}
 		return true
@@ -85,7 +85,7 @@ func foreachHeaderKV(arg pl.Val, fn func(key string, val string)) bool {
 	case pl.ValMap:
 		arg.Map().Foreach(
 			func(k string, v pl.Val) bool {
-				if v.Type == pl.ValStr {
+				if v.Type() == pl.ValStr {
 					fn(k, v.String())
 				}
 				return true
@@ -15,4 +15,5 @@ const (
 	HttpResponseTypeId     = "http.response"
 	HttpRouterParamsTypeId = "http.router.params"
 	HttpCookieTypeId       = "http.cookie"
+	HttpFutureTypeId       = "http.future"
 )
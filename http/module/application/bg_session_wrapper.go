@@ -5,6 +5,7 @@ import (
 	"github.com/dianpeng/mono-service/http/framework"
 	"github.com/dianpeng/mono-service/http/runtime"
 	"github.com/dianpeng/mono-service/pl"
+	"github.com/dianpeng/mono-service/pubsub"
 )
 
 type bgApplicationWrapper struct {
@@ -29,6 +30,10 @@ func (b *bgApplicationWrapper) GetHttpClient(url string) (hpl.HttpClient, error)
 	return b.parent.GetHttpClient(url)
 }
 
+func (b *bgApplicationWrapper) Broker() *pubsub.Broker {
+	return b.parent.Broker()
+}
+
 func newBgApplicationWrapper(
 	parent runtime.SessionWrapper,
 	application framework.Application,
@@ -20,16 +20,6 @@ import (
 	"hash"
 )
 
-// default configuration
-const (
-	defTempDir          = "/tmp"
-	defSignPrefix       = "BODY-SIGN-SIGN-"
-	defVerifyPrefix     = "BODY-SIGN-VERIFY-"
-	defVerifyHeaderName = "x-body-sign-verify-expect"
-	defOpHeaderName     = "x-body-sign-op"
-	defMethodHeaderName = "x-body-sign-digest"
-)
-
 const (
 	algoMd4 = iota
 	algoMd5
@@ -47,13 +37,14 @@ type signResult struct {
 	body          *os.File
 }
 
+// positional configuration arguments of the application, with default value
 type bodySignConfig struct {
-	tempDir          string
-	signPrefix       string
-	verifyPrefix     string
-	verifyHeaderName string
-	opHeaderName     string
-	methodHeaderName string
+	TempDir          string `pl:"tempDir" default:"/tmp"`
+	SignPrefix       string `pl:"signPrefix" default:"BODY-SIGN-SIGN-"`
+	VerifyPrefix     string `pl:"verifyPrefix" default:"BODY-SIGN-VERIFY-"`
+	VerifyHeaderName string `pl:"verifyHeaderName" default:"x-body-sign-verify-expect"`
+	OpHeaderName     string `pl:"opHeaderName" default:"x-body-sign-op"`
+	MethodHeaderName string `pl:"methodHeaderName" default:"x-body-sign-digest"`
 }
 
 // prepare's returned result
@@ -88,7 +79,7 @@ func (b *bodySignApplication) reset() {
 func (b *bodySignApplication) Prepare(req *http.Request, p hrouter.Params) (interface{}, error) {
 	op := p.ByName("op")
 	if op == "" {
-		xx := req.Header.Get(b.config.opHeaderName)
+		xx := req.Header.Get(b.config.OpHeaderName)
 		if xx == "" {
 			return nil, fmt.Errorf("body_sign op parameter is not specified")
 		}
@@ -96,7 +87,7 @@ func (b *bodySignApplication) Prepare(req *http.Request, p hrouter.Params) (inte
 
 	method := p.ByName("method")
 	if method == "" {
-		xx := req.Header.Get(b.config.methodHeaderName)
+		xx := req.Header.Get(b.config.MethodHeaderName)
 		if xx == "" {
 			return nil, fmt.Errorf("body_sign method parameter is not specified")
 		}
@@ -105,7 +96,7 @@ func (b *bodySignApplication) Prepare(req *http.Request, p hrouter.Params) (inte
 	expect := ""
 
 	if op == "verify" {
-		expect = req.Header.Get(b.config.verifyHeaderName)
+		expect = req.Header.Get(b.config.VerifyHeaderName)
 		if expect == "" {
 			return nil, fmt.Errorf("verification result header is not set")
 		}
@@ -131,12 +122,9 @@ func (b *bodySignApplication) prepareConfig(
 		b.args,
 	)
 
-	cfg.TryGetStr(0, &b.config.tempDir, defTempDir)
-	cfg.TryGetStr(1, &b.config.signPrefix, defSignPrefix)
-	cfg.TryGetStr(2, &b.config.verifyPrefix, defVerifyPrefix)
-	cfg.TryGetStr(3, &b.config.verifyHeaderName, defVerifyHeaderName)
-	cfg.TryGetStr(4, &b.config.opHeaderName, defOpHeaderName)
-	cfg.TryGetStr(5, &b.config.methodHeaderName, defMethodHeaderName)
+	if err := cfg.Unmarshal(&b.config); err != nil {
+		return fmt.Errorf("module(body_sign): %s", err.Error())
+	}
 	return nil
 }
 
@@ -211,7 +199,7 @@ func (b *bodySignApplication) Accept(
 }
 
 func (b *bodySignApplication) hashBody(data io.Reader, method string) (*signResult, error) {
-	file, err := os.CreateTemp(b.config.tempDir, b.config.signPrefix)
+	file, err := os.CreateTemp(b.config.TempDir, b.config.SignPrefix)
 	if err != nil {
 		return nil, err
 	}
@@ -283,7 +283,7 @@ func (s *concateApplication) onActionBackground(name string, val pl.Val) error {
 
 	switch name {
 	case "reject":
-		if val.Type == pl.ValStr {
+		if val.Type() == pl.ValStr {
 			x.pass = false
 			x.rejectError = fmt.Errorf(val.String())
 		} else {
@@ -292,7 +292,7 @@ func (s *concateApplication) onActionBackground(name string, val pl.Val) error {
 		return nil
 
 	case "pass":
-		if val.Type == pl.ValBool {
+		if val.Type() == pl.ValBool {
 			x.pass = val.Bool()
 		} else {
 			return fmt.Errorf("action 'pass''s argument must be bool")
@@ -300,7 +300,7 @@ func (s *concateApplication) onActionBackground(name string, val pl.Val) error {
 		return nil
 
 	case "rewrite":
-		switch val.Type {
+		switch val.Type() {
 		case pl.ValStr:
 			x.setString(val.String())
 			break
@@ -331,7 +331,7 @@ func (s *concateApplication) onActionBackground(name string, val pl.Val) error {
 
 func (s *concateApplication) oneBackend(val pl.Val) (*concateRequest, error) {
 	o := &concateRequest{}
-	switch val.Type {
+	switch val.Type() {
 	case pl.ValStr:
 		o.setString(val.String())
 		break
@@ -366,7 +366,7 @@ func (s *concateApplication) onActionBackend(name string, val pl.Val) error {
 
 	switch name {
 	case "output":
-		if val.Type == pl.ValList {
+		if val.Type() == pl.ValList {
 			for _, v := range val.List().Data {
 				c, err := s.oneBackend(v)
 				if err != nil {
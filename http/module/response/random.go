@@ -13,6 +13,12 @@ type random struct {
 	args []pl.Val
 }
 
+type randomConfig struct {
+	Status int  `pl:"status" default:"200"`
+	Size   int  `pl:"size" default:"1024"`
+	Flush  bool `pl:"flush"`
+}
+
 func (e *random) Name() string {
 	return "response.random"
 }
@@ -28,32 +34,20 @@ func (e *random) Accept(
 		e.args,
 	)
 
-	status := 0
-	size := 0
-	flush := false
-
-	cfg.TryGetInt(
-		0,
-		&status,
-		200,
-	)
-
-	cfg.TryGetInt(
-		1,
-		&size,
-		1024,
-	)
-
-	cfg.TryGetBool(
-		2,
-		&flush,
-		false,
-	)
+	config := randomConfig{}
+	if err := cfg.Unmarshal(&config); err != nil {
+		w.ReplyError(
+			"response.random",
+			500,
+			err,
+		)
+		return false
+	}
 
-	w.WriteStatus(status)
-	w.WriteBody(hpl.NewReadCloserFromString(util.RandomString(size)))
+	w.WriteStatus(config.Status)
+	w.WriteBody(hpl.NewReadCloserFromString(util.RandomString(config.Size)))
 
-	if flush {
+	if config.Flush {
 		w.Flush()
 	}
 	return true
@@ -1,6 +1,7 @@
 package runtime
 
 import (
+	"context"
 	"fmt"
 	"io/fs"
 	"net/http"
@@ -10,6 +11,7 @@ import (
 	"github.com/dianpeng/mono-service/alog"
 	"github.com/dianpeng/mono-service/hpl"
 	"github.com/dianpeng/mono-service/pl"
+	"github.com/dianpeng/mono-service/pubsub"
 )
 
 type Context interface {
@@ -25,6 +27,10 @@ type Action interface {
 type Resource interface {
 	// special function used for exposing other utilities
 	hpl.HttpClientFactory
+
+	// pub/sub broker of the vhost, backs pubsub::publish. Nil if the broker is
+	// not available
+	Broker() *pubsub.Broker
 }
 
 // Interface used by VHost to bridge SessionWrapper/Service object into the HPL
@@ -55,6 +61,12 @@ type Runtime struct {
 	hplCtx    Context
 	hplRt     Resource
 	hplAction Action
+
+	// pending http::async requests issued during the session
+	futures []*hpl.Future
+
+	// order of the event queue, the derived runtime uses the same order
+	eventOrder int
 }
 
 func NewRuntime() *Runtime {
@@ -83,10 +95,22 @@ func (h *Runtime) SetModule(p *pl.Module) {
 	h.Module = p
 }
 
+// Order of the events emitted by the script, ie pl.EventOrderFIFO
+func (h *Runtime) SetEventOrder(order int) {
+	h.eventOrder = order
+	h.Eval.SetEventQueue(pl.NewEventQueue(order))
+}
+
 // Derive a HPL state from another existed HPL, suitable for using in background
 func (h *Runtime) Derive(that *Runtime) {
 	h.Module = that.Module
 
+	// derived runtime runs in background, ie PhaseBackground, so it is able to
+	// wait for the delayed event emitted by emit_after
+	h.Eval.Background = true
+	h.SetEventOrder(that.eventOrder)
+	h.Eval.Trace = that.Eval.Trace
+
 	// notes, we currently do not have a way to duplicate session state from that
 	// HPL to our HPL and due to the thread issue, we cannot safely just do shallow
 	// copy of the session object. Therefore, we do not support session variable
@@ -116,6 +140,13 @@ func (h *Runtime) getHttpClientFactory() hpl.HttpClientFactory {
 	return h.hplRt
 }
 
+func (h *Runtime) getBroker() *pubsub.Broker {
+	if h.hplRt == nil {
+		return nil
+	}
+	return h.hplRt.Broker()
+}
+
 func (h *Runtime) fnHttp(args []pl.Val,
 	entry func(hpl.HttpClientFactory, []pl.Val) (pl.Val, error)) (pl.Val, error) {
 
@@ -126,6 +157,30 @@ func (h *Runtime) fnHttp(args []pl.Val,
 	return entry(fac, args)
 }
 
+func (h *Runtime) fnHttpAsync(args []pl.Val) (pl.Val, error) {
+	fac := h.getHttpClientFactory()
+	if fac == nil {
+		return pl.NewValNull(), fmt.Errorf("http client factory is not setup")
+	}
+	v, f, err := hpl.FnHttpAsync(fac, args)
+	if err != nil {
+		return pl.NewValNull(), err
+	}
+	h.futures = append(h.futures, f)
+	return v, nil
+}
+
+// Cancel all the pending http::async requests and wait for them to be settled.
+// Must be called before the http clients are returned back to the pool, since
+// the background request may still use the client
+func (h *Runtime) CancelPending() {
+	for _, f := range h.futures {
+		f.Cancel()
+		f.Settle()
+	}
+	h.futures = nil
+}
+
 func (p *Runtime) loadFnVar(_ *pl.Evaluator, n string) (pl.Val, bool) {
 	switch n {
 	case "http::do":
@@ -152,6 +207,38 @@ func (p *Runtime) loadFnVar(_ *pl.Evaluator, n string) (pl.Val, bool) {
 			},
 		), true
 
+	case "http::async":
+		return pl.NewValNativeFunction(
+			"http::async",
+			p.fnHttpAsync,
+		), true
+
+	case "http::all":
+		return pl.NewValNativeFunction(
+			"http::all",
+			hpl.FnHttpAll,
+		), true
+
+	case "http::any":
+		return pl.NewValNativeFunction(
+			"http::any",
+			hpl.FnHttpAny,
+		), true
+
+	case "http::race":
+		return pl.NewValNativeFunction(
+			"http::race",
+			hpl.FnHttpRace,
+		), true
+
+	case "pubsub::publish":
+		return pl.NewValNativeFunction(
+			"pubsub::publish",
+			func(args []pl.Val) (pl.Val, error) {
+				return pubsub.FnPublish(p.getBroker(), args)
+			},
+		), true
+
 	default:
 		break
 	}
@@ -206,7 +293,11 @@ func (h *Runtime) OnCustomize(selector string, session SessionWrapper) (pl.Val,
 		h.hplAction = oldAct
 	}()
 
-	return h.Eval.Eval(selector, h.Module)
+	v, err := h.Eval.Eval(selector, h.Module)
+	if err != nil || !h.Eval.Background {
+		return v, err
+	}
+	return v, h.Eval.WaitEventQueue(context.Background(), h.Module)
 }
 
 // -----------------------------------------------------------------------------
@@ -355,6 +446,10 @@ func (c *testHttpClientFactory) GetHttpClient(url string) (hpl.HttpClient, error
 	}, nil
 }
 
+func (c *testHttpClientFactory) Broker() *pubsub.Broker {
+	return nil
+}
+
 func (h *Runtime) testLoadVar(x *pl.Evaluator, n string) (pl.Val, error) {
 	if v, ok := h.loadFnVar(x, n); ok {
 		return v, nil
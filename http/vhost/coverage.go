@@ -0,0 +1,42 @@
+package vhost
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// coverage endpoint of the vhost
+//
+//	GET  : ?format=lcov|html, download the line coverage of the service script,
+//	       lcov is the default
+//	POST : ?enable=true|false turns on/off the recording, ?reset=true clears
+//	       all the hit count
+func (v *VHost) serveCoverage(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		var err error
+		switch format := r.URL.Query().Get("format"); format {
+		case "", "lcov":
+			w.Header().Set("content-type", "text/plain")
+			err = v.Coverage.WriteLCOV(w)
+		case "html":
+			w.Header().Set("content-type", "text/html")
+			err = v.Coverage.WriteHTML(w)
+		default:
+			http.Error(w, fmt.Sprintf("invalid format: %s", format), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+
+	case http.MethodPost:
+		serveSwitch(w, r, "coverage", v.Coverage.Enable, v.Coverage.Reset, v.Coverage.Enabled)
+		return
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+}
@@ -0,0 +1,95 @@
+package vhost
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/dianpeng/mono-service/manifest"
+)
+
+func TestCoverageEndpoint(t *testing.T) {
+	vhost, err := CreateVHost(&manifest.Manifest{
+		FS: fstest.MapFS{
+			"main.pl": &fstest.MapFile{
+				Data: []byte(`
+config http_vhost {
+  .name = "test";
+  .listener = "test";
+  .coverage_endpoint = "[GET,POST]/_coverage";
+  .admin_token = "secret";
+}
+`),
+			},
+			"svc.pl": &fstest.MapFile{
+				Data: []byte(`
+config service {
+  .router = "[GET]/a";
+  application noop();
+}
+rule log {
+  let x = 1 + 2;
+}
+rule error {
+  let y = 1;
+}
+`),
+			},
+		},
+		Main:        "main.pl",
+		ServiceFile: []string{"svc.pl"},
+		Type:        "http",
+	})
+	assert.True(t, err == nil, "%s", err)
+	assert.False(t, vhost.Coverage.Enabled())
+
+	serve := func(method string, url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(method, url, nil)
+		if strings.HasPrefix(url, "/_coverage") {
+			r.Header.Set("authorization", "Bearer secret")
+		}
+		vhost.Router.ServeHTTP(w, r)
+		return w
+	}
+
+	// admin token is required
+	w := httptest.NewRecorder()
+	vhost.Router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/_coverage", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// traffic outside of the window is not recorded
+	serve(http.MethodGet, "/a")
+
+	w = serve(http.MethodPost, "/_coverage?enable=true")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, vhost.Coverage.Enabled())
+
+	serve(http.MethodGet, "/a")
+	serve(http.MethodGet, "/a")
+
+	w = serve(http.MethodPost, "/_coverage?enable=false")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = serve(http.MethodGet, "/_coverage")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "SF:svc.pl\n")
+	assert.Contains(t, w.Body.String(), "FNDA:2,rule log\n")
+	assert.Contains(t, w.Body.String(), "FNDA:0,rule error\n")
+
+	w = serve(http.MethodGet, "/_coverage?format=html")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "svc.pl")
+
+	w = serve(http.MethodGet, "/_coverage?format=xx")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = serve(http.MethodPost, "/_coverage?reset=true")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = serve(http.MethodGet, "/_coverage")
+	assert.Contains(t, w.Body.String(), "FNDA:0,rule log\n")
+}
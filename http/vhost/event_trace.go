@@ -0,0 +1,39 @@
+package vhost
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// event trace endpoint of the vhost
+//
+//	GET  : the latest emitted events, one per line in the order they are
+//	       emitted, with the rule emits them and how they are handled
+//	POST : ?reset=true drops all the records
+func (v *VHost) serveEventTrace(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("content-type", "text/plain")
+		fmt.Fprint(w, v.EventTrace.Dump())
+		return
+
+	case http.MethodPost:
+		if x := r.URL.Query().Get("reset"); x != "" {
+			on, err := strconv.ParseBool(x)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("invalid reset: %s", x), http.StatusBadRequest)
+				return
+			}
+			if on {
+				v.EventTrace.Reset()
+			}
+		}
+		fmt.Fprintf(w, "event trace records: %d\n", len(v.EventTrace.Records()))
+		return
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+}
@@ -0,0 +1,123 @@
+package vhost
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/dianpeng/mono-service/manifest"
+)
+
+func testEventVHost(main string) (*VHost, error) {
+	return CreateVHost(&manifest.Manifest{
+		FS: fstest.MapFS{
+			"main.pl": &fstest.MapFile{
+				Data: []byte(main),
+			},
+			"svc.pl": &fstest.MapFile{
+				Data: []byte(`
+config service {
+  .router = "[GET]/a";
+  application noop();
+}
+rule log {
+  emit "ev.low";
+  emit_priority(5, "ev.high");
+}
+rule "ev.*" {
+}
+`),
+			},
+		},
+		Main:        "main.pl",
+		ServiceFile: []string{"svc.pl"},
+		Type:        "http",
+	})
+}
+
+func TestEventTraceEndpoint(t *testing.T) {
+	vhost, err := testEventVHost(`
+config http_vhost {
+  .name = "test";
+  .listener = "test";
+  .event_order = "priority";
+  .event_trace = 10;
+  .event_trace_endpoint = "[GET,POST]/_event_trace";
+  .admin_token = "secret";
+}
+`)
+	if !assert.True(t, err == nil, "%s", err) {
+		return
+	}
+
+	serve := func(method string, url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(method, url, nil)
+		r.Header.Set("authorization", "Bearer secret")
+		vhost.Router.ServeHTTP(w, r)
+		return w
+	}
+
+	w := httptest.NewRecorder()
+	vhost.Router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/_event_trace", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	serve(http.MethodGet, "/a")
+
+	// higher priority is handled first
+	records := vhost.EventTrace.Records()
+	if assert.Equal(t, 2, len(records)) {
+		assert.Equal(t, "ev.low", records[0].Name)
+		assert.Equal(t, "ev.high", records[1].Name)
+		assert.Equal(t, "log", records[1].Emitter)
+		assert.True(t, records[0].Handled && records[1].Handled)
+		assert.True(t, records[1].HandleAt.Before(records[0].HandleAt))
+	}
+
+	w = serve(http.MethodGet, "/_event_trace")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, strings.Contains(w.Body.String(), "ev.high emitted by log (priority 5"), w.Body.String())
+
+	w = serve(http.MethodPost, "/_event_trace?reset=true")
+	assert.Equal(t, "event trace records: 0\n", w.Body.String())
+}
+
+func TestEventConfig(t *testing.T) {
+	for _, c := range []struct {
+		config string
+		err    string
+	}{
+		{`.event_order = "lifo";`, "http_vhost.event_order: unknown event order lifo, expect fifo or priority"},
+		{`.event_trace_endpoint = "/_event_trace";`, "http_vhost.event_trace_endpoint requires http_vhost.admin_token"},
+		{`.event_trace_endpoint = "/_event_trace"; .admin_token = "x";`, "http_vhost.event_trace_endpoint requires http_vhost.event_trace"},
+	} {
+		_, err := testEventVHost(`
+config http_vhost {
+  .name = "test";
+  .listener = "test";
+  ` + c.config + `
+}
+`)
+		if assert.True(t, err != nil, c.config) {
+			assert.True(t, strings.Contains(err.Error(), c.err), err.Error())
+		}
+	}
+
+	// fifo by default, and tracing is off
+	vhost, err := testEventVHost(`
+config http_vhost {
+  .name = "test";
+  .listener = "test";
+}
+`)
+	if assert.True(t, err == nil, "%s", err) {
+		assert.True(t, vhost.EventTrace == nil)
+		w := httptest.NewRecorder()
+		vhost.Router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/a", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+}
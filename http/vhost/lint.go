@@ -0,0 +1,141 @@
+package vhost
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"strings"
+
+	"github.com/dianpeng/mono-service/hpl"
+	"github.com/dianpeng/mono-service/pl"
+)
+
+// static analysis of the service module. The config scope is replayed against
+// the svcConfigBuilder's checks without evaluating any code, and the events
+// triggered by the middleware/application are collected during the replay
+
+type svcConfigLint struct {
+	builder svcConfigBuilder
+	appName string
+	event   map[string]bool
+	prefix  []string
+	dynamic bool // event name cannot be known statically
+}
+
+func newSvcConfigLint() *svcConfigLint {
+	return &svcConfigLint{
+		builder: svcConfigBuilder{
+			config: &vHSConfig{},
+		},
+		event: map[string]bool{
+			EventNameLog:   true,
+			EventNameError: true,
+		},
+	}
+}
+
+func (s *svcConfigLint) PushConfig(name string) error {
+	return s.builder.PushConfig(nil, name, pl.NewValNull())
+}
+
+func (s *svcConfigLint) PopConfig() error {
+	return s.builder.PopConfig(nil)
+}
+
+func (s *svcConfigLint) ConfigProperty(name string) error {
+	return s.builder.checkProperty(name)
+}
+
+func (s *svcConfigLint) ConfigCommand(name string, arg []pl.Val, literal bool) error {
+	cur := s.builder.curType()
+	if err := s.builder.checkCommand(name); err != nil {
+		return err
+	}
+
+	// the event middleware and the event application trigger the event named by
+	// its first argument, other applications trigger events prefixed with its
+	// own name, ie body_sign.pass
+	switch {
+	case cur == svcConfigApplication && name != "event":
+		s.prefix = append(s.prefix, name+".")
+		break
+
+	case name == "event":
+		if literal && len(arg) != 0 && arg[0].Type() == pl.ValStr {
+			s.event[arg[0].String()] = true
+		} else {
+			s.dynamic = true
+		}
+		break
+
+	default:
+		break
+	}
+	return nil
+}
+
+func (s *svcConfigLint) hasEvent(name string) bool {
+	if s.dynamic || s.event[name] {
+		return true
+	}
+	for _, x := range s.prefix {
+		if strings.HasPrefix(name, x) {
+			return true
+		}
+	}
+	return false
+}
+
+func lintService(p *pl.Module) []pl.LintDiag {
+	cfg := newSvcConfigLint()
+	return pl.Lint(p, &pl.LintOption{
+		Event: cfg.hasEvent,
+		Method: map[string][]string{
+			"request.header":  hpl.HttpHeaderMethodList,
+			"response.header": hpl.HttpHeaderMethodList,
+		},
+		Config: cfg,
+	})
+}
+
+// convert all error level diagnostics into a go error, returns nil if there's
+// no error
+func lintError(diag []pl.LintDiag) error {
+	var b bytes.Buffer
+	for _, d := range diag {
+		if d.IsError() {
+			b.WriteString(d.String())
+			b.WriteString("\n")
+		}
+	}
+	if b.Len() == 0 {
+		return nil
+	}
+	return fmt.Errorf("static analysis failed:\n%s", b.String())
+}
+
+// LintService runs the static analysis against a service file, used by the
+// command line tools. The path is resolved inside of fsp, which should be the
+// root of the manifest to make the import resolved as loading. No code of the
+// service is executed
+func LintService(
+	path string,
+	fsp fs.FS,
+) ([]pl.LintDiag, error) {
+	src, err := fs.ReadFile(fsp, path)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := pl.CompileModule(string(src), fsp)
+	if err != nil {
+		return nil, wrapErr(
+			"service",
+			"compilation",
+			path,
+			err,
+		)
+	}
+
+	return lintService(p), nil
+}
@@ -0,0 +1,183 @@
+package vhost
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+
+	_ "github.com/dianpeng/mono-service/http/prelude"
+	"github.com/dianpeng/mono-service/manifest"
+	"github.com/dianpeng/mono-service/pl"
+)
+
+func testLintService(t *testing.T, code string) []pl.LintDiag {
+	diag, err := LintService("svc.pl", fstest.MapFS{
+		"svc.pl": &fstest.MapFile{
+			Data: []byte(code),
+		},
+	})
+	assert.True(t, err == nil, "%s", err)
+	return diag
+}
+
+func lintHasDiag(diag []pl.LintDiag, check string, level int, line int) bool {
+	for _, d := range diag {
+		if d.Check == check && d.Level == level && d.Line == line {
+			return true
+		}
+	}
+	return false
+}
+
+func TestLintServiceConfig(t *testing.T) {
+	diag := testLintService(t, `
+config service {
+  .name = "svc";
+  .bogus = 1;
+  request {
+    .header_set(("a", "b"));
+    .nosuch();
+    .x = 1;
+  }
+  application noapp();
+}
+`)
+	assert.Equal(t, 4, len(diag))
+	assert.True(t, lintHasDiag(diag, pl.LintCheckConfig, pl.LintError, 4))
+	assert.True(t, lintHasDiag(diag, pl.LintCheckConfig, pl.LintError, 7))
+	assert.True(t, lintHasDiag(diag, pl.LintCheckConfig, pl.LintError, 8))
+	assert.True(t, lintHasDiag(diag, pl.LintCheckConfig, pl.LintError, 10))
+}
+
+func TestLintServiceEvent(t *testing.T) {
+	// events of the event middleware and the application prefix
+	diag := testLintService(t, `
+config service {
+  .name = "svc";
+  request {
+    .event("show");
+  }
+  application body_sign();
+}
+rule show {}
+rule "body_sign.pass" {}
+rule log {}
+rule error {}
+rule never {}
+`)
+	assert.Equal(t, 1, len(diag))
+	assert.True(t, lintHasDiag(diag, pl.LintCheckUnreachable, pl.LintWarning, 13))
+
+	// event application triggers its first argument
+	diag = testLintService(t, `
+config service {
+  application event("app", "value");
+}
+rule app {}
+rule "event.x" {}
+`)
+	assert.Equal(t, 1, len(diag))
+	assert.True(t, lintHasDiag(diag, pl.LintCheckUnreachable, pl.LintWarning, 6))
+
+	// dynamic event name makes every rule reachable
+	diag = testLintService(t, `
+config service {
+  request {
+    .event(str::to_upper("a"));
+  }
+  application noop();
+}
+rule anything {}
+`)
+	assert.Equal(t, 0, len(diag))
+}
+
+func TestLintServiceMethod(t *testing.T) {
+	diag := testLintService(t, `
+config service {
+  application noop();
+}
+rule "noop.x" {
+  request.header:set("a", "b");
+  response.header:sett("a", "b");
+}
+`)
+	assert.Equal(t, 1, len(diag))
+	assert.True(t, lintHasDiag(diag, pl.LintCheckMethod, pl.LintError, 7))
+}
+
+func testStrictVHost(strict string, svc string) (*VHost, error) {
+	return CreateVHost(&manifest.Manifest{
+		FS: fstest.MapFS{
+			"main.pl": &fstest.MapFile{
+				Data: []byte(`
+config http_vhost {
+  .name = "test";
+  .listener = "test";
+  .strict = ` + strict + `;
+}
+`),
+			},
+			"svc.pl": &fstest.MapFile{
+				Data: []byte(svc),
+			},
+		},
+		Main:        "main.pl",
+		ServiceFile: []string{"svc.pl"},
+		Type:        "http",
+	})
+}
+
+func TestStrictLoad(t *testing.T) {
+	// unknown middleware
+	_, err := testStrictVHost("true", `
+config service {
+  .router = "[GET]/a";
+  request {
+    .bogus();
+  }
+  application noop();
+}
+`)
+	assert.True(t, err != nil)
+	assert.Contains(t, err.Error(), "static analysis")
+
+	// unknown method of request header
+	_, err = testStrictVHost("true", `
+config service {
+  .router = "[GET]/a";
+  application noop();
+}
+rule "noop.x" {
+  request.header:sett("a", "b");
+}
+`)
+	assert.True(t, err != nil)
+	assert.Contains(t, err.Error(), "static analysis")
+
+	// warnings do not fail the loading
+	vhost, err := testStrictVHost("true", `
+config service {
+  .router = "[GET]/a";
+  application noop();
+}
+rule unreachable {
+  let a = 1;
+}
+`)
+	assert.True(t, err == nil, "%s", err)
+	assert.Equal(t, 1, len(vhost.ServiceList))
+
+	// without strict, the method is only checked at runtime
+	_, err = testStrictVHost("false", `
+config service {
+  .router = "[GET]/a";
+  application noop();
+}
+rule "noop.x" {
+  request.header:sett("a", "b");
+}
+`)
+	assert.True(t, err == nil, "%s", err)
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/dianpeng/mono-service/http/runtime"
 	"github.com/dianpeng/mono-service/manifest"
 	"github.com/dianpeng/mono-service/pl"
+	"github.com/dianpeng/mono-service/pubsub"
 	"io/fs"
 	"net/http"
 	"time"
@@ -22,24 +23,28 @@ func (c *constHttpClientFactory) GetHttpClient(url string) (hpl.HttpClient, erro
 	}, nil
 }
 
+// broker is not available during the global and config phase
+func (c *constHttpClientFactory) Broker() *pubsub.Broker {
+	return nil
+}
+
 func initmodule(x string, config pl.EvalConfig, fs fs.FS) (*pl.Module, error) {
 	p, err := pl.CompileModule(x, fs)
 	if err != nil {
 		return nil, err
 	}
+	return p, evalmodule(p, config)
+}
 
+func evalmodule(p *pl.Module, config pl.EvalConfig) error {
 	session := &constHttpClientFactory{}
 	hpl := runtime.NewRuntimeWithModule(p)
 
 	if err := hpl.OnGlobal(session); err != nil {
-		return nil, err
-	}
-
-	if err := hpl.OnConfig(config, session); err != nil {
-		return nil, err
+		return err
 	}
 
-	return p, nil
+	return hpl.OnConfig(config, session)
 }
 
 func wrapErr(
@@ -102,11 +107,7 @@ func initVHostSVC(
 		config: cfg,
 	}
 
-	p, err := initmodule(
-		string(src),
-		builder,
-		fsp,
-	)
+	p, err := pl.CompileModule(string(src), fsp)
 	if err != nil {
 		return nil, wrapErr(
 			"service",
@@ -116,6 +117,52 @@ func initVHostSVC(
 		)
 	}
 
+	// static analysis runs before any code of the service is executed, the
+	// warnings are only logged
+	if vhost.Config.Strict {
+		diag := lintService(p)
+		for _, d := range diag {
+			if !d.IsError() {
+				vhost.Logger.Printf("%s:%s", path, d.String())
+			}
+		}
+		if err := lintError(diag); err != nil {
+			return nil, wrapErr(
+				"service",
+				"static analysis",
+				path,
+				err,
+			)
+		}
+	}
+
+	if vhost.Config.Optimize {
+		p.Optimize(pl.OptAll)
+	}
+
+	switch vhost.Config.RuleMatch {
+	case "", "first":
+		p.SetRuleMatch(pl.RuleMatchFirst)
+	case "all":
+		p.SetRuleMatch(pl.RuleMatchAll)
+	default:
+		return nil, wrapErr(
+			"service",
+			"initialization",
+			path,
+			fmt.Errorf("unknown rule_match %s, expect first or all", vhost.Config.RuleMatch),
+		)
+	}
+
+	if err := evalmodule(p, builder); err != nil {
+		return nil, wrapErr(
+			"service",
+			"initialization",
+			path,
+			err,
+		)
+	}
+
 	fac, err := cfg.Compose()
 	if err != nil {
 		return nil, wrapErr(
@@ -126,6 +173,8 @@ func initVHostSVC(
 		)
 	}
 
+	vhost.Coverage.AddModule(p, path)
+
 	return newvHS(
 		vhost,
 		fac,
@@ -161,6 +210,51 @@ func CreateVHost(
 	if err != nil {
 		return nil, err
 	}
+	if manifest.Broker != nil {
+		vhost.Broker = manifest.Broker
+	}
+
+	if vhost.Config.ProfileEndpoint != "" {
+		if vhost.Config.AdminToken == "" {
+			return nil, fmt.Errorf("http_vhost.profile_endpoint requires http_vhost.admin_token")
+		}
+		if _, err := newRouter(
+			vhost.Config.ProfileEndpoint,
+			vhost.Router,
+			vhost.admin(vhost.serveProfile),
+		); err != nil {
+			return nil, err
+		}
+	}
+
+	if vhost.Config.CoverageEndpoint != "" {
+		if vhost.Config.AdminToken == "" {
+			return nil, fmt.Errorf("http_vhost.coverage_endpoint requires http_vhost.admin_token")
+		}
+		if _, err := newRouter(
+			vhost.Config.CoverageEndpoint,
+			vhost.Router,
+			vhost.admin(vhost.serveCoverage),
+		); err != nil {
+			return nil, err
+		}
+	}
+
+	if vhost.Config.EventTraceEndpoint != "" {
+		if vhost.Config.AdminToken == "" {
+			return nil, fmt.Errorf("http_vhost.event_trace_endpoint requires http_vhost.admin_token")
+		}
+		if vhost.EventTrace == nil {
+			return nil, fmt.Errorf("http_vhost.event_trace_endpoint requires http_vhost.event_trace")
+		}
+		if _, err := newRouter(
+			vhost.Config.EventTraceEndpoint,
+			vhost.Router,
+			vhost.admin(vhost.serveEventTrace),
+		); err != nil {
+			return nil, err
+		}
+	}
 
 	for _, cfg := range manifest.ServiceFile {
 		if svc, err := initVHostSVC(
@@ -0,0 +1,51 @@
+package vhost
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/dianpeng/mono-service/manifest"
+)
+
+func TestOptimizeLoad(t *testing.T) {
+	vhost, err := CreateVHost(&manifest.Manifest{
+		FS: fstest.MapFS{
+			"main.pl": &fstest.MapFile{
+				Data: []byte(`
+config http_vhost {
+  .name = "test";
+  .listener = "test";
+  .optimize = true;
+}
+`),
+			},
+			"svc.pl": &fstest.MapFile{
+				Data: []byte(`
+config service {
+  .router = "[GET]/a";
+  application noop();
+}
+rule log {
+  let x = 1 + 2 if true else 3;
+  if x != 3 {
+    response.status = 500;
+  }
+}
+`),
+			},
+		},
+		Main:        "main.pl",
+		ServiceFile: []string{"svc.pl"},
+		Type:        "http",
+	})
+	assert.True(t, err == nil, "%s", err)
+	assert.True(t, vhost.Config.Optimize)
+
+	w := httptest.NewRecorder()
+	vhost.Router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/a", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
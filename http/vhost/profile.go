@@ -0,0 +1,89 @@
+package vhost
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// admin endpoint requires the request to carry the admin token of the vhost,
+// ie Authorization: Bearer <admin_token>
+func (v *VHost) admin(h http.HandlerFunc) http.HandlerFunc {
+	expect := sha256.Sum256([]byte(v.Config.AdminToken))
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("authorization")
+		ok := len(auth) > 7 && strings.EqualFold(auth[:7], "bearer ")
+		if ok {
+			token := sha256.Sum256([]byte(auth[7:]))
+			ok = subtle.ConstantTimeCompare(expect[:], token[:]) == 1
+		}
+		if !ok {
+			w.Header().Set("www-authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// profile endpoint of the vhost
+//
+//	GET  : download the pprof profile of the script, ie
+//	       go tool pprof http://host/_profile
+//	POST : ?enable=true|false turns on/off the profiling, ?reset=true drops all
+//	       the recorded samples
+func (v *VHost) serveProfile(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("content-type", "application/octet-stream")
+		w.Header().Set("content-disposition", `attachment; filename="profile"`)
+		if err := v.Profile.WritePprof(w); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+
+	case http.MethodPost:
+		serveSwitch(w, r, "profile", v.Profile.Enable, v.Profile.Reset, v.Profile.Enabled)
+		return
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+}
+
+// handles the ?enable= and ?reset= query of the POST request, used by the
+// profile and coverage endpoint
+func serveSwitch(
+	w http.ResponseWriter,
+	r *http.Request,
+	name string,
+	enable func(bool),
+	reset func(),
+	enabled func() bool,
+) {
+	q := r.URL.Query()
+	if x := q.Get("enable"); x != "" {
+		on, err := strconv.ParseBool(x)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid enable: %s", x), http.StatusBadRequest)
+			return
+		}
+		enable(on)
+	}
+	if x := q.Get("reset"); x != "" {
+		on, err := strconv.ParseBool(x)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid reset: %s", x), http.StatusBadRequest)
+			return
+		}
+		if on {
+			reset()
+		}
+	}
+	fmt.Fprintf(w, "%s enabled: %t\n", name, enabled())
+}
@@ -0,0 +1,102 @@
+package vhost
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/dianpeng/mono-service/manifest"
+)
+
+func TestProfileEndpoint(t *testing.T) {
+	vhost, err := CreateVHost(&manifest.Manifest{
+		FS: fstest.MapFS{
+			"main.pl": &fstest.MapFile{
+				Data: []byte(`
+config http_vhost {
+  .name = "test";
+  .listener = "test";
+  .profile_endpoint = "[GET,POST]/_profile";
+  .admin_token = "secret";
+}
+`),
+			},
+			"svc.pl": &fstest.MapFile{
+				Data: []byte(`
+config service {
+  .router = "[GET]/a";
+  application noop();
+}
+rule log {
+  let x = 1 + 2;
+}
+`),
+			},
+		},
+		Main:        "main.pl",
+		ServiceFile: []string{"svc.pl"},
+		Type:        "http",
+	})
+	assert.True(t, err == nil, "%s", err)
+	assert.False(t, vhost.Profile.Enabled())
+
+	serve := func(method string, url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(method, url, nil)
+		r.Header.Set("authorization", "Bearer secret")
+		vhost.Router.ServeHTTP(w, r)
+		return w
+	}
+
+	// admin token is required
+	for _, auth := range []string{"", "Bearer", "Bearer wrong", "Basic secret"} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/_profile?enable=true", nil)
+		if auth != "" {
+			r.Header.Set("authorization", auth)
+		}
+		vhost.Router.ServeHTTP(w, r)
+		assert.Equal(t, http.StatusUnauthorized, w.Code, auth)
+	}
+	assert.False(t, vhost.Profile.Enabled())
+
+	w := serve(http.MethodPost, "/_profile?enable=true")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, vhost.Profile.Enabled())
+
+	w = serve(http.MethodPost, "/_profile?enable=xx")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// the service runs its log rule for each request
+	serve(http.MethodGet, "/a")
+
+	w = serve(http.MethodGet, "/_profile")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, w.Body.Len() > 0)
+
+	w = serve(http.MethodPost, "/_profile?enable=false&reset=true")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.False(t, vhost.Profile.Enabled())
+}
+
+func TestProfileEndpointAdminToken(t *testing.T) {
+	_, err := CreateVHost(&manifest.Manifest{
+		FS: fstest.MapFS{
+			"main.pl": &fstest.MapFile{
+				Data: []byte(`
+config http_vhost {
+  .name = "test";
+  .listener = "test";
+  .profile_endpoint = "[GET,POST]/_profile";
+}
+`),
+			},
+		},
+		Main: "main.pl",
+		Type: "http",
+	})
+	assert.Equal(t, "http_vhost.profile_endpoint requires http_vhost.admin_token", err.Error())
+}
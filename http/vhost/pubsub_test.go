@@ -0,0 +1,62 @@
+package vhost
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/dianpeng/mono-service/manifest"
+	"github.com/dianpeng/mono-service/pubsub"
+)
+
+type testSubscriber struct {
+	msg []string
+}
+
+func (s *testSubscriber) Message(_ string, channel string, msg []byte) {
+	s.msg = append(s.msg, channel+":"+string(msg))
+}
+
+func TestPubSubBroker(t *testing.T) {
+	broker := pubsub.NewBroker()
+	vhost, err := CreateVHost(&manifest.Manifest{
+		FS: fstest.MapFS{
+			"main.pl": &fstest.MapFile{
+				Data: []byte(`
+config http_vhost {
+  .name = "test";
+  .listener = "test";
+}
+`),
+			},
+			"svc.pl": &fstest.MapFile{
+				Data: []byte(`
+config service {
+  .router = "[GET]/a";
+  application noop();
+}
+rule log {
+  pubsub::publish("news", "hi");
+}
+`),
+			},
+		},
+		Main:        "main.pl",
+		ServiceFile: []string{"svc.pl"},
+		Type:        "http",
+		Broker:      broker,
+	})
+	assert.True(t, err == nil, "%s", err)
+	assert.True(t, vhost.Broker == broker)
+
+	s := &testSubscriber{}
+	broker.Subscribe(s, "news")
+
+	w := httptest.NewRecorder()
+	vhost.Router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/a", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []string{"news:hi"}, s.msg)
+}
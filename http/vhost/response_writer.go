@@ -74,138 +74,136 @@ func (r *responseWriterWrapper) SetReply(
 
 // -----------------------------------------------------------------------------
 // Interface for pl.Usr
-func (r *responseWriterWrapper) Index(
-	key pl.Val,
-) (pl.Val, error) {
-	if key.Type != pl.ValStr {
-		return pl.NewValNull(), fmt.Errorf("invalid index, http.response_writer's " +
-			"component require a string as index")
+func rwField(fn func(*responseWriterWrapper) pl.Val) pl.DotFn {
+	return func(u pl.Usr) (pl.Val, error) {
+		return fn(u.(*responseWriterWrapper)), nil
 	}
-	switch key.String() {
-	case "status":
-		return pl.NewValInt(r.status), nil
-	case "body":
-		return r.bodyVal, nil
-	case "header":
-		return r.headerVal, nil
-
-	case "headerDone":
-		return pl.NewValBool(r.headerDone), nil
-	case "bodyDone":
-		return pl.NewValBool(r.bodyDone), nil
-	case "bodyFlushError":
-		if r.bodyError == nil {
-			return pl.NewValStr(""), nil
-		} else {
-			return pl.NewValStr(r.bodyError.Error()), nil
-		}
-
-	default:
-		break
-	}
-
-	return pl.NewValNull(), fmt.Errorf("unknown field name: %s", key.String())
 }
 
-func (r *responseWriterWrapper) Dot(
-	key string,
-) (pl.Val, error) {
-	return r.Index(pl.NewValStr(key))
+func rwMethod(proto *pl.FuncProto, fn func(*responseWriterWrapper) bool) pl.UsrMethodFn {
+	return func(u pl.Usr, arg []pl.Val) (pl.Val, error) {
+		if _, err := proto.Check(arg); err != nil {
+			return pl.NewValNull(), err
+		}
+		return pl.NewValBool(fn(u.(*responseWriterWrapper))), nil
+	}
 }
 
-func (r *responseWriterWrapper) IndexSet(
-	key pl.Val,
-	val pl.Val,
-) error {
-	if !key.IsString() {
-		return fmt.Errorf("invalid index, http.response_writer's field name " +
-			"must be type string")
-	}
+var (
+	rwMethodFlushHeader     = pl.MustNewFuncProto("http.response_writer.flushHeader", "%0")
+	rwMethodFlush           = pl.MustNewFuncProto("http.response_writer.flush", "%0")
+	rwMethodIsHeaderFlushed = pl.MustNewFuncProto("http.response_writer.isHeaderFlushed", "%0")
+	rwMethodIsFlushed       = pl.MustNewFuncProto("http.response_writer.isFlushed", "%0")
+)
 
-	switch key.String() {
-	case "status":
-		if val.Type == pl.ValInt {
-			r.status = int(val.Int())
-		} else {
+// dispatch table of http.response_writer, ie the response object of script
+var rwDispatch = pl.NewDispatchTable().
+	AddDot("status", rwField(func(r *responseWriterWrapper) pl.Val {
+		return pl.NewValInt(r.status)
+	})).
+	AddDot("body", rwField(func(r *responseWriterWrapper) pl.Val {
+		return r.bodyVal
+	})).
+	AddDot("header", rwField(func(r *responseWriterWrapper) pl.Val {
+		return r.headerVal
+	})).
+	AddDot("headerDone", rwField(func(r *responseWriterWrapper) pl.Val {
+		return pl.NewValBool(r.headerDone)
+	})).
+	AddDot("bodyDone", rwField(func(r *responseWriterWrapper) pl.Val {
+		return pl.NewValBool(r.bodyDone)
+	})).
+	AddDot("bodyFlushError", rwField(func(r *responseWriterWrapper) pl.Val {
+		if r.bodyError == nil {
+			return pl.NewValStr("")
+		}
+		return pl.NewValStr(r.bodyError.Error())
+	})).
+	AddDotSet("status", func(u pl.Usr, val pl.Val) error {
+		if val.Type() != pl.ValInt {
 			return fmt.Errorf("invalid status type: %s", val.Id())
 		}
-		break
-
-	case "header":
+		u.(*responseWriterWrapper).status = int(val.Int())
+		return nil
+	}).
+	AddDotSet("header", func(u pl.Usr, val pl.Val) error {
 		hdrVal, err := hpl.NewHeaderValFromVal(val)
 		if err != nil {
 			return err
 		}
+		r := u.(*responseWriterWrapper)
 		hdr, _ := hdrVal.Usr().(*hpl.Header)
 		r.headerVal = val
 		r.header = hdr.HttpHeader()
-		break
-
-	case "body":
+		return nil
+	}).
+	AddDotSet("body", func(u pl.Usr, val pl.Val) error {
 		bodyVal, err := hpl.NewBodyValFromVal(val)
 		if err != nil {
 			return err
 		}
+		r := u.(*responseWriterWrapper)
 		body, _ := bodyVal.Usr().(*hpl.Body)
 		r.bodyVal = bodyVal
 		r.body = body.Stream().Stream
-		break
+		return nil
+	}).
+	AddMethod("flushHeader", rwMethod(rwMethodFlushHeader, (*responseWriterWrapper).FlushHeader)).
+	AddMethod("flush", rwMethod(rwMethodFlush, (*responseWriterWrapper).Flush)).
+	AddMethod("isFlushed", rwMethod(rwMethodIsFlushed, (*responseWriterWrapper).IsFlushed)).
+	AddMethod("isHeaderFlushed", rwMethod(rwMethodIsHeaderFlushed, (*responseWriterWrapper).IsHeaderFlushed))
+
+func (r *responseWriterWrapper) Dispatch() *pl.DispatchTable {
+	return rwDispatch
+}
+
+func (r *responseWriterWrapper) Index(
+	key pl.Val,
+) (pl.Val, error) {
+	if key.Type() != pl.ValStr {
+		return pl.NewValNull(), fmt.Errorf("invalid index, http.response_writer's " +
+			"component require a string as index")
+	}
+	return r.Dot(key.String())
+}
 
-	default:
-		return fmt.Errorf("invalid field of http.response_writer: %s", key.String())
+func (r *responseWriterWrapper) Dot(
+	key string,
+) (pl.Val, error) {
+	if fn, ok := rwDispatch.LookupDot(key); ok {
+		return fn(r)
 	}
+	return pl.NewValNull(), fmt.Errorf("unknown field name: %s", key)
+}
 
-	return nil
+func (r *responseWriterWrapper) IndexSet(
+	key pl.Val,
+	val pl.Val,
+) error {
+	if !key.IsString() {
+		return fmt.Errorf("invalid index, http.response_writer's field name " +
+			"must be type string")
+	}
+	return r.DotSet(key.String(), val)
 }
 
 func (r *responseWriterWrapper) DotSet(
 	key string,
 	val pl.Val,
 ) error {
-	return r.IndexSet(pl.NewValStr(key), val)
+	if fn, ok := rwDispatch.LookupDotSet(key); ok {
+		return fn(r, val)
+	}
+	return fmt.Errorf("invalid field of http.response_writer: %s", key)
 }
 
-var (
-	rwMethodFlushHeader     = pl.MustNewFuncProto("http.response_writer.flushHeader", "%0")
-	rwMethodFlush           = pl.MustNewFuncProto("http.response_writer.flush", "%0")
-	rwMethodIsHeaderFlushed = pl.MustNewFuncProto("http.response_writer.isHeaderFlushed", "%0")
-	rwMethodIsFlushed       = pl.MustNewFuncProto("http.response_writer.isFlushed", "%0")
-)
-
 func (r *responseWriterWrapper) Method(
 	name string,
 	arg []pl.Val,
 ) (pl.Val, error) {
-
-	switch name {
-	case "flushHeader":
-		if _, err := rwMethodFlushHeader.Check(arg); err != nil {
-			return pl.NewValNull(), err
-		}
-		return pl.NewValBool(r.FlushHeader()), nil
-
-	case "flush":
-		if _, err := rwMethodFlush.Check(arg); err != nil {
-			return pl.NewValNull(), err
-		}
-		return pl.NewValBool(r.Flush()), nil
-
-	case "isFlushed":
-		if _, err := rwMethodIsFlushed.Check(arg); err != nil {
-			return pl.NewValNull(), err
-		}
-		return pl.NewValBool(r.IsFlushed()), nil
-
-	case "isHeaderFlushed":
-		if _, err := rwMethodIsHeaderFlushed.Check(arg); err != nil {
-			return pl.NewValNull(), err
-		}
-		return pl.NewValBool(r.IsHeaderFlushed()), nil
-
-	default:
-		break
+	if fn, ok := rwDispatch.LookupMethod(name); ok {
+		return fn(r, arg)
 	}
-
 	return pl.NewValNull(), fmt.Errorf("http.response_writer method: %s is unknown", name)
 }
 
@@ -0,0 +1,68 @@
+package vhost
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/dianpeng/mono-service/manifest"
+)
+
+func testRuleMatchVHost(mode string) (*VHost, error) {
+	return CreateVHost(&manifest.Manifest{
+		FS: fstest.MapFS{
+			"main.pl": &fstest.MapFile{
+				Data: []byte(`
+config http_vhost {
+  .name = "test";
+  .listener = "test";
+  .rule_match = "` + mode + `";
+}
+`),
+			},
+			"svc.pl": &fstest.MapFile{
+				Data: []byte(`
+config service {
+  .router = "[GET,POST]/a";
+  application noop();
+  response event("response");
+}
+rule response when request.method == "GET" {
+  response.status = 201;
+}
+rule response {
+  response.status = 202;
+}
+`),
+			},
+		},
+		Main:        "main.pl",
+		ServiceFile: []string{"svc.pl"},
+		Type:        "http",
+	})
+}
+
+func TestRuleMatch(t *testing.T) {
+	for _, c := range []struct {
+		mode   string
+		method string
+		code   int
+	}{
+		{"first", http.MethodGet, 201},
+		{"first", http.MethodPost, 202},
+		{"all", http.MethodGet, 202},
+		{"all", http.MethodPost, 202},
+	} {
+		vhost, err := testRuleMatchVHost(c.mode)
+		assert.True(t, err == nil, "%s", err)
+		w := httptest.NewRecorder()
+		vhost.Router.ServeHTTP(w, httptest.NewRequest(c.method, "/a", nil))
+		assert.Equal(t, c.code, w.Code, "%s %s", c.mode, c.method)
+	}
+
+	_, err := testRuleMatchVHost("best")
+	assert.True(t, err != nil)
+}
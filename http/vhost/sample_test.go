@@ -0,0 +1,88 @@
+package vhost
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/dianpeng/mono-service/manifest"
+)
+
+// sample/simple1 with the given service files, the vhost is optimized by
+// turning on the .optimize of the sample's main.pl
+func simple1VHost(b *testing.B, optimize bool, service ...string) *VHost {
+	fs := fstest.MapFS{}
+	for _, f := range append([]string{"main.pl"}, service...) {
+		data, err := os.ReadFile(filepath.Join("../../sample/simple1", f))
+		if err != nil {
+			b.Fatal(err)
+		}
+		fs[f] = &fstest.MapFile{
+			Data: data,
+		}
+	}
+	if optimize {
+		fs["main.pl"].Data = []byte(strings.Replace(string(fs["main.pl"].Data),
+			"config http_vhost {", "config http_vhost {\n  .optimize = true;", 1))
+	}
+
+	vhost, err := CreateVHost(&manifest.Manifest{
+		FS:          fs,
+		Main:        "main.pl",
+		ServiceFile: service,
+		Type:        "http",
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	if vhost.Config.Optimize != optimize {
+		b.Fatal("optimize is not setup")
+	}
+	return vhost
+}
+
+// handlers of sample/simple1 which do not touch the network, the hot path is
+// mostly the property access of request and response. Each handler runs with
+// and without the bytecode optimization
+func BenchmarkSimple1(b *testing.B) {
+	for _, opt := range []struct {
+		name     string
+		optimize bool
+	}{
+		{"plain", false},
+		{"optimized", true},
+	} {
+		vhost := simple1VHost(b, opt.optimize,
+			"helloworld.pl",
+			"response.pl",
+			"body_sign.pl",
+		)
+
+		for _, c := range []struct {
+			name   string
+			method string
+			url    string
+			body   string
+			status int
+		}{
+			{"helloworld", http.MethodGet, "/helloworld", "", 200},
+			{"response", http.MethodGet, "/yyy", "", 200},
+			{"body_sign", http.MethodPost, "/body_sign/sign/md5", "hello world", 200},
+		} {
+			b.Run(opt.name+"/"+c.name, func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					w := httptest.NewRecorder()
+					vhost.Router.ServeHTTP(w, httptest.NewRequest(c.method, c.url, strings.NewReader(c.body)))
+					if w.Code != c.status {
+						b.Fatalf("%s: unexpected status %d", c.name, w.Code)
+					}
+				}
+			})
+		}
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/dianpeng/mono-service/http/phase"
 	"github.com/dianpeng/mono-service/http/runtime"
 	"github.com/dianpeng/mono-service/pl"
+	"github.com/dianpeng/mono-service/pubsub"
 	"github.com/dianpeng/mono-service/util"
 )
 
@@ -79,6 +80,12 @@ func newServiceHandler(s *framework.Service, vhs *vHS) *serviceHandler {
 		runtime: runtime.NewRuntimeWithModule(vhs.module),
 		vhs:     vhs,
 	}
+	h.runtime.Eval.Profile = vhs.vhost.Profile
+	h.runtime.Eval.Coverage = vhs.vhost.Coverage
+	h.runtime.SetEventOrder(vhs.vhost.eventOrder)
+	if vhs.vhost.EventTrace != nil {
+		h.runtime.Eval.Trace = vhs.vhost.EventTrace
+	}
 	return h
 }
 
@@ -274,6 +281,9 @@ func (s *serviceHandler) init(
 }
 
 func (s *serviceHandler) finish() {
+	// pending http::async requests may still use the client, settle them first
+	s.runtime.CancelPending()
+
 	// http client pool draining operations
 	if s.activeHttpClient != nil {
 		for _, c := range s.activeHttpClient {
@@ -293,6 +303,10 @@ func (s *serviceHandler) GetHttpClient(url string) (hpl.HttpClient, error) {
 	return &c, nil
 }
 
+func (s *serviceHandler) Broker() *pubsub.Broker {
+	return s.vhs.vhost.Broker
+}
+
 func (s *serviceHandler) OnLoadVar(_ *pl.Evaluator, name string) (pl.Val, error) {
 	return pl.NewValNull(), fmt.Errorf(
 		"unknown variable name: %s",
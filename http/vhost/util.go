@@ -24,7 +24,7 @@ func propSetInt(
 	name string,
 ) error {
 	if !v.IsInt() {
-		return fmt.Errorf("%s: set field error, value is not int")
+		return fmt.Errorf("%s: set field error, value is not int", name)
 	}
 
 	*ptr = int(v.Int())
@@ -37,9 +37,22 @@ func propSetInt64(
 	name string,
 ) error {
 	if !v.IsInt() {
-		return fmt.Errorf("%s: set field error, value is not int")
+		return fmt.Errorf("%s: set field error, value is not int", name)
 	}
 
 	*ptr = v.Int()
 	return nil
 }
+
+func propSetBool(
+	v pl.Val,
+	ptr *bool,
+	name string,
+) error {
+	if v.Type() != pl.ValBool {
+		return fmt.Errorf("%s: set field error, value is not bool", name)
+	}
+
+	*ptr = v.Bool()
+	return nil
+}
@@ -2,6 +2,8 @@ package vhost
 
 import (
 	"fmt"
+	"log"
+	"os"
 
 	"github.com/gorilla/mux"
 
@@ -9,6 +11,7 @@ import (
 	"github.com/dianpeng/mono-service/g"
 	"github.com/dianpeng/mono-service/manifest"
 	"github.com/dianpeng/mono-service/pl"
+	"github.com/dianpeng/mono-service/pubsub"
 	"github.com/dianpeng/mono-service/server"
 	"github.com/dianpeng/mono-service/util"
 )
@@ -21,6 +24,39 @@ type VHostConfig struct {
 	Listener   string
 	LogFormat  string
 
+	// whether the service module should pass static analysis while loading
+	Strict bool
+
+	// whether the bytecode of the service module is optimized after loading
+	Optimize bool
+
+	// how the rules of the same event are matched, ie "first" or "all". The
+	// default is "first"
+	RuleMatch string
+
+	// order of the emitted events, ie "fifo" or "priority". The default is
+	// "fifo"
+	EventOrder string
+
+	// number of the latest emitted events traced for debugging, 0 disables it.
+	// The trace can be fetched via the endpoint, ie GET /_event_trace
+	EventTrace         int
+	EventTraceEndpoint string
+
+	// script profiling, the profile can be fetched and turned on/off at runtime
+	// via the endpoint, ie [GET,POST]/_profile. Empty endpoint disables it
+	Profile         bool
+	ProfileEndpoint string
+
+	// bearer token of the admin endpoints, ie profile and coverage endpoint.
+	// The admin endpoint cannot be configured without it
+	AdminToken string
+
+	// script line coverage, works the same as profile, ie a window of traffic
+	// can be covered by turning it on and off via the endpoint
+	Coverage         bool
+	CoverageEndpoint string
+
 	HttpClientPoolMaxSize      int64
 	HttpClientPoolTimeout      int64
 	HttpClientPoolMaxDrainSize int64
@@ -32,7 +68,13 @@ type VHost struct {
 	LogFormat   *alog.Format
 	Config      *VHostConfig
 	Module      *pl.Module
+	Logger      *log.Logger
+	Profile     *pl.Profile
+	Coverage    *pl.Coverage
+	Broker      *pubsub.Broker
+	EventTrace  *pl.EventTrace
 	clientPool  *util.HClientPool
+	eventOrder  int
 }
 
 type VHostConfigBuilder struct {
@@ -56,6 +98,30 @@ func (config *VHostConfig) Compose(p *pl.Module) (*VHost, error) {
 		VHost.LogFormat = logf
 	}
 
+	VHost.Profile = pl.NewProfile()
+	VHost.Profile.Enable(config.Profile)
+
+	VHost.Coverage = pl.NewCoverage()
+	VHost.Coverage.Enable(config.Coverage)
+
+	// replaced by the broker of the host, see CreateVHost
+	VHost.Broker = pubsub.NewBroker()
+
+	if order, err := pl.ParseEventOrder(config.EventOrder); err != nil {
+		return nil, fmt.Errorf("http_vhost.event_order: %s", err.Error())
+	} else {
+		VHost.eventOrder = order
+	}
+	if config.EventTrace > 0 {
+		VHost.EventTrace = pl.NewEventTrace(config.EventTrace)
+	}
+
+	VHost.Logger = log.New(
+		os.Stderr,
+		fmt.Sprintf("[http_vhost %s] ", config.Name),
+		log.LstdFlags,
+	)
+
 	router := mux.NewRouter()
 
 	// finish the creation of VHost object
@@ -144,6 +210,83 @@ func (s *VHostConfigBuilder) ConfigProperty(
 			"http_vhost.log_format",
 		)
 
+	case "strict":
+		return propSetBool(
+			value,
+			&s.config.Strict,
+			"http_vhost.strict",
+		)
+
+	case "optimize":
+		return propSetBool(
+			value,
+			&s.config.Optimize,
+			"http_vhost.optimize",
+		)
+
+	case "rule_match":
+		return propSetString(
+			value,
+			&s.config.RuleMatch,
+			"http_vhost.rule_match",
+		)
+
+	case "event_order":
+		return propSetString(
+			value,
+			&s.config.EventOrder,
+			"http_vhost.event_order",
+		)
+
+	case "event_trace":
+		return propSetInt(
+			value,
+			&s.config.EventTrace,
+			"http_vhost.event_trace",
+		)
+
+	case "event_trace_endpoint":
+		return propSetString(
+			value,
+			&s.config.EventTraceEndpoint,
+			"http_vhost.event_trace_endpoint",
+		)
+
+	case "profile":
+		return propSetBool(
+			value,
+			&s.config.Profile,
+			"http_vhost.profile",
+		)
+
+	case "profile_endpoint":
+		return propSetString(
+			value,
+			&s.config.ProfileEndpoint,
+			"http_vhost.profile_endpoint",
+		)
+
+	case "admin_token":
+		return propSetString(
+			value,
+			&s.config.AdminToken,
+			"http_vhost.admin_token",
+		)
+
+	case "coverage":
+		return propSetBool(
+			value,
+			&s.config.Coverage,
+			"http_vhost.coverage",
+		)
+
+	case "coverage_endpoint":
+		return propSetString(
+			value,
+			&s.config.CoverageEndpoint,
+			"http_vhost.coverage_endpoint",
+		)
+
 	case "http_client_pool_max_size":
 		return propSetInt64(
 			value,
@@ -187,128 +187,84 @@ func (s *svcConfigBuilder) PopConfig(
 	return nil
 }
 
-func (s *svcConfigBuilder) propService(
-	key string,
-	value pl.Val,
-	_ pl.Val,
-) error {
-	switch key {
-	case "name":
-		if err := propSetString(
-			value,
-			&s.config.Name,
-			"service.name",
-		); err != nil {
-			return err
-		}
-		break
-
-	case "tag":
-		if err := propSetString(
-			value,
-			&s.config.Tag,
-			"service.tag",
-		); err != nil {
-			return err
-		}
-		break
+// property of service config, shared by the config evaluation and the static
+// analysis of the config scope
+var svcServiceProperty = map[string]func(*vHSConfig, pl.Val) error{
+	"name": func(c *vHSConfig, v pl.Val) error {
+		return propSetString(v, &c.Name, "service.name")
+	},
+	"tag": func(c *vHSConfig, v pl.Val) error {
+		return propSetString(v, &c.Tag, "service.tag")
+	},
+	"comment": func(c *vHSConfig, v pl.Val) error {
+		return propSetString(v, &c.Comment, "service.comment")
+	},
+	"router": func(c *vHSConfig, v pl.Val) error {
+		return propSetString(v, &c.Router, "service.router")
+	},
+	"max_session_cache_size": func(c *vHSConfig, v pl.Val) error {
+		return propSetInt(v, &c.MaxSessionCacheSize, "service.max_session_cache_size")
+	},
+}
 
-	case "comment":
-		if err := propSetString(
-			value,
-			&s.config.Comment,
-			"service.comment",
-		); err != nil {
-			return err
+// checks whether the property is allowed in current config scope, only the
+// service scope has property
+func (s *svcConfigBuilder) checkProperty(key string) error {
+	switch s.curType() {
+	case svcConfigService:
+		if _, ok := svcServiceProperty[key]; !ok {
+			return fmt.Errorf("unknown property %s of service config", key)
 		}
-		break
+		return nil
 
-	case "router":
-		if err := propSetString(
-			value,
-			&s.config.Router,
-			"service.router",
-		); err != nil {
-			return err
-		}
-		break
+	case svcConfigRequest, svcConfigResponse:
+		return fmt.Errorf("unknown property %s of service middleware", key)
 
-	case "max_session_cache_size":
-		if err := propSetInt(
-			value,
-			&s.config.MaxSessionCacheSize,
-			"service.max_session_cache_size",
-		); err != nil {
-			return err
-		}
-		break
+	case svcConfigApplication:
+		return fmt.Errorf("unknown property %s of service application", key)
 
 	default:
-		return fmt.Errorf("unknown property %s of service config", key)
+		return fmt.Errorf("unknown config instruction: %s", key)
 	}
-
-	return nil
-}
-
-func (s *svcConfigBuilder) propMiddleware(
-	key string,
-	_ pl.Val,
-	_ pl.Val,
-	_ *vHSMiddlewareConfig,
-) error {
-	return fmt.Errorf("unknown property %s of service middleware", key)
-}
-
-func (s *svcConfigBuilder) propApplication(
-	key string,
-	_ pl.Val,
-	_ pl.Val,
-) error {
-	return fmt.Errorf("unknown property %s of service application", key)
 }
 
 func (s *svcConfigBuilder) ConfigProperty(
 	_ *pl.Evaluator,
 	key string,
 	value pl.Val,
-	attr pl.Val,
+	_ pl.Val,
 ) error {
-	switch s.curType() {
-	case svcConfigService:
-		return s.propService(
-			key,
-			value,
-			attr,
-		)
-
-	case svcConfigRequest, svcConfigResponse:
-		var x *vHSMiddlewareConfig
+	if err := s.checkProperty(key); err != nil {
+		return err
+	}
+	return svcServiceProperty[key](s.config, value)
+}
 
-		if s.curType() == svcConfigRequest {
-			x = &s.config.Request
-		} else {
-			x = &s.config.Response
+// checks whether the command is allowed in current config scope and the
+// middleware/application it refers to exists
+func (s *svcConfigBuilder) checkCommand(key string) error {
+	switch s.curType() {
+	case svcConfigRequest:
+		if framework.GetRequestFactory(key) == nil {
+			return fmt.Errorf("middleware(request): %s is not found", key)
 		}
+		return nil
 
-		return s.propMiddleware(
-			key,
-			value,
-			attr,
-			x,
-		)
+	case svcConfigResponse:
+		if framework.GetResponseFactory(key) == nil {
+			return fmt.Errorf("middleware(response): %s is not found", key)
+		}
+		return nil
 
 	case svcConfigApplication:
-		return s.propApplication(
-			key,
-			value,
-			attr,
-		)
+		if framework.GetApplicationFactory(key) == nil {
+			return fmt.Errorf("application %s is not found", key)
+		}
+		return nil
 
 	default:
-		break
+		return fmt.Errorf("unknown command %s inside of config service", key)
 	}
-
-	return fmt.Errorf("unknown config instruction: %s", key)
 }
 
 func (s *svcConfigBuilder) cmdMiddleware(
@@ -340,6 +296,9 @@ func (s *svcConfigBuilder) ConfigCommand(
 	value []pl.Val,
 	attr pl.Val,
 ) error {
+	if err := s.checkCommand(key); err != nil {
+		return err
+	}
 
 	switch s.curType() {
 	case svcConfigRequest, svcConfigResponse:
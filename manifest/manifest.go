@@ -2,6 +2,8 @@ package manifest
 
 import (
 	"io/fs"
+
+	"github.com/dianpeng/mono-service/pubsub"
 )
 
 // Each application to be served by mono-service will needs to have a unfied
@@ -12,4 +14,8 @@ type Manifest struct {
 	Main        string
 	ServiceFile []string
 	Type        string
+
+	// pub/sub broker shared by the vhosts of the host, the vhost uses its own
+	// broker if it is nil
+	Broker *pubsub.Broker
 }
@@ -3,6 +3,7 @@ package pl
 import (
 	"bytes"
 	"fmt"
+	"io/fs"
 	"regexp"
 )
 
@@ -48,6 +49,11 @@ const (
 	bcStoreLocal   = 22
 	bcReserveLocal = 23
 	bcLoadRegexp   = 24
+	bcLoadBytes    = 25
+
+	// fused load-local + dot, only generated by the optimizer. The argument
+	// packs the local index and the string index of the field name
+	bcLoadLocalDot = 26
 
 	bcAction = 30
 
@@ -203,6 +209,7 @@ type upvalue struct {
 type program struct {
 	module    *Module
 	name      string
+	file      string // source file, empty for the main module
 	localSize int
 	argSize   int // if this program is a function call, then this indicates
 	// size of the arguments required for calling the function
@@ -220,6 +227,79 @@ type program struct {
 
 	// used when the program is a function, ie for capturing its upvalue
 	upvalue []upvalue
+
+	// parser metadata, only used by static analysis
+	meta progMeta
+
+	// inline cache of each instruction, see dispatch.go
+	icache []inlineCache
+
+	// rule only, the guard is the when condition of the rule and nil means the
+	// rule always matches its event. Rules of the same event are ordered by the
+	// priority, ie higher first, and then the declaration order
+	guard    *program
+	guardSrc string
+	priority int64
+}
+
+type metaVar struct {
+	name string
+	loc  sourceloc
+}
+
+// information that cannot be recovered from the bytecode directly, recorded
+// by the parser during code generation
+type progMeta struct {
+	// pc of bcICall to the intrinsic function index
+	icall map[int]int
+
+	// pc of config property/command instruction to its name, only recorded
+	// when the name is literal
+	config map[int]string
+
+	// pc of bcEmit to the event name and the location of the event name
+	emit map[int]metaVar
+
+	// local variable declared via let/const but never been read
+	unused []metaVar
+}
+
+func (p *progMeta) addICall(pc int, idx int) {
+	if p.icall == nil {
+		p.icall = make(map[int]int)
+	}
+	p.icall[pc] = idx
+}
+
+func (p *progMeta) addConfig(pc int, name string) {
+	if p.config == nil {
+		p.config = make(map[int]string)
+	}
+	p.config[pc] = name
+}
+
+func (p *progMeta) addEmit(pc int, name string, loc sourceloc) {
+	if p.emit == nil {
+		p.emit = make(map[int]metaVar)
+	}
+	p.emit[pc] = metaVar{
+		name: name,
+		loc:  loc,
+	}
+}
+
+// human readable name of the program, ie rule foo, fn bar
+func (p *program) displayName() string {
+	switch p.progtype {
+	case progRule:
+		return fmt.Sprintf("rule %s", p.name)
+	case progFunc:
+		return fmt.Sprintf("fn %s", p.name)
+	case progIter:
+		return fmt.Sprintf("iter %s", p.name)
+	default:
+		return p.name
+	}
 }
 
 func newProgram(p *Module, n string, t int) *program {
@@ -306,19 +386,59 @@ func (p *program) idxRegexp(i int) *regexp.Regexp {
 	return p.tbRegexp[i]
 }
 
-func (p *program) addTemplate(t string, c string, opt Val) (int, error) {
-	temp := newTemplate(t)
-	if temp == nil {
-		return -1, fmt.Errorf("unsupported template type %s", t)
+// inline template, the file system is used by the template which references
+// other template, see TemplateInlineFS
+func (p *program) addTemplate(
+	selector, t, c string,
+	opt Val,
+	fsys fs.FS,
+) (int, error) {
+	temp, err := p.module.compileTemplate(
+		"inline\x00"+selector+"\x00"+c,
+		t,
+		func(temp Template, name string) error {
+			if x, ok := temp.(TemplateInlineFS); ok {
+				return x.CompileInlineFS(fsys, name, c, opt)
+			}
+			return temp.Compile(name, c, opt)
+		},
+	)
+	if err != nil {
+		return 0, err
 	}
+	return p.addTemplateObj(temp), nil
+}
 
-	idx := len(p.tbTemplate)
-	err := temp.Compile(fmt.Sprintf("temp-%d", idx), c, opt)
+// template loaded from file system, the file's path is part of the selector
+func (p *program) addTemplateFile(
+	selector, t, file string,
+	opt Val,
+	fsys fs.FS,
+) (int, error) {
+	temp, err := p.module.compileTemplate(
+		"file\x00"+selector,
+		t,
+		func(temp Template, _ string) error {
+			if x, ok := temp.(TemplateFS); ok {
+				return x.CompileFS(fsys, file, opt)
+			}
+			data, err := fs.ReadFile(fsys, file)
+			if err != nil {
+				return err
+			}
+			return temp.Compile(file, string(data), opt)
+		},
+	)
 	if err != nil {
 		return 0, err
 	}
+	return p.addTemplateObj(temp), nil
+}
+
+func (p *program) addTemplateObj(temp Template) int {
+	idx := len(p.tbTemplate)
 	p.tbTemplate = append(p.tbTemplate, temp)
-	return idx, nil
+	return idx
 }
 
 func (p *program) addRegexp(r string) (int, error) {
@@ -411,6 +531,8 @@ func (x *bytecode) dumpWithProgram(p *program) string {
 			return fmt.Sprintf("%f", p.idxReal(arg))
 		case bcLoadStr, bcAction, bcDot:
 			return p.idxStr(arg)
+		case bcLoadBytes:
+			return fmt.Sprintf("%x", p.idxStr(arg))
 		case bcLoadRegexp:
 			return p.idxRegexp(arg).String()
 		case bcTemplate:
@@ -437,12 +559,18 @@ func (x *bytecode) dump(resolver func(int, int) string) string {
 
 	switch x.opcode {
 
+	case bcLoadLocalDot:
+		local, field := unpackLocalDot(arg)
+		b.WriteString(fmt.Sprintf("%s(%d, %d%s)", name, local, field, wrapper(bcDot, field)))
+		break
+
 	case bcLoadInt,
 		bcLoadReal,
 		bcLoadStr,
 		bcAction,
 		bcDot,
 		bcLoadRegexp,
+		bcLoadBytes,
 		bcTemplate:
 
 		b.WriteString(fmt.Sprintf("%s(%d%s)", name, arg, wrapper(x.opcode, arg)))
@@ -545,6 +673,8 @@ func getBytecodeName(bc int) string {
 		return "load-dollar"
 	case bcLoadLocal:
 		return "load-local"
+	case bcLoadLocalDot:
+		return "load-local-dot"
 	case bcStoreLocal:
 		return "store-local"
 	case bcReserveLocal:
@@ -617,6 +747,8 @@ func getBytecodeName(bc int) string {
 
 	case bcLoadRegexp:
 		return "load-regexp"
+	case bcLoadBytes:
+		return "load-bytes"
 	case bcPushException:
 		return "push-exception"
 	case bcPopException:
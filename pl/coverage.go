@@ -0,0 +1,340 @@
+package pl
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Line coverage of the script code. Each Evaluator records the hit count of
+// every bytecode executed while a rule or a script function called from native
+// code is running, and merges it into the shared Coverage once the execution
+// is done. The bytecode position is mapped back to the source line via the
+// debug information of the program, ie dbgList.
+//
+// Module should be registered to the Coverage via AddModule, otherwise the
+// lines that are never executed are not reported at all.
+
+type Coverage struct {
+	enabled int32
+	sync.Mutex
+	hits   map[*program][]int64
+	module map[*Module]string
+}
+
+func NewCoverage() *Coverage {
+	return &Coverage{
+		hits:   make(map[*program][]int64),
+		module: make(map[*Module]string),
+	}
+}
+
+// Enable turns on/off the coverage recording, it takes effect for the next
+// rule
+func (c *Coverage) Enable(enable bool) {
+	if enable {
+		atomic.StoreInt32(&c.enabled, 1)
+	} else {
+		atomic.StoreInt32(&c.enabled, 0)
+	}
+}
+
+func (c *Coverage) Enabled() bool {
+	return atomic.LoadInt32(&c.enabled) == 1
+}
+
+// Reset clears all the hit count, the registered module is kept
+func (c *Coverage) Reset() {
+	c.Lock()
+	defer c.Unlock()
+	for prog := range c.hits {
+		c.hits[prog] = make([]int64, len(prog.bcList))
+	}
+}
+
+// AddModule registers all the program of the module, name is the file name of
+// the module's main source, the imported module uses its import path
+func (c *Coverage) AddModule(m *Module, name string) {
+	c.Lock()
+	defer c.Unlock()
+	c.module[m] = name
+	for _, prog := range m.allProgram() {
+		if _, ok := c.hits[prog]; !ok {
+			c.hits[prog] = make([]int64, len(prog.bcList))
+		}
+	}
+}
+
+func (c *Coverage) merge(hits map[*program][]int64) {
+	c.Lock()
+	defer c.Unlock()
+	for prog, h := range hits {
+		dst, ok := c.hits[prog]
+		if !ok {
+			dst = make([]int64, len(prog.bcList))
+			c.hits[prog] = dst
+		}
+		for pc, cnt := range h {
+			dst[pc] += cnt
+			h[pc] = 0
+		}
+	}
+}
+
+// per Evaluator recording state
+type covState struct {
+	coverage *Coverage
+	hits     map[*program][]int64
+	curProg  *program
+	cur      []int64
+}
+
+func (s *covState) begin(c *Coverage) {
+	s.coverage = c
+	s.curProg = nil
+	s.cur = nil
+}
+
+func (s *covState) end() {
+	s.coverage.merge(s.hits)
+	s.curProg = nil
+	s.cur = nil
+}
+
+// invoked for every bytecode
+func (s *covState) onBytecode(prog *program, pc int) {
+	if prog != s.curProg {
+		h, ok := s.hits[prog]
+		if !ok {
+			h = make([]int64, len(prog.bcList))
+			s.hits[prog] = h
+		}
+		s.curProg = prog
+		s.cur = h
+	}
+	s.cur[pc]++
+}
+
+// coverage starts, if enabled, at the outermost rule or script function
+// execution
+func (e *Evaluator) covBegin() bool {
+	if e.cov != nil || e.Coverage == nil || !e.Coverage.Enabled() {
+		return false
+	}
+	if e.covBuf == nil {
+		e.covBuf = &covState{
+			hits: make(map[*program][]int64),
+		}
+	}
+	e.cov = e.covBuf
+	e.cov.begin(e.Coverage)
+	return true
+}
+
+func (e *Evaluator) covEnd() {
+	e.cov.end()
+	e.cov = nil
+}
+
+// ----------------------------------------------------------------------------
+// report
+
+type covFunc struct {
+	name string
+	line int
+	hit  int64
+}
+
+type covFile struct {
+	name   string
+	source string
+	line   map[int]int64
+	fn     []covFunc
+}
+
+func (f *covFile) lineList() []int {
+	o := []int{}
+	for l := range f.line {
+		o = append(o, l)
+	}
+	sort.Ints(o)
+	return o
+}
+
+func (f *covFile) lineHit() int {
+	cnt := 0
+	for _, hit := range f.line {
+		if hit > 0 {
+			cnt++
+		}
+	}
+	return cnt
+}
+
+func (c *Coverage) fileName(prog *program) string {
+	if prog.file != "" {
+		return prog.file
+	}
+	return c.module[prog.module]
+}
+
+// group the hit count by file and line, a line's hit count is the max hit count
+// of all the bytecode generated from the line
+func (c *Coverage) fileList() []*covFile {
+	c.Lock()
+	defer c.Unlock()
+
+	files := make(map[string]*covFile)
+	for prog, hits := range c.hits {
+		if len(prog.dbgList) == 0 {
+			continue
+		}
+		name := c.fileName(prog)
+		f, ok := files[name]
+		if !ok {
+			f = &covFile{
+				name:   name,
+				source: prog.dbgList[0].source,
+				line:   make(map[int]int64),
+			}
+			files[name] = f
+		}
+
+		for pc, hit := range hits {
+			if pc >= len(prog.dbgList) {
+				break
+			}
+			line := prog.dbgList[pc].line
+			if old, ok := f.line[line]; !ok || hit > old {
+				f.line[line] = hit
+			}
+		}
+
+		if prog.progtype == progRule || prog.progtype == progFunc ||
+			prog.progtype == progIter {
+			f.fn = append(f.fn, covFunc{
+				name: prog.displayName(),
+				line: int(prog.startLine()),
+				hit:  hits[0],
+			})
+		}
+	}
+
+	o := []*covFile{}
+	for _, f := range files {
+		sort.Slice(f.fn, func(i, j int) bool {
+			return f.fn[i].line < f.fn[j].line
+		})
+		o = append(o, f)
+	}
+	sort.Slice(o, func(i, j int) bool {
+		return o[i].name < o[j].name
+	})
+	return o
+}
+
+// WriteLCOV writes out the coverage as LCOV tracefile, each rule and function
+// is reported as a function record
+func (c *Coverage) WriteLCOV(out io.Writer) error {
+	b := &strings.Builder{}
+	for _, f := range c.fileList() {
+		fmt.Fprintf(b, "TN:\n")
+		fmt.Fprintf(b, "SF:%s\n", f.name)
+
+		fnHit := 0
+		for _, fn := range f.fn {
+			fmt.Fprintf(b, "FN:%d,%s\n", fn.line, fn.name)
+		}
+		for _, fn := range f.fn {
+			fmt.Fprintf(b, "FNDA:%d,%s\n", fn.hit, fn.name)
+			if fn.hit > 0 {
+				fnHit++
+			}
+		}
+		fmt.Fprintf(b, "FNF:%d\n", len(f.fn))
+		fmt.Fprintf(b, "FNH:%d\n", fnHit)
+
+		for _, l := range f.lineList() {
+			fmt.Fprintf(b, "DA:%d,%d\n", l, f.line[l])
+		}
+		fmt.Fprintf(b, "LF:%d\n", len(f.line))
+		fmt.Fprintf(b, "LH:%d\n", f.lineHit())
+		fmt.Fprintf(b, "end_of_record\n")
+	}
+	_, err := io.WriteString(out, b.String())
+	return err
+}
+
+func covPercent(hit int, total int) float64 {
+	if total == 0 {
+		return 100.0
+	}
+	return float64(hit) * 100.0 / float64(total)
+}
+
+// WriteHTML writes out a single page HTML report with the source of each file,
+// the executed line is marked green and the line never executed is marked red
+func (c *Coverage) WriteHTML(out io.Writer) error {
+	files := c.fileList()
+
+	b := &strings.Builder{}
+	b.WriteString(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>PL Coverage</title>
+<style>
+body { font-family: monospace; }
+table.src { border-collapse: collapse; }
+table.src td { padding: 0 8px; white-space: pre; }
+td.num, td.cnt { text-align: right; color: #888; }
+tr.hit { background: #dfd; }
+tr.miss { background: #fdd; }
+</style>
+</head>
+<body>
+<h1>PL Coverage</h1>
+<ul>
+`)
+	for idx, f := range files {
+		fmt.Fprintf(b, "<li><a href=\"#file%d\">%s</a> %.1f%%</li>\n",
+			idx,
+			html.EscapeString(f.name),
+			covPercent(f.lineHit(), len(f.line)),
+		)
+	}
+	b.WriteString("</ul>\n")
+
+	for idx, f := range files {
+		fmt.Fprintf(b, "<h2 id=\"file%d\">%s</h2>\n", idx, html.EscapeString(f.name))
+		b.WriteString("<table class=\"src\">\n")
+		for i, text := range strings.Split(f.source, "\n") {
+			line := i + 1
+			class := ""
+			cnt := ""
+			if hit, ok := f.line[line]; ok {
+				cnt = fmt.Sprintf("%d", hit)
+				if hit > 0 {
+					class = " class=\"hit\""
+				} else {
+					class = " class=\"miss\""
+				}
+			}
+			fmt.Fprintf(b, "<tr%s><td class=\"num\">%d</td><td class=\"cnt\">%s</td><td>%s</td></tr>\n",
+				class,
+				line,
+				cnt,
+				html.EscapeString(text),
+			)
+		}
+		b.WriteString("</table>\n")
+	}
+	b.WriteString("</body>\n</html>\n")
+
+	_, err := io.WriteString(out, b.String())
+	return err
+}
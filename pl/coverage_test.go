@@ -0,0 +1,125 @@
+package pl
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testCoverage(t *testing.T, code string, event string) *Coverage {
+	module, err := CompileModule(code, nil)
+	assert.True(t, err == nil, "%s", err)
+
+	coverage := NewCoverage()
+	coverage.AddModule(module, "main.pl")
+	coverage.Enable(true)
+
+	eval := NewEvaluatorSimple()
+	eval.Coverage = coverage
+	_, err = eval.Eval(event, module)
+	assert.True(t, err == nil, "%s", err)
+	return coverage
+}
+
+func TestCoverageLine(t *testing.T) {
+	coverage := testCoverage(t, `
+fn never() {
+  return 1;
+}
+test {
+  let a = 1;
+  if a == 1 {
+    a = 2;
+  } else {
+    a = 3;
+  }
+}
+`, "test")
+
+	files := coverage.fileList()
+	assert.Equal(t, 1, len(files))
+	f := files[0]
+	assert.Equal(t, "main.pl", f.name)
+
+	assert.True(t, f.line[6] > 0)
+	assert.True(t, f.line[8] > 0)
+
+	// the else branch and the function are never executed
+	assert.Equal(t, int64(0), f.line[10])
+	assert.Equal(t, int64(0), f.line[3])
+
+	assert.Equal(t, 2, len(f.fn))
+	assert.Equal(t, "fn never", f.fn[0].name)
+	assert.Equal(t, int64(0), f.fn[0].hit)
+	assert.Equal(t, "rule test", f.fn[1].name)
+	assert.Equal(t, int64(1), f.fn[1].hit)
+}
+
+func TestCoverageDisabled(t *testing.T) {
+	module, err := CompileModule(`test { let a = 1; }`, nil)
+	assert.True(t, err == nil)
+
+	coverage := NewCoverage()
+	coverage.AddModule(module, "main.pl")
+	eval := NewEvaluatorSimple()
+	eval.Coverage = coverage
+
+	_, err = eval.Eval("test", module)
+	assert.True(t, err == nil)
+	assert.Equal(t, 0, coverage.fileList()[0].lineHit())
+
+	coverage.Enable(true)
+	_, err = eval.Eval("test", module)
+	assert.True(t, err == nil)
+	assert.Equal(t, 1, coverage.fileList()[0].lineHit())
+
+	coverage.Reset()
+	assert.Equal(t, 0, coverage.fileList()[0].lineHit())
+}
+
+func TestCoverageFunction(t *testing.T) {
+	// script function called by the embedder
+	module, err := CompileModule(`
+fn foo(a) {
+  return a + 1;
+}
+`, nil)
+	assert.True(t, err == nil)
+
+	coverage := NewCoverage()
+	coverage.AddModule(module, "main.pl")
+	coverage.Enable(true)
+
+	eval := NewEvaluatorSimple()
+	eval.Coverage = coverage
+	fn := module.GetFunction("foo")
+	v, err := fn.Closure().Call(eval, []Val{NewValInt(1)})
+	assert.True(t, err == nil)
+	assert.Equal(t, int64(2), v.Int())
+	assert.True(t, coverage.fileList()[0].line[3] > 0)
+}
+
+func TestCoverageReport(t *testing.T) {
+	coverage := testCoverage(t, `
+test {
+  let a = "<b>";
+}
+`, "test")
+
+	var b bytes.Buffer
+	assert.True(t, coverage.WriteLCOV(&b) == nil)
+	lcov := b.String()
+	assert.Contains(t, lcov, "SF:main.pl\n")
+	assert.Contains(t, lcov, "FN:2,rule test\n")
+	assert.Contains(t, lcov, "FNDA:1,rule test\n")
+	assert.Contains(t, lcov, "DA:3,1\n")
+	assert.Contains(t, lcov, "end_of_record\n")
+
+	b.Reset()
+	assert.True(t, coverage.WriteHTML(&b) == nil)
+	page := b.String()
+	assert.Contains(t, page, "main.pl")
+	assert.Contains(t, page, "&lt;b&gt;")
+	assert.Contains(t, page, `class="hit"`)
+}
@@ -0,0 +1,252 @@
+package pl
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Fast property and method dispatch of user type.
+//
+// By default the dot, dot set and method call of Usr go through Usr.Dot,
+// Usr.DotSet and Usr.Method which typically dispatch via string switch. A Usr
+// type can opt into the integer indexed dispatch by implementing UsrDispatch,
+// ie returning a DispatchTable built once for the type. The property and
+// method names are interned into process wide integer id, and each dot, dot
+// set and method loading instruction has its own inline cache which remembers
+// the dispatch table and the slot of the last receiver seen by that
+// instruction. When the cache hits, the handler is invoked directly without
+// any string comparison.
+//
+// Name not registered inside of the dispatch table falls back to the Usr
+// interface, so a type can register only its hot properties.
+
+type (
+	DotFn       func(Usr) (Val, error)
+	DotSetFn    func(Usr, Val) error
+	UsrMethodFn func(Usr, []Val) (Val, error)
+)
+
+type UsrDispatch interface {
+	Dispatch() *DispatchTable
+}
+
+// -----------------------------------------------------------------------------
+// name interning
+var nameTable = struct {
+	sync.RWMutex
+	id   map[string]int
+	name []string
+}{
+	id: make(map[string]int),
+}
+
+// Intern the property or method name, the returned id is stable for the whole
+// process
+func InternName(name string) int {
+	nameTable.RLock()
+	id, ok := nameTable.id[name]
+	nameTable.RUnlock()
+	if ok {
+		return id
+	}
+
+	nameTable.Lock()
+	defer nameTable.Unlock()
+	if id, ok := nameTable.id[name]; ok {
+		return id
+	}
+	id = len(nameTable.name)
+	nameTable.id[name] = id
+	nameTable.name = append(nameTable.name, name)
+	return id
+}
+
+func InternedName(id int) string {
+	nameTable.RLock()
+	defer nameTable.RUnlock()
+	if id < 0 || id >= len(nameTable.name) {
+		return ""
+	}
+	return nameTable.name[id]
+}
+
+// -----------------------------------------------------------------------------
+// dispatch table
+type dispatchIndex struct {
+	names  []string
+	byName map[string]int
+	byId   map[int]int
+}
+
+func newDispatchIndex() dispatchIndex {
+	return dispatchIndex{
+		byName: make(map[string]int),
+		byId:   make(map[int]int),
+	}
+}
+
+func (d *dispatchIndex) add(name string, slot int) {
+	d.names = append(d.names, name)
+	d.byName[name] = slot
+	d.byId[InternName(name)] = slot
+}
+
+// DispatchTable is immutable once it is built, and it is shared by all the
+// objects of the same type. It must be built during initialization, ie as a
+// package variable
+type DispatchTable struct {
+	dot      dispatchIndex
+	dotFn    []DotFn
+	dotSet   dispatchIndex
+	dotSetFn []DotSetFn
+	method   dispatchIndex
+	methodFn []UsrMethodFn
+}
+
+func NewDispatchTable() *DispatchTable {
+	return &DispatchTable{
+		dot:    newDispatchIndex(),
+		dotSet: newDispatchIndex(),
+		method: newDispatchIndex(),
+	}
+}
+
+func (t *DispatchTable) AddDot(name string, fn DotFn) *DispatchTable {
+	t.dot.add(name, len(t.dotFn))
+	t.dotFn = append(t.dotFn, fn)
+	return t
+}
+
+func (t *DispatchTable) AddDotSet(name string, fn DotSetFn) *DispatchTable {
+	t.dotSet.add(name, len(t.dotSetFn))
+	t.dotSetFn = append(t.dotSetFn, fn)
+	return t
+}
+
+func (t *DispatchTable) AddMethod(name string, fn UsrMethodFn) *DispatchTable {
+	t.method.add(name, len(t.methodFn))
+	t.methodFn = append(t.methodFn, fn)
+	return t
+}
+
+// Lookup by name, used by the Usr implementation to share the handler with
+// the fast path, ie Usr.Dot can be implemented on top of the table
+func (t *DispatchTable) LookupDot(name string) (DotFn, bool) {
+	if slot, ok := t.dot.byName[name]; ok {
+		return t.dotFn[slot], true
+	}
+	return nil, false
+}
+
+func (t *DispatchTable) LookupDotSet(name string) (DotSetFn, bool) {
+	if slot, ok := t.dotSet.byName[name]; ok {
+		return t.dotSetFn[slot], true
+	}
+	return nil, false
+}
+
+func (t *DispatchTable) LookupMethod(name string) (UsrMethodFn, bool) {
+	if slot, ok := t.method.byName[name]; ok {
+		return t.methodFn[slot], true
+	}
+	return nil, false
+}
+
+// all the method names in registration order, used by static analysis
+func (t *DispatchTable) MethodList() []string {
+	return append([]string(nil), t.method.names...)
+}
+
+// -----------------------------------------------------------------------------
+// inline cache
+//
+// The program is shared by all the evaluators, ie one per session, so the
+// cache entry is swapped atomically. The entry is immutable, a site which has
+// seen more than one dispatch table is marked as megamorphic and stops being
+// updated afterwards
+type icEntry struct {
+	table *DispatchTable
+	slot  int // -1 means the name is not inside of the table
+	mega  bool
+}
+
+type inlineCache struct {
+	name  int // interned name of the instruction, -1 if not a dispatch site
+	entry atomic.Value
+}
+
+func (c *inlineCache) lookup(t *DispatchTable, idx *dispatchIndex) (int, bool) {
+	x, _ := c.entry.Load().(*icEntry)
+	if x != nil && x.table == t {
+		return x.slot, x.slot >= 0
+	}
+
+	slot, ok := idx.byId[c.name]
+	if !ok {
+		slot = -1
+	}
+
+	switch {
+	case x == nil:
+		c.entry.Store(&icEntry{table: t, slot: slot})
+	case !x.mega:
+		c.entry.Store(&icEntry{mega: true})
+	}
+	return slot, ok
+}
+
+// allocate the inline cache for each instruction of the program, must be
+// called whenever the bytecode is changed
+func (p *program) initInlineCache() {
+	p.icache = make([]inlineCache, len(p.bcList))
+	for pc, bc := range p.bcList {
+		name := -1
+		switch bc.opcode {
+		case bcDot, bcDotSet, bcLoadMethod:
+			name = InternName(p.idxStr(bc.argument))
+		case bcLoadLocalDot:
+			_, field := unpackLocalDot(bc.argument)
+			name = InternName(p.idxStr(field))
+		}
+		p.icache[pc].name = name
+	}
+}
+
+func (p *program) usrDispatch(pc int, recv Val) (Usr, *DispatchTable, *inlineCache) {
+	if recv.Type() != ValUsr || pc >= len(p.icache) {
+		return nil, nil, nil
+	}
+	u := recv.Usr()
+	d, ok := u.(UsrDispatch)
+	if !ok {
+		return nil, nil, nil
+	}
+	return u, d.Dispatch(), &p.icache[pc]
+}
+
+func (p *program) icDot(pc int, recv Val, name string) (Val, error) {
+	if u, t, c := p.usrDispatch(pc, recv); t != nil {
+		if slot, ok := c.lookup(t, &t.dot); ok {
+			return t.dotFn[slot](u)
+		}
+	}
+	return recv.Dot(name)
+}
+
+func (p *program) icDotSet(pc int, recv Val, name string, value Val) error {
+	if u, t, c := p.usrDispatch(pc, recv); t != nil {
+		if slot, ok := c.lookup(t, &t.dotSet); ok {
+			return t.dotSetFn[slot](u, value)
+		}
+	}
+	return recv.DotSet(name, value)
+}
+
+func (p *program) icMethodClosure(pc int, recv Val, name string) (Val, error) {
+	if u, t, c := p.usrDispatch(pc, recv); t != nil {
+		if slot, ok := c.lookup(t, &t.method); ok {
+			return newValUsrMethodFunction(u, t.methodFn[slot], name), nil
+		}
+	}
+	return recv.MethodClosure(name)
+}
@@ -0,0 +1,250 @@
+package pl
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// object dispatched via string switch
+type testSwitchObj struct {
+	n int64
+}
+
+func (c *testSwitchObj) Index(name Val) (Val, error) {
+	return c.Dot(name.String())
+}
+
+func (c *testSwitchObj) IndexSet(name Val, v Val) error {
+	return c.DotSet(name.String(), v)
+}
+
+func (c *testSwitchObj) Dot(name string) (Val, error) {
+	switch name {
+	case "n":
+		return NewValInt64(c.n), nil
+	case "slow":
+		return NewValStr("slow"), nil
+	default:
+		return NewValNull(), fmt.Errorf("unknown field %s", name)
+	}
+}
+
+func (c *testSwitchObj) DotSet(name string, v Val) error {
+	switch name {
+	case "n":
+		c.n = v.Int()
+		return nil
+	default:
+		return fmt.Errorf("unknown field %s", name)
+	}
+}
+
+func (c *testSwitchObj) Method(name string, args []Val) (Val, error) {
+	switch name {
+	case "add":
+		c.n += args[0].Int()
+		return NewValInt64(c.n), nil
+	default:
+		return NewValNull(), fmt.Errorf("unknown method %s", name)
+	}
+}
+
+func (c *testSwitchObj) ToString() (string, error) { return c.Info(), nil }
+func (c *testSwitchObj) ToJSON() (Val, error)      { return NewValInt64(c.n), nil }
+func (c *testSwitchObj) Id() string                { return "counter" }
+func (c *testSwitchObj) Info() string              { return "counter" }
+func (c *testSwitchObj) ToNative() interface{}     { return c }
+func (c *testSwitchObj) IsThreadSafe() bool        { return false }
+func (c *testSwitchObj) NewIterator() (Iter, error) {
+	return nil, fmt.Errorf("counter does not support iterator")
+}
+
+// the same object with dispatch table, the field slow is not registered
+type testFastObj struct {
+	testSwitchObj
+}
+
+var testObjDispatch = NewDispatchTable().
+	AddDot("n", func(u Usr) (Val, error) {
+		return NewValInt64(u.(*testFastObj).n), nil
+	}).
+	AddDotSet("n", func(u Usr, v Val) error {
+		u.(*testFastObj).n = v.Int()
+		return nil
+	}).
+	AddMethod("add", func(u Usr, args []Val) (Val, error) {
+		c := u.(*testFastObj)
+		c.n += args[0].Int()
+		return NewValInt64(c.n), nil
+	})
+
+func (c *testFastObj) Dispatch() *DispatchTable {
+	return testObjDispatch
+}
+
+func testObjEval(code string, counter Usr) (Val, error) {
+	module, err := CompileModule(code, nil)
+	if err != nil {
+		return NewValNull(), err
+	}
+	return testObjRun(module, counter)
+}
+
+func testObjRun(module *Module, counter Usr) (Val, error) {
+	eval := NewEvaluatorWithContextCallback(
+		func(_ *Evaluator, name string) (Val, error) {
+			if name == "counter" {
+				return NewValUsr(counter), nil
+			}
+			return NewValNull(), fmt.Errorf("%s unknown var", name)
+		},
+		nil,
+		nil,
+	)
+	return eval.Eval("test", module)
+}
+
+func TestInternName(t *testing.T) {
+	a := InternName("test.intern.a")
+	b := InternName("test.intern.b")
+	assert.NotEqual(t, a, b)
+	assert.Equal(t, a, InternName("test.intern.a"))
+	assert.Equal(t, "test.intern.b", InternedName(b))
+	assert.Equal(t, "", InternedName(-1))
+}
+
+func TestDispatchTable(t *testing.T) {
+	fn, ok := testObjDispatch.LookupDot("n")
+	assert.True(t, ok)
+	v, err := fn(&testFastObj{testSwitchObj{n: 3}})
+	assert.True(t, err == nil)
+	assert.Equal(t, int64(3), v.Int())
+
+	_, ok = testObjDispatch.LookupDot("slow")
+	assert.False(t, ok)
+	_, ok = testObjDispatch.LookupDotSet("n")
+	assert.True(t, ok)
+	_, ok = testObjDispatch.LookupMethod("add")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"add"}, testObjDispatch.MethodList())
+}
+
+func TestInlineCache(t *testing.T) {
+	code := `
+rule test {
+  for let i = 0; i < 10; i++ {
+    counter.n = counter.n + 1;
+    counter:add(2);
+  }
+  return [counter.n, counter.slow];
+}
+`
+	for _, c := range []Usr{&testSwitchObj{}, &testFastObj{}} {
+		v, err := testObjEval(code, c)
+		assert.True(t, err == nil, "%s", err)
+		assert.Equal(t, int64(30), v.List().Data[0].Int())
+		assert.Equal(t, "slow", v.List().Data[1].String())
+	}
+
+	// the same site sees different dispatch table, ie megamorphic, and the plain
+	// user type
+	module, err := CompileModule(`
+rule test {
+  counter:add(1);
+  return counter.n;
+}
+`, nil)
+	assert.True(t, err == nil)
+	module.Optimize(OptAll)
+
+	for i := 0; i < 3; i++ {
+		for _, c := range []Usr{&testFastObj{}, &testSwitchObj{}, &testOtherFastObj{}} {
+			v, err := testObjRun(module, c)
+			assert.True(t, err == nil, "%s", err)
+			assert.Equal(t, int64(1), v.Int())
+		}
+	}
+}
+
+// another dispatch table with the same names
+type testOtherFastObj struct {
+	testFastObj
+}
+
+var testOtherObjDispatch = NewDispatchTable().
+	AddDot("n", func(u Usr) (Val, error) {
+		return NewValInt64(u.(*testOtherFastObj).n), nil
+	}).
+	AddMethod("add", func(u Usr, args []Val) (Val, error) {
+		c := u.(*testOtherFastObj)
+		c.n += args[0].Int()
+		return NewValInt64(c.n), nil
+	})
+
+func (c *testOtherFastObj) Dispatch() *DispatchTable {
+	return testOtherObjDispatch
+}
+
+func TestInlineCacheConcurrent(t *testing.T) {
+	module, err := CompileModule(`
+rule test {
+  counter:add(1);
+  counter.n = counter.n * 2;
+  return counter.n;
+}
+`, nil)
+	assert.True(t, err == nil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				var c Usr
+				if (i+j)%2 == 0 {
+					c = &testFastObj{}
+				} else {
+					c = &testSwitchObj{}
+				}
+				v, err := testObjRun(module, c)
+				assert.True(t, err == nil, "%s", err)
+				assert.Equal(t, int64(2), v.Int())
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func BenchmarkInlineCache(b *testing.B) {
+	module, err := CompileModule(`
+rule test {
+  for let i = 0; i < 100; i++ {
+    counter.n = counter.n + 1;
+    counter:add(1);
+  }
+}
+`, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		name    string
+		counter Usr
+	}{
+		{"switch", &testSwitchObj{}},
+		{"dispatch", &testFastObj{}},
+	} {
+		b.Run(c.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := testObjRun(module, c.counter); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package pl
+
+import (
+	"fmt"
+)
+
+// One function frame of the runtime error's backtrace
+type Frame struct {
+	Name   string // rule, function name or the id of the native function
+	File   string // source file, empty for the main module and native function
+	Line   int
+	Column int
+}
+
+func (f Frame) String() string {
+	if f.Line == 0 {
+		return f.Name
+	}
+	if f.File != "" {
+		return fmt.Sprintf("%s (%s:%d:%d)", f.Name, f.File, f.Line, f.Column)
+	}
+	return fmt.Sprintf("%s (%d:%d)", f.Name, f.Line, f.Column)
+}
+
+// Error returned by the Evaluator when the script fails at runtime, it keeps
+// the frames that are on the stack when the error is raised, innermost first
+type Error struct {
+	Err       error
+	Backtrace []Frame
+
+	msg string
+}
+
+func (e *Error) Error() string {
+	return e.msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func newFrame(prog *program, pc int) Frame {
+	f := Frame{
+		Name: prog.name,
+		File: prog.file,
+	}
+	if pc >= 0 && pc < len(prog.dbgList) {
+		f.Line = prog.dbgList[pc].line
+		f.Column = prog.dbgList[pc].column
+	}
+	return f
+}
+
+// the pc of the innermost frame is not synchronized until the next call, so
+// its position comes from the program and pc that raise the error
+func newBacktrace(bt btlist, p *program, pc int) []Frame {
+	o := []Frame{}
+	for idx, ff := range bt {
+		if idx == 0 && p != nil && ff.prog == p {
+			o = append(o, newFrame(p, pc))
+		} else if ff.prog != nil {
+			o = append(o, newFrame(ff.prog, ff.pc))
+		} else if ff.closure != nil {
+			o = append(o, Frame{
+				Name: ff.closure.Id(),
+			})
+		}
+	}
+	return o
+}
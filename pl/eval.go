@@ -3,9 +3,12 @@ package pl
 import (
 	"bytes"
 	"fmt"
+	"io/fs"
 	"log"
 	"math"
 	"strings"
+	"sync/atomic"
+	"time"
 )
 
 const (
@@ -116,14 +119,45 @@ type Evaluator struct {
 	Config  EvalConfig
 	Event   EventContext
 
+	// profile of the script code, nil or disabled means no profiling
+	Profile *Profile
+
+	// coverage, if set and enabled, records the bytecode executed
+	Coverage *Coverage
+
 	// internal states -----------------------------------------------------------
 	// current frame, ie the one that is been executing
 	curframe     funcframe
 	curexcep     Val
 	eventQ       EventQueue
 	inEventQueue bool
+
+	// profiling state, only set while profiling the current rule
+	prof    *profState
+	profBuf *profState
+
+	// coverage state, only set while recording the current execution
+	cov    *covState
+	covBuf *covState
+
+	// module of the program been executed
+	module *Module
+
+	// tracing of the emitted events, nil means no tracing
+	Trace EventTracer
+
+	// whether the host runs the Evaluator in background, ie it is allowed to
+	// wait for the delayed event via WaitEventQueue. Only background Evaluator
+	// can emit delayed event
+	Background bool
+
+	// concrete name of the event been executed, the rule may be matched by a
+	// pattern of the event name
+	eventName string
 }
 
+var eventId uint64
+
 type exception struct {
 	// where should this exception goes to
 	handlerPc int
@@ -359,14 +393,28 @@ func (e *Evaluator) EventQueue() EventQueue {
 func (e *Evaluator) emitEvent(
 	name string,
 	context Val,
-) {
+) error {
+	return e.queueEvent(&Event{
+		Name:    name,
+		Context: context,
+	})
+}
+
+func (e *Evaluator) queueEvent(ev *Event) error {
 	must(e.eventQ != nil, "event queue must be setup")
-	e.eventQ.OnEvent(name, context)
+	ev.EmitAt = time.Now()
+	ev.Id = atomic.AddUint64(&eventId, 1)
+	ev.Emitter = e.eventName
+	if e.Trace != nil {
+		e.Trace.OnEmit(ev)
+	}
+	return e.eventQ.OnEvent(ev)
 }
 
-func (e *Evaluator) drainEventQueue(p *Module) {
+// returns false if the draining is stopped by the EventContext
+func (e *Evaluator) drainEventQueue(p *Module) bool {
 	if e.inEventQueue {
-		return
+		return true
 	}
 	e.inEventQueue = true
 	defer func() {
@@ -403,6 +451,7 @@ func (e *Evaluator) drainEventQueue(p *Module) {
 	if *statusp == EventContextStopAndClear {
 		e.eventQ.Clear()
 	}
+	return *statusp == EventContextContinue
 }
 
 func (e *Evaluator) pushExcep(pc int, stackSize int) {
@@ -428,9 +477,8 @@ func (e *Evaluator) localpos() int {
 
 func (e *Evaluator) prevfuncframe() *funcframe {
 	v := e.Stack[e.prevframepos()]
-	ff, ok := v.frame().(*funcframe)
-	must(ok, "unknown stack, corrupted? %s", v.Id())
-	return ff
+	must(v.isFrame(), "unknown stack, corrupted? %s", v.Id())
+	return v.frame()
 }
 
 func (e *Evaluator) popfuncframe(prev *funcframe) (int, *program) {
@@ -473,7 +521,7 @@ func NewEvaluatorWithContext(context EvalContext) *Evaluator {
 		Stack:   make([]Val, 0, defaultStackSize),
 		Session: nil,
 		Context: context,
-		eventQ:  &defEventQueue{},
+		eventQ:  NewEventQueue(EventOrderFIFO),
 	}
 }
 
@@ -491,7 +539,7 @@ func NewEvaluator(context EvalContext, config EvalConfig) *Evaluator {
 		Session: nil,
 		Context: context,
 		Config:  config,
-		eventQ:  &defEventQueue{},
+		eventQ:  NewEventQueue(EventOrderFIFO),
 	}
 }
 
@@ -552,7 +600,7 @@ func (e *Evaluator) localslot(arg int) int {
 }
 
 func mustReal(x Val) float64 {
-	if x.Type == ValInt {
+	if x.Type() == ValInt {
 		return float64(x.Int())
 	} else {
 		return x.Real()
@@ -575,11 +623,11 @@ func powI(n, m int64) int64 {
 func (e *Evaluator) doBin(lhs, rhs Val, op int) (Val, error) {
 	switch op {
 	case bcSub:
-		if lhs.Type == rhs.Type {
-			if lhs.Type == ValInt {
+		if lhs.Type() == rhs.Type() {
+			if lhs.Type() == ValInt {
 				return NewValInt64(lhs.Int() - rhs.Int()), nil
 			}
-			if lhs.Type == ValReal {
+			if lhs.Type() == ValReal {
 				return NewValReal(lhs.Real() - rhs.Real()), nil
 			}
 		} else if lhs.IsNumber() && rhs.IsNumber() {
@@ -588,11 +636,11 @@ func (e *Evaluator) doBin(lhs, rhs Val, op int) (Val, error) {
 		return NewValNull(), fmt.Errorf("invalid operand for -")
 
 	case bcMul:
-		if lhs.Type == rhs.Type {
-			if lhs.Type == ValInt {
+		if lhs.Type() == rhs.Type() {
+			if lhs.Type() == ValInt {
 				return NewValInt64(lhs.Int() * rhs.Int()), nil
 			}
-			if lhs.Type == ValReal {
+			if lhs.Type() == ValReal {
 				return NewValReal(lhs.Real() * rhs.Real()), nil
 			}
 		} else if lhs.IsNumber() && rhs.IsNumber() {
@@ -601,11 +649,11 @@ func (e *Evaluator) doBin(lhs, rhs Val, op int) (Val, error) {
 		return NewValNull(), fmt.Errorf("invalid operand for *")
 
 	case bcPow:
-		if lhs.Type == rhs.Type {
-			if lhs.Type == ValInt {
+		if lhs.Type() == rhs.Type() {
+			if lhs.Type() == ValInt {
 				return NewValInt64(powI(lhs.Int(), rhs.Int())), nil
 			}
-			if lhs.Type == ValReal {
+			if lhs.Type() == ValReal {
 				return NewValReal(math.Pow(lhs.Real(), rhs.Real())), nil
 			}
 		} else if lhs.IsNumber() && rhs.IsNumber() {
@@ -614,8 +662,8 @@ func (e *Evaluator) doBin(lhs, rhs Val, op int) (Val, error) {
 		return NewValNull(), fmt.Errorf("invalid operand for *")
 
 	case bcMod:
-		if lhs.Type == rhs.Type {
-			if lhs.Type == ValInt {
+		if lhs.Type() == rhs.Type() {
+			if lhs.Type() == ValInt {
 				if rhs.Int() == 0 {
 					return NewValNull(), fmt.Errorf("divide zero")
 				}
@@ -625,14 +673,14 @@ func (e *Evaluator) doBin(lhs, rhs Val, op int) (Val, error) {
 		return NewValNull(), fmt.Errorf("invalid operand for *")
 
 	case bcDiv:
-		if lhs.Type == rhs.Type {
-			if lhs.Type == ValInt {
+		if lhs.Type() == rhs.Type() {
+			if lhs.Type() == ValInt {
 				if rhs.Int() == 0 {
 					return NewValNull(), fmt.Errorf("divide zero")
 				}
 				return NewValInt64(lhs.Int() / rhs.Int()), nil
 			}
-			if lhs.Type == ValReal {
+			if lhs.Type() == ValReal {
 				return NewValReal(lhs.Real() / rhs.Real()), nil
 			}
 		} else if lhs.IsNumber() && rhs.IsNumber() {
@@ -641,19 +689,26 @@ func (e *Evaluator) doBin(lhs, rhs Val, op int) (Val, error) {
 		return NewValNull(), fmt.Errorf("invalid operand for *")
 
 	case bcAdd:
-		if lhs.Type == rhs.Type {
-			if lhs.Type == ValInt {
+		if lhs.Type() == rhs.Type() {
+			if lhs.Type() == ValInt {
 				return NewValInt64(lhs.Int() + rhs.Int()), nil
 			}
-			if lhs.Type == ValReal {
+			if lhs.Type() == ValReal {
 				return NewValReal(lhs.Real() + rhs.Real()), nil
 			}
-			if lhs.Type == ValStr {
+			if lhs.Type() == ValStr {
 				return NewValStr(lhs.String() + rhs.String()), nil
 			}
+			if lhs.Type() == ValBytes {
+				return NewValBytes(concatBytes(lhs.Bytes(), rhs.Bytes())), nil
+			}
 		} else if lhs.IsNumber() && rhs.IsNumber() {
 			return NewValReal(mustReal(lhs) + mustReal(rhs)), nil
-		} else if lhs.Type == ValStr || rhs.Type == ValStr {
+		} else if lhs.Type() == ValBytes && rhs.Type() == ValStr {
+			return NewValBytes(concatBytes(lhs.Bytes(), []byte(rhs.String()))), nil
+		} else if lhs.Type() == ValStr && rhs.Type() == ValBytes {
+			return NewValBytes(concatBytes([]byte(lhs.String()), rhs.Bytes())), nil
+		} else if lhs.Type() == ValStr || rhs.Type() == ValStr {
 			if lhsStr, e1 := lhs.ToString(); e1 == nil {
 				if rhsStr, e2 := rhs.ToString(); e2 == nil {
 					return NewValStr(lhsStr + rhsStr), nil
@@ -663,113 +718,135 @@ func (e *Evaluator) doBin(lhs, rhs Val, op int) (Val, error) {
 		return NewValNull(), fmt.Errorf("invalid operator for +")
 
 	case bcEq:
-		if lhs.Type == rhs.Type {
-			if lhs.Type == ValInt {
+		if lhs.Type() == rhs.Type() {
+			if lhs.Type() == ValInt {
 				return NewValBool(lhs.Int() == rhs.Int()), nil
 			}
-			if lhs.Type == ValReal {
+			if lhs.Type() == ValReal {
 				return NewValBool(lhs.Real() == rhs.Real()), nil
 			}
-			if lhs.Type == ValStr {
+			if lhs.Type() == ValStr {
 				return NewValBool(lhs.String() == rhs.String()), nil
 			}
+			if lhs.Type() == ValBytes {
+				return NewValBool(bytes.Compare(lhs.Bytes(), rhs.Bytes()) == 0), nil
+			}
 		} else if lhs.IsNumber() && rhs.IsNumber() {
 			return NewValBool(mustReal(lhs) == mustReal(rhs)), nil
 		}
 		return NewValNull(), fmt.Errorf("invalid operand for ==")
 
 	case bcNe:
-		if lhs.Type == rhs.Type {
-			if lhs.Type == ValInt {
+		if lhs.Type() == rhs.Type() {
+			if lhs.Type() == ValInt {
 				return NewValBool(lhs.Int() != rhs.Int()), nil
 			}
-			if lhs.Type == ValReal {
+			if lhs.Type() == ValReal {
 				return NewValBool(lhs.Real() != rhs.Real()), nil
 			}
-			if lhs.Type == ValStr {
+			if lhs.Type() == ValStr {
 				return NewValBool(lhs.String() != rhs.String()), nil
 			}
+			if lhs.Type() == ValBytes {
+				return NewValBool(bytes.Compare(lhs.Bytes(), rhs.Bytes()) != 0), nil
+			}
 		} else if lhs.IsNumber() && rhs.IsNumber() {
 			return NewValBool(mustReal(lhs) != mustReal(rhs)), nil
 		}
 		return NewValNull(), fmt.Errorf("invalid operand for !=")
 
 	case bcLt:
-		if lhs.Type == rhs.Type {
-			if lhs.Type == ValInt {
+		if lhs.Type() == rhs.Type() {
+			if lhs.Type() == ValInt {
 				return NewValBool(lhs.Int() < rhs.Int()), nil
 			}
-			if lhs.Type == ValReal {
+			if lhs.Type() == ValReal {
 				return NewValBool(lhs.Real() < rhs.Real()), nil
 			}
-			if lhs.Type == ValStr {
+			if lhs.Type() == ValStr {
 				return NewValBool(lhs.String() < rhs.String()), nil
 			}
+			if lhs.Type() == ValBytes {
+				return NewValBool(bytes.Compare(lhs.Bytes(), rhs.Bytes()) < 0), nil
+			}
 		} else if lhs.IsNumber() && rhs.IsNumber() {
 			return NewValBool(mustReal(lhs) < mustReal(rhs)), nil
 		}
 		return NewValNull(), fmt.Errorf("invalid operand for <")
 
 	case bcLe:
-		if lhs.Type == rhs.Type {
-			if lhs.Type == ValInt {
+		if lhs.Type() == rhs.Type() {
+			if lhs.Type() == ValInt {
 				return NewValBool(lhs.Int() <= rhs.Int()), nil
 			}
-			if lhs.Type == ValReal {
+			if lhs.Type() == ValReal {
 				return NewValBool(lhs.Real() <= rhs.Real()), nil
 			}
-			if lhs.Type == ValStr {
+			if lhs.Type() == ValStr {
 				return NewValBool(lhs.String() <= rhs.String()), nil
 			}
+			if lhs.Type() == ValBytes {
+				return NewValBool(bytes.Compare(lhs.Bytes(), rhs.Bytes()) <= 0), nil
+			}
 		} else if lhs.IsNumber() && rhs.IsNumber() {
 			return NewValBool(mustReal(lhs) <= mustReal(rhs)), nil
 		}
 		return NewValNull(), fmt.Errorf("invalid operand for <=")
 
 	case bcGt:
-		if lhs.Type == rhs.Type {
-			if lhs.Type == ValInt {
+		if lhs.Type() == rhs.Type() {
+			if lhs.Type() == ValInt {
 				return NewValBool(lhs.Int() > rhs.Int()), nil
 			}
-			if lhs.Type == ValReal {
+			if lhs.Type() == ValReal {
 				return NewValBool(lhs.Real() > rhs.Real()), nil
 			}
-			if lhs.Type == ValStr {
+			if lhs.Type() == ValStr {
 				return NewValBool(lhs.String() > rhs.String()), nil
 			}
+			if lhs.Type() == ValBytes {
+				return NewValBool(bytes.Compare(lhs.Bytes(), rhs.Bytes()) > 0), nil
+			}
 		} else if lhs.IsNumber() && rhs.IsNumber() {
 			return NewValBool(mustReal(lhs) > mustReal(rhs)), nil
 		}
 		return NewValNull(), fmt.Errorf("invalid operand for >")
 
 	case bcGe:
-		if lhs.Type == rhs.Type {
-			if lhs.Type == ValInt {
+		if lhs.Type() == rhs.Type() {
+			if lhs.Type() == ValInt {
 				return NewValBool(lhs.Int() >= rhs.Int()), nil
 			}
-			if lhs.Type == ValReal {
+			if lhs.Type() == ValReal {
 				return NewValBool(lhs.Real() >= rhs.Real()), nil
 			}
-			if lhs.Type == ValStr {
+			if lhs.Type() == ValStr {
 				return NewValBool(lhs.String() >= rhs.String()), nil
 			}
+			if lhs.Type() == ValBytes {
+				return NewValBool(bytes.Compare(lhs.Bytes(), rhs.Bytes()) >= 0), nil
+			}
 		} else if lhs.IsNumber() && rhs.IsNumber() {
 			return NewValBool(mustReal(lhs) >= mustReal(rhs)), nil
 		}
 		return NewValNull(), fmt.Errorf("invalid operand for >=")
 
 	case bcRegexpMatch:
-		if lhs.Type == ValStr && rhs.Type == ValRegexp {
+		if lhs.Type() == ValStr && rhs.Type() == ValRegexp {
 			r := rhs.Regexp().Match([]byte(lhs.String()))
 			return NewValBool(r), nil
+		} else if lhs.Type() == ValBytes && rhs.Type() == ValRegexp {
+			return NewValBool(rhs.Regexp().Match(lhs.Bytes())), nil
 		} else {
 			return NewValNull(), fmt.Errorf("regexp operator ~ must be applied on string and regexp")
 		}
 
 	case bcRegexpNMatch:
-		if lhs.Type == ValStr && rhs.Type == ValRegexp {
+		if lhs.Type() == ValStr && rhs.Type() == ValRegexp {
 			r := rhs.Regexp().Match([]byte(lhs.String()))
 			return NewValBool(!r), nil
+		} else if lhs.Type() == ValBytes && rhs.Type() == ValRegexp {
+			return NewValBool(!rhs.Regexp().Match(lhs.Bytes())), nil
 		} else {
 			return NewValNull(), fmt.Errorf("regexp operator !~ must be applied on string and regexp")
 		}
@@ -783,7 +860,7 @@ func (e *Evaluator) doBin(lhs, rhs Val, op int) (Val, error) {
 }
 
 func (e *Evaluator) doNegate(v Val) (Val, error) {
-	switch v.Type {
+	switch v.Type() {
 	case ValInt:
 		return NewValInt64(-v.Int()), nil
 	case ValReal:
@@ -815,13 +892,18 @@ func (e *Evaluator) backtrace(curframe *program, max int, bt btlist) string {
 
 // TODO(dpeng): Optimize diagnostic information
 func (e *Evaluator) doErr(bt btlist, p *program, pc int, err error) error {
+	x := &Error{
+		Err:       err,
+		Backtrace: newBacktrace(bt, p, pc),
+	}
 	if p != nil {
 		dbg := p.dbgList[pc]
-		return fmt.Errorf("symbol(%s), %s has error: %s\n%s",
+		x.msg = fmt.Sprintf("symbol(%s), %s has error: %s\n%s",
 			p.name, dbg.where(), err.Error(), e.backtrace(p, 10, bt))
 	} else {
-		return fmt.Errorf("symbol([native function]): %s", err.Error())
+		x.msg = fmt.Sprintf("symbol([native function]): %s", err.Error())
 	}
+	return x
 }
 
 // Return 3 tuple elements
@@ -888,12 +970,28 @@ func (e *Evaluator) runP(
 ) runresult {
 	module := prog.module
 
+	// record the module for the intrinsic function, restored once done since
+	// the closure of other module can be invoked from native code
+	if e.module != module {
+		prev := e.module
+		e.module = module
+		defer func() {
+			e.module = prev
+		}()
+	}
+
 	// script function entry label, the bcSCall will setup stack layout and
 	// jump(goto) this label for rexecution. prog will be swapped with the
 	// function program
 FUNC:
 	for ; ; pc++ {
 		bc := prog.bcList[pc]
+		if e.prof != nil {
+			e.prof.onBytecode(e)
+		}
+		if e.cov != nil {
+			e.cov.onBytecode(prog, pc)
+		}
 
 		switch bc.opcode {
 		case bcAction:
@@ -1041,6 +1139,10 @@ FUNC:
 			e.push(NewValRegexp(prog.idxRegexp(bc.argument)))
 			break
 
+		case bcLoadBytes:
+			e.push(NewValBytes([]byte(prog.idxStr(bc.argument))))
+			break
+
 		case bcLoadTrue:
 			e.push(NewValBool(true))
 			break
@@ -1060,7 +1162,7 @@ FUNC:
 		case bcAddList:
 			cnt := bc.argument
 			l := e.topN(cnt)
-			must(l.Type == ValList, "must be list")
+			must(l.Type() == ValList, "must be list")
 			for ii := len(e.Stack) - cnt; ii < len(e.Stack); ii++ {
 				l.AddList(e.Stack[ii])
 			}
@@ -1074,10 +1176,10 @@ FUNC:
 		case bcAddMap:
 			cnt := bc.argument
 			m := e.topN(cnt * 2)
-			must(m.Type == ValMap, "must be map")
+			must(m.Type() == ValMap, "must be map")
 			for ii := len(e.Stack) - cnt*2; ii < len(e.Stack); {
 				name := e.Stack[ii]
-				must(name.Type == ValStr, "must be string")
+				must(name.Type() == ValStr, "must be string")
 				val := e.Stack[ii+1]
 				m.AddMap(name.String(), val)
 				ii = ii + 2
@@ -1102,7 +1204,7 @@ FUNC:
 
 			funcIndex := e.topN(paramSize)
 
-			must(funcIndex.Type == ValInt,
+			must(funcIndex.Type() == ValInt,
 				fmt.Sprintf("function indext must be indext but %s", funcIndex.Id()))
 
 			must(funcIndex.Int() >= 0,
@@ -1130,7 +1232,7 @@ FUNC:
 			e.pop()
 
 			name := prog.idxStr(bc.argument)
-			method, err := recv.MethodClosure(name)
+			method, err := prog.icMethodClosure(pc, recv, name)
 			if err != nil {
 				return rrErr(prog, pc, err)
 			}
@@ -1142,6 +1244,7 @@ FUNC:
 		case bcSCall, bcVCall:
 			paramSize := bc.argument
 			funcIndexOrEntry := e.topN(paramSize)
+			caller := prog
 
 			var nfunc *nativeFunc
 			var mfunc *methodFunc
@@ -1190,6 +1293,12 @@ FUNC:
 				}
 			}
 
+			// checked before the frame of callee is setup, so the error is reported
+			// at the call site
+			if prog != nil && paramSize != prog.argSize {
+				return rrErrf(caller, pc, "script function call, argument number mismatch")
+			}
+
 			e.curframe.pc = pc
 			e.prologue(
 				ftype,
@@ -1199,9 +1308,6 @@ FUNC:
 			)
 
 			if prog != nil {
-				if paramSize != prog.argSize {
-					return rrErrf(prog, pc, "script function call, argument number mismatch")
-				}
 				// make sure to reset the PC when entering into the new function
 				pc = 0
 
@@ -1229,8 +1335,8 @@ FUNC:
 				} else {
 					// method function
 					must(mfunc != nil, "method must existed")
-					if val, err := mfunc.entry(
-						mfunc.name,
+					if val, err := mfunc.Call(
+						e,
 						args,
 					); err != nil {
 						return rrErr(prog, pc, err)
@@ -1302,7 +1408,7 @@ FUNC:
 			var b bytes.Buffer
 			for ii := len(e.Stack) - sz; ii < len(e.Stack); ii++ {
 				v := e.Stack[ii]
-				must(v.Type == ValStr, "must be string during concatenation")
+				must(v.Type() == ValStr, "must be string during concatenation")
 				b.WriteString(v.String())
 			}
 			e.popN(sz)
@@ -1364,7 +1470,7 @@ FUNC:
 		case bcDot:
 			ee := e.top0()
 			vname := prog.idxStr(bc.argument)
-			val, err := ee.Dot(vname)
+			val, err := prog.icDot(pc, ee, vname)
 			if err != nil {
 				return rrErr(prog, pc, err)
 			}
@@ -1377,7 +1483,7 @@ FUNC:
 			value := e.top0()
 			e.popN(2)
 
-			if err := recv.DotSet(prog.idxStr(bc.argument), value); err != nil {
+			if err := prog.icDotSet(pc, recv, prog.idxStr(bc.argument), value); err != nil {
 				return rrErr(prog, pc, err)
 			}
 			break
@@ -1400,6 +1506,16 @@ FUNC:
 			e.push(e.Stack[e.localslot(bc.argument)])
 			break
 
+		case bcLoadLocalDot:
+			local, field := unpackLocalDot(bc.argument)
+			ee := e.Stack[e.localslot(local)]
+			val, err := prog.icDot(pc, ee, prog.idxStr(field))
+			if err != nil {
+				return rrErr(prog, pc, err)
+			}
+			e.push(val)
+			break
+
 		// special functions, ie intrinsic
 		case bcTemplate:
 			ctx := e.top0()
@@ -1669,10 +1785,12 @@ FUNC:
 			e.popN(2)
 			must(name.IsString(), "event name must be string")
 
-			e.emitEvent(
+			if err := e.emitEvent(
 				name.String(),
 				context,
-			)
+			); err != nil {
+				return rrErr(prog, pc, err)
+			}
 			break
 
 		default:
@@ -1842,6 +1960,13 @@ func (e *Evaluator) iterPrologue(siter *scriptIter, args []Val) {
 func (e *Evaluator) runRule(event Val, prog *program) (Val, error) {
 	must(e.Context != nil, "Evaluator's context is nil!")
 
+	if e.profBegin() {
+		defer e.profEnd()
+	}
+	if e.covBegin() {
+		defer e.covEnd()
+	}
+
 	// just clear the stack size if needed before every run, since we need to reuse
 	// this evaluator
 	e.clearStack()
@@ -2018,6 +2143,11 @@ func (e *Evaluator) runSFunc(
 		return NewValNull(), fmt.Errorf("function call, argument mismatch")
 	}
 
+	// script function invoked by the embedder directly, ie outside of rule
+	if e.covBegin() {
+		defer e.covEnd()
+	}
+
 	// performing arguments shuffling here, ie move user provided function
 	// arguments into our own stack and create a valid frame for script function
 	e.closurePrologue(newValSFunc(sfunc), args)
@@ -2087,6 +2217,15 @@ func (e *Evaluator) runNFunc(
 	return ret, err
 }
 
+// Read a file from the file system of the module been executed, ie the
+// manifest's FS. Used by intrinsic function which loads file at runtime
+func (e *Evaluator) ReadFile(path string) ([]byte, error) {
+	if e.module == nil || e.module.fs == nil {
+		return nil, fmt.Errorf("file system is not available, cannot read %s", path)
+	}
+	return fs.ReadFile(e.module.fs, path)
+}
+
 func (e *Evaluator) EvalConfig(p *Module) error {
 	if !p.HasConfig() {
 		return nil
@@ -2098,6 +2237,41 @@ func (e *Evaluator) EvalConfig(p *Module) error {
 	return err
 }
 
+// Run the rules of the event, rule whose guard is not satisfied is skipped.
+// With RuleMatchFirst only the first matched rule is executed, otherwise all
+// the matched rules are executed and the last one's result is returned
+func (e *Evaluator) runEvent(event string, context Val, p *Module) (Val, error) {
+	saved := e.eventName
+	e.eventName = event
+	defer func() {
+		e.eventName = saved
+	}()
+
+	ret := NewValNull()
+	for _, prog := range p.findEvent(event) {
+		if prog.guard != nil {
+			ok, err := e.runRule(context, prog.guard)
+			if err != nil {
+				return NewValNull(), err
+			}
+			if !ok.ToBoolean() {
+				continue
+			}
+		}
+
+		v, err := e.runRule(context, prog)
+		if err != nil {
+			return NewValNull(), err
+		}
+		ret = v
+
+		if p.ruleMatch == RuleMatchFirst {
+			break
+		}
+	}
+	return ret, nil
+}
+
 func (e *Evaluator) EvalGlobal(p *Module) error {
 	defer func() {
 		e.drainEventQueue(p)
@@ -2139,11 +2313,7 @@ func (e *Evaluator) Eval(event string, p *Module) (Val, error) {
 		e.drainEventQueue(p)
 	}()
 
-	if prog := p.findEvent(event); prog != nil {
-		return e.runRule(NewValNull(), prog)
-	} else {
-		return NewValNull(), nil
-	}
+	return e.runEvent(event, NewValNull(), p)
 }
 
 func (e *Evaluator) EvalWithContext(event string, context Val, p *Module) (Val, error) {
@@ -2151,31 +2321,33 @@ func (e *Evaluator) EvalWithContext(event string, context Val, p *Module) (Val,
 		e.drainEventQueue(p)
 	}()
 
-	if prog := p.findEvent(event); prog != nil {
-		return e.runRule(context, prog)
-	} else {
-		return NewValNull(), nil
+	return e.runEvent(event, context, p)
+}
+
+// Run the event from the event queue, used by EventQueue.Drain. It does NOT
+// drain the event queue again, which prevents it from being called recursively
+func (e *Evaluator) EvalEvent(ev *Event, p *Module) (Val, error) {
+	if e.Trace == nil {
+		return e.EvalDeferred(ev.Name, ev.Context, p)
 	}
+
+	start := time.Now()
+	v, err := e.EvalDeferred(ev.Name, ev.Context, p)
+	e.Trace.OnHandle(ev, time.Since(start), err)
+	return v, err
 }
 
-// Notes, this must be used for evaluation of event queue event since inside of
-// this function, it will NOT issue event queue call again which prevent from
-// been called recursively
 func (e *Evaluator) EvalDeferred(
 	name string,
 	context Val,
 	p *Module,
 ) (Val, error) {
-	if prog := p.findEvent(name); prog != nil {
-		return e.runRule(context, prog)
-	} else {
-		return NewValNull(), nil
-	}
+	return e.runEvent(name, context, p)
 }
 
 func (e *Evaluator) EmitEvent(
 	name string,
 	context Val,
-) {
-	e.emitEvent(name, context)
+) error {
+	return e.emitEvent(name, context)
 }
@@ -0,0 +1,173 @@
+package pl
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// run the event with the context, and collect all the output action
+func testEvalGuard(module *Module, event string, context Val) ([]string, Val, error) {
+	out := []string{}
+	eval := NewEvaluatorWithContextCallback(
+		nil,
+		nil,
+		func(_ *Evaluator, aname string, aval Val) error {
+			if aname == "output" {
+				out = append(out, aval.String())
+			}
+			return nil
+		})
+
+	v, err := eval.EvalWithContext(event, context, module)
+	return out, v, err
+}
+
+func testGuardContext(method, path string) Val {
+	m := NewValMap()
+	m.AddMap("method", NewValStr(method))
+	m.AddMap("path", NewValStr(path))
+	return m
+}
+
+func TestRuleGuard(t *testing.T) {
+	assert := assert.New(t)
+
+	module, err := CompileModule(`
+rule "http.request" when $.method == "GET" {
+  output => "get";
+}
+
+rule "http.request"
+when $.method == "POST" &&
+     $.path == "/upload"
+{
+  output => "upload";
+}
+
+rule "http.request" => {
+  output => "default";
+}
+`, nil)
+	assert.True(err == nil, "%s", err)
+	assert.Equal([]string{"http.request"}, module.RuleList())
+
+	for _, c := range []struct {
+		method string
+		path   string
+		expect string
+	}{
+		{"GET", "/", "get"},
+		{"POST", "/upload", "upload"},
+		{"POST", "/", "default"},
+		{"PUT", "/upload", "default"},
+	} {
+		out, _, err := testEvalGuard(module, "http.request", testGuardContext(c.method, c.path))
+		assert.True(err == nil, "%s", err)
+		assert.Equal([]string{c.expect}, out)
+	}
+
+	// all match mode, the matched rules are executed in declaration order
+	module.SetRuleMatch(RuleMatchAll)
+	out, _, err := testEvalGuard(module, "http.request", testGuardContext("GET", "/"))
+	assert.True(err == nil, "%s", err)
+	assert.Equal([]string{"get", "default"}, out)
+
+	// no rule matched
+	out, v, err := testEvalGuard(module, "other", NewValNull())
+	assert.True(err == nil, "%s", err)
+	assert.Equal(0, len(out))
+	assert.True(v.IsNull())
+}
+
+func TestRulePriority(t *testing.T) {
+	assert := assert.New(t)
+
+	module, err := CompileModule(`
+rule test {
+  return "a";
+}
+rule test priority 10 when $ == 1 {
+  return "b";
+}
+rule test priority -1 {
+  return "c";
+}
+rule test when $ == 2 priority 10 {
+  return "d";
+}
+`, nil)
+	assert.True(err == nil, "%s", err)
+
+	for _, c := range []struct {
+		context int
+		expect  string
+	}{
+		{1, "b"},
+		{2, "d"},
+		{3, "a"},
+	} {
+		_, v, err := testEvalGuard(module, "test", NewValInt(c.context))
+		assert.True(err == nil, "%s", err)
+		assert.Equal(c.expect, v.String())
+	}
+
+	// the last matched rule's result is returned
+	module.SetRuleMatch(RuleMatchAll)
+	_, v, err := testEvalGuard(module, "test", NewValInt(1))
+	assert.True(err == nil, "%s", err)
+	assert.Equal("c", v.String())
+
+	// guard works with the optimizer as well
+	module.SetRuleMatch(RuleMatchFirst)
+	module.Optimize(OptAll)
+	_, v, err = testEvalGuard(module, "test", NewValInt(2))
+	assert.True(err == nil, "%s", err)
+	assert.Equal("d", v.String())
+}
+
+func TestRuleGuardError(t *testing.T) {
+	assert := assert.New(t)
+
+	// error raised by the guard is reported as rule's error
+	module, err := CompileModule(`
+rule test when $.a.b == 1 {
+  return 1;
+}
+`, nil)
+	assert.True(err == nil, "%s", err)
+	_, _, err = testEvalGuard(module, "test", NewValInt(1))
+	assert.True(err != nil)
+
+	for _, code := range []string{
+		`rule test when { }`,
+		`rule test when $ == 1 when $ == 2 { }`,
+		`rule test priority "a" { }`,
+		`rule test priority 1.0 { }`,
+		`rule test unless $ == 1 { }`,
+	} {
+		_, err := CompileModule(code, nil)
+		assert.True(err != nil, code)
+	}
+}
+
+func TestRuleGuardDump(t *testing.T) {
+	assert := assert.New(t)
+
+	module, err := CompileModule(`
+rule dd
+
+when $ == "dd" && // matching the event name
+     $ != "ee"
+
+priority 2 {
+}
+`, nil)
+	assert.True(err == nil, "%s", err)
+
+	dump := module.Dump()
+	assert.True(strings.Contains(dump, `:when ($ == "dd" && // matching the event name
+     $ != "ee") priority 2`), dump)
+	assert.True(strings.Contains(dump, ":program (dd#when)"), dump)
+}
@@ -1,17 +1,57 @@
 package pl
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
 	"testing"
+	"testing/fstest"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
+// optimization passes applied to the module compiled by the test, used by the
+// differential test in optimize_test.go to run the same cases with optimizer
+var testOptimize = OptNone
+
+func testCompileModule(code string, fs fs.FS) (*Module, error) {
+	module, err := CompileModule(code, fs)
+	if err != nil {
+		return nil, err
+	}
+	module.Optimize(testOptimize)
+	return module, nil
+}
+
 // a simple testing frameworking which is designed for testing the basic
 // expression of the module. It assumes user will write output => as return
-// action
+// action. When optimization is enabled, the result must be the same as the
+// result of the plain module
 func test(code string) (Val, bool) {
+	if testOptimize == OptNone {
+		return testWith(code, OptNone)
+	}
+
+	expect, ok1 := testWith(code, OptNone)
+	got, ok2 := testWith(code, testOptimize)
+	if ok1 != ok2 || testDumpVal(expect) != testDumpVal(got) {
+		fmt.Printf(":optimize mismatch, expect %s(%t), got %s(%t)\n",
+			testDumpVal(expect), ok1, testDumpVal(got), ok2)
+		return NewValNull(), false
+	}
+	return got, ok2
+}
+
+func testDumpVal(v Val) string {
+	str, err := v.ToString()
+	if err != nil {
+		return v.Info()
+	}
+	return v.Info() + str
+}
+
+func testWith(code string, opt int) (Val, bool) {
 	rr := NewValNull()
 	ret := &rr
 	eval := NewEvaluatorWithContextCallback(
@@ -43,6 +83,7 @@ func test(code string) (Val, bool) {
 		fmt.Printf(":module \n%s", err.Error())
 		return NewValNull(), false
 	}
+	module.Optimize(opt)
 
 	err = eval.EvalSession(module)
 	if err != nil {
@@ -64,7 +105,7 @@ func testInt64(code string, expect int64) bool {
 		return false
 	}
 
-	if val.Type != ValInt {
+	if val.Type() != ValInt {
 		fmt.Printf(":return invalid type %s\n", val.Id())
 		return false
 	}
@@ -83,7 +124,7 @@ func testInt(code string, expect int) bool {
 		return false
 	}
 
-	if val.Type != ValInt {
+	if val.Type() != ValInt {
 		fmt.Printf(":return invalid type %s\n", val.Id())
 		return false
 	}
@@ -102,7 +143,7 @@ func testReal(code string, expect float64) bool {
 		return false
 	}
 
-	if val.Type != ValReal {
+	if val.Type() != ValReal {
 		fmt.Printf(":return invalid type %s\n", val.Id())
 		return false
 	}
@@ -121,7 +162,7 @@ func testBool(code string, expect bool) bool {
 		return false
 	}
 
-	if val.Type != ValBool {
+	if val.Type() != ValBool {
 		fmt.Printf(":return invalid type %s\n", val.Id())
 		return false
 	}
@@ -154,7 +195,7 @@ func testNull(code string) bool {
 		return false
 	}
 
-	if val.Type != ValNull {
+	if val.Type() != ValNull {
 		fmt.Printf(":return invalid type %s\n", val.Id())
 		return false
 	}
@@ -168,7 +209,7 @@ func testString(code string, expect string) bool {
 		return false
 	}
 
-	if val.Type != ValStr {
+	if val.Type() != ValStr {
 		fmt.Printf(":return invalid type %s\n", val.Id())
 		return false
 	}
@@ -190,7 +231,7 @@ func (a *actionOutput) intAt(idx string, val int) bool {
 		return false
 	}
 
-	if x.Type != ValInt {
+	if x.Type() != ValInt {
 		fmt.Printf("actionOutput(%s) not int", idx)
 		return false
 	}
@@ -210,7 +251,7 @@ func (a *actionOutput) realAt(idx string, val float64) bool {
 		return false
 	}
 
-	if x.Type != ValReal {
+	if x.Type() != ValReal {
 		fmt.Printf("actionOutput(%s) not real", idx)
 		return false
 	}
@@ -230,7 +271,7 @@ func (a *actionOutput) boolAt(idx string, val bool) bool {
 		return false
 	}
 
-	if x.Type != ValBool {
+	if x.Type() != ValBool {
 		fmt.Printf("actionOutput(%s) not bool", idx)
 		return false
 	}
@@ -250,7 +291,7 @@ func (a *actionOutput) strAt(idx string, val string) bool {
 		return false
 	}
 
-	if x.Type != ValStr {
+	if x.Type() != ValStr {
 		fmt.Printf("actionOutput(%s) not string", idx)
 		return false
 	}
@@ -270,7 +311,7 @@ func (a *actionOutput) nullAt(idx string) bool {
 		return false
 	}
 
-	if x.Type != ValNull {
+	if x.Type() != ValNull {
 		fmt.Printf("actionOutput(%s) not null", idx)
 		return false
 	}
@@ -284,7 +325,7 @@ func (a *actionOutput) listAt(idx string) *List {
 		return nil
 	}
 
-	if x.Type != ValList {
+	if x.Type() != ValList {
 		fmt.Printf("actionOutput(%s) not list", idx)
 		return nil
 	}
@@ -298,7 +339,7 @@ func (a *actionOutput) mapAt(idx string) *Map {
 		return nil
 	}
 
-	if x.Type != ValMap {
+	if x.Type() != ValMap {
 		fmt.Printf("actionOutput(%s) not map", idx)
 		return nil
 	}
@@ -330,6 +371,48 @@ func TestTryExpr(t *testing.T) {
 	  }
 	  `, "hello world"))
 
+	// argument mismatch of a callee shorter than the caller
+	assert.True(testString(
+		`
+	  fn short() {}
+	  test{
+	    let a = 1;
+	    let b = 2;
+	    let c = try short(a, b) else "mismatch";
+	    output => c;
+	  }
+	  `, "mismatch"))
+
+	{
+		_, ok := test(
+			`
+	  fn short() {}
+	  test{
+	    let a = 1;
+	    let b = 2;
+	    short(a, b, a, b);
+	  }
+	  `)
+		assert.False(ok)
+	}
+
+	// reported at the call site, the frame of callee is not setup
+	{
+		module, err := testCompileModule(`
+fn short() {}
+test {
+  short(1, 2);
+}
+`, nil)
+		assert.True(err == nil)
+		_, err = NewEvaluatorSimple().Eval("test", module)
+		var perr *Error
+		if assert.True(errors.As(err, &perr)) {
+			assert.Equal("script function call, argument number mismatch", perr.Err.Error())
+			assert.Equal([]Frame{{Name: "test", Line: 4, Column: 15}}, perr.Backtrace)
+		}
+	}
+
 	assert.True(testString(
 		`
 	  test{
@@ -660,7 +743,7 @@ func TestEval1(t *testing.T) {
 						"abs",
 						func(args []Val) (Val, error) {
 							a0 := args[0]
-							must(a0.Type == ValInt, "must be int")
+							must(a0.Type() == ValInt, "must be int")
 							return NewValInt64(-a0.Int()), nil
 						},
 					), nil
@@ -673,7 +756,7 @@ func TestEval1(t *testing.T) {
 				return nil
 			})
 
-		module, err := CompileModule(
+		module, err := testCompileModule(
 			`
 mm => {
   act_int => 10;
@@ -726,31 +809,31 @@ mm => {
 			assert.Equal(l.Length(), 7, "length")
 			{
 				e := l.Data[0]
-				assert.True(e.Type == ValInt)
+				assert.True(e.Type() == ValInt)
 				assert.Equal(e.Int(), int64(1), "int")
 			}
 			{
 				e := l.Data[1]
-				assert.True(e.Type == ValBool)
+				assert.True(e.Type() == ValBool)
 				assert.Equal(e.Bool(), true, "bool")
 			}
 			{
 				e := l.Data[2]
-				assert.True(e.Type == ValReal)
+				assert.True(e.Type() == ValReal)
 				assert.Equal(e.Real(), 3.0, "real")
 			}
 			{
 				e := l.Data[3]
-				assert.True(e.Type == ValStr)
+				assert.True(e.Type() == ValStr)
 				assert.Equal(e.String(), "Hello World", "str")
 			}
 			{
 				e := l.Data[5]
-				assert.True(e.Type == ValNull)
+				assert.True(e.Type() == ValNull)
 			}
 			{
 				e := l.Data[6]
-				assert.True(e.Type == ValList)
+				assert.True(e.Type() == ValList)
 				assert.True(e.List() != nil)
 				assert.True(len(e.List().Data) == 0)
 			}
@@ -760,7 +843,7 @@ mm => {
 			assert.Equal(m.Length(), 1)
 			v, ok := m.Get("a")
 			assert.True(ok)
-			assert.Equal(v.Type, ValStr, "map.type")
+			assert.Equal(v.Type(), ValStr, "map.type")
 			assert.Equal(v.String(), "Hello World")
 		}
 	}
@@ -779,7 +862,7 @@ mm => {
 						"abs",
 						func(args []Val) (Val, error) {
 							a0 := args[0]
-							must(a0.Type == ValInt, "must be int")
+							must(a0.Type() == ValInt, "must be int")
 							return NewValInt64(-a0.Int()), nil
 						},
 					), nil
@@ -793,7 +876,7 @@ mm => {
 				return nil
 			})
 
-		module, err := CompileModule(
+		module, err := testCompileModule(
 			`
 mm {
   empty_list => [];
@@ -838,31 +921,31 @@ mm {
 			l := output.listAt("list1")
 			assert.True(l != nil)
 			assert.True(l.Length() == 1)
-			assert.True(l.Data[0].Type == ValInt)
+			assert.True(l.Data[0].Type() == ValInt)
 			assert.True(l.Data[0].Int() == 1)
 		}
 		{
 			l := output.listAt("list2")
 			assert.True(l != nil)
 			assert.True(len(l.Data) == 2)
-			assert.True(l.Data[0].Type == ValInt)
+			assert.True(l.Data[0].Type() == ValInt)
 			assert.True(l.Data[0].Int() == 1)
-			assert.True(l.Data[1].Type == ValBool)
+			assert.True(l.Data[1].Type() == ValBool)
 			assert.True(l.Data[1].Bool())
 		}
 		{
 			l := output.listAt("list3")
 			assert.True(l != nil)
 			assert.True(len(l.Data) == 3)
-			assert.True(l.Data[0].Type == ValInt)
+			assert.True(l.Data[0].Type() == ValInt)
 			assert.True(l.Data[0].Int() == 1)
 
-			assert.True(l.Data[1].Type == ValStr)
+			assert.True(l.Data[1].Type() == ValStr)
 			assert.True(l.Data[1].String() == "Hello World")
 
-			assert.True(l.Data[2].Type == ValList)
+			assert.True(l.Data[2].Type() == ValList)
 			assert.True(l.Data[2].List() != nil)
-			assert.True(l.Data[2].List().Data[0].Type == ValInt)
+			assert.True(l.Data[2].List().Data[0].Type() == ValInt)
 			assert.True(l.Data[2].List().Data[0].Int() == 1)
 		}
 
@@ -878,7 +961,7 @@ mm {
 
 			v0, ok := l.Get("a")
 			assert.True(ok)
-			assert.True(v0.Type == ValMap)
+			assert.True(v0.Type() == ValMap)
 		}
 		{
 			l := output.mapAt("map2")
@@ -887,7 +970,7 @@ mm {
 
 			v1, ok := l.Get("b")
 			assert.True(ok)
-			assert.True(v1.Type == ValBool)
+			assert.True(v1.Type() == ValBool)
 			assert.True(v1.Bool())
 		}
 
@@ -898,15 +981,15 @@ mm {
 
 			v0, ok := l.Get("a")
 			assert.True(ok)
-			assert.True(v0.Type == ValMap)
+			assert.True(v0.Type() == ValMap)
 
 			v00, ok := v0.Map().Get("b")
 			assert.True(ok)
-			assert.True(v00.Type == ValMap)
+			assert.True(v00.Type() == ValMap)
 
 			v000, ok := v00.Map().Get("c")
 			assert.True(ok)
-			assert.True(v000.Type == ValInt)
+			assert.True(v000.Type() == ValInt)
 			assert.True(v000.Int() == 1)
 		}
 	}
@@ -927,7 +1010,7 @@ func TestStrInterpo(t *testing.T) {
 						"abs",
 						func(args []Val) (Val, error) {
 							a0 := args[0]
-							must(a0.Type == ValInt, "must be int")
+							must(a0.Type() == ValInt, "must be int")
 							return NewValInt64(-a0.Int()), nil
 						},
 					), nil
@@ -941,7 +1024,7 @@ func TestStrInterpo(t *testing.T) {
 				return nil
 			})
 
-		module, err := CompileModule(
+		module, err := testCompileModule(
 			`
 // whatever module
 "mm" => {
@@ -981,7 +1064,7 @@ func TestLocal(t *testing.T) {
 						"abs",
 						func(args []Val) (Val, error) {
 							a0 := args[0]
-							must(a0.Type == ValInt, "must be int")
+							must(a0.Type() == ValInt, "must be int")
 							return NewValInt64(-a0.Int()), nil
 						},
 					), nil
@@ -994,7 +1077,7 @@ func TestLocal(t *testing.T) {
 				return nil
 			})
 
-		module, err := CompileModule(
+		module, err := testCompileModule(
 			`
 // whatever module
 "mm" => {
@@ -1832,7 +1915,7 @@ test {
 }
 `
 
-	module, err := CompileModule(code, nil)
+	module, err := testCompileModule(code, nil)
 
 	// fmt.Printf(":code\n%s", module.Dump())
 
@@ -1841,7 +1924,7 @@ test {
 	_, err = eval.Eval("test", module)
 	assert.True(err == nil)
 
-	assert.True(ret.Type == ValStr)
+	assert.True(ret.Type() == ValStr)
 	assert.True(ret.String() == "Hello World")
 }
 
@@ -2099,3 +2182,58 @@ test {
 `, "1000"))
 
 }
+
+func TestErrorBacktrace(t *testing.T) {
+	assert := assert.New(t)
+	module, err := CompileModule(`
+import (
+  "mod.m"
+)
+fn outer() {
+  mod::inner(0);
+}
+rule test {
+  outer();
+}
+`, fstest.MapFS{
+		"mod.m": &fstest.MapFile{
+			Data: []byte(`module mod
+fn inner(x) {
+  assert::eq(x, 1);
+}
+`),
+		},
+	})
+	if !assert.True(err == nil, "%s", err) {
+		return
+	}
+
+	eval := NewEvaluatorSimple()
+	_, err = eval.Eval("test", module)
+	assert.True(err != nil)
+
+	var perr *Error
+	if assert.True(errors.As(err, &perr)) {
+		assert.Contains(perr.Err.Error(), "assert::eq")
+		assert.Equal([]Frame{
+			{Name: "mod::inner", File: "mod.m", Line: 3, Column: 20},
+			{Name: "outer", Line: 6, Column: 17},
+			{Name: "test", Line: 9, Column: 11},
+		}, perr.Backtrace)
+		assert.Equal("mod::inner (mod.m:3:20)", perr.Backtrace[0].String())
+		assert.Equal(err.Error(), perr.Error())
+	}
+
+	// error raised without a call, the innermost frame is the failed one
+	module, err = CompileModule(`
+test {
+  let a = 1;
+  a();
+}
+`, nil)
+	assert.True(err == nil, "%s", err)
+	_, err = NewEvaluatorSimple().Eval("test", module)
+	if assert.True(errors.As(err, &perr)) {
+		assert.Equal([]Frame{{Name: "test", Line: 4, Column: 7}}, perr.Backtrace)
+	}
+}
@@ -0,0 +1,167 @@
+package pl
+
+import (
+	"sort"
+	"strings"
+)
+
+// Rule name can be a glob pattern of event name. Event name is separated into
+// segments by '.', and the pattern supports
+//
+//   *  matches any characters inside of one segment, ie redis.H* matches
+//      redis.HGET but not redis.H.GET
+//   ** matches any characters including '.', ie concate.** matches
+//      concate.background.check
+//
+// Wildcard never matches the segment starts with '@' which is the builtin
+// event, ie redis.* does not match redis.@accept.
+//
+// Exact rule name always wins, otherwise the most specific pattern wins, ie
+// the pattern has more literal characters, then fewer ** and then fewer *.
+// Patterns with the same specificity are tried in declaration order.
+//
+// All the patterns are compiled into a trie, matching an event name walks the
+// trie along the name and only the wildcard node needs to try different split
+// of the name.
+
+func isEventPattern(name string) bool {
+	return strings.IndexByte(name, '*') >= 0
+}
+
+type eventPattern struct {
+	name    string
+	literal int
+	star    int
+	dstar   int
+	order   int
+}
+
+// whether pattern a is more specific than pattern b
+func (a *eventPattern) before(b *eventPattern) bool {
+	if a.literal != b.literal {
+		return a.literal > b.literal
+	}
+	if a.dstar != b.dstar {
+		return a.dstar < b.dstar
+	}
+	if a.star != b.star {
+		return a.star < b.star
+	}
+	return a.order < b.order
+}
+
+type eventNode struct {
+	child   map[byte]*eventNode
+	star    *eventNode
+	dstar   *eventNode
+	pattern *eventPattern
+}
+
+type eventTrie struct {
+	root eventNode
+	size int
+}
+
+func (t *eventTrie) add(name string) {
+	p := &eventPattern{
+		name:  name,
+		order: t.size,
+	}
+	n := &t.root
+
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case c == '*' && i+1 < len(name) && name[i+1] == '*':
+			if n.dstar == nil {
+				n.dstar = &eventNode{}
+			}
+			n = n.dstar
+			p.dstar++
+			i++
+			break
+
+		case c == '*':
+			if n.star == nil {
+				n.star = &eventNode{}
+			}
+			n = n.star
+			p.star++
+			break
+
+		default:
+			if n.child == nil {
+				n.child = make(map[byte]*eventNode)
+			}
+			x, ok := n.child[c]
+			if !ok {
+				x = &eventNode{}
+				n.child[c] = x
+			}
+			n = x
+			p.literal++
+			break
+		}
+	}
+
+	if n.pattern == nil {
+		n.pattern = p
+		t.size++
+	}
+}
+
+func (n *eventNode) match(name string, i int, out []*eventPattern) []*eventPattern {
+	if i == len(name) && n.pattern != nil {
+		out = appendEventPattern(out, n.pattern)
+	}
+
+	if n.star != nil {
+		for j := i; ; j++ {
+			out = n.star.match(name, j, out)
+			if j == len(name) || name[j] == '.' || isBuiltinSegment(name, j) {
+				break
+			}
+		}
+	}
+
+	if n.dstar != nil {
+		for j := i; ; j++ {
+			out = n.dstar.match(name, j, out)
+			if j == len(name) || isBuiltinSegment(name, j) {
+				break
+			}
+		}
+	}
+
+	if i < len(name) {
+		if x, ok := n.child[name[i]]; ok {
+			out = x.match(name, i+1, out)
+		}
+	}
+	return out
+}
+
+func isBuiltinSegment(name string, i int) bool {
+	return name[i] == '@' && (i == 0 || name[i-1] == '.')
+}
+
+func appendEventPattern(out []*eventPattern, p *eventPattern) []*eventPattern {
+	for _, x := range out {
+		if x == p {
+			return out
+		}
+	}
+	return append(out, p)
+}
+
+// all the patterns matched the name, the most specific one comes first
+func (t *eventTrie) match(name string) []*eventPattern {
+	if t.size == 0 {
+		return nil
+	}
+	out := t.root.match(name, 0, nil)
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].before(out[j])
+	})
+	return out
+}
@@ -0,0 +1,125 @@
+package pl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testEventMatch(patterns []string, name string) []string {
+	t := eventTrie{}
+	for _, p := range patterns {
+		t.add(p)
+	}
+	o := []string{}
+	for _, x := range t.match(name) {
+		o = append(o, x.name)
+	}
+	return o
+}
+
+func TestEventTrie(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, c := range []struct {
+		pattern string
+		name    string
+		match   bool
+	}{
+		{"redis.*", "redis.GET", true},
+		{"redis.*", "redis.", true},
+		{"redis.*", "redis", false},
+		{"redis.*", "redis.a.b", false},
+		{"redis.*", "redis.@accept", false},
+		{"redis.H*", "redis.HGET", true},
+		{"redis.H*", "redis.GET", false},
+		{"redis.*GET", "redis.HGET", true},
+		{"redis.*GET", "redis.HSET", false},
+		{"*.sign", "body_sign.sign", true},
+		{"body_sign.*", "body_sign.sign", true},
+		{"concate.**", "concate.background.check", true},
+		{"concate.**", "concate.response", true},
+		{"concate.**", "concat.response", false},
+		{"concate.**", "concate.@close", false},
+		{"**", "a.b.c", true},
+		{"a.**.d", "a.b.c.d", true},
+		{"a.**.d", "a.b.c.e", false},
+	} {
+		m := testEventMatch([]string{c.pattern}, c.name)
+		assert.Equal(c.match, len(m) == 1, "%s %s", c.pattern, c.name)
+	}
+
+	// most specific first
+	assert.Equal(
+		[]string{"redis.H*", "redis.*", "redis.**", "**"},
+		testEventMatch([]string{"**", "redis.**", "redis.*", "redis.H*", "redis.S*"}, "redis.HGET"),
+	)
+
+	// same specificity in declaration order
+	assert.Equal(
+		[]string{"*.b", "a.*"},
+		testEventMatch([]string{"*.b", "a.*"}, "a.b"),
+	)
+	assert.Equal(
+		[]string{"body_sign.*", "*.sign"},
+		testEventMatch([]string{"*.sign", "body_sign.*"}, "body_sign.sign"),
+	)
+}
+
+func TestEventPatternRule(t *testing.T) {
+	assert := assert.New(t)
+
+	module, err := CompileModule(`
+rule "redis.HGET" {
+  output => "hget";
+  return "hget:" + event_name();
+}
+rule "redis.H*" {
+  output => "h";
+  return "h:" + event_name();
+}
+rule "redis.*" when $ == 1 {
+  return "guard:" + event_name();
+}
+rule "redis.*" {
+  output => "any";
+  return "any:" + event_name();
+}
+rule "concate.**" {
+  emit "redis.SET", 1;
+  return "concate:" + event_name();
+}
+`, nil)
+	assert.True(err == nil, "%s", err)
+
+	assert.True(module.HasEvent("redis.GET"))
+	assert.True(module.HasEvent("concate.a.b"))
+	assert.False(module.HasEvent("redis.@accept"))
+	assert.False(module.HasEvent("other"))
+	assert.True(module.HasExactEvent("redis.HGET"))
+	assert.False(module.HasExactEvent("redis.HSET"))
+	assert.False(module.HasExactEvent("redis.GET"))
+
+	for _, c := range []struct {
+		event   string
+		context int
+		expect  string
+	}{
+		{"redis.HGET", 0, "hget:redis.HGET"},
+		{"redis.HSET", 0, "h:redis.HSET"},
+		{"redis.GET", 0, "any:redis.GET"},
+		{"redis.GET", 1, "guard:redis.GET"},
+		{"concate.background.check", 0, "concate:concate.background.check"},
+	} {
+		_, v, err := testEvalGuard(module, c.event, NewValInt(c.context))
+		assert.True(err == nil, "%s", err)
+		assert.Equal(c.expect, v.String())
+	}
+
+	// all match mode executes from the most specific rule
+	module.SetRuleMatch(RuleMatchAll)
+	out, v, err := testEvalGuard(module, "redis.HGET", NewValInt(0))
+	assert.True(err == nil, "%s", err)
+	assert.Equal([]string{"hget", "h", "any"}, out)
+	assert.Equal("any:redis.HGET", v.String())
+}
@@ -1,15 +1,69 @@
 package pl
 
-// event is been pushed into the queue for execution and it is not been executed
-// at once but deferred its execution in event queue.
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Event is emitted by the script, ie emit statement, emit_after and
+// emit_priority, and it is not been executed at once but deferred its
+// execution in event queue.
+type Event struct {
+	Name    string
+	Context Val
+
+	// higher priority runs first when the queue is ordered by priority
+	Priority int64
+
+	// delayed event is not executed until the delay elapsed since it is emitted
+	Delay  time.Duration
+	EmitAt time.Time
+
+	// unique id of the event inside of the process, and name of the event been
+	// executed when it is emitted. Used for pairing emission and handling
+	Id      uint64
+	Emitter string
+}
+
+// time when the event can be executed
+func (ev *Event) Due() time.Time {
+	return ev.EmitAt.Add(ev.Delay)
+}
+
+const (
+	// events are executed in the order they are emitted
+	EventOrderFIFO = iota
+
+	// events are executed from the highest priority, and the order they are
+	// emitted for the same priority
+	EventOrderPriority
+)
+
+// Event order by its name used by the vhost config, ie "fifo" or "priority",
+// empty name is fifo
+func ParseEventOrder(name string) (int, error) {
+	switch name {
+	case "", "fifo":
+		return EventOrderFIFO, nil
+	case "priority":
+		return EventOrderPriority, nil
+	default:
+		return EventOrderFIFO, fmt.Errorf("unknown event order %s, expect fifo or priority", name)
+	}
+}
+
 type EventQueue interface {
-	// invoked when a event is emitted, the first argument is the event name and
-	// the second is its corresponding context
-	OnEvent(string, Val) error
+	// invoked when a event is emitted
+	OnEvent(*Event) error
 
-	// Drain the event queue
+	// Drain the event queue, the delayed event which is not due yet is kept
 	Drain(*Evaluator, *Module, func(string, error) bool) int
 
+	// Duration until the next delayed event is due, false if there's no delayed
+	// event pending
+	NextDelay() (time.Duration, bool)
+
 	// Size of the queue that is pending
 	PendingSize() int
 
@@ -17,39 +71,58 @@ type EventQueue interface {
 	Clear() int
 }
 
-type eventEntry struct {
-	name    string
-	context Val
+type defEventQueue struct {
+	order int
+	q     []*Event
 }
 
-type defEventQueue struct {
-	q []eventEntry
+func NewEventQueue(order int) EventQueue {
+	return &defEventQueue{
+		order: order,
+	}
 }
 
-func (d *defEventQueue) OnEvent(n string, v Val) error {
-	d.q = append(d.q, eventEntry{
-		name:    n,
-		context: v,
-	})
+func (d *defEventQueue) OnEvent(ev *Event) error {
+	d.q = append(d.q, ev)
 	return nil
 }
 
+// index of the next event to run, -1 if no event is due
+func (d *defEventQueue) next(now time.Time) int {
+	idx := -1
+	for i, ev := range d.q {
+		if ev.Delay > 0 && ev.Due().After(now) {
+			continue
+		}
+		if d.order == EventOrderFIFO {
+			return i
+		}
+		if idx == -1 || ev.Priority > d.q[idx].Priority {
+			idx = i
+		}
+	}
+	return idx
+}
+
 func (d *defEventQueue) Drain(ev *Evaluator,
 	p *Module,
 	onError func(string, error) bool,
 ) int {
 	count := 0
 
-	for len(d.q) != 0 {
-		sz := len(d.q)
-		last := d.q[sz-1]
-		d.q = d.q[:sz-1]
+	for {
+		idx := d.next(time.Now())
+		if idx == -1 {
+			break
+		}
+		x := d.q[idx]
+		d.q = append(d.q[:idx], d.q[idx+1:]...)
 		count++
 
-		_, err := ev.EvalDeferred(last.name, last.context, p)
+		_, err := ev.EvalEvent(x, p)
 
 		if !onError(
-			last.name,
+			x.Name,
 			err,
 		) {
 			break
@@ -58,12 +131,55 @@ func (d *defEventQueue) Drain(ev *Evaluator,
 	return count
 }
 
+func (d *defEventQueue) NextDelay() (time.Duration, bool) {
+	var due time.Time
+	found := false
+	for _, ev := range d.q {
+		if !found || ev.Due().Before(due) {
+			due = ev.Due()
+			found = true
+		}
+	}
+	if !found {
+		return 0, false
+	}
+	if x := time.Until(due); x > 0 {
+		return x, true
+	}
+	return 0, true
+}
+
 func (d *defEventQueue) PendingSize() int {
 	return len(d.q)
 }
 
 func (d *defEventQueue) Clear() int {
 	x := len(d.q)
-	d.q = []eventEntry{}
+	d.q = nil
 	return x
 }
+
+// Wait for all the pending events, including the delayed one, to be executed.
+// Only the host which runs the Evaluator in background should wait, see
+// Evaluator.Background
+func (e *Evaluator) WaitEventQueue(ctx context.Context, p *Module) error {
+	for {
+		if !e.drainEventQueue(p) {
+			return nil
+		}
+
+		delay, ok := e.eventQ.NextDelay()
+		if !ok {
+			return nil
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+			break
+		}
+	}
+}
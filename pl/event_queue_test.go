@@ -0,0 +1,179 @@
+package pl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testEventCode = `
+rule test {
+  emit "a", 1;
+  emit_priority(10, "b", 2);
+  emit "c";
+  emit_priority(-1, "d");
+}
+
+rule delay {
+  emit_after(30, "late", "x");
+  emit "now";
+}
+
+rule "*" {
+  output => event_name();
+  if event_name() == "c" {
+    emit "e";
+  }
+}
+`
+
+func testEventEval(t *testing.T, module *Module, event string, setup func(*Evaluator)) (*Evaluator, *[]string) {
+	out := []string{}
+	eval := NewEvaluatorWithContextCallback(
+		nil,
+		nil,
+		func(_ *Evaluator, aname string, aval Val) error {
+			out = append(out, aval.String())
+			return nil
+		})
+	if setup != nil {
+		setup(eval)
+	}
+	_, err := eval.Eval(event, module)
+	assert.True(t, err == nil, "%s", err)
+	return eval, &out
+}
+
+func TestEventQueueOrder(t *testing.T) {
+	module, err := CompileModule(testEventCode, nil)
+	assert.True(t, err == nil, "%s", err)
+
+	// FIFO by default, event emitted while draining is appended
+	_, out := testEventEval(t, module, "test", nil)
+	assert.Equal(t, []string{"a", "b", "c", "d", "e"}, *out)
+
+	// priority order, the same priority is FIFO
+	_, out = testEventEval(t, module, "test", func(e *Evaluator) {
+		e.SetEventQueue(NewEventQueue(EventOrderPriority))
+	})
+	assert.Equal(t, []string{"b", "a", "c", "e", "d"}, *out)
+}
+
+func TestEventQueueDelay(t *testing.T) {
+	assert := assert.New(t)
+
+	module, err := CompileModule(testEventCode, nil)
+	assert.True(err == nil, "%s", err)
+
+	// not allowed unless running in background
+	_, err = NewEvaluatorSimple().Eval("delay", module)
+	assert.True(err != nil)
+
+	start := time.Now()
+	eval, out := testEventEval(t, module, "delay", func(e *Evaluator) {
+		e.Background = true
+	})
+	assert.Equal([]string{"now"}, *out)
+	assert.Equal(1, eval.EventQueue().PendingSize())
+
+	assert.True(eval.WaitEventQueue(context.Background(), module) == nil)
+	assert.Equal([]string{"now", "late"}, *out)
+	assert.Equal(0, eval.EventQueue().PendingSize())
+	assert.True(time.Since(start) >= 30*time.Millisecond)
+
+	// cancelled
+	eval, out = testEventEval(t, module, "delay", func(e *Evaluator) {
+		e.Background = true
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(context.Canceled, eval.WaitEventQueue(ctx, module))
+	assert.Equal([]string{"now"}, *out)
+	assert.Equal(1, eval.EventQueue().PendingSize())
+}
+
+func TestEventTrace(t *testing.T) {
+	assert := assert.New(t)
+
+	module, err := CompileModule(testEventCode, nil)
+	assert.True(err == nil, "%s", err)
+
+	trace := NewEventTrace(4)
+	testEventEval(t, module, "test", func(e *Evaluator) {
+		e.Trace = trace
+	})
+
+	// the oldest one is dropped
+	records := trace.Records()
+	assert.Equal(4, len(records))
+
+	names := []string{}
+	for _, r := range records {
+		names = append(names, r.Name)
+		assert.True(r.Handled)
+		assert.True(r.Error == nil)
+		assert.False(r.HandleAt.Before(r.EmitAt))
+	}
+	assert.Equal([]string{"b", "c", "d", "e"}, names)
+	assert.Equal("test", records[0].Emitter)
+	assert.Equal(int64(10), records[0].Priority)
+	assert.Equal("c", records[3].Emitter)
+	assert.True(records[0].Id < records[3].Id)
+	assert.True(strings.Contains(trace.Dump(), "e emitted by c"), trace.Dump())
+
+	trace.Reset()
+	assert.Equal(0, len(trace.Records()))
+}
+
+// rejects all the event
+type testFullEventQueue struct {
+	EventQueue
+}
+
+func (q *testFullEventQueue) OnEvent(*Event) error {
+	return fmt.Errorf("event queue is full")
+}
+
+func TestEventQueueError(t *testing.T) {
+	assert := assert.New(t)
+
+	module, err := CompileModule(`
+rule test {
+  let a = 1;
+  emit "a", a;
+}
+`, nil)
+	if !assert.True(err == nil, "%s", err) {
+		return
+	}
+
+	eval := NewEvaluatorSimple()
+	eval.SetEventQueue(&testFullEventQueue{NewEventQueue(EventOrderFIFO)})
+	_, err = eval.Eval("test", module)
+	var perr *Error
+	if assert.True(errors.As(err, &perr)) {
+		assert.Equal("event queue is full", perr.Err.Error())
+		assert.Equal(4, perr.Backtrace[0].Line)
+	}
+	assert.Equal("event queue is full", eval.EmitEvent("c", NewValNull()).Error())
+}
+
+func TestParseEventOrder(t *testing.T) {
+	assert := assert.New(t)
+	for name, order := range map[string]int{
+		"":         EventOrderFIFO,
+		"fifo":     EventOrderFIFO,
+		"priority": EventOrderPriority,
+	} {
+		x, err := ParseEventOrder(name)
+		assert.True(err == nil)
+		assert.Equal(order, x, name)
+	}
+	_, err := ParseEventOrder("lifo")
+	assert.Equal("unknown event order lifo, expect fifo or priority", err.Error())
+}
@@ -0,0 +1,128 @@
+package pl
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Hook of the event queue for debugging, OnEmit is invoked when the event is
+// queued and OnHandle is invoked once the event's rules are executed. The
+// same Event object is passed to both, ie Event.Id pairs them.
+type EventTracer interface {
+	OnEmit(*Event)
+	OnHandle(*Event, time.Duration, error)
+}
+
+type EventTraceRecord struct {
+	Id       uint64
+	Name     string
+	Emitter  string
+	Priority int64
+	Delay    time.Duration
+	EmitAt   time.Time
+
+	// set once the event is handled
+	Handled  bool
+	HandleAt time.Time
+	Elapsed  time.Duration
+	Error    error
+}
+
+func (r *EventTraceRecord) String() string {
+	emitter := r.Emitter
+	if emitter == "" {
+		emitter = "<host>"
+	}
+	status := "pending"
+	if r.Handled {
+		status = fmt.Sprintf("handled after %s, took %s",
+			r.HandleAt.Sub(r.EmitAt), r.Elapsed)
+		if r.Error != nil {
+			status += fmt.Sprintf(", error: %s", r.Error.Error())
+		}
+	}
+	return fmt.Sprintf("#%d %s emitted by %s (priority %d, delay %s), %s",
+		r.Id, r.Name, emitter, r.Priority, r.Delay, status)
+}
+
+// EventTrace records the emission and handling pair of the latest events, it
+// can be shared by multiple Evaluator
+type EventTrace struct {
+	sync.Mutex
+	limit   int
+	records []*EventTraceRecord
+	index   map[uint64]*EventTraceRecord
+}
+
+// limit is the max number of records kept, the oldest one is dropped
+func NewEventTrace(limit int) *EventTrace {
+	return &EventTrace{
+		limit: limit,
+		index: make(map[uint64]*EventTraceRecord),
+	}
+}
+
+func (t *EventTrace) OnEmit(ev *Event) {
+	t.Lock()
+	defer t.Unlock()
+
+	r := &EventTraceRecord{
+		Id:       ev.Id,
+		Name:     ev.Name,
+		Emitter:  ev.Emitter,
+		Priority: ev.Priority,
+		Delay:    ev.Delay,
+		EmitAt:   ev.EmitAt,
+	}
+	t.records = append(t.records, r)
+	t.index[r.Id] = r
+
+	if t.limit > 0 && len(t.records) > t.limit {
+		delete(t.index, t.records[0].Id)
+		t.records = t.records[1:]
+	}
+}
+
+func (t *EventTrace) OnHandle(ev *Event, elapsed time.Duration, err error) {
+	t.Lock()
+	defer t.Unlock()
+
+	r, ok := t.index[ev.Id]
+	if !ok {
+		return
+	}
+	r.Handled = true
+	r.HandleAt = time.Now().Add(-elapsed)
+	r.Elapsed = elapsed
+	r.Error = err
+}
+
+// snapshot of the records in emission order
+func (t *EventTrace) Records() []EventTraceRecord {
+	t.Lock()
+	defer t.Unlock()
+
+	o := make([]EventTraceRecord, 0, len(t.records))
+	for _, r := range t.records {
+		o = append(o, *r)
+	}
+	return o
+}
+
+func (t *EventTrace) Reset() {
+	t.Lock()
+	defer t.Unlock()
+	t.records = nil
+	t.index = make(map[uint64]*EventTraceRecord)
+}
+
+func (t *EventTrace) Dump() string {
+	var b bytes.Buffer
+	for _, r := range t.Records() {
+		b.WriteString(r.String())
+		b.WriteRune('\n')
+	}
+	return b.String()
+}
@@ -0,0 +1,628 @@
+package pl
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// A simple source code formatter for PL. The formatter works on top of a
+// lossless token stream generated by the lexer, ie each token carries its raw
+// source text and all the comments/line breaks appear before it. Since PL's
+// parser directly generates bytecode without any AST, the formatter does not
+// try to understand the full grammar but just uses the bracket structure and
+// few local heuristics to decide the layout. The canonical style is as
+// following :
+//
+//   1) 2 spaces indentation, nested by brackets
+//   2) opening brace of a block stays at the end of its header line, K&R style
+//   3) else/elif stays at the same line with the closing brace
+//   4) one statement per line inside of a multiple line block
+//   5) at most one blank line, no blank line after { or before }
+//   6) comments are always preserved
+//
+// The user's line break is respected, ie the formatter never tries to join
+// or split a long expression by its own.
+
+type fmtComment struct {
+	text string
+	nl   int // line breaks before the comment
+}
+
+func (c *fmtComment) isLine() bool {
+	return strings.HasPrefix(c.text, "//")
+}
+
+type fmtToken struct {
+	tk       int
+	text     string
+	comments []fmtComment
+	nl       int // line breaks between the last comment(or token) and the token
+}
+
+// scan the gap between 2 tokens, the gap can only contain whitespace and
+// comments since the lexer has validated them already
+func fmtTrivia(gap []rune) ([]fmtComment, int) {
+	var out []fmtComment
+	nl := 0
+	for i := 0; i < len(gap); i++ {
+		c := gap[i]
+		if c == '\n' {
+			nl++
+			continue
+		}
+		if c != '/' || i+1 >= len(gap) {
+			continue
+		}
+
+		start := i
+		switch gap[i+1] {
+		case '/':
+			for i < len(gap) && gap[i] != '\n' {
+				i++
+			}
+			out = append(out, fmtComment{
+				text: strings.TrimRight(string(gap[start:i]), " \t\r\v"),
+				nl:   nl,
+			})
+			nl = 0
+			// leave the line break to be counted by the loop
+			i--
+			break
+
+		case '*':
+			i += 2
+			for i+1 < len(gap) && !(gap[i] == '*' && gap[i+1] == '/') {
+				i++
+			}
+			i++
+			out = append(out, fmtComment{
+				text: string(gap[start : i+1]),
+				nl:   nl,
+			})
+			nl = 0
+			break
+
+		default:
+			break
+		}
+	}
+	return out, nl
+}
+
+func fmtTokenText(l *lexer, tk int, start int) string {
+	switch tk {
+	case tkSId:
+		return "session::" + l.valueText
+	case tkGId:
+		return "global::" + l.valueText
+	case tkDId:
+		return "dynamic::" + l.valueText
+	case tkEId:
+		return "extern::" + l.valueText
+	default:
+		return string(l.input[start:l.cursor])
+	}
+}
+
+func fmtTokenize(src string) ([]fmtToken, error) {
+	l := newLexer(src)
+	prev := 0
+	out := []fmtToken{}
+
+	for {
+		tk := l.next()
+		if tk == tkError {
+			return nil, l.toError()
+		}
+
+		start := l.tokenStart
+		if tk == tkEof {
+			start = len(l.input)
+		}
+
+		x := fmtToken{
+			tk: tk,
+		}
+		x.comments, x.nl = fmtTrivia(l.input[prev:start])
+
+		if tk == tkEof {
+			out = append(out, x)
+			break
+		}
+
+		x.text = fmtTokenText(l, tk, start)
+		out = append(out, x)
+		prev = l.cursor
+	}
+
+	return out, nil
+}
+
+const (
+	fmtParen = iota
+	fmtParam
+	fmtBlock
+	fmtMap
+)
+
+type fmtBracket struct {
+	kind   int
+	indent int  // indentation of the line that opens the bracket
+	multi  bool // whether the bracket's content starts at a new line
+	key    bool // map only, whether a key is expected
+	quest  int  // pending ternary '?' inside of the bracket
+}
+
+const (
+	fmtColonMethod = iota
+	fmtColonMap
+	fmtColonTernary
+	fmtColonFunc
+)
+
+type formatter struct {
+	tks []fmtToken
+
+	out  bytes.Buffer
+	line bytes.Buffer
+
+	stack []fmtBracket
+
+	// state of current pending line
+	indent    int
+	firstTk   int
+	lineTk    int
+	lineEmpty bool
+
+	// state of last flushed line
+	lastTk    int
+	blank     bool
+	comment   bool // whether a line comment is pending at the end of line
+	forHeader bool
+
+	// previous token and its classification
+	prev2     int
+	prev      int
+	prevKind  int
+	prevUnary bool
+	prevText  string
+}
+
+// Format reformats the PL source code into canonical style. The formatted
+// code is guaranteed to have exactly the same token stream as the input.
+func Format(src string) (string, error) {
+	tks, err := fmtTokenize(src)
+	if err != nil {
+		return "", err
+	}
+
+	f := &formatter{
+		tks:       tks,
+		lineEmpty: true,
+		prev:      -1,
+		prev2:     -1,
+		stack: []fmtBracket{
+			fmtBracket{
+				kind:   fmtBlock,
+				indent: -1,
+				multi:  true,
+			},
+		},
+	}
+	f.run()
+
+	output := f.out.String()
+	if err := fmtVerify(tks, output); err != nil {
+		return "", err
+	}
+	return output, nil
+}
+
+// make sure the formatter does not change anything beyond layout
+func fmtVerify(expect []fmtToken, output string) error {
+	tks, err := fmtTokenize(output)
+	if err != nil {
+		return fmt.Errorf("format: invalid output, %s", err.Error())
+	}
+	if len(tks) != len(expect) {
+		return fmt.Errorf("format: token stream mismatched, expect %d tokens, got %d",
+			len(expect), len(tks))
+	}
+	for idx, x := range expect {
+		y := tks[idx]
+		if x.tk != y.tk || x.text != y.text || len(x.comments) != len(y.comments) {
+			return fmt.Errorf("format: token stream mismatched at %s(%s)",
+				getTokenName(x.tk), x.text)
+		}
+		for cidx, c := range x.comments {
+			if c.text != y.comments[cidx].text {
+				return fmt.Errorf("format: comment mismatched: %s", c.text)
+			}
+		}
+	}
+	return nil
+}
+
+func fmtIsCloser(tk int) bool {
+	switch tk {
+	case tkRPar, tkRSqr, tkRBra:
+		return true
+	default:
+		return false
+	}
+}
+
+func fmtIsOpener(tk int) bool {
+	switch tk {
+	case tkLPar, tkLSqr, tkLBra, tkLExprBra:
+		return true
+	default:
+		return false
+	}
+}
+
+// whether the token can be the end of an expression
+func fmtIsExprEnd(tk int) bool {
+	switch tk {
+	case tkId, tkSId, tkGId, tkDId, tkEId,
+		tkInt, tkReal, tkStr, tkBytes, tkMStr, tkRegex, tkRId,
+		tkDollar, tkTrue, tkFalse, tkNull,
+		tkRPar, tkRSqr, tkRBra:
+		return true
+	default:
+		return false
+	}
+}
+
+func fmtIsBinary(tk int) bool {
+	switch tk {
+	case tkAdd, tkSub, tkMul, tkDiv, tkMod, tkPow,
+		tkLt, tkLe, tkGt, tkGe, tkEq, tkNe,
+		tkRegexpMatch, tkRegexpNMatch, tkAnd, tkOr, tkPipe:
+		return true
+	default:
+		return false
+	}
+}
+
+// whether a line ends with the token should have its following line indented
+// as a continuation
+func fmtIsContinuation(tk int) bool {
+	return fmtIsBinary(tk) || isassign(tk) && !isaggassign(tk) ||
+		tk == tkArrow || tk == tkQuest
+}
+
+// a '{' following these tokens is a map literal, otherwise it is a block. The
+// ++ and -- are statements, ie for let i = 0; i < n; i++ { ... }
+func fmtIsMapPrefix(tk int) bool {
+	if fmtIsBinary(tk) || isassign(tk) && tk != tkInc && tk != tkDec {
+		return true
+	}
+	switch tk {
+	case tkLPar, tkLSqr, tkComma, tkColon, tkQuest, tkNot, tkReturn, tkYield:
+		return true
+	default:
+		return false
+	}
+}
+
+func (f *formatter) top() *fmtBracket {
+	return &f.stack[len(f.stack)-1]
+}
+
+func (f *formatter) flush() {
+	if f.lineEmpty {
+		return
+	}
+	if f.blank && f.out.Len() > 0 && !fmtIsOpener(f.lastTk) && !fmtIsCloser(f.firstTk) {
+		f.out.WriteString("\n")
+	}
+	for i := 0; i < f.indent; i++ {
+		f.out.WriteString("  ")
+	}
+	f.out.Write(f.line.Bytes())
+	f.out.WriteString("\n")
+
+	f.lastTk = f.lineTk
+	f.line.Reset()
+	f.lineEmpty = true
+	f.blank = false
+	f.comment = false
+}
+
+func (f *formatter) newline(nl int) {
+	f.flush()
+	if nl >= 2 {
+		f.blank = true
+	}
+}
+
+func (f *formatter) startLine(tk int) {
+	top := f.top()
+	indent := top.indent + 1
+	if fmtIsCloser(tk) {
+		indent = top.indent
+		if indent < 0 {
+			indent = 0
+		}
+	} else if f.lastTk == tkTry {
+		// nested try expression, ie try try foo else bar else 10
+		indent = f.indent + 1
+	} else if fmtIsContinuation(f.lastTk) {
+		indent++
+	}
+
+	f.indent = indent
+	f.firstTk = tk
+	f.lineTk = -1
+	f.lineEmpty = false
+}
+
+func (f *formatter) write(tk int, text string, space bool) {
+	if f.lineEmpty {
+		f.startLine(tk)
+	} else if space {
+		f.line.WriteString(" ")
+	}
+	f.line.WriteString(text)
+	f.lineTk = tk
+}
+
+func (f *formatter) writeComment(c fmtComment) {
+	if c.nl == 0 && !f.lineEmpty && !f.comment {
+		f.line.WriteString(" ")
+		f.line.WriteString(c.text)
+	} else {
+		f.newline(c.nl)
+		f.startLine(-1)
+		f.line.WriteString(c.text)
+	}
+	if c.isLine() {
+		f.comment = true
+	}
+}
+
+func (f *formatter) isMulti(idx int) bool {
+	if idx+1 >= len(f.tks) {
+		return false
+	}
+	next := f.tks[idx+1]
+	if len(next.comments) > 0 {
+		c := next.comments[0]
+		return c.nl > 0 || c.isLine() || len(next.comments) > 1 || next.nl > 0
+	}
+	return next.nl > 0
+}
+
+// whether the block started at idx contains at least n statements, ie
+// semicolons at its own nesting level. Such block is always expanded one
+// statement per line. A block directly inside of an expanded block expands
+// with any statement, otherwise, ie fn() { return 1; }(), it may stay inline
+// with a single statement
+func (f *formatter) hasStatement(idx int, n int) bool {
+	depth := 0
+	stmt := 0
+	for _, t := range f.tks[idx+1:] {
+		switch {
+		case fmtIsOpener(t.tk):
+			depth++
+		case fmtIsCloser(t.tk):
+			if depth == 0 {
+				return false
+			}
+			depth--
+		case t.tk == tkSemicolon && depth == 0:
+			if stmt++; stmt >= n {
+				return true
+			}
+		case t.tk == tkEof:
+			return false
+		}
+	}
+	return false
+}
+
+func (f *formatter) colonKind() int {
+	// shortcut function, ie fn(a): a + 1
+	if f.prev == tkRPar && f.prevKind == fmtParam {
+		return fmtColonFunc
+	}
+
+	top := f.top()
+	if top.quest > 0 {
+		top.quest--
+		return fmtColonTernary
+	}
+	if top.kind == fmtMap && top.key {
+		top.key = false
+		return fmtColonMap
+	}
+	return fmtColonMethod
+}
+
+// whether the previous token ends an expression, a postfix ++/-- does as well,
+// ie a-- - 1
+func (f *formatter) isExprEnd() bool {
+	if f.prev == tkInc || f.prev == tkDec {
+		return !f.prevUnary
+	}
+	return fmtIsExprEnd(f.prev)
+}
+
+func (f *formatter) space(tk int, kind int, unary bool) bool {
+	prev := f.prev
+
+	switch prev {
+	case tkLPar, tkLSqr, tkDot, tkScope, tkAt, tkSharp, tkNot:
+		return false
+	case tkLBra:
+		if tk == tkRBra {
+			return false
+		}
+		return f.prevKind == fmtBlock
+	case tkColon:
+		return f.prevKind != fmtColonMethod
+	case tkFunction, tkIterator:
+		return tk != tkLPar
+	case tkAdd, tkSub, tkInc, tkDec:
+		if f.prevUnary {
+			switch tk {
+			case tkAdd, tkInc, tkAddAssign:
+				return prev == tkAdd || prev == tkInc
+			case tkSub, tkDec, tkSubAssign:
+				return prev == tkSub || prev == tkDec
+			default:
+				return false
+			}
+		}
+		break
+	default:
+		break
+	}
+
+	switch tk {
+	case tkComma, tkScope, tkRPar, tkRSqr:
+		return false
+	case tkSemicolon:
+		return prev == tkFor
+	case tkRBra:
+		return kind == fmtBlock
+	case tkDot, tkLSqr:
+		return !fmtIsExprEnd(prev)
+	case tkLPar:
+		switch prev {
+		case tkId, tkSId, tkGId, tkDId, tkEId, tkRPar, tkRSqr, tkRBra,
+			tkFunction, tkIterator, tkTemplate, tkYield:
+			return false
+		default:
+			return true
+		}
+	case tkColon:
+		return kind == fmtColonTernary
+	case tkInc, tkDec:
+		return unary
+	default:
+		return true
+	}
+}
+
+func (f *formatter) run() {
+	for idx, t := range f.tks {
+		for _, c := range t.comments {
+			f.writeComment(c)
+		}
+
+		if t.tk == tkEof {
+			f.flush()
+			break
+		}
+
+		brk := (t.nl > 0 || f.comment) && !f.lineEmpty
+		if brk && !f.comment {
+			// K&R style brace and else/elif placement
+			if t.tk == tkLBra && !fmtIsMapPrefix(f.prev) {
+				switch f.prev {
+				case tkSemicolon, tkLBra, tkRBra, tkLExprBra, -1:
+					break
+				default:
+					brk = false
+					break
+				}
+			} else if (t.tk == tkElse || t.tk == tkElif) && f.prev == tkRBra {
+				brk = false
+			}
+		}
+		if !brk && !f.lineEmpty {
+			top := f.top()
+			if t.tk == tkRBra && top.kind != fmtParen && top.multi {
+				brk = true
+			} else if f.prev == tkSemicolon && top.kind == fmtBlock && top.multi &&
+				!f.forHeader {
+				brk = true
+			} else if fmtIsOpener(f.prev) && top.kind == fmtBlock && top.multi {
+				brk = true
+			}
+		}
+		if brk {
+			f.newline(t.nl)
+		}
+
+		kind := 0
+		unary := false
+
+		switch t.tk {
+		case tkLPar:
+			if f.prev == tkFunction || f.prev == tkIterator ||
+				(f.prev == tkId && (f.prev2 == tkFunction || f.prev2 == tkIterator)) {
+				kind = fmtParam
+			} else {
+				kind = fmtParen
+			}
+			break
+		case tkLSqr:
+			kind = fmtParen
+			break
+		case tkLBra:
+			if fmtIsMapPrefix(f.prev) {
+				kind = fmtMap
+			} else {
+				kind = fmtBlock
+			}
+			break
+		case tkLExprBra:
+			kind = fmtBlock
+			break
+		case tkRBra, tkRPar, tkRSqr:
+			kind = f.top().kind
+			break
+		case tkColon:
+			kind = f.colonKind()
+			break
+		case tkQuest:
+			f.top().quest++
+			break
+		case tkComma:
+			if top := f.top(); top.kind == fmtMap {
+				top.key = true
+			}
+			break
+		case tkAdd, tkSub, tkInc, tkDec:
+			// the sign of rule priority, ie rule foo priority -1 {}
+			unary = !f.isExprEnd() ||
+				(len(f.stack) == 1 && f.prev == tkId && f.prevText == "priority")
+			break
+		case tkFor:
+			f.forHeader = true
+			break
+		default:
+			break
+		}
+
+		f.write(t.tk, t.text, f.space(t.tk, kind, unary))
+
+		if fmtIsOpener(t.tk) {
+			min := 2
+			if top := f.top(); top.kind == fmtBlock && top.multi {
+				min = 1
+			}
+			if kind == fmtBlock {
+				f.forHeader = false
+			}
+			f.stack = append(f.stack, fmtBracket{
+				kind:   kind,
+				indent: f.indent,
+				multi:  f.isMulti(idx) || kind == fmtBlock && f.hasStatement(idx, min),
+				key:    kind == fmtMap,
+			})
+		} else if fmtIsCloser(t.tk) && len(f.stack) > 1 {
+			f.stack = f.stack[:len(f.stack)-1]
+		}
+
+		f.prev2 = f.prev
+		f.prev = t.tk
+		f.prevKind = kind
+		f.prevUnary = unary
+		f.prevText = t.text
+	}
+}
@@ -0,0 +1,255 @@
+package pl
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden files")
+
+func testFormat(t *testing.T, input string, expect string) {
+	output, err := Format(input)
+	assert.True(t, err == nil, "%s", err)
+	assert.Equal(t, expect, output)
+
+	// formatting must be idempotent
+	again, err := Format(output)
+	assert.True(t, err == nil, "%s", err)
+	assert.Equal(t, output, again)
+}
+
+func TestFormatBasic(t *testing.T) {
+	// indentation and brace style
+	testFormat(t,
+		`fn foo(a,b){
+if a>b
+{
+return a;
+}
+else {
+    return b;
+}
+}`,
+		`fn foo(a, b) {
+  if a > b {
+    return a;
+  } else {
+    return b;
+  }
+}
+`)
+
+	// one statement per line, blank lines are folded
+	testFormat(t,
+		`test {
+  let a = 1; let b = -a;
+
+
+  a++;
+  assert::eq(a , b);}
+`,
+		`test {
+  let a = 1;
+  let b = -a;
+
+  a++;
+  assert::eq(a, b);
+}
+`)
+
+	// method call, map literal and ternary
+	testFormat(t,
+		`test {
+  let m = {"a" : 1, "b":{}:length()};
+  let v = m:get( "a" )==1?"x":'y';
+  let l = [1,2,3][0];
+}
+`,
+		`test {
+  let m = {"a": 1, "b": {}:length()};
+  let v = m:get("a") == 1 ? "x" : 'y';
+  let l = [1, 2, 3][0];
+}
+`)
+}
+
+func TestFormatIncDec(t *testing.T) {
+	// the block after i++ is not a map literal
+	testFormat(t,
+		`fn foo(n) {
+  let a = 0;
+  for let i = 0; i < n; i++ { let b = 2; a = a + i + b; if a { b = 1; } }
+  return a;
+}
+`,
+		`fn foo(n) {
+  let a = 0;
+  for let i = 0; i < n; i++ {
+    let b = 2;
+    a = a + i + b;
+    if a {
+      b = 1;
+    }
+  }
+  return a;
+}
+`)
+
+	// binary operator after postfix ++/--
+	testFormat(t,
+		`test {
+  let a = 1;
+  let b = a-- - 1;
+  let c = a++ + -b;
+  let d = -a;
+}
+`,
+		`test {
+  let a = 1;
+  let b = a-- - 1;
+  let c = a++ + -b;
+  let d = -a;
+}
+`)
+}
+
+func TestFormatComment(t *testing.T) {
+	testFormat(t,
+		`// leading comment
+fn foo() { // trailing comment
+      /* block
+  comment */
+  return 1;   // return
+  // dangling comment
+}
+`,
+		`// leading comment
+fn foo() { // trailing comment
+  /* block
+  comment */
+  return 1; // return
+  // dangling comment
+}
+`)
+}
+
+func TestFormatRule(t *testing.T) {
+	// rule with guard
+	testFormat(t,
+		`rule "http.request" when $.method=="GET"
+{
+emit "done",{"status":200};
+}
+rule [event] => {
+  session::counter+=1;
+}
+`,
+		`rule "http.request" when $.method == "GET" {
+  emit "done", {"status": 200};
+}
+rule [event] => {
+  session::counter += 1;
+}
+`)
+
+	// guard with priority
+	testFormat(t,
+		`rule test when $==1 priority  -2 {
+  return 1;
+}
+rule test priority 3 when $ == 2 {
+}
+`,
+		`rule test when $ == 1 priority -2 {
+  return 1;
+}
+rule test priority 3 when $ == 2 {
+}
+`)
+
+	// config block with attributes
+	testFormat(t,
+		`config service {
+.name = "body_sign";
+  .router = "[GET,POST]/body_sign/{op}/{method}";
+  request {
+      .event( "my_request" );
+  }
+  application body_sign();
+}
+`,
+		`config service {
+  .name = "body_sign";
+  .router = "[GET,POST]/body_sign/{op}/{method}";
+  request {
+    .event("my_request");
+  }
+  application body_sign();
+}
+`)
+}
+
+func TestFormatLiteral(t *testing.T) {
+	// regex, string interpolation and templates are kept as is
+	testFormat(t,
+		"test {\n"+
+			"  let x = \"a{{ $.b + 1 }}c\";\n"+
+			"  assert::yes(\"ab\"~r\"a[b]+\");\n"+
+			"  let y = template(\"go\", {\"a\": 1}, ```\n"+
+			"  {{.a}}\n"+
+			"     text\n"+
+			"```);\n"+
+			"}\n",
+		"test {\n"+
+			"  let x = \"a{{ $.b + 1 }}c\";\n"+
+			"  assert::yes(\"ab\" ~ r\"a[b]+\");\n"+
+			"  let y = template(\"go\", {\"a\": 1}, ```\n"+
+			"  {{.a}}\n"+
+			"     text\n"+
+			"```);\n"+
+			"}\n")
+}
+
+func TestFormatError(t *testing.T) {
+	_, err := Format("test { \"abc }")
+	assert.True(t, err != nil)
+}
+
+// golden files test, each script inside of assets/test has a formatted golden
+// output inside of assets/test/golden. Run go test with -update to refresh them
+func TestFormatGolden(t *testing.T) {
+	files, err := filepath.Glob("../assets/test/*.pl")
+	assert.True(t, err == nil)
+	assert.True(t, len(files) > 0)
+
+	for _, f := range files {
+		src, err := os.ReadFile(f)
+		assert.True(t, err == nil)
+
+		output, err := Format(string(src))
+		assert.True(t, err == nil, "%s: %s", f, err)
+
+		golden := filepath.Join(
+			"../assets/test/golden",
+			strings.TrimSuffix(filepath.Base(f), ".pl")+".golden",
+		)
+
+		if *updateGolden {
+			assert.True(t, os.WriteFile(golden, []byte(output), 0644) == nil)
+			continue
+		}
+
+		expect, err := os.ReadFile(golden)
+		assert.True(t, err == nil, "%s: %s", golden, err)
+		assert.Equal(t, string(expect), output, f)
+
+		again, err := Format(output)
+		assert.True(t, err == nil, "%s: %s", f, err)
+		assert.Equal(t, output, again, f)
+	}
+}
@@ -19,6 +19,7 @@ import (
 // %F -> positive real number, including 0
 // %s -> string
 // %S -> none empty string
+// %B -> bytes
 // %b -> boolean
 // %Y -> true
 // %N -> false
@@ -81,6 +82,7 @@ const (
 
 	PString
 	PNEString
+	PBytes
 	PBool
 	PTrue
 	PFalse
@@ -106,6 +108,8 @@ func mapToObjType(ptype int) int {
 		return ValReal
 	case PString, PNEString:
 		return ValStr
+	case PBytes:
+		return ValBytes
 	case PBool, PTrue, PFalse:
 		return ValBool
 	case PNull:
@@ -135,6 +139,8 @@ func mapFromObjType(otype int) opc {
 		return opc(PReal)
 	case ValStr:
 		return opc(PString)
+	case ValBytes:
+		return opc(PBytes)
 	case ValBool:
 		return opc(PBool)
 	case ValNull:
@@ -255,6 +261,8 @@ func (p *protoelem) str() string {
 		return "string"
 	case PNEString:
 		return "none_empty_string"
+	case PBytes:
+		return "bytes"
 	case PBool:
 		return "bool"
 	case PTrue:
@@ -408,6 +416,9 @@ func (f *FuncProto) compT(rList []rune, cursor int, vlen *bool) (int, protoelem,
 	case 'S':
 		opcode = PNEString
 		break
+	case 'B':
+		opcode = PBytes
+		break
 	case 'b':
 		opcode = PBool
 		break
@@ -702,7 +713,7 @@ func (f *FuncProto) check0(exp protoelem, got Val, c convoneval) (the_return boo
 		return true
 	}
 
-	switch got.Type {
+	switch got.Type() {
 	case ValInt:
 		if exp.opcode.isInt() {
 			return true
@@ -727,6 +738,12 @@ func (f *FuncProto) check0(exp protoelem, got Val, c convoneval) (the_return boo
 		}
 		break
 
+	case ValBytes:
+		if exp.opcode == PBytes {
+			return true
+		}
+		break
+
 	case ValBool:
 		if exp.opcode == PBool {
 			return true
@@ -952,6 +969,24 @@ func (f *FuncProto) Check(args []Val) (int, error) {
 	}
 }
 
+// Only check the number of arguments regardless of their types, used by the
+// static analysis where the argument types are unknown
+func (f *FuncProto) CheckArgc(alen int) bool {
+	if f.alwayspass {
+		return true
+	}
+	if f.noarg {
+		return alen == 0
+	}
+	for _, c := range f.d {
+		sz := len(c.d)
+		if sz == alen || (sz < alen && c.varlen) {
+			return true
+		}
+	}
+	return false
+}
+
 // return nil when we cannot convert Val into reflect.Value (ie not supported)
 func (f *FuncProto) pack(v Val, t opc) *reflect.Value {
 	var rv reflect.Value
@@ -1012,6 +1047,10 @@ func (f *FuncProto) pack(v Val, t opc) *reflect.Value {
 		rv = reflect.ValueOf(v.String())
 		break
 
+	case PBytes:
+		rv = reflect.ValueOf(v.Bytes())
+		break
+
 	case PBool, PTrue, PFalse:
 		rv = reflect.ValueOf(v.Bool())
 		break
@@ -1035,7 +1074,7 @@ func (f *FuncProto) pack(v Val, t opc) *reflect.Value {
 		break
 
 	case PAny:
-		return f.pack(v, mapFromObjType(v.Type))
+		return f.pack(v, mapFromObjType(v.Type()))
 
 	default:
 		return nil
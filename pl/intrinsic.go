@@ -78,14 +78,12 @@ func unpack(i reflect.Value) (Val, error) {
 		// -------------------------------------------------------------------------
 		// special cases that we can recognize and can perform the conversion
 
-		// 1. []byte, convert to string
+		// 1. []byte, convert to bytes
 		if i.Kind() == reflect.Slice {
-			ev := i.Elem()
-			if ev.Kind() == reflect.Uint8 {
-				// just a byte slice, then perform the conversion to string
+			if i.Type().Elem().Kind() == reflect.Uint8 {
 				barray, ok := ivalue.([]byte)
 				must(ok, "must be convertable")
-				return NewValStr(string(barray)), nil
+				return NewValBytes(barray), nil
 			}
 		}
 
@@ -113,6 +111,9 @@ func unpack(i reflect.Value) (Val, error) {
 // the first error is the reflection's value's error and the second is just the
 // error about the unpack process
 func unpackError(i reflect.Value) (error, error) {
+	if i.Kind() == reflect.Interface && i.IsNil() {
+		return nil, nil
+	}
 	v, ok := i.Interface().(error)
 	if !ok {
 		return nil, fmt.Errorf("cannot convert %s to error", i.Type().String())
@@ -22,6 +22,7 @@ const (
 	tkInt
 	tkReal
 	tkStr
+	tkBytes
 	tkDollar
 	tkLPar
 	tkRPar
@@ -196,6 +197,10 @@ type lexer struct {
 	// option
 	allowDRBra bool
 
+	// start offset of the current token, ie skipping any whitespace and
+	// comments before it. Used by tools want a lossless token stream
+	tokenStart int
+
 	// internal shit
 	cursorStart int
 }
@@ -241,6 +246,8 @@ func getTokenName(tk int) string {
 		return "real"
 	case tkStr:
 		return "str"
+	case tkBytes:
+		return "bytes"
 	case tkMStr:
 		return "mstr"
 	case tkDollar:
@@ -584,6 +591,12 @@ func (t *lexer) expect(tk int) bool {
 }
 
 func (t *lexer) scanStr() int {
+	return t.scanQuoted(false)
+}
+
+// scan a quoted literal, the bytes literal additionally allows \xHH escape
+// which generates a single raw byte instead of a utf8 sequence
+func (t *lexer) scanQuoted(isBytes bool) int {
 	var buffer bytes.Buffer
 
 	singleQuote := true
@@ -632,6 +645,21 @@ func (t *lexer) scanStr() int {
 					buffer.WriteRune('\v')
 					break
 
+				case 'x':
+					if !isBytes {
+						return t.err("invalid character escape")
+					}
+					if ncursor+2 >= len(t.input) {
+						return t.err("early termination of bytes literal")
+					}
+					x, err := strconv.ParseUint(string(t.input[ncursor+1:ncursor+3]), 16, 8)
+					if err != nil {
+						return t.err("invalid \\x escape, expect 2 hex digits")
+					}
+					buffer.WriteByte(byte(x))
+					t.cursor += 2
+					break
+
 				default:
 					return t.err("invalid character escape")
 				}
@@ -742,25 +770,31 @@ func (t *lexer) scanRId() int {
 
 func (t *lexer) tryPrefixString(c rune) (int, bool) {
 	// Prefix string's prefix checking
+	tk := tkError
+
 	switch c {
 	case 'R', 'r':
+		tk = tkRegex
+		break
+
+	case 'B', 'b':
+		tk = tkBytes
 		break
 
 	default:
 		return 0, false
 	}
 
-	tk := tkError
-
 	if t.cursor+1 < len(t.input) {
 		nc := t.input[t.cursor+1]
 		switch nc {
 		case '\'', '"':
-			tk = tkRegex
 			break
 		default:
 			return 0, false
 		}
+	} else {
+		return 0, false
 	}
 
 	// Scan the rest of the string into valueText, and the scanStr will mark the
@@ -770,7 +804,7 @@ func (t *lexer) tryPrefixString(c rune) (int, bool) {
 		return t.err("early terminate of prefixed identifiers"), false
 	}
 
-	x := t.scanStr()
+	x := t.scanQuoted(tk == tkBytes)
 	if x != tkStr {
 		return x, true
 	}
@@ -1060,7 +1094,6 @@ func (t *lexer) scanMStr() int {
 
 	startPos := t.cursor
 	endPos := t.cursor + tagPos
-	fmt.Printf(":: %d:%d:%d\n", t.cursor, tagPos, endPos)
 
 	t.valueText = string(t.input[startPos:endPos])
 	t.cursor = endPos + len(endTag)
@@ -1072,6 +1105,7 @@ func (t *lexer) next() int {
 	startDCursor := t.cursor
 	pDCursor := &startDCursor
 	defer func() {
+		t.tokenStart = *pDCursor
 		t.saveDCursor(*pDCursor)
 	}()
 
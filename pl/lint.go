@@ -0,0 +1,349 @@
+package pl
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Static analysis over a compiled module. The checker works on the bytecode
+// along with the metadata recorded by the parser, it does not execute any
+// code. The checks that require knowledge of the host environment, ie which
+// event is triggered by the host or which config property is valid, are
+// provided by the LintOption; a nil field just disables the check.
+
+const (
+	LintWarning = iota
+	LintError
+)
+
+const (
+	LintCheckMethod      = "method"
+	LintCheckArity       = "arity"
+	LintCheckUnreachable = "unreachable"
+	LintCheckUnused      = "unused"
+	LintCheckEmit        = "emit"
+	LintCheckConfig      = "config"
+)
+
+type LintDiag struct {
+	Level   int
+	Check   string
+	Where   string // name of the rule/function the diagnostic belongs to
+	Line    int
+	Column  int
+	Message string
+}
+
+func (d *LintDiag) IsError() bool {
+	return d.Level == LintError
+}
+
+func (d *LintDiag) String() string {
+	lvl := "warning"
+	if d.IsError() {
+		lvl = "error"
+	}
+	return fmt.Sprintf("%d:%d: %s(%s) in %s: %s", d.Line, d.Column, lvl, d.Check,
+		d.Where, d.Message)
+}
+
+type LintOption struct {
+	// returns true when the event is triggered by the host environment, rules
+	// that handle neither host event nor emitted event are unreachable
+	Event func(string) bool
+
+	// known methods of user types loaded from a dynamic variable path, ie
+	// request.header. Method calls on paths not inside of the map are skipped
+	Method map[string][]string
+
+	// receives the config scope replayed statically, the config code is never
+	// executed. Since the Event is checked after the config scope, a host may
+	// collect the events triggered by its config, ie middleware, here
+	Config LintConfig
+}
+
+// LintConfig mirrors the EvalConfig, but only the literal parts of the config
+// are known. The returned error is reported as diagnostic
+type LintConfig interface {
+	PushConfig(string) error
+	PopConfig() error
+
+	// name of the property, the value is not known statically
+	ConfigProperty(string) error
+
+	// name of the command and its arguments, the arguments are passed only when
+	// all of them are literal, otherwise literal is false
+	ConfigCommand(name string, arg []Val, literal bool) error
+}
+
+type linter struct {
+	module *Module
+	opt    *LintOption
+	diag   []LintDiag
+}
+
+// Lint runs static analysis against the module and returns all diagnostics
+// ordered by source location
+func Lint(m *Module, opt *LintOption) []LintDiag {
+	if opt == nil {
+		opt = &LintOption{}
+	}
+	l := &linter{
+		module: m,
+		opt:    opt,
+	}
+
+	for _, prog := range l.module.allProgram() {
+		l.checkMethod(prog)
+		l.checkArity(prog)
+		l.checkUnused(prog)
+		l.checkEmit(prog)
+	}
+	if m.config != nil {
+		l.checkConfig(m.config)
+	}
+	l.checkUnreachable()
+
+	sort.SliceStable(l.diag, func(i, j int) bool {
+		if l.diag[i].Line != l.diag[j].Line {
+			return l.diag[i].Line < l.diag[j].Line
+		}
+		return l.diag[i].Column < l.diag[j].Column
+	})
+	return l.diag
+}
+
+func (l *linter) addLoc(level int, check string, prog *program, loc sourceloc,
+	f string, a ...interface{}) {
+	l.diag = append(l.diag, LintDiag{
+		Level:   level,
+		Check:   check,
+		Where:   prog.displayName(),
+		Line:    loc.line,
+		Column:  loc.column,
+		Message: fmt.Sprintf(f, a...),
+	})
+}
+
+func (l *linter) add(level int, check string, prog *program, pc int,
+	f string, a ...interface{}) {
+	var loc sourceloc
+	if pc >= 0 && pc < len(prog.dbgList) {
+		loc = prog.dbgList[pc]
+	}
+	l.addLoc(level, check, prog, loc, f, a...)
+}
+
+// unknown method on known user type, ie request.header:foo(). The pattern is
+// load-var followed by series of dot and a load-method
+func (l *linter) checkMethod(prog *program) {
+	if len(l.opt.Method) == 0 {
+		return
+	}
+
+	path := []string{}
+	for pc, bc := range prog.bcList {
+		switch bc.opcode {
+		case bcLoadVar:
+			path = append(path[:0], prog.idxStr(bc.argument))
+			break
+
+		case bcDot:
+			if len(path) != 0 {
+				path = append(path, prog.idxStr(bc.argument))
+			}
+			break
+
+		case bcLoadMethod:
+			if len(path) != 0 {
+				p := strings.Join(path, ".")
+				name := prog.idxStr(bc.argument)
+				if mlist, ok := l.opt.Method[p]; ok && !lintHasName(mlist, name) {
+					l.add(LintError, LintCheckMethod, prog, pc,
+						"method %s is unknown for %s", name, p)
+				}
+			}
+			path = path[:0]
+			break
+
+		default:
+			path = path[:0]
+			break
+		}
+	}
+}
+
+func lintHasName(l []string, name string) bool {
+	for _, x := range l {
+		if x == name {
+			return true
+		}
+	}
+	return false
+}
+
+// intrinsic function call with arguments count that never matches its
+// prototype
+func (l *linter) checkArity(prog *program) {
+	for pc, idx := range prog.meta.icall {
+		info := intrinsicFunc[idx]
+		argc := prog.bcList[pc].argument
+		if !info.argproto.CheckArgc(argc) {
+			l.add(LintError, LintCheckArity, prog, pc,
+				"function %s called with %d arguments, but its prototype is %s",
+				info.cname, argc, info.argproto.Descriptor)
+		}
+	}
+}
+
+func (l *linter) checkUnused(prog *program) {
+	for _, v := range prog.meta.unused {
+		l.addLoc(LintWarning, LintCheckUnused, prog, v.loc,
+			"local variable %s is declared but never used", v.name)
+	}
+}
+
+func (l *linter) checkEmit(prog *program) {
+	for _, e := range prog.meta.emit {
+		if e.name != "" && !l.module.HasEvent(e.name) {
+			l.addLoc(LintWarning, LintCheckEmit, prog, e.loc,
+				"event %s is emitted but no rule handles it", e.name)
+		}
+	}
+}
+
+func (l *linter) checkUnreachable() {
+	if l.opt.Event == nil {
+		return
+	}
+
+	emitted := make(map[string]bool)
+	for _, prog := range l.module.allProgram() {
+		for _, e := range prog.meta.emit {
+			emitted[e.name] = true
+		}
+	}
+
+	for _, prog := range l.module.p {
+		if emitted[prog.name] || l.opt.Event(prog.name) {
+			continue
+		}
+
+		// rule of event pattern may match any event triggered by the host, which
+		// cannot be enumerated, so it is never reported
+		if isEventPattern(prog.name) {
+			continue
+		}
+		l.add(LintWarning, LintCheckUnreachable, prog, 0,
+			"rule %s is unreachable, its event is never triggered", prog.name)
+	}
+}
+
+// literal arguments of the config command at pc, the instructions right
+// before the command must be the load of its name followed by one literal load
+// per argument, otherwise the argument is an expression
+func lintConfigArg(prog *program, pc int, name string, argc int) ([]Val, bool) {
+	start := pc - argc
+	if start < 1 {
+		return nil, false
+	}
+	if bc := prog.bcList[start-1]; bc.opcode != bcLoadStr ||
+		prog.idxStr(bc.argument) != name {
+		return nil, false
+	}
+
+	arg := []Val{}
+	for _, bc := range prog.bcList[start:pc] {
+		switch bc.opcode {
+		case bcLoadInt:
+			arg = append(arg, NewValInt64(prog.idxInt(bc.argument)))
+			break
+		case bcLoadReal:
+			arg = append(arg, NewValReal(prog.idxReal(bc.argument)))
+			break
+		case bcLoadStr:
+			arg = append(arg, NewValStr(prog.idxStr(bc.argument)))
+			break
+		case bcLoadBytes:
+			arg = append(arg, NewValBytes([]byte(prog.idxStr(bc.argument))))
+			break
+		case bcLoadTrue:
+			arg = append(arg, NewValBool(true))
+			break
+		case bcLoadFalse:
+			arg = append(arg, NewValBool(false))
+			break
+		case bcLoadNull:
+			arg = append(arg, NewValNull())
+			break
+		default:
+			return nil, false
+		}
+	}
+	return arg, true
+}
+
+// replay the config scope against the LintOption.Config. The bytecode is walked
+// linearly, ie both branches of an if are visited
+func (l *linter) checkConfig(prog *program) {
+	cfg := l.opt.Config
+	if cfg == nil {
+		return
+	}
+
+	// whether each pushed scope is accepted, nothing inside of a rejected scope
+	// is checked since the scope itself is already reported
+	scope := []bool{}
+	valid := func() bool {
+		return len(scope) == 0 || scope[len(scope)-1]
+	}
+
+	for pc, bc := range prog.bcList {
+		var err error
+
+		switch bc.opcode {
+		case bcConfigPush, bcConfigPushWithAttr:
+			ok := false
+			if valid() {
+				err = cfg.PushConfig(prog.idxStr(bc.argument))
+				ok = err == nil
+			}
+			scope = append(scope, ok)
+			break
+
+		case bcConfigPop:
+			if len(scope) != 0 {
+				if scope[len(scope)-1] {
+					err = cfg.PopConfig()
+				}
+				scope = scope[:len(scope)-1]
+			}
+			break
+
+		case bcConfigPropertySet, bcConfigPropertySetWithAttr:
+			if name, ok := prog.meta.config[pc]; ok && valid() {
+				err = cfg.ConfigProperty(name)
+			}
+			break
+
+		case bcConfigCommand, bcConfigCommandWithAttr:
+			if name, ok := prog.meta.config[pc]; ok && valid() {
+				var arg []Val
+				literal := false
+				if bc.opcode == bcConfigCommand {
+					arg, literal = lintConfigArg(prog, pc, name, bc.argument)
+				}
+				err = cfg.ConfigCommand(name, arg, literal)
+			}
+			break
+
+		default:
+			break
+		}
+
+		if err != nil {
+			l.add(LintError, LintCheckConfig, prog, pc, "%s", err.Error())
+		}
+	}
+}
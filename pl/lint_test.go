@@ -0,0 +1,184 @@
+package pl
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testLint(t *testing.T, code string, opt *LintOption) []LintDiag {
+	m, err := CompileModule(code, nil)
+	assert.True(t, err == nil, "%s", err)
+	if m == nil {
+		return nil
+	}
+	return Lint(m, opt)
+}
+
+func lintHasDiag(diag []LintDiag, check string, level int, line int) bool {
+	for _, d := range diag {
+		if d.Check == check && d.Level == level && d.Line == line {
+			return true
+		}
+	}
+	return false
+}
+
+func TestLintClean(t *testing.T) {
+	diag := testLint(t, `
+fn foo(a) {
+  let b = a + 1;
+  return b;
+}
+test {
+  emit "done", foo(1);
+}
+rule done {
+  str::to_upper("a");
+}
+`, &LintOption{
+		Event: func(x string) bool {
+			return x == "test"
+		},
+	})
+	assert.Equal(t, 0, len(diag), "%v", diag)
+}
+
+func TestLintUnused(t *testing.T) {
+	diag := testLint(t, `
+fn foo() {
+  let a = 1;
+  let _ = 2;
+  const b = 3;
+  let c = 4;
+  let d = fn() {
+    return c;
+  };
+  return d;
+}
+`, nil)
+	assert.Equal(t, 2, len(diag))
+	assert.True(t, lintHasDiag(diag, LintCheckUnused, LintWarning, 3))
+	assert.True(t, lintHasDiag(diag, LintCheckUnused, LintWarning, 5))
+}
+
+func TestLintArity(t *testing.T) {
+	diag := testLint(t, `
+test {
+  str::to_upper("a");
+  str::to_upper("a", "b");
+  "a" | str::to_upper;
+  "a" | str::to_upper("b");
+}
+`, nil)
+	assert.Equal(t, 2, len(diag))
+	assert.True(t, lintHasDiag(diag, LintCheckArity, LintError, 4))
+	assert.True(t, lintHasDiag(diag, LintCheckArity, LintError, 6))
+}
+
+func TestLintMethod(t *testing.T) {
+	diag := testLint(t, `
+test {
+  request.header:set("a", "b");
+  request.header:sett("a", "b");
+  request.header.foo:bar();
+  request:whatever();
+}
+`, &LintOption{
+		Method: map[string][]string{
+			"request.header": []string{"set"},
+		},
+	})
+	assert.Equal(t, 1, len(diag))
+	assert.True(t, lintHasDiag(diag, LintCheckMethod, LintError, 4))
+}
+
+func TestLintEvent(t *testing.T) {
+	diag := testLint(t, `
+test {
+  emit a;
+  emit nowhere;
+}
+rule a {
+}
+rule b {
+}
+`, &LintOption{
+		Event: func(x string) bool {
+			return x == "test"
+		},
+	})
+	assert.Equal(t, 2, len(diag))
+	assert.True(t, lintHasDiag(diag, LintCheckEmit, LintWarning, 4))
+	assert.True(t, lintHasDiag(diag, LintCheckUnreachable, LintWarning, 8))
+
+	// without host event information, the reachability is not checked
+	diag = testLint(t, `
+rule b {
+}
+`, nil)
+	assert.Equal(t, 0, len(diag))
+}
+
+type testLintConfig struct {
+	scope []string
+	trace []string
+}
+
+func (c *testLintConfig) PushConfig(name string) error {
+	if name == "bad" {
+		return fmt.Errorf("unknown scope %s", name)
+	}
+	c.scope = append(c.scope, name)
+	return nil
+}
+
+func (c *testLintConfig) PopConfig() error {
+	c.scope = c.scope[:len(c.scope)-1]
+	return nil
+}
+
+func (c *testLintConfig) ConfigProperty(name string) error {
+	c.trace = append(c.trace, fmt.Sprintf("%v.%s", c.scope, name))
+	if name != "name" {
+		return fmt.Errorf("unknown property %s", name)
+	}
+	return nil
+}
+
+func (c *testLintConfig) ConfigCommand(name string, arg []Val, literal bool) error {
+	c.trace = append(c.trace, fmt.Sprintf("%v.%s(%d, %v)", c.scope, name, len(arg), literal))
+	return nil
+}
+
+func TestLintConfig(t *testing.T) {
+	cfg := &testLintConfig{}
+	diag := testLint(t, `
+config service {
+  .name = "a";
+  .unknown = 1;
+  ["dynamic"] = 2;
+  request {
+    .event("a", 1);
+    .event(str::to_upper("a"));
+  }
+  bad {
+    .name = 1;
+  }
+  application noop();
+}
+`, &LintOption{
+		Config: cfg,
+	})
+	assert.Equal(t, 2, len(diag))
+	assert.True(t, lintHasDiag(diag, LintCheckConfig, LintError, 4))
+	assert.True(t, lintHasDiag(diag, LintCheckConfig, LintError, 10))
+	assert.Equal(t, []string{
+		"[service].name",
+		"[service].unknown",
+		"[service request].event(2, true)",
+		"[service request].event(0, false)",
+		"[service application].noop(0, true)",
+	}, cfg.trace)
+}
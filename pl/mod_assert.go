@@ -1,13 +1,14 @@
 package pl
 
 import (
+	"bytes"
 	"fmt"
 )
 
 func assertVeq(lhs Val, rhs Val) (bool, string) {
-	if lhs.Type == rhs.Type {
-		if IsValueType(lhs.Type) {
-			switch lhs.Type {
+	if lhs.Type() == rhs.Type() {
+		if IsValueType(lhs.Type()) {
+			switch lhs.Type() {
 			case ValNull:
 				return true, fmt.Sprintf("lhs: null; rhs: null")
 
@@ -26,6 +27,11 @@ func assertVeq(lhs Val, rhs Val) (bool, string) {
 				r := rhs.String()
 				return l == r, fmt.Sprintf("lhs: %s; rhs: %s", l, r)
 
+			case ValBytes:
+				l := lhs.Bytes()
+				r := rhs.Bytes()
+				return bytes.Equal(l, r), fmt.Sprintf("lhs: %x; rhs: %x", l, r)
+
 			case ValBool:
 				l := lhs.Bool()
 				r := rhs.Bool()
@@ -83,7 +89,7 @@ func assertVeq(lhs Val, rhs Val) (bool, string) {
 				return false, info
 
 			default:
-				must(lhs.Type == ValRegexp, "must be regexp")
+				must(lhs.Type() == ValRegexp, "must be regexp")
 				l := lhs.Regexp()
 				r := rhs.Regexp()
 				info := fmt.Sprintf("lhs: [regexp %s]; rhs: [regexp %s]", l.String(), r.String())
@@ -94,6 +94,23 @@ func init() {
 		},
 	)
 
+	addF(
+		"to_bytes",
+		"",
+		"(%s|%B|%l)",
+		func(info *IntrinsicInfo, _ *Evaluator, _ string, args []Val) (Val, error) {
+			_, err := info.argproto.Check(args)
+			if err != nil {
+				return NewValNull(), err
+			}
+			b, err := ToBytes(args[0])
+			if err != nil {
+				return NewValNull(), err
+			}
+			return NewValBytes(b), nil
+		},
+	)
+
 	addF(
 		"to_int",
 		"",
@@ -208,7 +225,7 @@ func init() {
 				return NewValNull(), err
 			}
 			a := args[0]
-			switch args[0].Type {
+			switch args[0].Type() {
 			case ValStr:
 				return NewValInt(len(a.String())), nil
 			case ValRegexp, ValUsr, ValInt, ValReal, ValBool, ValNull:
@@ -233,7 +250,7 @@ func init() {
 				return NewValNull(), err
 			}
 			a := args[0]
-			switch args[0].Type {
+			switch args[0].Type() {
 			case ValStr:
 				return NewValBool(len(a.String()) == 0), nil
 			case ValRegexp, ValUsr, ValInt, ValReal, ValBool, ValNull:
@@ -247,4 +264,67 @@ func init() {
 			}
 		},
 	)
+	// concrete name of the event which triggers current rule, the rule name
+	// can be a pattern of event name, ie rule "redis.*"
+	addF(
+		"event_name",
+		"",
+		"%0",
+		func(info *IntrinsicInfo, e *Evaluator, _ string, args []Val) (Val, error) {
+			_, err := info.argproto.Check(args)
+			if err != nil {
+				return NewValNull(), err
+			}
+			return NewValStr(e.eventName), nil
+		},
+	)
+	// event emitted with the priority, only effective when the event queue is
+	// ordered by priority, ie emit_priority(10, "name", context)
+	addF(
+		"emit_priority",
+		"",
+		"{%d%S}{%d%S%a}",
+		func(info *IntrinsicInfo, e *Evaluator, _ string, args []Val) (Val, error) {
+			alen, err := info.argproto.Check(args)
+			if err != nil {
+				return NewValNull(), err
+			}
+			ev := &Event{
+				Name:     args[1].String(),
+				Context:  NewValNull(),
+				Priority: args[0].Int(),
+			}
+			if alen == 3 {
+				ev.Context = args[2]
+			}
+			return NewValNull(), e.queueEvent(ev)
+		},
+	)
+
+	// event emitted after the delay in milliseconds, ie emit_after(100, "name",
+	// context). Only the Evaluator runs in background can emit delayed event
+	addF(
+		"emit_after",
+		"",
+		"{%u%S}{%u%S%a}",
+		func(info *IntrinsicInfo, e *Evaluator, _ string, args []Val) (Val, error) {
+			alen, err := info.argproto.Check(args)
+			if err != nil {
+				return NewValNull(), err
+			}
+			if !e.Background {
+				return NewValNull(), fmt.Errorf("emit_after: delayed event is only " +
+					"allowed when running in background")
+			}
+			ev := &Event{
+				Name:    args[1].String(),
+				Context: NewValNull(),
+				Delay:   time.Duration(args[0].Int()) * time.Millisecond,
+			}
+			if alen == 3 {
+				ev.Context = args[2]
+			}
+			return NewValNull(), e.queueEvent(ev)
+		},
+	)
 }
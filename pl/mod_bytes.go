@@ -0,0 +1,108 @@
+package pl
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+)
+
+func init() {
+	addrefMF(
+		"bytes",
+		"from_hex",
+		"",
+		"%s",
+		func(input string) ([]byte, error) {
+			return hex.DecodeString(input)
+		},
+	)
+
+	addrefMF(
+		"bytes",
+		"from_base64",
+		"",
+		"%s",
+		func(input string) ([]byte, error) {
+			return base64.StdEncoding.DecodeString(input)
+		},
+	)
+
+	addMF(
+		"bytes",
+		"concat",
+		"",
+		"%a*",
+		func(info *IntrinsicInfo, _ *Evaluator, _ string, args []Val) (Val, error) {
+			o := []byte{}
+			for _, x := range args {
+				b, err := ToBytes(x)
+				if err != nil {
+					return NewValNull(), err
+				}
+				o = append(o, b...)
+			}
+			return NewValBytes(o), nil
+		},
+	)
+
+	// big endian integer encoding, used by the binary protocol. The width is
+	// 1, 2, 4 or 8 bytes
+	addMF(
+		"bytes",
+		"from_int",
+		"",
+		"%d%d",
+		func(info *IntrinsicInfo, _ *Evaluator, _ string, args []Val) (Val, error) {
+			_, err := info.argproto.Check(args)
+			if err != nil {
+				return NewValNull(), err
+			}
+			v := uint64(args[0].Int())
+			switch args[1].Int() {
+			case 1:
+				return NewValBytes([]byte{byte(v)}), nil
+			case 2:
+				o := make([]byte, 2)
+				binary.BigEndian.PutUint16(o, uint16(v))
+				return NewValBytes(o), nil
+			case 4:
+				o := make([]byte, 4)
+				binary.BigEndian.PutUint32(o, uint32(v))
+				return NewValBytes(o), nil
+			case 8:
+				o := make([]byte, 8)
+				binary.BigEndian.PutUint64(o, v)
+				return NewValBytes(o), nil
+			default:
+				return NewValNull(), fmt.Errorf("bytes::from_int: invalid width %d", args[1].Int())
+			}
+		},
+	)
+
+	addMF(
+		"bytes",
+		"to_int",
+		"",
+		"%B",
+		func(info *IntrinsicInfo, _ *Evaluator, _ string, args []Val) (Val, error) {
+			_, err := info.argproto.Check(args)
+			if err != nil {
+				return NewValNull(), err
+			}
+			b := args[0].Bytes()
+			switch len(b) {
+			case 1:
+				return NewValInt(int(b[0])), nil
+			case 2:
+				return NewValInt(int(binary.BigEndian.Uint16(b))), nil
+			case 4:
+				return NewValInt64(int64(binary.BigEndian.Uint32(b))), nil
+			case 8:
+				return NewValInt64(int64(binary.BigEndian.Uint64(b))), nil
+			default:
+				return NewValNull(), fmt.Errorf("bytes::to_int: invalid width %d", len(b))
+			}
+		},
+	)
+}
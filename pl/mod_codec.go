@@ -2,17 +2,24 @@ package pl
 
 import (
 	"encoding/base64"
+	"encoding/hex"
 	"net/url"
 )
 
 func init() {
-	addrefMF(
+	// the input can be either string or bytes, bytes is encoded as it is
+	addMF(
 		"codec",
 		"b64_tostring",
 		"",
-		"%s",
-		func(input string) string {
-			return base64.StdEncoding.EncodeToString([]byte(input))
+		"(%s|%B)",
+		func(info *IntrinsicInfo, _ *Evaluator, _ string, args []Val) (Val, error) {
+			_, err := info.argproto.Check(args)
+			if err != nil {
+				return NewValNull(), err
+			}
+			b, _ := ToBytes(args[0])
+			return NewValStr(base64.StdEncoding.EncodeToString(b)), nil
 		},
 	)
 	addrefMF(
@@ -30,6 +37,42 @@ func init() {
 		},
 	)
 
+	// decode into bytes instead of string, used by binary payload
+	addrefMF(
+		"codec",
+		"b64_tobytes",
+		"",
+		"%s",
+		func(input string) ([]byte, error) {
+			return base64.StdEncoding.DecodeString(input)
+		},
+	)
+
+	addMF(
+		"codec",
+		"hex_encode",
+		"",
+		"(%s|%B)",
+		func(info *IntrinsicInfo, _ *Evaluator, _ string, args []Val) (Val, error) {
+			_, err := info.argproto.Check(args)
+			if err != nil {
+				return NewValNull(), err
+			}
+			b, _ := ToBytes(args[0])
+			return NewValStr(hex.EncodeToString(b)), nil
+		},
+	)
+
+	addrefMF(
+		"codec",
+		"hex_decode",
+		"",
+		"%s",
+		func(input string) ([]byte, error) {
+			return hex.DecodeString(input)
+		},
+	)
+
 	addrefMF(
 		"code",
 		"url_encode",
@@ -0,0 +1,436 @@
+package pl
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"hash"
+)
+
+const (
+	CryptoKeyTypeId = "crypto.key"
+)
+
+// asymmetric key loaded from PEM, the key is immutable so it can be stored
+// inside of global variable
+type cryptoKey struct {
+	key     interface{} // ed25519/rsa/ecdsa private or public key
+	private bool
+}
+
+func ValIsCryptoKey(v Val) bool {
+	return v.Id() == CryptoKeyTypeId
+}
+
+func (k *cryptoKey) typeName() string {
+	switch k.key.(type) {
+	case ed25519.PrivateKey, ed25519.PublicKey:
+		return "ed25519"
+	case *rsa.PrivateKey, *rsa.PublicKey:
+		return "rsa"
+	case *ecdsa.PrivateKey, *ecdsa.PublicKey:
+		return "ecdsa"
+	default:
+		return "unknown"
+	}
+}
+
+func (k *cryptoKey) public() *cryptoKey {
+	if !k.private {
+		return k
+	}
+	signer, ok := k.key.(crypto.Signer)
+	must(ok, "private key must be signer")
+	return &cryptoKey{
+		key:     signer.Public(),
+		private: false,
+	}
+}
+
+func (k *cryptoKey) Index(name Val) (Val, error) {
+	if !name.IsString() {
+		return NewValNull(), fmt.Errorf("%s index: invalid index", k.Id())
+	}
+	return k.Dot(name.String())
+}
+
+func (k *cryptoKey) IndexSet(_ Val, _ Val) error {
+	return fmt.Errorf("%s index set: unsupported operation", k.Id())
+}
+
+func (k *cryptoKey) Dot(name string) (Val, error) {
+	switch name {
+	case "type":
+		return NewValStr(k.typeName()), nil
+	case "private":
+		return NewValBool(k.private), nil
+	default:
+		return NewValNull(), fmt.Errorf("%s dot: unknown field %s", k.Id(), name)
+	}
+}
+
+func (k *cryptoKey) DotSet(_ string, _ Val) error {
+	return fmt.Errorf("%s dot set: unsupported operation", k.Id())
+}
+
+var (
+	mpCryptoKeyPublic = MustNewFuncProto("crypto.key.public", "%0")
+)
+
+func (k *cryptoKey) Method(name string, args []Val) (Val, error) {
+	switch name {
+	case "public":
+		if _, err := mpCryptoKeyPublic.Check(args); err != nil {
+			return NewValNull(), err
+		}
+		return NewValUsr(k.public()), nil
+	default:
+		return NewValNull(), fmt.Errorf("method: %s:%s is unknown", k.Id(), name)
+	}
+}
+
+func (k *cryptoKey) ToString() (string, error) {
+	return k.Info(), nil
+}
+
+func (k *cryptoKey) ToJSON() (Val, error) {
+	return MarshalVal(
+		map[string]interface{}{
+			"type":    k.typeName(),
+			"private": k.private,
+		},
+	)
+}
+
+func (k *cryptoKey) Id() string {
+	return CryptoKeyTypeId
+}
+
+func (k *cryptoKey) Info() string {
+	return fmt.Sprintf("[%s: %s; private=%t]", k.Id(), k.typeName(), k.private)
+}
+
+func (k *cryptoKey) ToNative() interface{} {
+	return k.key
+}
+
+func (k *cryptoKey) IsThreadSafe() bool {
+	return true
+}
+
+func (k *cryptoKey) NewIterator() (Iter, error) {
+	return nil, fmt.Errorf("%s does not support iterator", k.Id())
+}
+
+// the data argument of crypto function can be either string or bytes
+func cryptoInput(x interface{}) []byte {
+	switch v := x.(type) {
+	case string:
+		return []byte(v)
+	case []byte:
+		return v
+	default:
+		panic(fmt.Sprintf("crypto: invalid input type %T", x))
+	}
+}
+
+func cryptoHash(name string) (func() hash.Hash, error) {
+	switch name {
+	case "md5":
+		return md5.New, nil
+	case "sha1":
+		return sha1.New, nil
+	case "sha256":
+		return sha256.New, nil
+	case "sha512":
+		return sha512.New, nil
+	default:
+		return nil, fmt.Errorf("crypto: unknown hash algorithm %s", name)
+	}
+}
+
+func cryptoDigest(h func() hash.Hash, data interface{}) []byte {
+	x := h()
+	x.Write(cryptoInput(data))
+	return x.Sum(nil)
+}
+
+// parse the first PEM block, private key can be PKCS8, PKCS1(rsa) or SEC1(ec)
+// and public key can be PKIX, PKCS1(rsa) or certificate
+func cryptoParseKey(data []byte) (*cryptoKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("crypto: invalid PEM data")
+	}
+
+	var key interface{}
+	var err error
+	private := true
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		private = false
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		private = false
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		var cert *x509.Certificate
+		private = false
+		cert, err = x509.ParseCertificate(block.Bytes)
+		if err == nil {
+			key = cert.PublicKey
+		}
+	default:
+		return nil, fmt.Errorf("crypto: unsupported PEM block %s", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	k := &cryptoKey{
+		key:     key,
+		private: private,
+	}
+	if k.typeName() == "unknown" {
+		return nil, fmt.Errorf("crypto: unsupported key type %T", key)
+	}
+	return k, nil
+}
+
+// ed25519 signs the raw message, rsa(PKCS1 v1.5) and ecdsa(ASN.1) sign the
+// sha256 digest of the message
+func cryptoSign(k *cryptoKey, data interface{}) ([]byte, error) {
+	msg := cryptoInput(data)
+
+	switch key := k.key.(type) {
+	case ed25519.PrivateKey:
+		return ed25519.Sign(key, msg), nil
+	case *rsa.PrivateKey:
+		digest := sha256.Sum256(msg)
+		return rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		digest := sha256.Sum256(msg)
+		return ecdsa.SignASN1(rand.Reader, key, digest[:])
+	default:
+		return nil, fmt.Errorf("crypto::sign: key must be private key")
+	}
+}
+
+func cryptoVerify(k *cryptoKey, data interface{}, sig []byte) bool {
+	msg := cryptoInput(data)
+
+	switch key := k.public().key.(type) {
+	case ed25519.PublicKey:
+		return ed25519.Verify(key, msg, sig)
+	case *rsa.PublicKey:
+		digest := sha256.Sum256(msg)
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig) == nil
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(msg)
+		return ecdsa.VerifyASN1(key, digest[:], sig)
+	default:
+		return false
+	}
+}
+
+func cryptoGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func init() {
+	// digest, the result is bytes and can be converted via :to_hex()
+	addrefMF(
+		"crypto",
+		"md5",
+		"",
+		"(%s|%B)",
+		func(data interface{}) []byte {
+			return cryptoDigest(md5.New, data)
+		},
+	)
+	addrefMF(
+		"crypto",
+		"sha1",
+		"",
+		"(%s|%B)",
+		func(data interface{}) []byte {
+			return cryptoDigest(sha1.New, data)
+		},
+	)
+	addrefMF(
+		"crypto",
+		"sha256",
+		"",
+		"(%s|%B)",
+		func(data interface{}) []byte {
+			return cryptoDigest(sha256.New, data)
+		},
+	)
+	addrefMF(
+		"crypto",
+		"sha512",
+		"",
+		"(%s|%B)",
+		func(data interface{}) []byte {
+			return cryptoDigest(sha512.New, data)
+		},
+	)
+
+	// crypto::hmac("sha256", key, data)
+	addrefMF(
+		"crypto",
+		"hmac",
+		"",
+		"%s(%s|%B)(%s|%B)",
+		func(algo string, key interface{}, data interface{}) ([]byte, error) {
+			h, err := cryptoHash(algo)
+			if err != nil {
+				return nil, err
+			}
+			mac := hmac.New(h, cryptoInput(key))
+			mac.Write(cryptoInput(data))
+			return mac.Sum(nil), nil
+		},
+	)
+
+	// constant time comparison, used to compare signature/token
+	addrefMF(
+		"crypto",
+		"equal",
+		"",
+		"(%s|%B)(%s|%B)",
+		func(a interface{}, b interface{}) bool {
+			return subtle.ConstantTimeCompare(cryptoInput(a), cryptoInput(b)) == 1
+		},
+	)
+
+	addrefMF(
+		"crypto",
+		"random_bytes",
+		"",
+		"%d",
+		func(n int) ([]byte, error) {
+			if n < 0 {
+				return nil, fmt.Errorf("crypto::random_bytes: negative size")
+			}
+			o := make([]byte, n)
+			if _, err := rand.Read(o); err != nil {
+				return nil, err
+			}
+			return o, nil
+		},
+	)
+
+	// AES-GCM, the key must be 16, 24 or 32 bytes. The random nonce is prepended
+	// to the cipher text
+	addrefMF(
+		"crypto",
+		"aes_gcm_encrypt",
+		"",
+		"(%s|%B)(%s|%B)(%s|%B)",
+		func(key interface{}, plain interface{}, aad interface{}) ([]byte, error) {
+			gcm, err := cryptoGCM(cryptoInput(key))
+			if err != nil {
+				return nil, err
+			}
+			nonce := make([]byte, gcm.NonceSize())
+			if _, err := rand.Read(nonce); err != nil {
+				return nil, err
+			}
+			return gcm.Seal(nonce, nonce, cryptoInput(plain), cryptoInput(aad)), nil
+		},
+	)
+	addrefMF(
+		"crypto",
+		"aes_gcm_decrypt",
+		"",
+		"(%s|%B)(%s|%B)(%s|%B)",
+		func(key interface{}, data interface{}, aad interface{}) ([]byte, error) {
+			gcm, err := cryptoGCM(cryptoInput(key))
+			if err != nil {
+				return nil, err
+			}
+			d := cryptoInput(data)
+			if len(d) < gcm.NonceSize() {
+				return nil, fmt.Errorf("crypto::aes_gcm_decrypt: data too short")
+			}
+			ns := gcm.NonceSize()
+			return gcm.Open(nil, d[:ns], d[ns:], cryptoInput(aad))
+		},
+	)
+
+	// key loading, the path is resolved against the file system of the module,
+	// ie the manifest FS
+	addMF(
+		"crypto",
+		"load_key",
+		"",
+		"%S",
+		func(info *IntrinsicInfo, e *Evaluator, _ string, args []Val) (Val, error) {
+			if _, err := info.argproto.Check(args); err != nil {
+				return NewValNull(), err
+			}
+			data, err := e.ReadFile(args[0].String())
+			if err != nil {
+				return NewValNull(), err
+			}
+			k, err := cryptoParseKey(data)
+			if err != nil {
+				return NewValNull(), err
+			}
+			return NewValUsr(k), nil
+		},
+	)
+	addrefMF(
+		"crypto",
+		"parse_key",
+		"",
+		"(%s|%B)",
+		func(data interface{}) (Val, error) {
+			k, err := cryptoParseKey(cryptoInput(data))
+			if err != nil {
+				return NewValNull(), err
+			}
+			return NewValUsr(k), nil
+		},
+	)
+
+	addrefMF(
+		"crypto",
+		"sign",
+		"",
+		"%U['crypto.key'](%s|%B)",
+		cryptoSign,
+	)
+	addrefMF(
+		"crypto",
+		"verify",
+		"",
+		"%U['crypto.key'](%s|%B)%B",
+		cryptoVerify,
+	)
+}
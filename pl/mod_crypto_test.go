@@ -0,0 +1,107 @@
+package pl
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCryptoDigest(t *testing.T) {
+	err := testAssertRule(t, `
+test {
+  assert::eq(crypto::md5("abc"):to_hex(), "900150983cd24fb0d6963f7d28e17f72");
+  assert::eq(crypto::sha1(b"abc"):to_hex(), "a9993e364706816aba3e25717850c26c9cd0d89d");
+  assert::eq(crypto::sha256("abc"):to_hex(),
+             "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad");
+  assert::eq(crypto::sha512("abc"):to_hex(),
+             "ddaf35a193617abacc417349ae20413112e6fa4e89a97ea20a9eeee64b55d39a" +
+             "2192992a274fc1a836ba3c23a3feebbd454d4423643ce80e2a9ac94fa54ca49f");
+  assert::eq(crypto::hmac("sha256", "key", "The quick brown fox jumps over the lazy dog"):to_hex(),
+             "f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8");
+
+  assert::eq(crypto::equal("abc", b"abc"), true);
+  assert::eq(crypto::equal("abc", "abd"), false);
+  assert::eq(crypto::random_bytes(16):length(), 16);
+
+  let key = crypto::random_bytes(32);
+  let data = crypto::aes_gcm_encrypt(key, "hello", "aad");
+  assert::eq(crypto::aes_gcm_decrypt(key, data, "aad"), b"hello");
+}
+`)
+	assert.True(t, err == nil, "%s", err)
+
+	for _, code := range []string{
+		`test { crypto::hmac("sha3", "key", "data"); }`,
+		`test { crypto::aes_gcm_encrypt("short", "data", ""); }`,
+		`test { let k = crypto::random_bytes(16); crypto::aes_gcm_decrypt(k, crypto::aes_gcm_encrypt(k, "a", "x"), "y"); }`,
+		`test { crypto::random_bytes(-1); }`,
+		`test { crypto::load_key("key.pem"); }`,
+	} {
+		assert.True(t, testAssertRule(t, code) != nil, code)
+	}
+}
+
+func testPEM(t *testing.T, typ string, der []byte, err error) []byte {
+	assert.True(t, err == nil, "%s", err)
+	return pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der})
+}
+
+func TestCryptoSign(t *testing.T) {
+	edPub, edPriv, _ := ed25519.GenerateKey(rand.Reader)
+	rsaPriv, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecPriv, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	fs := fstest.MapFS{}
+	der, err := x509.MarshalPKCS8PrivateKey(edPriv)
+	fs["ed.pem"] = &fstest.MapFile{Data: testPEM(t, "PRIVATE KEY", der, err)}
+	der, err = x509.MarshalPKIXPublicKey(edPub)
+	fs["ed.pub"] = &fstest.MapFile{Data: testPEM(t, "PUBLIC KEY", der, err)}
+	fs["rsa.pem"] = &fstest.MapFile{Data: testPEM(t, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaPriv), nil)}
+	der, err = x509.MarshalECPrivateKey(ecPriv)
+	fs["ec.pem"] = &fstest.MapFile{Data: testPEM(t, "EC PRIVATE KEY", der, err)}
+
+	module, err := CompileModule(`
+test {
+  let ed = crypto::load_key("ed.pem");
+  let pub = crypto::load_key("ed.pub");
+  assert::eq(ed.type, "ed25519");
+  assert::eq(ed.private, true);
+  assert::eq(pub.private, false);
+
+  let sig = crypto::sign(ed, "payload");
+  assert::eq(crypto::verify(pub, "payload", sig), true);
+  assert::eq(crypto::verify(ed:public(), b"payload", sig), true);
+  assert::eq(crypto::verify(pub, "tampered", sig), false);
+
+  for let _, name = ["rsa.pem", "ec.pem"] {
+    let k = crypto::load_key(name);
+    let s = crypto::sign(k, "payload");
+    assert::eq(crypto::verify(k, "payload", s), true);
+    assert::eq(crypto::verify(k:public(), "tampered", s), false);
+  }
+}
+`, fs)
+	assert.True(t, err == nil, "%s", err)
+	_, err = NewEvaluatorSimple().Eval("test", module)
+	assert.True(t, err == nil, "%s", err)
+
+	// public key cannot sign, and the unknown file is reported
+	for _, code := range []string{
+		`test { crypto::sign(crypto::load_key("ed.pub"), "a"); }`,
+		`test { crypto::load_key("none.pem"); }`,
+		`test { crypto::parse_key("garbage"); }`,
+	} {
+		module, err := CompileModule(code, fs)
+		assert.True(t, err == nil, "%s", err)
+		_, err = NewEvaluatorSimple().Eval("test", module)
+		assert.True(t, err != nil, code)
+	}
+}
@@ -113,7 +113,7 @@ func init() {
 			}
 			var slist []string
 			for _, v := range args[0].List().Data {
-				if v.Type == ValStr {
+				if v.Type() == ValStr {
 					slist = append(slist, v.String())
 				}
 			}
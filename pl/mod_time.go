@@ -1,6 +1,7 @@
 package pl
 
 import (
+	"net/http"
 	"time"
 )
 
@@ -55,13 +56,21 @@ func init() {
 		},
 	)
 
-	addrefMF(
+	// HTTP-date, ie RFC1123 in GMT, of now or the time argument
+	addMF(
 		"time",
 		"http_date",
 		"",
-		"%0",
-		func() string {
-			return time.Now().Format(time.RFC3339)
+		"{%0}{%U['time']}",
+		func(info *IntrinsicInfo, _ *Evaluator, _ string, args []Val) (Val, error) {
+			alog, err := info.argproto.Check(args)
+			if err != nil {
+				return NewValNull(), err
+			}
+			if alog == 1 {
+				return NewValStr(HttpDate(argTime(args[0]))), nil
+			}
+			return NewValStr(HttpDate(time.Now())), nil
 		},
 	)
 
@@ -74,4 +83,144 @@ func init() {
 			return time.Now().Format(time.RFC3339Nano)
 		},
 	)
+
+	// Time and Duration value --------------------------------------------------
+	addrefMF(
+		"time",
+		"now",
+		"",
+		"%0",
+		func() Val {
+			return NewValTime(time.Now())
+		},
+	)
+
+	// time::parse(str), time::parse(str, layout), time::parse(str, layout, zone)
+	// the layout can be a predefined name, ie rfc3339/http etc, or go's layout
+	addMF(
+		"time",
+		"parse",
+		"",
+		"{%s}{%s%s}{%s%s%s}",
+		func(info *IntrinsicInfo, _ *Evaluator, _ string, args []Val) (Val, error) {
+			alog, err := info.argproto.Check(args)
+			if err != nil {
+				return NewValNull(), err
+			}
+
+			var t time.Time
+			switch alog {
+			case 1:
+				t, err = ParseTime(args[0].String())
+			case 2:
+				t, err = time.Parse(timeLayout(args[1].String()), args[0].String())
+			default:
+				var loc *time.Location
+				loc, err = time.LoadLocation(args[2].String())
+				if err == nil {
+					t, err = time.ParseInLocation(timeLayout(args[1].String()), args[0].String(), loc)
+				}
+			}
+			if err != nil {
+				return NewValNull(), err
+			}
+			return NewValTime(t), nil
+		},
+	)
+
+	addrefMF(
+		"time",
+		"parse_http_date",
+		"",
+		"%s",
+		func(s string) (Val, error) {
+			t, err := http.ParseTime(s)
+			if err != nil {
+				return NewValNull(), err
+			}
+			return NewValTime(t), nil
+		},
+	)
+
+	addrefMF(
+		"time",
+		"from_unix",
+		"",
+		"%d",
+		func(sec int) Val {
+			return NewValTime(time.Unix(int64(sec), 0))
+		},
+	)
+
+	addrefMF(
+		"time",
+		"from_unix_milli",
+		"",
+		"%d",
+		func(msec int) Val {
+			return NewValTime(time.UnixMilli(int64(msec)))
+		},
+	)
+
+	addrefMF(
+		"time",
+		"duration",
+		"",
+		"%s",
+		func(s string) (Val, error) {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return NewValNull(), err
+			}
+			return NewValDuration(d), nil
+		},
+	)
+
+	addrefMF(
+		"time",
+		"seconds",
+		"",
+		"%d",
+		func(n int) Val {
+			return NewValDuration(time.Duration(n) * time.Second)
+		},
+	)
+
+	addrefMF(
+		"time",
+		"milliseconds",
+		"",
+		"%d",
+		func(n int) Val {
+			return NewValDuration(time.Duration(n) * time.Millisecond)
+		},
+	)
+
+	// value of the Expires header, ie HTTP-date of now + duration
+	addrefMF(
+		"time",
+		"expires",
+		"",
+		"%U['duration']",
+		func(d *Duration) string {
+			return HttpDate(time.Now().Add(d.d))
+		},
+	)
+
+	// whether the resource is modified since the If-Modified-Since header, an
+	// empty or invalid header is treated as modified. HTTP-date has only second
+	// precision, so the modification time is truncated
+	addrefMF(
+		"time",
+		"modified_since",
+		"",
+		"%s%U['time']",
+		func(header string, modtime *Time) bool {
+			ims, err := http.ParseTime(header)
+			if err != nil {
+				return true
+			}
+			return modtime.t.Truncate(time.Second).After(ims)
+		},
+	)
 }
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"io/fs"
+	"sort"
 	"strings"
 	"sync"
 )
@@ -40,7 +41,13 @@ type Module struct {
 	// will become key in this table for finding out which rule should be used
 	// NOTES(dpeng): for optimization purpose, this table *MAY* be nil since if
 	// our rule limited, linear search is the fastest
-	eventMap map[string]*program
+	eventMap map[string][]*program
+
+	// rule name which is a glob pattern of event name, see event_match.go
+	eventTrie eventTrie
+
+	// how the rules of the same event are executed, see RuleMatchFirst
+	ruleMatch int
 
 	// all the named rules, evaluation will iterate each program for execution
 	// until one is found
@@ -53,12 +60,19 @@ type Module struct {
 
 	// symbol info, used for instrumentation/debugging purpose
 	sinfo symbolInfo
+
+	// file system the module is compiled with, ie the manifest's FS. It is used
+	// by the intrinsic function to load file at runtime, can be nil
+	fs fs.FS
+
+	// compiled template, shared by all the programs of the module
+	templateCache map[string]Template
 }
 
 func newModule() *Module {
 	return &Module{
 		global:   &globalState{},
-		eventMap: make(map[string]*program),
+		eventMap: make(map[string][]*program),
 	}
 }
 
@@ -136,7 +150,7 @@ func (p *Module) getFn(name string) *program {
 
 func (p *Module) getFunction(name string) *program {
 	r, _ := p.getfromlist(name, p.fn)
-	if r.progtype == progFunc {
+	if r != nil && r.progtype == progFunc {
 		return r
 	} else {
 		return nil
@@ -145,7 +159,7 @@ func (p *Module) getFunction(name string) *program {
 
 func (p *Module) getIterator(name string) *program {
 	r, _ := p.getfromlist(name, p.fn)
-	if r.progtype == progIter {
+	if r != nil && r.progtype == progIter {
 		return r
 	} else {
 		return nil
@@ -189,41 +203,128 @@ func (p *Module) GetFunction(name string) Val {
 	}
 }
 
+// Name of all the rules, ie event name. Event with multiple rules shows up
+// once
+func (p *Module) RuleList() []string {
+	o := []string{}
+	seen := make(map[string]bool)
+	for _, prog := range p.p {
+		if !seen[prog.name] {
+			seen[prog.name] = true
+			o = append(o, prog.name)
+		}
+	}
+	return o
+}
+
+// Name of all the named script functions, including the one from imported
+// module. Anonymous function and iterator are not included
+func (p *Module) FunctionList() []string {
+	o := []string{}
+	for _, prog := range p.fn {
+		if prog.progtype == progFunc &&
+			!strings.HasPrefix(prog.name, "[anonymous_function_") {
+			o = append(o, prog.name)
+		}
+	}
+	return o
+}
+
 func (p *Module) HasSession() bool {
 	return len(p.session) != 0
 }
 
+const (
+	// only the first rule whose guard is satisfied is executed
+	RuleMatchFirst = iota
+
+	// all the rules whose guard is satisfied are executed in order
+	RuleMatchAll
+)
+
+func (p *Module) SetRuleMatch(mode int) {
+	p.ruleMatch = mode
+}
+
+func (p *Module) RuleMatch() int {
+	return p.ruleMatch
+}
+
 func (p *Module) HasConfig() bool {
 	return p.config != nil
 }
 
+// All the programs of the module, ie global, session, config, rules with their
+// guards and functions
+func (p *Module) allProgram() []*program {
+	o := []*program{}
+	if p.global != nil {
+		o = append(o, p.global.globalProgram...)
+	}
+	o = append(o, p.session...)
+	if p.config != nil {
+		o = append(o, p.config)
+	}
+	o = append(o, p.p...)
+	for _, prog := range p.p {
+		if prog.guard != nil {
+			o = append(o, prog.guard)
+		}
+	}
+	o = append(o, p.fn...)
+	return o
+}
+
 func (p *Module) HasGlobal() bool {
 	return len(p.global.globalProgram) != 0
 }
 
 func (p *Module) HaveEvent(name string) bool {
-	_, ok := p.eventMap[name]
-	return ok
+	return p.HasEvent(name)
 }
 
-func (p *Module) addEvent(name string, prog *program) bool {
-	if p.HaveEvent(name) {
-		return false
+// Rules of the same event are kept in order of priority, ie higher first, and
+// the declaration order for the same priority
+func (p *Module) addEvent(name string, prog *program) {
+	l := append(p.eventMap[name], prog)
+	sort.SliceStable(l, func(i, j int) bool {
+		return l[i].priority > l[j].priority
+	})
+	p.eventMap[name] = l
+
+	if isEventPattern(name) {
+		p.eventTrie.add(name)
 	}
-	p.eventMap[name] = prog
-	return true
 }
 
-func (p *Module) findEvent(name string) *program {
-	v, ok := p.eventMap[name]
-	if !ok {
-		return nil
-	} else {
-		return v
+// Rules which handle the event, the rules of the exact event name come first
+// and then the rules of the matched patterns from the most specific one
+func (p *Module) findEvent(name string) []*program {
+	exact := p.eventMap[name]
+	patterns := p.eventTrie.match(name)
+	if len(patterns) == 0 {
+		return exact
+	}
+
+	o := append([]*program(nil), exact...)
+	for _, x := range patterns {
+		if x.name != name {
+			o = append(o, p.eventMap[x.name]...)
+		}
 	}
+	return o
 }
 
 func (p *Module) HasEvent(name string) bool {
+	if _, ok := p.eventMap[name]; ok {
+		return true
+	}
+	return len(p.eventTrie.match(name)) != 0
+}
+
+// HasExactEvent tells whether any rule is declared with the name itself, the
+// pattern rules matching the name are not counted
+func (p *Module) HasExactEvent(name string) bool {
 	_, ok := p.eventMap[name]
 	return ok
 }
@@ -238,6 +339,12 @@ func (p *Module) Dump() string {
 
 	b.WriteString("rules>    -------------------------------- \n")
 	for _, p := range p.p {
+		if p.guard != nil {
+			b.WriteString(fmt.Sprintf(":when (%s) priority %d\n", p.guardSrc, p.priority))
+			b.WriteString(p.guard.dump())
+		} else if p.priority != 0 {
+			b.WriteString(fmt.Sprintf(":priority %d\n", p.priority))
+		}
 		b.WriteString(p.dump())
 		b.WriteRune('\n')
 	}
@@ -251,9 +358,43 @@ func CompileModule(module string, fs fs.FS) (*Module, error) {
 	if err != nil {
 		return nil, err
 	}
+	po.fs = fs
+	for _, prog := range po.allProgram() {
+		prog.initInlineCache()
+	}
 	return po, nil
 }
 
+func (m *Module) FS() fs.FS {
+	return m.fs
+}
+
+// Get the compiled template from the module's cache or compile it with the
+// compile function and cache it
+func (m *Module) compileTemplate(
+	key string,
+	t string,
+	compile func(Template, string) error,
+) (Template, error) {
+	if temp, ok := m.templateCache[key]; ok {
+		return temp, nil
+	}
+
+	temp := newTemplate(t)
+	if temp == nil {
+		return nil, fmt.Errorf("unsupported template type %s", t)
+	}
+	if err := compile(temp, fmt.Sprintf("temp-%d", len(m.templateCache))); err != nil {
+		return nil, err
+	}
+
+	if m.templateCache == nil {
+		m.templateCache = make(map[string]Template)
+	}
+	m.templateCache[key] = temp
+	return temp, nil
+}
+
 func (g *globalState) size() int {
 	g.lock.RLock()
 	defer func() {
@@ -0,0 +1,176 @@
+package pl
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+var (
+	// bytes#method
+	mpBytesLength   = MustNewFuncProto("bytes.length", "%0")
+	mpBytesSlice    = MustNewFuncProto("bytes.slice", "{%d}{%d%d}")
+	mpBytesToString = MustNewFuncProto("bytes.to_string", "%0")
+	mpBytesToHex    = MustNewFuncProto("bytes.to_hex", "%0")
+	mpBytesToBase64 = MustNewFuncProto("bytes.to_base64", "%0")
+	mpBytesToList   = MustNewFuncProto("bytes.to_list", "%0")
+	mpBytesIndexOf  = MustNewFuncProto("bytes.index_of", "{%B}{%B%d}")
+)
+
+// bytes value is immutable from the script's perspective, every operation
+// that produces bytes allocates a new slice, so the value can be shared
+// among evaluators safely
+func concatBytes(lhs, rhs []byte) []byte {
+	o := make([]byte, 0, len(lhs)+len(rhs))
+	o = append(o, lhs...)
+	return append(o, rhs...)
+}
+
+// convert value to bytes, string is converted as its raw bytes, list must
+// contain integer in range [0, 255]
+func ToBytes(v Val) ([]byte, error) {
+	switch v.Type() {
+	case ValBytes:
+		return v.Bytes(), nil
+
+	case ValStr:
+		return []byte(v.String()), nil
+
+	case ValList:
+		o := make([]byte, 0, v.List().Length())
+		for _, x := range v.List().Data {
+			if !x.IsInt() || x.Int() < 0 || x.Int() > 255 {
+				return nil, fmt.Errorf("bytes: list element must be int in range [0, 255]")
+			}
+			o = append(o, byte(x.Int()))
+		}
+		return o, nil
+
+	default:
+		return nil, fmt.Errorf("bytes: cannot convert type %s to bytes", v.Id())
+	}
+}
+
+type bytesiter struct {
+	b   []byte
+	cnt int
+}
+
+func newBytesIter(b []byte) Iter {
+	return &bytesiter{
+		b:   b,
+		cnt: 0,
+	}
+}
+
+func (s *bytesiter) SetUp(*Evaluator, []Val) error {
+	return nil
+}
+
+func (s *bytesiter) Has() bool {
+	return s.cnt < len(s.b)
+}
+
+func (s *bytesiter) Next() (bool, error) {
+	s.cnt++
+	return s.Has(), nil
+}
+
+func (s *bytesiter) Deref() (Val, Val, error) {
+	if s.Has() {
+		return NewValInt(s.cnt), NewValInt(int(s.b[s.cnt])), nil
+	}
+	return NewValNull(), NewValNull(), fmt.Errorf("iterator is out of bound")
+}
+
+func (v *Val) methodBytes(name string, args []Val) (Val, error) {
+	b := v.Bytes()
+
+	switch name {
+	case "length":
+		_, err := mpBytesLength.Check(args)
+		if err != nil {
+			return NewValNull(), err
+		}
+		return NewValInt(len(b)), nil
+
+	case "slice":
+		alog, err := mpBytesSlice.Check(args)
+		if err != nil {
+			return NewValNull(), err
+		}
+		length := len(b)
+		start := int(args[0].Int())
+		end := length
+		if alog == 2 {
+			end = int(args[1].Int())
+		}
+		if end > length {
+			end = length
+		}
+		if start > end {
+			start = end
+		}
+		if start < 0 {
+			return NewValNull(), fmt.Errorf("bytes.slice: negative index")
+		}
+
+		o := make([]byte, end-start)
+		copy(o, b[start:end])
+		return NewValBytes(o), nil
+
+	case "to_string":
+		_, err := mpBytesToString.Check(args)
+		if err != nil {
+			return NewValNull(), err
+		}
+		return NewValStr(string(b)), nil
+
+	case "to_hex":
+		_, err := mpBytesToHex.Check(args)
+		if err != nil {
+			return NewValNull(), err
+		}
+		return NewValStr(hex.EncodeToString(b)), nil
+
+	case "to_base64":
+		_, err := mpBytesToBase64.Check(args)
+		if err != nil {
+			return NewValNull(), err
+		}
+		return NewValStr(base64.StdEncoding.EncodeToString(b)), nil
+
+	case "to_list":
+		_, err := mpBytesToList.Check(args)
+		if err != nil {
+			return NewValNull(), err
+		}
+		o := make([]Val, 0, len(b))
+		for _, x := range b {
+			o = append(o, NewValInt(int(x)))
+		}
+		return NewValListRaw(o), nil
+
+	case "index_of":
+		alog, err := mpBytesIndexOf.Check(args)
+		if err != nil {
+			return NewValNull(), err
+		}
+		start := 0
+		if alog == 2 {
+			start = int(args[1].Int())
+			if start < 0 || start > len(b) {
+				return NewValNull(), fmt.Errorf("bytes.index_of: index out of range")
+			}
+		}
+		where := bytes.Index(b[start:], args[0].Bytes())
+		if where == -1 {
+			return NewValInt(-1), nil
+		}
+		return NewValInt(where + start), nil
+
+	default:
+		return NewValNull(), fmt.Errorf("method: bytes:%s is unknown", name)
+	}
+}
@@ -0,0 +1,87 @@
+package pl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBytesBasic(t *testing.T) {
+	err := testAssertRule(t, `
+test {
+  let b = b"ab\x00\xff";
+  assert::eq(type(b), "bytes");
+  assert::eq(b:length(), 4);
+  assert::eq(b[0], 97);
+  assert::eq(b[2], 0);
+  assert::eq(b[3], 255);
+
+  assert::eq(b:slice(1), b"b\x00\xff");
+  assert::eq(b:slice(1, 2), b"b");
+  assert::eq(b:slice(3, 100), b"\xff");
+  assert::eq(b:index_of(b"\x00"), 2);
+  assert::eq(b:index_of(b"a", 1), -1);
+  assert::eq(b:to_list(), [97, 98, 0, 255]);
+
+  // conversion
+  assert::eq(b:to_hex(), "616200ff");
+  assert::eq(b:to_base64(), "YWIA/w==");
+  assert::eq(bytes::from_hex("616200ff"), b);
+  assert::eq(bytes::from_base64("YWIA/w=="), b);
+  assert::eq(b"abc":to_string(), "abc");
+  assert::eq(to_bytes("abc"), b"abc");
+  assert::eq(to_bytes([97, 98]), b"ab");
+  assert::eq(to_string(b"abc"), "abc");
+
+  // concatenation and comparison
+  assert::eq(b"a" + b"b", b"ab");
+  assert::eq(b"a" + "b", b"ab");
+  assert::eq("a" + b"b", b"ab");
+  assert::eq(bytes::concat(b"a", "b", b"\x01"), b"ab\x01");
+  assert::eq(b"a" == b"a", true);
+  assert::eq(b"a" != b"b", true);
+  assert::eq(b"a" < b"b", true);
+  assert::eq(b"ab" ~ r"^a", true);
+
+  // big endian integer
+  assert::eq(bytes::from_int(258, 2), b"\x01\x02");
+  assert::eq(bytes::to_int(b"\x01\x02"), 258);
+  assert::eq(bytes::to_int(bytes::from_int(7, 8)), 7);
+
+  // codec
+  assert::eq(codec::b64_tostring(b"\xff"), "/w==");
+  assert::eq(codec::b64_tobytes("/w=="), b"\xff");
+  assert::eq(codec::hex_encode(b"\x01\xab"), "01ab");
+  assert::eq(codec::hex_decode("01ab"), b"\x01\xab");
+
+  let sum = 0;
+  for let i, v = b"\x01\x02\x03" {
+    sum += v;
+  }
+  assert::eq(sum, 6);
+  if b"" {
+    assert::yes(false);
+  }
+}
+`)
+	assert.True(t, err == nil, "%s", err)
+}
+
+func TestBytesError(t *testing.T) {
+	for _, code := range []string{
+		`test { b"a"[1]; }`,
+		`test { let b = b"a"; b[0] = 1; }`,
+		`test { to_bytes([256]); }`,
+		`test { bytes::from_hex("zz"); }`,
+		`test { bytes::to_int(b"\x01\x02\x03"); }`,
+		`test { b"a" + 1; }`,
+	} {
+		assert.True(t, testAssertRule(t, code) != nil, code)
+	}
+
+	// \x is only allowed inside of bytes literal
+	_, err := CompileModule(`test { let a = "\x01"; }`, nil)
+	assert.True(t, err != nil)
+	_, err = CompileModule(`test { let a = b"\x0"; }`, nil)
+	assert.True(t, err != nil)
+}
@@ -0,0 +1,111 @@
+package pl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testAssertRule(t *testing.T, code string) error {
+	module, err := CompileModule(code, nil)
+	assert.True(t, err == nil, "%s", err)
+	if err != nil {
+		return err
+	}
+	_, err = NewEvaluatorSimple().Eval("test", module)
+	return err
+}
+
+func TestListHigherOrder(t *testing.T) {
+	err := testAssertRule(t, `
+fn double(x) {
+  return x * 2;
+}
+test {
+  let l = [3, 1, 2];
+
+  // script function, closure and native function
+  assert::eq(l:map(double), [6, 2, 4]);
+  assert::eq(l:map(fn(x) { return x + 1; }), [4, 2, 3]);
+  assert::eq(["a", "b"]:map(str::to_upper), ["A", "B"]);
+  assert::eq(l, [3, 1, 2]);
+
+  assert::eq(l:filter(fn(x) { return x > 1; }), [3, 2]);
+  assert::eq(l:reduce(fn(a, x) { return a + x; }), 6);
+  assert::eq(l:reduce(fn(a, x) { return a + x; }, 10), 16);
+  assert::eq([]:reduce(fn(a, x) { return a + x; }), null);
+
+  assert::eq(l:find(fn(x) { return x < 3; }), 1);
+  assert::eq(l:find(fn(x) { return x > 3; }), null);
+  assert::eq(l:any(fn(x) { return x == 2; }), true);
+  assert::eq(l:any(fn(x) { return x == 4; }), false);
+  assert::eq(l:all(fn(x) { return x > 0; }), true);
+  assert::eq(l:all(fn(x) { return x > 1; }), false);
+  assert::eq([]:all(fn(x) { return false; }), true);
+
+  assert::eq(l:index_of(2), 2);
+  assert::eq(l:index_of("x"), -1);
+
+  // sort, reverse, insert and remove_at modify the list
+  assert::eq([3, 1, 2]:sort(), [1, 2, 3]);
+  assert::eq([3, 1, 2]:sort(fn(a, b) { return a > b; }), [3, 2, 1]);
+  assert::eq(["b", "c", "a"]:sort(str::cmp), ["a", "b", "c"]);
+  assert::eq([1, 2, 3]:reverse(), [3, 2, 1]);
+  assert::eq([1, 3]:insert(1, 2), [1, 2, 3]);
+  assert::eq([1, 2]:insert(2, 3), [1, 2, 3]);
+  assert::eq([1, 2, 3]:remove_at(0), [2, 3]);
+
+  // chaining
+  assert::eq(
+    [1, 2, 3, 4]:filter(fn(x) { return x % 2 == 0; }):map(double):reduce(fn(a, x) { return a + x; }),
+    12
+  );
+}
+`)
+	assert.True(t, err == nil, "%s", err)
+}
+
+func TestListHigherOrderError(t *testing.T) {
+	for _, code := range []string{
+		`test { [1]:map(1); }`,
+		`test { [1]:map(fn(a, b) { return a; }); }`,
+		`test { [1]:map(fn(x) { return x / 0; }); }`,
+		`test { [2, 1]:sort(fn(a, b) { return "x"; }); }`,
+		`test { [1, "a"]:sort(); }`,
+		`test { [1]:insert(2, 1); }`,
+		`test { [1]:remove_at(1); }`,
+	} {
+		assert.True(t, testAssertRule(t, code) != nil, code)
+	}
+}
+
+func TestMapHigherOrder(t *testing.T) {
+	err := testAssertRule(t, `
+test {
+  let m = {"b": 2, "a": 1, "c": 3};
+
+  // insertion order is kept
+  assert::eq(m:keys(), ["b", "a", "c"]);
+  assert::eq(m:values(), [2, 1, 3]);
+
+  let e = m:entries();
+  assert::eq(e:length(), 3);
+  assert::eq(e[0].first, "b");
+  assert::eq(e[0].second, 2);
+
+  assert::eq(m:map_values(fn(x) { return x * 10; }), {"b": 20, "a": 10, "c": 30});
+  assert::eq(m:filter(fn(k, v) { return k != "a" && v < 3; }), {"b": 2});
+  assert::eq(m:length(), 3);
+
+  assert::eq({"a": 1}:merge({"b": 2}, {"a": 3}), {"a": 3, "b": 2});
+
+  // transforming upstream json, ie a list of map
+  let users = [{"name": "x", "age": 10}, {"name": "y", "age": 20}];
+  assert::eq(
+    users:filter(fn(u) { return u.age > 15; }):map(fn(u) { return u.name; }),
+    ["y"]
+  );
+}
+`)
+	assert.True(t, err == nil, "%s", err)
+}
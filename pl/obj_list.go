@@ -2,6 +2,7 @@ package pl
 
 import (
 	"fmt"
+	"sort"
 )
 
 var (
@@ -10,6 +11,19 @@ var (
 	mpListPopBack  = MustNewFuncProto("list.pop_back", "{%d}{%0}")
 	mpListExtend   = MustNewFuncProto("list.extend", "%l")
 	mpListSlice    = MustNewFuncProto("list.slice", "{%d}{%d%d}")
+
+	// higher order method, the closure is invoked via Closure.Call
+	mpListMap      = MustNewFuncProto("list.map", "%c")
+	mpListFilter   = MustNewFuncProto("list.filter", "%c")
+	mpListReduce   = MustNewFuncProto("list.reduce", "{%c}{%c%a}")
+	mpListFind     = MustNewFuncProto("list.find", "%c")
+	mpListAny      = MustNewFuncProto("list.any", "%c")
+	mpListAll      = MustNewFuncProto("list.all", "%c")
+	mpListSort     = MustNewFuncProto("list.sort", "{%0}{%c}")
+	mpListReverse  = MustNewFuncProto("list.reverse", "%0")
+	mpListIndexOf  = MustNewFuncProto("list.index_of", "%a")
+	mpListInsert   = MustNewFuncProto("list.insert", "%d%a")
+	mpListRemoveAt = MustNewFuncProto("list.remove_at", "%d")
 )
 
 type List struct {
@@ -193,3 +207,230 @@ func (l *List) Method(name string, args []Val) (Val, error) {
 		return NewValNull(), fmt.Errorf("method: list:%s is unknown", name)
 	}
 }
+
+// invoke the closure and returns its result as boolean, used by the predicate
+// of the higher order method
+func callPredicate(e *Evaluator, fn Val, args ...Val) (bool, error) {
+	r, err := fn.Closure().Call(e, args)
+	if err != nil {
+		return false, err
+	}
+	return r.ToBoolean(), nil
+}
+
+// comparison of the sort method, the comparator returns a boolean which means
+// less than, or an integer which is negative when less than. Without comparator
+// the < operator is used
+func lessThan(e *Evaluator, cmp Val, lhs Val, rhs Val) (bool, error) {
+	if cmp.IsNull() {
+		r, err := e.doBin(lhs, rhs, bcLt)
+		if err != nil {
+			return false, err
+		}
+		return r.Bool(), nil
+	}
+
+	r, err := cmp.Closure().Call(e, []Val{lhs, rhs})
+	if err != nil {
+		return false, err
+	}
+	switch r.Type() {
+	case ValBool:
+		return r.Bool(), nil
+	case ValInt:
+		return r.Int() < 0, nil
+	default:
+		return false, fmt.Errorf("list.sort comparator must return bool or int, "+
+			"but got type: %s", r.Id())
+	}
+}
+
+func (l *List) IndexOf(e *Evaluator, x Val) int {
+	for idx, v := range l.Data {
+		if r, err := e.doBin(v, x, bcEq); err == nil && r.Bool() {
+			return idx
+		}
+	}
+	return -1
+}
+
+// MethodEval is the method invoked by the script, it handles the higher order
+// method which invokes its closure argument and falls back to Method for the
+// rest
+func (l *List) MethodEval(e *Evaluator, name string, args []Val) (Val, error) {
+	switch name {
+	case "map":
+		_, err := mpListMap.Check(args)
+		if err != nil {
+			return NewValNull(), err
+		}
+		fn := args[0]
+		o := make([]Val, 0, len(l.Data))
+		for _, v := range l.Data {
+			r, err := fn.Closure().Call(e, []Val{v})
+			if err != nil {
+				return NewValNull(), err
+			}
+			o = append(o, r)
+		}
+		return NewValListRaw(o), nil
+
+	case "filter":
+		_, err := mpListFilter.Check(args)
+		if err != nil {
+			return NewValNull(), err
+		}
+		fn := args[0]
+		o := []Val{}
+		for _, v := range l.Data {
+			ok, err := callPredicate(e, fn, v)
+			if err != nil {
+				return NewValNull(), err
+			}
+			if ok {
+				o = append(o, v)
+			}
+		}
+		return NewValListRaw(o), nil
+
+	case "reduce":
+		alog, err := mpListReduce.Check(args)
+		if err != nil {
+			return NewValNull(), err
+		}
+		fn := args[0]
+		data := l.Data
+
+		// without initial value, the first element is used
+		var acc Val
+		if alog == 2 {
+			acc = args[1]
+		} else if len(data) == 0 {
+			return NewValNull(), nil
+		} else {
+			acc = data[0]
+			data = data[1:]
+		}
+
+		for _, v := range data {
+			acc, err = fn.Closure().Call(e, []Val{acc, v})
+			if err != nil {
+				return NewValNull(), err
+			}
+		}
+		return acc, nil
+
+	case "find":
+		_, err := mpListFind.Check(args)
+		if err != nil {
+			return NewValNull(), err
+		}
+		for _, v := range l.Data {
+			ok, err := callPredicate(e, args[0], v)
+			if err != nil {
+				return NewValNull(), err
+			}
+			if ok {
+				return v, nil
+			}
+		}
+		return NewValNull(), nil
+
+	case "any", "all":
+		var err error
+		if name == "any" {
+			_, err = mpListAny.Check(args)
+		} else {
+			_, err = mpListAll.Check(args)
+		}
+		if err != nil {
+			return NewValNull(), err
+		}
+
+		// any stops at the first true and all stops at the first false
+		stop := name == "any"
+		for _, v := range l.Data {
+			ok, err := callPredicate(e, args[0], v)
+			if err != nil {
+				return NewValNull(), err
+			}
+			if ok == stop {
+				return NewValBool(stop), nil
+			}
+		}
+		return NewValBool(!stop), nil
+
+	case "sort":
+		alog, err := mpListSort.Check(args)
+		if err != nil {
+			return NewValNull(), err
+		}
+		cmp := NewValNull()
+		if alog == 1 {
+			cmp = args[0]
+		}
+
+		var serr error
+		sort.SliceStable(l.Data, func(i, j int) bool {
+			if serr != nil {
+				return false
+			}
+			ok, err := lessThan(e, cmp, l.Data[i], l.Data[j])
+			if err != nil {
+				serr = err
+				return false
+			}
+			return ok
+		})
+		if serr != nil {
+			return NewValNull(), serr
+		}
+		return NewValListFromList(l), nil
+
+	case "reverse":
+		_, err := mpListReverse.Check(args)
+		if err != nil {
+			return NewValNull(), err
+		}
+		for i, j := 0, len(l.Data)-1; i < j; i, j = i+1, j-1 {
+			l.Data[i], l.Data[j] = l.Data[j], l.Data[i]
+		}
+		return NewValListFromList(l), nil
+
+	case "index_of":
+		_, err := mpListIndexOf.Check(args)
+		if err != nil {
+			return NewValNull(), err
+		}
+		return NewValInt(l.IndexOf(e, args[0])), nil
+
+	case "insert":
+		_, err := mpListInsert.Check(args)
+		if err != nil {
+			return NewValNull(), err
+		}
+		idx := int(args[0].Int())
+		if idx < 0 || idx > len(l.Data) {
+			return NewValNull(), fmt.Errorf("list.insert index out of range")
+		}
+		l.Data = append(l.Data, NewValNull())
+		copy(l.Data[idx+1:], l.Data[idx:])
+		l.Data[idx] = args[1]
+		return NewValListFromList(l), nil
+
+	case "remove_at":
+		_, err := mpListRemoveAt.Check(args)
+		if err != nil {
+			return NewValNull(), err
+		}
+		idx := int(args[0].Int())
+		if idx < 0 || idx >= len(l.Data) {
+			return NewValNull(), fmt.Errorf("list.remove_at index out of range")
+		}
+		l.Data = append(l.Data[:idx], l.Data[idx+1:]...)
+		return NewValListFromList(l), nil
+
+	default:
+		return l.Method(name, args)
+	}
+}
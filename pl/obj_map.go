@@ -12,6 +12,14 @@ var (
 	mpMapTryGet = MustNewFuncProto("map.tryGet", "%s%a")
 	mpMapGet    = MustNewFuncProto("map.get", "%s")
 	mpMapHas    = MustNewFuncProto("map.has", "%s")
+
+	// higher order method, the closure is invoked via Closure.Call
+	mpMapKeys      = MustNewFuncProto("map.keys", "%0")
+	mpMapValues    = MustNewFuncProto("map.values", "%0")
+	mpMapEntries   = MustNewFuncProto("map.entries", "%0")
+	mpMapMerge     = MustNewFuncProto("map.merge", "%m*")
+	mpMapMapValues = MustNewFuncProto("map.map_values", "%c")
+	mpMapFilter    = MustNewFuncProto("map.filter", "%c")
 )
 
 type mapval struct {
@@ -295,6 +303,126 @@ func (m *Map) Method(name string, args []Val) (Val, error) {
 	}
 }
 
+// Foreach in insertion order, ie the same order as the iterator
+func (m *Map) ForeachOrdered(f func(string, Val) bool) int {
+	cnt := 0
+	for _, k := range m.key {
+		if !k.use {
+			continue
+		}
+		v, ok := m.data[k.key]
+		if !ok {
+			continue
+		}
+		if !f(k.key, v.val) {
+			break
+		}
+		cnt++
+	}
+	return cnt
+}
+
+// MethodEval is the method invoked by the script, it handles the higher order
+// method which invokes its closure argument and falls back to Method for the
+// rest. The result keeps the insertion order of the map
+func (m *Map) MethodEval(e *Evaluator, name string, args []Val) (Val, error) {
+	switch name {
+	case "keys":
+		_, err := mpMapKeys.Check(args)
+		if err != nil {
+			return NewValNull(), err
+		}
+		o := make([]Val, 0, m.Length())
+		m.ForeachOrdered(func(k string, _ Val) bool {
+			o = append(o, NewValStr(k))
+			return true
+		})
+		return NewValListRaw(o), nil
+
+	case "values":
+		_, err := mpMapValues.Check(args)
+		if err != nil {
+			return NewValNull(), err
+		}
+		o := make([]Val, 0, m.Length())
+		m.ForeachOrdered(func(_ string, v Val) bool {
+			o = append(o, v)
+			return true
+		})
+		return NewValListRaw(o), nil
+
+	case "entries":
+		_, err := mpMapEntries.Check(args)
+		if err != nil {
+			return NewValNull(), err
+		}
+		o := make([]Val, 0, m.Length())
+		m.ForeachOrdered(func(k string, v Val) bool {
+			o = append(o, NewValPair(NewValStr(k), v))
+			return true
+		})
+		return NewValListRaw(o), nil
+
+	case "merge":
+		_, err := mpMapMerge.Check(args)
+		if err != nil {
+			return NewValNull(), err
+		}
+		for _, that := range args {
+			that.Map().ForeachOrdered(func(k string, v Val) bool {
+				m.Set(k, v)
+				return true
+			})
+		}
+		return NewValMapFromMap(m), nil
+
+	case "map_values":
+		_, err := mpMapMapValues.Check(args)
+		if err != nil {
+			return NewValNull(), err
+		}
+		o := NewMap()
+		m.ForeachOrdered(func(k string, v Val) bool {
+			var r Val
+			r, err = args[0].Closure().Call(e, []Val{v})
+			if err != nil {
+				return false
+			}
+			o.Set(k, r)
+			return true
+		})
+		if err != nil {
+			return NewValNull(), err
+		}
+		return NewValMapFromMap(o), nil
+
+	case "filter":
+		_, err := mpMapFilter.Check(args)
+		if err != nil {
+			return NewValNull(), err
+		}
+		o := NewMap()
+		m.ForeachOrdered(func(k string, v Val) bool {
+			var ok bool
+			ok, err = callPredicate(e, args[0], NewValStr(k), v)
+			if err != nil {
+				return false
+			}
+			if ok {
+				o.Set(k, v)
+			}
+			return true
+		})
+		if err != nil {
+			return NewValNull(), err
+		}
+		return NewValMapFromMap(o), nil
+
+	default:
+		return m.Method(name, args)
+	}
+}
+
 func (m *Map) Info() string {
 	return fmt.Sprintf("[map: %d]", m.Length())
 }
@@ -3,6 +3,7 @@ package pl
 import (
 	"fmt"
 	"reflect"
+	"time"
 )
 
 // quick go interface{} to pl.Val style
@@ -19,12 +20,29 @@ func marshalValue(value reflect.Value) (Val, error) {
 
 	// 2. byte array
 	if value.Kind() == reflect.Slice {
-		ev := value.Elem()
-		ifc := value.Interface()
-		if ev.Kind() == reflect.Uint8 {
-			barray, ok := ifc.([]byte)
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			barray, ok := value.Interface().([]byte)
 			must(ok, "must be convertable")
-			return NewValStr(string(barray)), nil
+			return NewValBytes(barray), nil
+		}
+	}
+
+	// 3. time.Time and time.Duration, convert to time and duration value
+	if value.Type() == timeType && value.CanInterface() {
+		t, _ := value.Interface().(time.Time)
+		return NewValTime(t), nil
+	}
+	if value.Type() == durationType {
+		return NewValDuration(time.Duration(value.Int())), nil
+	}
+
+	// 4. Val and Usr are stored as is
+	if value.CanInterface() {
+		switch x := value.Interface().(type) {
+		case Val:
+			return x, nil
+		case Usr:
+			return NewValUsr(x), nil
 		}
 	}
 
@@ -64,7 +82,7 @@ func marshalValue(value reflect.Value) (Val, error) {
 	case reflect.Array:
 		return marshalArray(value)
 
-	case reflect.Interface:
+	case reflect.Interface, reflect.Ptr:
 		return marshalValue(value.Elem())
 
 	case reflect.Map:
@@ -119,17 +137,22 @@ func marshalMap(v reflect.Value) (Val, error) {
 	return m, nil
 }
 
+// the field name follows the same rule as UnmarshalVal, unexported field is
+// ignored
 func marshalStruct(v reflect.Value) (Val, error) {
 	m := NewValMap()
 	n := v.NumField()
 	t := v.Type()
 	for i := 0; i < n; i++ {
-		name := t.Field(i).Name
-		v, err := marshalValue(v.Index(i))
+		tag, ok := parseFieldTag(t.Field(i))
+		if !ok {
+			continue
+		}
+		fv, err := marshalValue(v.Field(i))
 		if err != nil {
 			return NewValNull(), err
 		}
-		m.AddMap(name, v)
+		m.AddMap(tag.name, fv)
 	}
 	return m, nil
 }
@@ -4,16 +4,31 @@ import (
 	"fmt"
 )
 
+// method function which needs the evaluator, ie the method that invokes its
+// closure argument
+type methodEvalFn func(*Evaluator, string, []Val) (Val, error)
+
 type methodFunc struct {
-	recv  Val
-	name  string
-	entry MethodFn
+	recv   Val
+	name   string
+	entry  MethodFn
+	eentry methodEvalFn
+
+	// method resolved via dispatch table of user type
+	urecv  Usr
+	uentry UsrMethodFn
 }
 
 func (f *methodFunc) Call(
-	_ *Evaluator,
+	e *Evaluator,
 	args []Val,
 ) (Val, error) {
+	if f.eentry != nil {
+		return f.eentry(e, f.name, args)
+	}
+	if f.uentry != nil {
+		return f.uentry(f.urecv, args)
+	}
 	return f.entry(f.name, args)
 }
 
@@ -50,3 +65,25 @@ func newMethodFunc(
 		name:  name,
 	}
 }
+
+func newMethodEvalFunc(
+	entry methodEvalFn,
+	name string,
+) *methodFunc {
+	return &methodFunc{
+		eentry: entry,
+		name:   name,
+	}
+}
+
+func newUsrMethodFunc(
+	recv Usr,
+	entry UsrMethodFn,
+	name string,
+) *methodFunc {
+	return &methodFunc{
+		urecv:  recv,
+		uentry: entry,
+		name:   name,
+	}
+}
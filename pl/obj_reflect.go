@@ -0,0 +1,200 @@
+package pl
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Reflection based Usr adapter. It exposes a Go struct pointer into PL without
+// writing the Index/Dot/Method boilerplate. The exported fields are accessible
+// via dot/index, the naming rule of the field is the same as UnmarshalVal, and
+// the exported methods of the pointer type are callable via method call. Both
+// field and method name are matched case insensitively, ie a Go method named
+// Sum can be invoked as obj:sum().
+//
+// The arguments of method are converted via UnmarshalVal and the return value
+// is converted via MarshalVal. The method can return nothing, a value, an error
+// or a value with an error.
+
+type reflectType struct {
+	field  map[string]int
+	method map[string]int
+}
+
+var reflectTypeCache sync.Map
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+func getReflectType(t reflect.Type) *reflectType {
+	if x, ok := reflectTypeCache.Load(t); ok {
+		return x.(*reflectType)
+	}
+
+	rt := &reflectType{
+		field:  make(map[string]int),
+		method: make(map[string]int),
+	}
+
+	st := t.Elem()
+	for i := 0; i < st.NumField(); i++ {
+		if tag, ok := parseFieldTag(st.Field(i)); ok {
+			rt.field[strings.ToLower(tag.name)] = i
+		}
+	}
+	for i := 0; i < t.NumMethod(); i++ {
+		rt.method[strings.ToLower(t.Method(i).Name)] = i
+	}
+
+	x, _ := reflectTypeCache.LoadOrStore(t, rt)
+	return x.(*reflectType)
+}
+
+type reflectUsr struct {
+	id  string
+	ptr reflect.Value
+	rt  *reflectType
+}
+
+// Wrap the Go struct pointer as Usr with the specified type id
+func NewValReflect(id string, ptr interface{}) (Val, error) {
+	v := reflect.ValueOf(ptr)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return NewValNull(), fmt.Errorf("reflect: %s must be a non-nil struct pointer", id)
+	}
+	return NewValUsr(&reflectUsr{
+		id:  id,
+		ptr: v,
+		rt:  getReflectType(v.Type()),
+	}), nil
+}
+
+func (r *reflectUsr) field(name string) (reflect.Value, bool) {
+	idx, ok := r.rt.field[strings.ToLower(name)]
+	if !ok {
+		return reflect.Value{}, false
+	}
+	return r.ptr.Elem().Field(idx), true
+}
+
+func (r *reflectUsr) Index(name Val) (Val, error) {
+	if !name.IsString() {
+		return NewValNull(), fmt.Errorf("%s index: invalid index", r.id)
+	}
+	return r.Dot(name.String())
+}
+
+func (r *reflectUsr) IndexSet(name Val, value Val) error {
+	if !name.IsString() {
+		return fmt.Errorf("%s index set: invalid index", r.id)
+	}
+	return r.DotSet(name.String(), value)
+}
+
+func (r *reflectUsr) Dot(name string) (Val, error) {
+	f, ok := r.field(name)
+	if !ok {
+		return NewValNull(), fmt.Errorf("%s dot: unknown field %s", r.id, name)
+	}
+	return marshalValue(f)
+}
+
+func (r *reflectUsr) DotSet(name string, value Val) error {
+	f, ok := r.field(name)
+	if !ok {
+		return fmt.Errorf("%s dot set: unknown field %s", r.id, name)
+	}
+	return unmarshalValue(value, f, r.id+"."+name)
+}
+
+func (r *reflectUsr) Method(name string, args []Val) (Val, error) {
+	idx, ok := r.rt.method[strings.ToLower(name)]
+	if !ok {
+		return NewValNull(), fmt.Errorf("method: %s:%s is unknown", r.id, name)
+	}
+	m := r.ptr.Method(idx)
+	mt := m.Type()
+
+	nin := mt.NumIn()
+	if mt.IsVariadic() {
+		if len(args) < nin-1 {
+			return NewValNull(), fmt.Errorf("method: %s:%s expects at least %d arguments, but got %d",
+				r.id, name, nin-1, len(args))
+		}
+	} else if len(args) != nin {
+		return NewValNull(), fmt.Errorf("method: %s:%s expects %d arguments, but got %d",
+			r.id, name, nin, len(args))
+	}
+
+	in := make([]reflect.Value, 0, len(args))
+	for i, a := range args {
+		var pt reflect.Type
+		if mt.IsVariadic() && i >= nin-1 {
+			pt = mt.In(nin - 1).Elem()
+		} else {
+			pt = mt.In(i)
+		}
+		x := reflect.New(pt).Elem()
+		if err := unmarshalValue(a, x, fmt.Sprintf("%s:%s argument %d", r.id, name, i)); err != nil {
+			return NewValNull(), err
+		}
+		in = append(in, x)
+	}
+
+	return reflectReturn(m.Call(in))
+}
+
+func reflectReturn(out []reflect.Value) (Val, error) {
+	if len(out) != 0 && out[len(out)-1].Type() == errorType {
+		if e := out[len(out)-1]; !e.IsNil() {
+			return NewValNull(), e.Interface().(error)
+		}
+		out = out[:len(out)-1]
+	}
+
+	switch len(out) {
+	case 0:
+		return NewValNull(), nil
+	case 1:
+		return marshalValue(out[0])
+	default:
+		o := NewValList()
+		for _, x := range out {
+			v, err := marshalValue(x)
+			if err != nil {
+				return NewValNull(), err
+			}
+			o.AddList(v)
+		}
+		return o, nil
+	}
+}
+
+func (r *reflectUsr) ToString() (string, error) {
+	return r.Info(), nil
+}
+
+func (r *reflectUsr) ToJSON() (Val, error) {
+	return marshalValue(r.ptr)
+}
+
+func (r *reflectUsr) Id() string {
+	return r.id
+}
+
+func (r *reflectUsr) Info() string {
+	return fmt.Sprintf("[%s: %s]", r.id, r.ptr.Type().Elem())
+}
+
+func (r *reflectUsr) ToNative() interface{} {
+	return r.ptr.Interface()
+}
+
+func (r *reflectUsr) IsThreadSafe() bool {
+	return false
+}
+
+func (r *reflectUsr) NewIterator() (Iter, error) {
+	return nil, fmt.Errorf("%s does not support iterator", r.id)
+}
@@ -0,0 +1,95 @@
+package pl
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testCounter struct {
+	Name  string `pl:"name"`
+	Value int
+	Tags  []string
+	calls int
+}
+
+func (c *testCounter) Add(n int) int {
+	c.calls++
+	c.Value += n
+	return c.Value
+}
+
+func (c *testCounter) Join(sep string, parts ...string) string {
+	return strings.Join(parts, sep)
+}
+
+func (c *testCounter) Check(limit int) (bool, error) {
+	if c.Value > limit {
+		return false, fmt.Errorf("value %d is over limit %d", c.Value, limit)
+	}
+	return true, nil
+}
+
+func (c *testCounter) Reset() {
+	c.Value = 0
+}
+
+func TestReflectUsr(t *testing.T) {
+	c := &testCounter{Name: "hits", Tags: []string{"a"}}
+	v, err := NewValReflect("test.counter", c)
+	assert.True(t, err == nil)
+
+	module, err := CompileModule(`
+rule test {
+  assert::eq(id($), "test.counter");
+  assert::eq($.name, "hits");
+  assert::eq($["value"], 0);
+  assert::eq($.tags, ["a"]);
+
+  assert::eq($:add(2), 2);
+  assert::eq($:Add(3), 5);
+  assert::eq($:join("-", "x", "y"), "x-y");
+  assert::eq($:join(","), "");
+  assert::eq($:check(10), true);
+
+  $.value = 100;
+  $.tags = ["b", "c"];
+  assert::eq($.value, 100);
+}
+rule over {
+  $:check(10);
+}
+rule bad_arg {
+  $:add("x");
+}
+rule bad_field {
+  $.value = "x";
+}
+rule unknown {
+  $:nothing();
+}
+`, nil)
+	assert.True(t, err == nil, "%s", err)
+
+	_, err = NewEvaluatorSimple().EvalWithContext("test", v, module)
+	assert.True(t, err == nil, "%s", err)
+	assert.Equal(t, 100, c.Value)
+	assert.Equal(t, []string{"b", "c"}, c.Tags)
+	assert.Equal(t, 2, c.calls)
+
+	j, err := v.Usr().ToJSON()
+	assert.True(t, err == nil)
+	name, _ := j.Map().Get("name")
+	assert.Equal(t, "hits", name.String())
+	assert.False(t, j.Map().Has("calls"))
+
+	for _, name := range []string{"over", "bad_arg", "bad_field", "unknown"} {
+		_, err = NewEvaluatorSimple().EvalWithContext(name, v, module)
+		assert.True(t, err != nil, name)
+	}
+
+	_, err = NewValReflect("x", *c)
+	assert.True(t, err != nil)
+}
@@ -0,0 +1,432 @@
+package pl
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	TimeTypeId     = "time"
+	DurationTypeId = "duration"
+)
+
+// time value, wrapper of go's time.Time. The value is immutable, all the
+// method returns a new time value
+type Time struct {
+	t time.Time
+}
+
+// duration value, wrapper of go's time.Duration
+type Duration struct {
+	d time.Duration
+}
+
+func NewValTime(t time.Time) Val {
+	return NewValUsr(&Time{t: t})
+}
+
+func NewValDuration(d time.Duration) Val {
+	return NewValUsr(&Duration{d: d})
+}
+
+func ValIsTime(v Val) bool {
+	return v.Id() == TimeTypeId
+}
+
+func ValIsDuration(v Val) bool {
+	return v.Id() == DurationTypeId
+}
+
+func (t *Time) Time() time.Time {
+	return t.t
+}
+
+func (d *Duration) Duration() time.Duration {
+	return d.d
+}
+
+// predefined layout name, otherwise the layout is go's reference layout
+func timeLayout(name string) string {
+	switch name {
+	case "rfc3339":
+		return time.RFC3339
+	case "rfc3339nano":
+		return time.RFC3339Nano
+	case "rfc1123", "http":
+		return http.TimeFormat
+	case "rfc1123z":
+		return time.RFC1123Z
+	case "rfc822":
+		return time.RFC822
+	case "rfc850":
+		return time.RFC850
+	case "ansic":
+		return time.ANSIC
+	case "kitchen":
+		return time.Kitchen
+	case "date":
+		return "2006-01-02"
+	case "datetime":
+		return "2006-01-02 15:04:05"
+	default:
+		return name
+	}
+}
+
+// format time as HTTP-date, which is always in GMT
+func HttpDate(t time.Time) string {
+	return t.UTC().Format(http.TimeFormat)
+}
+
+// parse time without layout, RFC3339 is tried first and then all the
+// HTTP-date format
+func ParseTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+	t, err := http.ParseTime(s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("time: cannot parse %s, expect RFC3339 or HTTP-date", s)
+	}
+	return t, nil
+}
+
+func (t *Time) Index(name Val) (Val, error) {
+	if !name.IsString() {
+		return NewValNull(), fmt.Errorf("%s index: invalid index", t.Id())
+	}
+	return t.Dot(name.String())
+}
+
+func (t *Time) IndexSet(_ Val, _ Val) error {
+	return fmt.Errorf("%s index set: unsupported operation", t.Id())
+}
+
+func (t *Time) Dot(name string) (Val, error) {
+	switch name {
+	case "year":
+		return NewValInt(t.t.Year()), nil
+	case "month":
+		return NewValInt(int(t.t.Month())), nil
+	case "day":
+		return NewValInt(t.t.Day()), nil
+	case "hour":
+		return NewValInt(t.t.Hour()), nil
+	case "minute":
+		return NewValInt(t.t.Minute()), nil
+	case "second":
+		return NewValInt(t.t.Second()), nil
+	case "nanosecond":
+		return NewValInt(t.t.Nanosecond()), nil
+	case "weekday":
+		return NewValStr(t.t.Weekday().String()), nil
+	case "yearDay":
+		return NewValInt(t.t.YearDay()), nil
+	case "zone":
+		name, _ := t.t.Zone()
+		return NewValStr(name), nil
+	case "offset":
+		_, offset := t.t.Zone()
+		return NewValInt(offset), nil
+	default:
+		return NewValNull(), fmt.Errorf("%s dot: unknown field %s", t.Id(), name)
+	}
+}
+
+func (t *Time) DotSet(_ string, _ Val) error {
+	return fmt.Errorf("%s dot set: unsupported operation", t.Id())
+}
+
+var (
+	mpTimeFormat    = MustNewFuncProto("time.format", "%s")
+	mpTimeHttpDate  = MustNewFuncProto("time.http_date", "%0")
+	mpTimeAdd       = MustNewFuncProto("time.add", "%U['duration']")
+	mpTimeSub       = MustNewFuncProto("time.sub", "(%U['duration']|%U['time'])")
+	mpTimeBefore    = MustNewFuncProto("time.before", "%U['time']")
+	mpTimeAfter     = MustNewFuncProto("time.after", "%U['time']")
+	mpTimeEqual     = MustNewFuncProto("time.equal", "%U['time']")
+	mpTimeCompare   = MustNewFuncProto("time.compare", "%U['time']")
+	mpTimeTruncate  = MustNewFuncProto("time.truncate", "%U['duration']")
+	mpTimeRound     = MustNewFuncProto("time.round", "%U['duration']")
+	mpTimeIn        = MustNewFuncProto("time.in", "%s")
+	mpTimeUTC       = MustNewFuncProto("time.utc", "%0")
+	mpTimeUnix      = MustNewFuncProto("time.unix", "%0")
+	mpTimeUnixMilli = MustNewFuncProto("time.unix_milli", "%0")
+	mpTimeUnixNano  = MustNewFuncProto("time.unix_nano", "%0")
+	mpTimeIsZero    = MustNewFuncProto("time.is_zero", "%0")
+)
+
+func argTime(v Val) time.Time {
+	x, ok := v.Usr().(*Time)
+	must(ok, "must be time")
+	return x.t
+}
+
+func argDuration(v Val) time.Duration {
+	x, ok := v.Usr().(*Duration)
+	must(ok, "must be duration")
+	return x.d
+}
+
+func (t *Time) Method(name string, args []Val) (Val, error) {
+	switch name {
+	case "format":
+		if _, err := mpTimeFormat.Check(args); err != nil {
+			return NewValNull(), err
+		}
+		return NewValStr(t.t.Format(timeLayout(args[0].String()))), nil
+
+	case "http_date":
+		if _, err := mpTimeHttpDate.Check(args); err != nil {
+			return NewValNull(), err
+		}
+		return NewValStr(HttpDate(t.t)), nil
+
+	case "add":
+		if _, err := mpTimeAdd.Check(args); err != nil {
+			return NewValNull(), err
+		}
+		return NewValTime(t.t.Add(argDuration(args[0]))), nil
+
+	case "sub":
+		if _, err := mpTimeSub.Check(args); err != nil {
+			return NewValNull(), err
+		}
+		// time - time = duration, time - duration = time
+		if ValIsTime(args[0]) {
+			return NewValDuration(t.t.Sub(argTime(args[0]))), nil
+		}
+		return NewValTime(t.t.Add(-argDuration(args[0]))), nil
+
+	case "before":
+		if _, err := mpTimeBefore.Check(args); err != nil {
+			return NewValNull(), err
+		}
+		return NewValBool(t.t.Before(argTime(args[0]))), nil
+
+	case "after":
+		if _, err := mpTimeAfter.Check(args); err != nil {
+			return NewValNull(), err
+		}
+		return NewValBool(t.t.After(argTime(args[0]))), nil
+
+	case "equal":
+		if _, err := mpTimeEqual.Check(args); err != nil {
+			return NewValNull(), err
+		}
+		return NewValBool(t.t.Equal(argTime(args[0]))), nil
+
+	case "compare":
+		if _, err := mpTimeCompare.Check(args); err != nil {
+			return NewValNull(), err
+		}
+		that := argTime(args[0])
+		if t.t.Before(that) {
+			return NewValInt(-1), nil
+		} else if t.t.After(that) {
+			return NewValInt(1), nil
+		}
+		return NewValInt(0), nil
+
+	case "truncate":
+		if _, err := mpTimeTruncate.Check(args); err != nil {
+			return NewValNull(), err
+		}
+		return NewValTime(t.t.Truncate(argDuration(args[0]))), nil
+
+	case "round":
+		if _, err := mpTimeRound.Check(args); err != nil {
+			return NewValNull(), err
+		}
+		return NewValTime(t.t.Round(argDuration(args[0]))), nil
+
+	case "in":
+		if _, err := mpTimeIn.Check(args); err != nil {
+			return NewValNull(), err
+		}
+		loc, err := time.LoadLocation(args[0].String())
+		if err != nil {
+			return NewValNull(), err
+		}
+		return NewValTime(t.t.In(loc)), nil
+
+	case "utc":
+		if _, err := mpTimeUTC.Check(args); err != nil {
+			return NewValNull(), err
+		}
+		return NewValTime(t.t.UTC()), nil
+
+	case "unix":
+		if _, err := mpTimeUnix.Check(args); err != nil {
+			return NewValNull(), err
+		}
+		return NewValInt64(t.t.Unix()), nil
+
+	case "unix_milli":
+		if _, err := mpTimeUnixMilli.Check(args); err != nil {
+			return NewValNull(), err
+		}
+		return NewValInt64(t.t.UnixMilli()), nil
+
+	case "unix_nano":
+		if _, err := mpTimeUnixNano.Check(args); err != nil {
+			return NewValNull(), err
+		}
+		return NewValInt64(t.t.UnixNano()), nil
+
+	case "is_zero":
+		if _, err := mpTimeIsZero.Check(args); err != nil {
+			return NewValNull(), err
+		}
+		return NewValBool(t.t.IsZero()), nil
+
+	default:
+		return NewValNull(), fmt.Errorf("method: %s:%s is unknown", t.Id(), name)
+	}
+}
+
+func (t *Time) ToString() (string, error) {
+	return t.t.Format(time.RFC3339Nano), nil
+}
+
+func (t *Time) ToJSON() (Val, error) {
+	return NewValStr(t.t.Format(time.RFC3339Nano)), nil
+}
+
+func (t *Time) Id() string {
+	return TimeTypeId
+}
+
+func (t *Time) Info() string {
+	return fmt.Sprintf("[time: %s]", t.t.Format(time.RFC3339Nano))
+}
+
+func (t *Time) ToNative() interface{} {
+	return t.t
+}
+
+func (t *Time) IsThreadSafe() bool {
+	return true
+}
+
+func (t *Time) NewIterator() (Iter, error) {
+	return nil, fmt.Errorf("%s does not support iterator", t.Id())
+}
+
+// duration ------------------------------------------------------------------
+var (
+	mpDurationSeconds = MustNewFuncProto("duration.seconds", "%0")
+	mpDurationMillis  = MustNewFuncProto("duration.milliseconds", "%0")
+	mpDurationNanos   = MustNewFuncProto("duration.nanoseconds", "%0")
+	mpDurationAdd     = MustNewFuncProto("duration.add", "%U['duration']")
+	mpDurationSub     = MustNewFuncProto("duration.sub", "%U['duration']")
+	mpDurationMul     = MustNewFuncProto("duration.mul", "%d")
+	mpDurationCompare = MustNewFuncProto("duration.compare", "%U['duration']")
+	mpDurationString  = MustNewFuncProto("duration.to_string", "%0")
+)
+
+func (d *Duration) Index(_ Val) (Val, error) {
+	return NewValNull(), fmt.Errorf("%s index: unsupported operation", d.Id())
+}
+
+func (d *Duration) IndexSet(_ Val, _ Val) error {
+	return fmt.Errorf("%s index set: unsupported operation", d.Id())
+}
+
+func (d *Duration) Dot(_ string) (Val, error) {
+	return NewValNull(), fmt.Errorf("%s dot: unsupported operation", d.Id())
+}
+
+func (d *Duration) DotSet(_ string, _ Val) error {
+	return fmt.Errorf("%s dot set: unsupported operation", d.Id())
+}
+
+func (d *Duration) Method(name string, args []Val) (Val, error) {
+	switch name {
+	case "seconds":
+		if _, err := mpDurationSeconds.Check(args); err != nil {
+			return NewValNull(), err
+		}
+		return NewValReal(d.d.Seconds()), nil
+
+	case "milliseconds":
+		if _, err := mpDurationMillis.Check(args); err != nil {
+			return NewValNull(), err
+		}
+		return NewValInt64(d.d.Milliseconds()), nil
+
+	case "nanoseconds":
+		if _, err := mpDurationNanos.Check(args); err != nil {
+			return NewValNull(), err
+		}
+		return NewValInt64(d.d.Nanoseconds()), nil
+
+	case "add":
+		if _, err := mpDurationAdd.Check(args); err != nil {
+			return NewValNull(), err
+		}
+		return NewValDuration(d.d + argDuration(args[0])), nil
+
+	case "sub":
+		if _, err := mpDurationSub.Check(args); err != nil {
+			return NewValNull(), err
+		}
+		return NewValDuration(d.d - argDuration(args[0])), nil
+
+	case "mul":
+		if _, err := mpDurationMul.Check(args); err != nil {
+			return NewValNull(), err
+		}
+		return NewValDuration(d.d * time.Duration(args[0].Int())), nil
+
+	case "compare":
+		if _, err := mpDurationCompare.Check(args); err != nil {
+			return NewValNull(), err
+		}
+		that := argDuration(args[0])
+		if d.d < that {
+			return NewValInt(-1), nil
+		} else if d.d > that {
+			return NewValInt(1), nil
+		}
+		return NewValInt(0), nil
+
+	case "to_string":
+		if _, err := mpDurationString.Check(args); err != nil {
+			return NewValNull(), err
+		}
+		return NewValStr(d.d.String()), nil
+
+	default:
+		return NewValNull(), fmt.Errorf("method: %s:%s is unknown", d.Id(), name)
+	}
+}
+
+func (d *Duration) ToString() (string, error) {
+	return d.d.String(), nil
+}
+
+func (d *Duration) ToJSON() (Val, error) {
+	return NewValStr(d.d.String()), nil
+}
+
+func (d *Duration) Id() string {
+	return DurationTypeId
+}
+
+func (d *Duration) Info() string {
+	return fmt.Sprintf("[duration: %s]", d.d.String())
+}
+
+func (d *Duration) ToNative() interface{} {
+	return d.d
+}
+
+func (d *Duration) IsThreadSafe() bool {
+	return true
+}
+
+func (d *Duration) NewIterator() (Iter, error) {
+	return nil, fmt.Errorf("%s does not support iterator", d.Id())
+}
@@ -0,0 +1,87 @@
+package pl
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeBasic(t *testing.T) {
+	err := testAssertRule(t, `
+test {
+  let t = time::parse("2022-03-04T05:06:07Z");
+  assert::eq(id(t), "time");
+  assert::eq(t.year, 2022);
+  assert::eq(t.month, 3);
+  assert::eq(t.day, 4);
+  assert::eq(t.hour, 5);
+  assert::eq(t.weekday, "Friday");
+  assert::eq(t:unix(), 1646370367);
+  assert::eq(t:format("date"), "2022-03-04");
+  assert::eq(t:format("15:04"), "05:06");
+  assert::eq(t:http_date(), "Fri, 04 Mar 2022 05:06:07 GMT");
+  assert::eq(time::http_date(t), "Fri, 04 Mar 2022 05:06:07 GMT");
+  assert::eq(to_string(t), "2022-03-04T05:06:07Z");
+
+  // HTTP-date and custom layout
+  assert::yes(time::parse("Fri, 04 Mar 2022 05:06:07 GMT"):equal(t));
+  assert::yes(time::parse_http_date("Friday, 04-Mar-22 05:06:07 GMT"):equal(t));
+  assert::yes(time::parse("2022/03/04 05:06:07", "2006/01/02 15:04:05"):equal(t));
+  let local = time::parse("2022-03-04 14:06:07", "datetime", "Asia/Tokyo");
+  assert::yes(local:equal(t));
+  assert::eq(local.offset, 32400);
+  assert::eq(t:in("Asia/Tokyo").hour, 14);
+  assert::yes(time::from_unix(1646370367):equal(t));
+
+  // arithmetic
+  let d = time::duration("1h30m");
+  assert::eq(id(d), "duration");
+  assert::eq(d:seconds(), 5400.0);
+  assert::eq(t:add(d).hour, 6);
+  assert::eq(t:add(d).minute, 36);
+  assert::eq(t:sub(d).hour, 3);
+  assert::eq(t:add(d):sub(t):milliseconds(), 5400000);
+  assert::eq(time::seconds(90):to_string(), "1m30s");
+  assert::eq(time::milliseconds(1500):add(time::seconds(1)):milliseconds(), 2500);
+  assert::eq(time::seconds(2):mul(3):seconds(), 6.0);
+
+  // comparison and truncation
+  assert::yes(t:before(t:add(d)));
+  assert::yes(t:add(d):after(t));
+  assert::eq(t:compare(t), 0);
+  assert::eq(t:compare(t:add(d)), -1);
+  assert::eq(time::seconds(1):compare(time::seconds(2)), -1);
+  assert::eq(t:truncate(time::duration("1h")):format("rfc3339"), "2022-03-04T05:00:00Z");
+
+  // conditional request helper
+  assert::yes(time::modified_since("Fri, 04 Mar 2022 05:00:00 GMT", t));
+  assert::no(time::modified_since("Fri, 04 Mar 2022 05:06:07 GMT", t));
+  assert::yes(time::modified_since("", t));
+}
+`)
+	assert.True(t, err == nil, "%s", err)
+
+	for _, code := range []string{
+		`test { time::parse("xxx"); }`,
+		`test { time::parse("2022", "2006", "No/Where"); }`,
+		`test { time::duration("1x"); }`,
+		`test { time::now():add(1); }`,
+		`test { time::now():in("No/Where"); }`,
+	} {
+		assert.True(t, testAssertRule(t, code) != nil, code)
+	}
+}
+
+func TestTimeMarshal(t *testing.T) {
+	now := time.Now()
+	v, err := MarshalVal(now)
+	assert.True(t, err == nil)
+	assert.True(t, ValIsTime(v))
+	x, _ := v.Usr().(*Time)
+	assert.True(t, x.Time().Equal(now))
+
+	expires, err := time.Parse(time.RFC1123, HttpDate(now.Add(time.Hour)))
+	assert.True(t, err == nil)
+	assert.True(t, expires.After(now))
+}
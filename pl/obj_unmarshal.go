@@ -0,0 +1,415 @@
+package pl
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Reverse of MarshalVal, converts the PL value into the Go value pointed by the
+// pointer. Struct is converted from map and the field can be customized via
+// struct tags:
+//
+//   pl:"name"            the key name of the field, defaults to the field name,
+//                        and the key is matched case insensitively
+//   pl:"name,required"   the value must be specified, ie not missing or null
+//   pl:"-"               the field is ignored
+//   default:"value"      the default value used when the value is missing or
+//                        null, only primitive types and duration are supported
+//
+// Special Go types:
+//
+//   pl.Val               the value is stored as is
+//   time.Time            time value or string in RFC3339 or HTTP-date format
+//   time.Duration        duration value, string like "1s" or integer in ms
+//   []byte               bytes or string
+
+var (
+	valType      = reflect.TypeOf(Val{})
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+type fieldTag struct {
+	name     string
+	required bool
+	def      string
+	hasDef   bool
+}
+
+// returns false if the field should not be visible by PL
+func parseFieldTag(f reflect.StructField) (fieldTag, bool) {
+	if f.PkgPath != "" {
+		return fieldTag{}, false
+	}
+
+	tag := fieldTag{
+		name: f.Name,
+	}
+
+	if pl, ok := f.Tag.Lookup("pl"); ok {
+		if pl == "-" {
+			return fieldTag{}, false
+		}
+		opts := strings.Split(pl, ",")
+		if opts[0] != "" {
+			tag.name = opts[0]
+		}
+		for _, o := range opts[1:] {
+			if o == "required" {
+				tag.required = true
+			}
+		}
+	}
+
+	tag.def, tag.hasDef = f.Tag.Lookup("default")
+	return tag, true
+}
+
+func UnmarshalVal(v Val, ptr interface{}) error {
+	rv := reflect.ValueOf(ptr)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("unmarshal: target must be a non-nil pointer")
+	}
+	return unmarshalValue(v, rv.Elem(), "value")
+}
+
+// Unmarshal positional arguments, ie config arguments of modules, into struct.
+// Each exported field of the struct is mapped to the argument with the same
+// position
+func UnmarshalArgs(args []Val, ptr interface{}) error {
+	rv := reflect.ValueOf(ptr)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("unmarshal: target must be a non-nil struct pointer")
+	}
+
+	out := rv.Elem()
+	t := out.Type()
+	pos := 0
+
+	for i := 0; i < t.NumField(); i++ {
+		tag, ok := parseFieldTag(t.Field(i))
+		if !ok {
+			continue
+		}
+
+		arg := NewValNull()
+		if pos < len(args) {
+			arg = args[pos]
+		}
+		path := fmt.Sprintf("argument %d(%s)", pos, tag.name)
+		pos++
+
+		if err := unmarshalField(arg, out.Field(i), tag, path); err != nil {
+			return err
+		}
+	}
+
+	if len(args) > pos {
+		return fmt.Errorf("unmarshal: too many arguments, expect at most %d, got %d",
+			pos, len(args))
+	}
+	return nil
+}
+
+func unmarshalField(v Val, out reflect.Value, tag fieldTag, path string) error {
+	if !v.IsNull() {
+		return unmarshalValue(v, out, path)
+	}
+	if tag.required {
+		return fmt.Errorf("unmarshal: %s is required", path)
+	}
+	if tag.hasDef {
+		return unmarshalDefault(tag.def, out, path)
+	}
+	out.Set(reflect.Zero(out.Type()))
+	return nil
+}
+
+func unmarshalDefault(def string, out reflect.Value, path string) error {
+	var err error
+
+	if out.Type() == durationType {
+		var d time.Duration
+		d, err = time.ParseDuration(def)
+		if err == nil {
+			out.SetInt(int64(d))
+		}
+	} else {
+		switch out.Kind() {
+		case reflect.String:
+			out.SetString(def)
+
+		case reflect.Bool:
+			var b bool
+			b, err = strconv.ParseBool(def)
+			out.SetBool(b)
+
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			var i int64
+			i, err = strconv.ParseInt(def, 10, out.Type().Bits())
+			out.SetInt(i)
+
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			var i uint64
+			i, err = strconv.ParseUint(def, 10, out.Type().Bits())
+			out.SetUint(i)
+
+		case reflect.Float32, reflect.Float64:
+			var f float64
+			f, err = strconv.ParseFloat(def, out.Type().Bits())
+			out.SetFloat(f)
+
+		default:
+			err = fmt.Errorf("default value is not supported for type %s", out.Type())
+		}
+	}
+
+	if err != nil {
+		return fmt.Errorf("unmarshal: %s has invalid default value %q: %s", path, def, err.Error())
+	}
+	return nil
+}
+
+func unmarshalTypeError(v Val, out reflect.Value, path string) error {
+	return fmt.Errorf("unmarshal: %s expects %s, but got %s", path, out.Type(), v.TypeName())
+}
+
+func unmarshalValue(v Val, out reflect.Value, path string) error {
+	t := out.Type()
+
+	// 1. special types
+	switch t {
+	case valType:
+		out.Set(reflect.ValueOf(v))
+		return nil
+
+	case timeType:
+		if ValIsTime(v) {
+			tt, _ := v.Usr().(*Time)
+			out.Set(reflect.ValueOf(tt.Time()))
+			return nil
+		}
+		if v.IsString() {
+			tt, err := ParseTime(v.String())
+			if err != nil {
+				return fmt.Errorf("unmarshal: %s: %s", path, err.Error())
+			}
+			out.Set(reflect.ValueOf(tt))
+			return nil
+		}
+		return unmarshalTypeError(v, out, path)
+
+	case durationType:
+		switch {
+		case ValIsDuration(v):
+			d, _ := v.Usr().(*Duration)
+			out.SetInt(int64(d.Duration()))
+		case v.IsInt():
+			out.SetInt(v.Int() * int64(time.Millisecond))
+		case v.IsString():
+			d, err := time.ParseDuration(v.String())
+			if err != nil {
+				return fmt.Errorf("unmarshal: %s: %s", path, err.Error())
+			}
+			out.SetInt(int64(d))
+		default:
+			return unmarshalTypeError(v, out, path)
+		}
+		return nil
+	}
+
+	// 2. user type which can be assigned directly, ie *pl.Time
+	if v.IsUsr() {
+		uv := reflect.ValueOf(v.Usr())
+		if uv.Type().AssignableTo(t) {
+			out.Set(uv)
+			return nil
+		}
+		if n := reflect.ValueOf(v.Usr().ToNative()); n.IsValid() && n.Type().AssignableTo(t) {
+			out.Set(n)
+			return nil
+		}
+	}
+
+	switch out.Kind() {
+	case reflect.Ptr:
+		if v.IsNull() {
+			out.Set(reflect.Zero(t))
+			return nil
+		}
+		x := reflect.New(t.Elem())
+		if err := unmarshalValue(v, x.Elem(), path); err != nil {
+			return err
+		}
+		out.Set(x)
+		return nil
+
+	case reflect.Interface:
+		if v.IsNull() {
+			out.Set(reflect.Zero(t))
+			return nil
+		}
+		n := reflect.ValueOf(v.ToNative())
+		if !n.IsValid() || !n.Type().AssignableTo(t) {
+			return unmarshalTypeError(v, out, path)
+		}
+		out.Set(n)
+		return nil
+
+	case reflect.Bool:
+		if !v.IsBool() {
+			return unmarshalTypeError(v, out, path)
+		}
+		out.SetBool(v.Bool())
+		return nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if !v.IsInt() {
+			return unmarshalTypeError(v, out, path)
+		}
+		if out.OverflowInt(v.Int()) {
+			return fmt.Errorf("unmarshal: %s value %d overflows %s", path, v.Int(), t)
+		}
+		out.SetInt(v.Int())
+		return nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if !v.IsInt() {
+			return unmarshalTypeError(v, out, path)
+		}
+		if v.Int() < 0 || out.OverflowUint(uint64(v.Int())) {
+			return fmt.Errorf("unmarshal: %s value %d overflows %s", path, v.Int(), t)
+		}
+		out.SetUint(uint64(v.Int()))
+		return nil
+
+	case reflect.Float32, reflect.Float64:
+		switch {
+		case v.IsReal():
+			out.SetFloat(v.Real())
+		case v.IsInt():
+			out.SetFloat(float64(v.Int()))
+		default:
+			return unmarshalTypeError(v, out, path)
+		}
+		return nil
+
+	case reflect.String:
+		switch {
+		case v.IsString():
+			out.SetString(v.String())
+		case v.IsBytes():
+			out.SetString(string(v.Bytes()))
+		default:
+			return unmarshalTypeError(v, out, path)
+		}
+		return nil
+
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 && (v.IsBytes() || v.IsString()) {
+			b, _ := ToBytes(v)
+			out.SetBytes(append([]byte(nil), b...))
+			return nil
+		}
+		if v.IsNull() {
+			out.Set(reflect.Zero(t))
+			return nil
+		}
+		if !v.IsList() {
+			return unmarshalTypeError(v, out, path)
+		}
+		l := v.List().Data
+		x := reflect.MakeSlice(t, len(l), len(l))
+		for idx, e := range l {
+			if err := unmarshalValue(e, x.Index(idx), fmt.Sprintf("%s[%d]", path, idx)); err != nil {
+				return err
+			}
+		}
+		out.Set(x)
+		return nil
+
+	case reflect.Array:
+		if !v.IsList() {
+			return unmarshalTypeError(v, out, path)
+		}
+		l := v.List().Data
+		if len(l) != out.Len() {
+			return fmt.Errorf("unmarshal: %s expects %d elements, but got %d", path, out.Len(), len(l))
+		}
+		for idx, e := range l {
+			if err := unmarshalValue(e, out.Index(idx), fmt.Sprintf("%s[%d]", path, idx)); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return fmt.Errorf("unmarshal: %s map key must be string", path)
+		}
+		if v.IsNull() {
+			out.Set(reflect.Zero(t))
+			return nil
+		}
+		if !v.IsMap() {
+			return unmarshalTypeError(v, out, path)
+		}
+		x := reflect.MakeMapWithSize(t, v.Map().Length())
+		var err error
+		v.Map().Foreach(func(key string, e Val) bool {
+			ev := reflect.New(t.Elem()).Elem()
+			if err = unmarshalValue(e, ev, fmt.Sprintf("%s.%s", path, key)); err != nil {
+				return false
+			}
+			x.SetMapIndex(reflect.ValueOf(key).Convert(t.Key()), ev)
+			return true
+		})
+		if err != nil {
+			return err
+		}
+		out.Set(x)
+		return nil
+
+	case reflect.Struct:
+		if !v.IsMap() {
+			return unmarshalTypeError(v, out, path)
+		}
+		return unmarshalStruct(v.Map(), out, path)
+
+	default:
+		return fmt.Errorf("unmarshal: %s has unsupported type %s", path, t)
+	}
+}
+
+func mapGetFold(m *Map, key string) Val {
+	if v, ok := m.Get(key); ok {
+		return v
+	}
+	o := NewValNull()
+	m.Foreach(func(k string, v Val) bool {
+		if strings.EqualFold(k, key) {
+			o = v
+			return false
+		}
+		return true
+	})
+	return o
+}
+
+func unmarshalStruct(m *Map, out reflect.Value, path string) error {
+	t := out.Type()
+	for i := 0; i < t.NumField(); i++ {
+		tag, ok := parseFieldTag(t.Field(i))
+		if !ok {
+			continue
+		}
+		v := mapGetFold(m, tag.name)
+		if err := unmarshalField(v, out.Field(i), tag, path+"."+tag.name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
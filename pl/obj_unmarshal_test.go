@@ -0,0 +1,105 @@
+package pl
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testUnmarshalInner struct {
+	Host string `pl:"host,required"`
+	Port uint16 `pl:"port" default:"80"`
+}
+
+type testUnmarshal struct {
+	Name     string
+	Count    int                  `pl:"count" default:"3"`
+	Ratio    float64              `pl:"ratio"`
+	Enable   bool                 `pl:"enable" default:"true"`
+	Timeout  time.Duration        `pl:"timeout" default:"5s"`
+	Since    time.Time            `pl:"since"`
+	Tags     []string             `pl:"tags"`
+	Meta     map[string]int       `pl:"meta"`
+	Upstream []testUnmarshalInner `pl:"upstream"`
+	Ptr      *int                 `pl:"ptr"`
+	Raw      Val                  `pl:"raw"`
+	Data     []byte               `pl:"data"`
+	Skip     int                  `pl:"-"`
+}
+
+func testEvalVal(t *testing.T, code string) Val {
+	module, err := CompileModule("test { return "+code+"; }", nil)
+	assert.True(t, err == nil, "%s", err)
+	v, err := NewEvaluatorSimple().Eval("test", module)
+	assert.True(t, err == nil, "%s", err)
+	return v
+}
+
+func TestUnmarshalVal(t *testing.T) {
+	v := testEvalVal(t, `{
+  "name": "svc",
+  "Ratio": 1,
+  "timeout": 1500,
+  "since": "2022-03-04T05:06:07Z",
+  "tags": ["a", "b"],
+  "meta": {"x": 1},
+  "upstream": [{"host": "a.com"}, {"host": "b.com", "port": 8080}],
+  "ptr": 10,
+  "raw": [1, 2],
+  "data": "abc",
+  "Skip": 100
+}`)
+
+	var x testUnmarshal
+	assert.True(t, UnmarshalVal(v, &x) == nil)
+	assert.Equal(t, "svc", x.Name)
+	assert.Equal(t, 3, x.Count)
+	assert.Equal(t, 1.0, x.Ratio)
+	assert.True(t, x.Enable)
+	assert.Equal(t, 1500*time.Millisecond, x.Timeout)
+	assert.Equal(t, 2022, x.Since.Year())
+	assert.Equal(t, []string{"a", "b"}, x.Tags)
+	assert.Equal(t, map[string]int{"x": 1}, x.Meta)
+	assert.Equal(t, []testUnmarshalInner{{"a.com", 80}, {"b.com", 8080}}, x.Upstream)
+	assert.Equal(t, 10, *x.Ptr)
+	assert.True(t, x.Raw.IsList())
+	assert.Equal(t, []byte("abc"), x.Data)
+	assert.Equal(t, 0, x.Skip)
+
+	// marshal back uses the same naming
+	m, err := MarshalVal(testUnmarshalInner{"a.com", 80})
+	assert.True(t, err == nil)
+	host, _ := m.Map().Get("host")
+	assert.Equal(t, "a.com", host.String())
+
+	// errors name the offending value
+	err = UnmarshalVal(testEvalVal(t, `{"count": "x"}`), &x)
+	assert.Contains(t, err.Error(), "value.count expects int, but got string")
+	err = UnmarshalVal(testEvalVal(t, `{"upstream": [{"port": 1}]}`), &x)
+	assert.Contains(t, err.Error(), "value.upstream[0].host is required")
+	err = UnmarshalVal(testEvalVal(t, `{"upstream": [{"host": "a", "port": 70000}]}`), &x)
+	assert.Contains(t, err.Error(), "overflows")
+	assert.True(t, UnmarshalVal(v, x) != nil)
+}
+
+func TestUnmarshalArgs(t *testing.T) {
+	type config struct {
+		Status int    `pl:"status" default:"200"`
+		Body   string `pl:"body,required"`
+		Flush  bool   `pl:"flush"`
+	}
+
+	var c config
+	assert.True(t, UnmarshalArgs([]Val{NewValNull(), NewValStr("hi")}, &c) == nil)
+	assert.Equal(t, config{200, "hi", false}, c)
+
+	err := UnmarshalArgs([]Val{NewValInt(404)}, &c)
+	assert.Contains(t, err.Error(), "argument 1(body) is required")
+
+	err = UnmarshalArgs([]Val{NewValStr("x"), NewValStr("hi")}, &c)
+	assert.Contains(t, err.Error(), "argument 0(status) expects int")
+
+	err = UnmarshalArgs([]Val{NewValInt(1), NewValStr("a"), NewValBool(true), NewValNull()}, &c)
+	assert.Contains(t, err.Error(), "too many arguments")
+}
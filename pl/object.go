@@ -5,6 +5,7 @@ import (
 	"math"
 	"regexp"
 	"strings"
+	"unsafe"
 )
 
 const (
@@ -12,6 +13,7 @@ const (
 	ValInt
 	ValReal
 	ValStr
+	ValBytes
 	ValBool
 	ValPair
 	ValList
@@ -27,7 +29,7 @@ const (
 
 func IsPrimitiveType(t int) bool {
 	switch t {
-	case ValInt, ValNull, ValReal, ValStr, ValBool:
+	case ValInt, ValNull, ValReal, ValStr, ValBytes, ValBool:
 		return true
 	default:
 		return false
@@ -40,6 +42,7 @@ func IsValueType(t int) bool {
 		ValNull,
 		ValReal,
 		ValStr,
+		ValBytes,
 		ValBool,
 		ValPair,
 		ValList,
@@ -130,119 +133,200 @@ type Usr interface {
 	// support invocation operations, ie calling a user type
 }
 
+// Val is a tagged union of 2 words, the payload is stored without any heap
+// allocation:
+//
+//	null              zero value
+//	int, real, bool   inline inside of num, ptr points to the type's slot of
+//	                  valTag
+//	str, bytes        data pointer inside of ptr and the length inside of num
+//	list, map, pair,
+//	regexp, frame     pointer of the object inside of ptr
+//	usr, iter         data word of the interface inside of ptr and its itab
+//	                  inside of num
+//	closure           pointer of the object inside of ptr, num records the
+//	                  concrete type of it, or the itab of the interface for
+//	                  closure that is not implemented by the runtime
+//
+// Except int, real and bool, the type is stored inside of the top byte of num.
+// The itab of an interface is never collected, so the GC does not need to see
+// it.
 type Val struct {
-	Type  int
-	vData interface{}
+	num uint64
+	ptr unsafe.Pointer
+}
+
+const (
+	valTagShift   = 56
+	valDataMask   = 1<<valTagShift - 1
+	valTagMaxType = valFrame + 1
+)
+
+// type slots of the values whose payload takes the whole num
+var valTag [valTagMaxType]byte
+
+// layout of string and slice header, used to store string and bytes without
+// boxing them into interface
+type stringHeader struct {
+	data unsafe.Pointer
+	len  int
+}
+
+type sliceHeader struct {
+	data unsafe.Pointer
+	len  int
+	cap  int
+}
+
+// layout of non empty interface
+type ifaceHeader struct {
+	tab  uintptr
+	data unsafe.Pointer
+}
+
+// concrete type of closure stored inside of num, any other value of num is the
+// itab of the Closure interface
+const (
+	closureSFunc = iota + 1
+	closureNFunc
+	closureMFunc
+)
+
+func newValInline(t int, num uint64) Val {
+	return Val{
+		num: num,
+		ptr: unsafe.Pointer(&valTag[t]),
+	}
+}
+
+func newValTagged(t int, num uint64, ptr unsafe.Pointer) Val {
+	return Val{
+		num: uint64(t)<<valTagShift | num,
+		ptr: ptr,
+	}
+}
+
+func (v Val) Type() int {
+	if d := uintptr(v.ptr) - uintptr(unsafe.Pointer(&valTag)); d < valTagMaxType {
+		return int(d)
+	}
+	return int(v.num >> valTagShift)
+}
+
+func (v *Val) data() uint64 {
+	return v.num & valDataMask
 }
 
 func (v *Val) Int() int64 {
-	x, ok := v.vData.(int64)
-	must(ok, "must be int")
-	return x
+	must(v.Type() == ValInt, "must be int")
+	return int64(v.num)
 }
 
 func (v *Val) SetInt(i int64) {
-	v.Type = ValInt
-	v.vData = i
+	*v = NewValInt64(i)
 }
 
 func (v *Val) Real() float64 {
-	x, ok := v.vData.(float64)
-	must(ok, "must be real")
-	return x
+	must(v.Type() == ValReal, "must be real")
+	return math.Float64frombits(v.num)
 }
 
 func (v *Val) SetReal(vv float64) {
-	v.Type = ValReal
-	v.vData = vv
+	*v = NewValReal(vv)
 }
 
 func (v *Val) Bool() bool {
-	x, ok := v.vData.(bool)
-	must(ok, "must be bool")
-	return x
+	must(v.Type() == ValBool, "must be bool")
+	return v.num != 0
 }
 
 func (v *Val) SetBool(vv bool) {
-	v.Type = ValBool
-	v.vData = vv
+	*v = NewValBool(vv)
 }
 
 func (v *Val) String() string {
-	x, ok := v.vData.(string)
-	must(ok, "must be string")
-	return x
+	must(v.Type() == ValStr, "must be string")
+	return *(*string)(unsafe.Pointer(&stringHeader{
+		data: v.ptr,
+		len:  int(v.data()),
+	}))
 }
 
 func (v *Val) SetString(vv string) {
-	v.Type = ValStr
-	v.vData = vv
+	*v = NewValStr(vv)
+}
+
+func (v *Val) Bytes() []byte {
+	must(v.Type() == ValBytes, "must be bytes")
+	if v.ptr == nil {
+		return nil
+	}
+	return *(*[]byte)(unsafe.Pointer(&sliceHeader{
+		data: v.ptr,
+		len:  int(v.data()),
+		cap:  int(v.data()),
+	}))
+}
+
+func (v *Val) SetBytes(vv []byte) {
+	*v = NewValBytes(vv)
 }
 
 func (v *Val) Regexp() *regexp.Regexp {
-	x, ok := v.vData.(*regexp.Regexp)
-	must(ok, "must be regexp")
-	return x
+	must(v.Type() == ValRegexp, "must be regexp")
+	return (*regexp.Regexp)(v.ptr)
 }
 
 func (v *Val) SetRegexp(vv *regexp.Regexp) {
-	v.Type = ValRegexp
-	v.vData = vv
+	*v = NewValRegexp(vv)
 }
 
 func (v *Val) List() *List {
-	x, ok := v.vData.(*List)
-	must(ok, "must be list")
-	return x
+	must(v.Type() == ValList, "must be list")
+	return (*List)(v.ptr)
 }
 
 func (v *Val) SetList(vv *List) {
-	v.Type = ValList
-	v.vData = vv
+	*v = NewValListFromList(vv)
 }
 
 func (v *Val) Map() *Map {
-	x, ok := v.vData.(*Map)
-	must(ok, "must be map")
-	return x
+	must(v.Type() == ValMap, "must be map")
+	return (*Map)(v.ptr)
 }
 
 func (v *Val) SetMap(vv *Map) {
-	v.Type = ValMap
-	v.vData = vv
+	*v = NewValMapFromMap(vv)
 }
 
 func (v *Val) UVal() *UVal {
-	x, ok := v.vData.(*UVal)
+	x, ok := v.Usr().(*UVal)
 	must(ok, "must be uval")
 	return x
 }
 
 func (v *Val) SetUVal(u *UVal) {
-	v.Type = ValUsr
-	v.vData = u
+	*v = NewValUsr(u)
 }
 
 func (v *Val) Usr() Usr {
-	x, ok := v.vData.(Usr)
-	must(ok, "must be user")
-	return x
+	must(v.Type() == ValUsr, "must be user")
+	var u Usr
+	*(*ifaceHeader)(unsafe.Pointer(&u)) = v.iface()
+	return u
 }
 
 func (v *Val) SetUsr(vv Usr) {
-	v.Type = ValUsr
-	v.vData = vv
+	*v = NewValUsr(vv)
 }
 
 func (v *Val) Pair() *Pair {
-	x, ok := v.vData.(*Pair)
-	must(ok, "must be pair")
-	return x
+	must(v.Type() == ValPair, "must be pair")
+	return (*Pair)(v.ptr)
 }
 
 func (v *Val) SetPair(vv *Pair) {
-	v.Type = ValPair
-	v.vData = vv
+	*v = newValTagged(ValPair, 0, unsafe.Pointer(vv))
 }
 
 func (v *Val) SetPairKV(first Val, second Val) {
@@ -254,29 +338,50 @@ func (v *Val) SetPairKV(first Val, second Val) {
 }
 
 func (v *Val) SetIter(iter Iter) {
-	v.Type = ValIter
-	v.vData = iter
+	*v = NewValIter(iter)
 }
 
 func (v *Val) Iter() Iter {
-	x, ok := v.vData.(Iter)
-	must(ok, "must be iterator")
-	return x
+	must(v.Type() == ValIter, "must be iterator")
+	var i Iter
+	*(*ifaceHeader)(unsafe.Pointer(&i)) = v.iface()
+	return i
 }
 
 func (v *Val) SetClosure(closure Closure) {
-	v.Type = ValClosure
-	v.vData = closure
+	*v = newValClosure(closure)
 }
 
 func (v *Val) Closure() Closure {
-	x, ok := v.vData.(Closure)
-	must(ok, "must be closure")
-	return x
+	must(v.Type() == ValClosure, "must be closure")
+	switch v.data() {
+	case closureSFunc:
+		return (*scriptFunc)(v.ptr)
+	case closureNFunc:
+		return (*nativeFunc)(v.ptr)
+	case closureMFunc:
+		return (*methodFunc)(v.ptr)
+	default:
+		var c Closure
+		*(*ifaceHeader)(unsafe.Pointer(&c)) = v.iface()
+		return c
+	}
+}
+
+func (v *Val) iface() ifaceHeader {
+	return ifaceHeader{
+		tab:  uintptr(v.data()),
+		data: v.ptr,
+	}
+}
+
+func newValIface(t int, x unsafe.Pointer) Val {
+	h := (*ifaceHeader)(x)
+	return newValTagged(t, uint64(h.tab), h.data)
 }
 
 func (v *Val) IsNumber() bool {
-	switch v.Type {
+	switch v.Type() {
 	case ValInt, ValReal:
 		return true
 	default:
@@ -285,169 +390,141 @@ func (v *Val) IsNumber() bool {
 }
 
 func (v *Val) IsInt() bool {
-	return v.Type == ValInt
+	return v.Type() == ValInt
 }
 
 func (v *Val) IsReal() bool {
-	return v.Type == ValReal
+	return v.Type() == ValReal
 }
 
 func (v *Val) IsBool() bool {
-	return v.Type == ValBool
+	return v.Type() == ValBool
 }
 
 func (v *Val) IsNull() bool {
-	return v.Type == ValNull
+	return v.Type() == ValNull
 }
 
 func (v *Val) IsString() bool {
-	return v.Type == ValStr
+	return v.Type() == ValStr
+}
+
+func (v *Val) IsBytes() bool {
+	return v.Type() == ValBytes
 }
 
 func (v *Val) IsPair() bool {
-	return v.Type == ValPair
+	return v.Type() == ValPair
 }
 
 func (v *Val) IsList() bool {
-	return v.Type == ValList
+	return v.Type() == ValList
 }
 
 func (v *Val) IsMap() bool {
-	return v.Type == ValMap
+	return v.Type() == ValMap
 }
 
 func (v *Val) IsRegexp() bool {
-	return v.Type == ValRegexp
+	return v.Type() == ValRegexp
 }
 
 func (v *Val) IsIter() bool {
-	return v.Type == ValIter
+	return v.Type() == ValIter
 }
 
 func (v *Val) IsUsr() bool {
-	return v.Type == ValUsr
+	return v.Type() == ValUsr
 }
 
 func (v *Val) IsClosure() bool {
-	return v.Type == ValClosure
+	return v.Type() == ValClosure
 }
 
 // val frame helper
 func (v *Val) isFrame() bool {
-	return v.Type == valFrame
+	return v.Type() == valFrame
 }
 
-func (v *Val) setFrame(f interface{}) {
-	v.Type = valFrame
-	v.vData = f
+func (v *Val) setFrame(f *funcframe) {
+	*v = newValFrame(f)
 }
 
-func (v *Val) frame() interface{} {
-	must(v.Type == valFrame, "must be frame")
-	return v.vData
+func (v *Val) frame() *funcframe {
+	must(v.Type() == valFrame, "must be frame")
+	return (*funcframe)(v.ptr)
 }
 
-func newValFrame(f interface{}) Val {
-	return Val{
-		Type:  valFrame,
-		vData: f,
-	}
+func newValFrame(f *funcframe) Val {
+	return newValTagged(valFrame, 0, unsafe.Pointer(f))
 }
 
 // New function ----------------------------------------------------------------
 func NewValNull() Val {
-	return Val{
-		Type: ValNull,
-	}
+	return Val{}
 }
 
 func NewValInt64(i int64) Val {
-	return Val{
-		Type:  ValInt,
-		vData: i,
-	}
+	return newValInline(ValInt, uint64(i))
 }
 
 func NewValInt(i int) Val {
-	return Val{
-		Type:  ValInt,
-		vData: int64(i),
-	}
+	return NewValInt64(int64(i))
 }
 
 func NewValStr(s string) Val {
-	return Val{
-		Type:  ValStr,
-		vData: s,
-	}
+	h := (*stringHeader)(unsafe.Pointer(&s))
+	return newValTagged(ValStr, uint64(h.len), h.data)
+}
+
+// the byte slice is owned by the value afterwards, caller should not modify it
+func NewValBytes(b []byte) Val {
+	h := (*sliceHeader)(unsafe.Pointer(&b))
+	return newValTagged(ValBytes, uint64(h.len), h.data)
 }
 
 func NewValReal(d float64) Val {
-	return Val{
-		Type:  ValReal,
-		vData: d,
-	}
+	return newValInline(ValReal, math.Float64bits(d))
 }
 
 func NewValBool(b bool) Val {
-	return Val{
-		Type:  ValBool,
-		vData: b,
+	if b {
+		return newValInline(ValBool, 1)
 	}
+	return newValInline(ValBool, 0)
 }
 
 func NewValPair(f Val, s Val) Val {
-	return Val{
-		Type: ValPair,
-		vData: &Pair{
-			First:  f,
-			Second: s,
-		},
-	}
+	return newValTagged(ValPair, 0, unsafe.Pointer(&Pair{
+		First:  f,
+		Second: s,
+	}))
 }
 
 func NewValRegexp(r *regexp.Regexp) Val {
-	return Val{
-		Type:  ValRegexp,
-		vData: r,
-	}
+	return newValTagged(ValRegexp, 0, unsafe.Pointer(r))
 }
 
 func NewValListRaw(d []Val) Val {
-	return Val{
-		Type: ValList,
-		vData: &List{
-			Data: d,
-		},
-	}
+	return NewValListFromList(&List{
+		Data: d,
+	})
 }
 
 func NewValList() Val {
-	return Val{
-		Type:  ValList,
-		vData: NewList(),
-	}
+	return NewValListFromList(NewList())
 }
 
 func NewValListFromList(l *List) Val {
-	return Val{
-		Type:  ValList,
-		vData: l,
-	}
+	return newValTagged(ValList, 0, unsafe.Pointer(l))
 }
 
 func NewValMap() Val {
-	return Val{
-		Type:  ValMap,
-		vData: NewMap(),
-	}
+	return NewValMapFromMap(NewMap())
 }
 
 func NewValMapFromMap(m *Map) Val {
-	return Val{
-		Type:  ValMap,
-		vData: m,
-	}
+	return newValTagged(ValMap, 0, unsafe.Pointer(m))
 }
 
 func NewValStrList(s []string) Val {
@@ -467,84 +544,97 @@ func NewValIntList(s []int) Val {
 }
 
 func NewValUsr(u Usr) Val {
-	return Val{
-		Type:  ValUsr,
-		vData: u,
-	}
+	return newValIface(ValUsr, unsafe.Pointer(&u))
 }
 
 func NewValIter(i Iter) Val {
-	return Val{
-		Type:  ValIter,
-		vData: i,
-	}
+	return newValIface(ValIter, unsafe.Pointer(&i))
 }
 
 func NewValUValData(
 	c interface{},
 ) Val {
-	return Val{
-		Type:  ValUsr,
-		vData: NewUValData(c),
+	return NewValUsr(NewUValData(c))
+}
+
+func newValClosure(closure Closure) Val {
+	switch x := closure.(type) {
+	case *scriptFunc:
+		return newValSFunc(x)
+	case *nativeFunc:
+		return newValNFunc(x)
+	case *methodFunc:
+		return newValMFunc(x)
+	default:
+		return newValIface(ValClosure, unsafe.Pointer(&closure))
 	}
 }
 
 func newValScriptFunction(
 	p *program) Val {
-	return Val{
-		Type:  ValClosure,
-		vData: newScriptFunc(p),
-	}
+	return newValSFunc(newScriptFunc(p))
 }
 
 func newValSFunc(
 	sfunc *scriptFunc) Val {
-	return Val{
-		Type:  ValClosure,
-		vData: sfunc,
-	}
+	return newValTagged(ValClosure, closureSFunc, unsafe.Pointer(sfunc))
 }
 
 func newValNFunc(
 	nfunc *nativeFunc) Val {
-	return Val{
-		Type:  ValClosure,
-		vData: nfunc,
-	}
+	return newValTagged(ValClosure, closureNFunc, unsafe.Pointer(nfunc))
+}
+
+func newValMFunc(
+	mfunc *methodFunc) Val {
+	return newValTagged(ValClosure, closureMFunc, unsafe.Pointer(mfunc))
 }
 
 func newValSIter(
 	siter *scriptIter) Val {
-	return Val{
-		Type:  ValIter,
-		vData: siter,
-	}
+	return NewValIter(siter)
+}
+
+func newValMethodEvalFunction(
+	entry methodEvalFn,
+	name string,
+) Val {
+	return newValMFunc(newMethodEvalFunc(
+		entry,
+		name,
+	))
+}
+
+func newValUsrMethodFunction(
+	recv Usr,
+	entry UsrMethodFn,
+	name string,
+) Val {
+	return newValMFunc(newUsrMethodFunc(
+		recv,
+		entry,
+		name,
+	))
 }
 
 func NewValMethodFunction(
 	entry MethodFn,
 	name string,
 ) Val {
-	return Val{
-		Type: ValClosure,
-		vData: newMethodFunc(
-			entry,
-			name,
-		),
-	}
+	return newValMFunc(newMethodFunc(
+		entry,
+		name,
+	))
 }
 
 func NewValNativeFunction(
 	id string,
 	entry func([]Val) (Val, error),
 ) Val {
-	return Val{
-		Type: ValClosure,
-		vData: NewNativeFunction(
-			id,
-			entry,
-		),
-	}
+	return newValClosure(NewNativeFunction(
+		id,
+		entry,
+	))
 }
 
 func NewValUVal(
@@ -563,24 +653,21 @@ func NewValUVal(
 	f10 UValIter,
 	f11 UValIsThreadSafe,
 ) Val {
-	return Val{
-		Type: ValUsr,
-		vData: NewUVal(
-			c,
-			f0,
-			f1,
-			f2,
-			f3,
-			f4,
-			f5,
-			f6,
-			f7,
-			f8,
-			f9,
-			f10,
-			f11,
-		),
-	}
+	return NewValUsr(NewUVal(
+		c,
+		f0,
+		f1,
+		f2,
+		f3,
+		f4,
+		f5,
+		f6,
+		f7,
+		f8,
+		f9,
+		f10,
+		f11,
+	))
 }
 
 func NewValUValImmutable(
@@ -596,45 +683,44 @@ func NewValUValImmutable(
 	f10 UValIter,
 	f11 UValIsThreadSafe,
 ) Val {
-	return Val{
-		Type: ValUsr,
-		vData: NewUVal(
-			c,
-			f0,
-			nil,
-			f2,
-			nil,
-			f4,
-			f5,
-			f6,
-			f7,
-			f8,
-			f9,
-			f10,
-			f11,
-		),
-	}
+	return NewValUsr(NewUVal(
+		c,
+		f0,
+		nil,
+		f2,
+		nil,
+		f4,
+		f5,
+		f6,
+		f7,
+		f8,
+		f9,
+		f10,
+		f11,
+	))
 }
 
 func (v *Val) AddList(vv Val) {
-	must(v.Type == ValList, "AddList: must be list")
+	must(v.Type() == ValList, "AddList: must be list")
 	v.List().Append(vv)
 }
 
 func (v *Val) AddMap(key string, val Val) {
-	must(v.Type == ValMap, "AddMap: must be map")
+	must(v.Type() == ValMap, "AddMap: must be map")
 	v.Map().Set(key, val)
 }
 
 // never failed
 func (v *Val) ToBoolean() bool {
-	switch v.Type {
+	switch v.Type() {
 	case ValInt:
 		return v.Int() != 0
 	case ValReal:
 		return v.Real() != 0
 	case ValStr:
 		return len(v.String()) != 0
+	case ValBytes:
+		return len(v.Bytes()) != 0
 	case ValBool:
 		return v.Bool()
 	case ValNull:
@@ -655,7 +741,7 @@ func (v *Val) ToBoolean() bool {
 }
 
 func (v *Val) ToIndex() (int, error) {
-	switch v.Type {
+	switch v.Type() {
 	case ValInt:
 		if v.Int() >= 0 {
 			return int(v.Int()), nil
@@ -671,13 +757,15 @@ func (v *Val) ToIndex() (int, error) {
 // external go envronment. Notes, for user type we just do nothing for now,
 // ie just returns a string.
 func (v *Val) ToNative() interface{} {
-	switch v.Type {
+	switch v.Type() {
 	case ValInt:
 		return v.Int()
 	case ValReal:
 		return v.Real()
 	case ValStr:
 		return v.String()
+	case ValBytes:
+		return v.Bytes()
 	case ValBool:
 		return v.Bool()
 	case ValNull:
@@ -709,7 +797,7 @@ func (v *Val) ToNative() interface{} {
 }
 
 func (v *Val) ToString() (string, error) {
-	switch v.Type {
+	switch v.Type() {
 	case ValInt:
 		return fmt.Sprintf("%d", v.Int()), nil
 	case ValReal:
@@ -725,6 +813,9 @@ func (v *Val) ToString() (string, error) {
 	case ValStr:
 		return v.String(), nil
 
+	case ValBytes:
+		return string(v.Bytes()), nil
+
 	case ValRegexp:
 		return v.Regexp().String(), nil
 
@@ -746,7 +837,7 @@ func (v *Val) ToString() (string, error) {
 }
 
 func (v *Val) Index(idx Val) (Val, error) {
-	switch v.Type {
+	switch v.Type() {
 	case ValInt, ValReal, ValBool, ValNull, ValIter, ValClosure:
 		return NewValNull(), fmt.Errorf("cannot index type: %s", v.Id())
 
@@ -763,6 +854,16 @@ func (v *Val) Index(idx Val) (Val, error) {
 		}
 		return NewValStr(v.String()[i : i+1]), nil
 
+	case ValBytes:
+		i, err := idx.ToIndex()
+		if err != nil {
+			return NewValNull(), err
+		}
+		if i >= len(v.Bytes()) {
+			return NewValNull(), fmt.Errorf("index out of range")
+		}
+		return NewValInt(int(v.Bytes()[i])), nil
+
 	case ValPair:
 		return v.Pair().Index(idx)
 
@@ -781,8 +882,8 @@ func (v *Val) Index(idx Val) (Val, error) {
 }
 
 func (v *Val) IndexSet(idx, val Val) error {
-	switch v.Type {
-	case ValStr, ValInt, ValReal, ValBool, ValNull, ValIter, ValClosure:
+	switch v.Type() {
+	case ValStr, ValBytes, ValInt, ValReal, ValBool, ValNull, ValIter, ValClosure:
 		return fmt.Errorf("cannot do index set on type: %s", v.Id())
 
 	case ValRegexp:
@@ -806,8 +907,8 @@ func (v *Val) IndexSet(idx, val Val) error {
 }
 
 func (v *Val) Dot(i string) (Val, error) {
-	switch v.Type {
-	case ValInt, ValReal, ValBool, ValNull, ValStr, ValList, ValIter, ValClosure:
+	switch v.Type() {
+	case ValInt, ValReal, ValBool, ValNull, ValStr, ValBytes, ValList, ValIter, ValClosure:
 		return NewValNull(), fmt.Errorf("cannot do dot on type: %s", v.Id())
 
 	case ValRegexp:
@@ -828,8 +929,8 @@ func (v *Val) Dot(i string) (Val, error) {
 }
 
 func (v *Val) DotSet(i string, val Val) error {
-	switch v.Type {
-	case ValInt, ValReal, ValBool, ValNull, ValStr, ValList, ValIter, ValClosure:
+	switch v.Type() {
+	case ValInt, ValReal, ValBool, ValNull, ValStr, ValBytes, ValList, ValIter, ValClosure:
 		return fmt.Errorf("cannot do dot set on type: %s", v.Id())
 
 	case ValRegexp:
@@ -1048,7 +1149,7 @@ func (v *Val) methodUsr(name string, args []Val) (Val, error) {
 
 // convert method to a callable clousre, used by the external world
 func (v *Val) MethodClosure(name string) (Val, error) {
-	switch v.Type {
+	switch v.Type() {
 	case ValInt:
 		return NewValMethodFunction(
 			v.Method,
@@ -1079,15 +1180,21 @@ func (v *Val) MethodClosure(name string) (Val, error) {
 			name,
 		), nil
 
-	case ValList:
+	case ValBytes:
 		return NewValMethodFunction(
-			v.List().Method,
+			v.methodBytes,
+			name,
+		), nil
+
+	case ValList:
+		return newValMethodEvalFunction(
+			v.List().MethodEval,
 			name,
 		), nil
 
 	case ValMap:
-		return NewValMethodFunction(
-			v.Map().Method,
+		return newValMethodEvalFunction(
+			v.Map().MethodEval,
 			name,
 		), nil
 
@@ -1112,7 +1219,7 @@ func (v *Val) MethodClosure(name string) (Val, error) {
 }
 
 func (v *Val) Method(name string, args []Val) (Val, error) {
-	switch v.Type {
+	switch v.Type() {
 	case ValInt:
 		return v.methodInt(name, args)
 
@@ -1128,6 +1235,9 @@ func (v *Val) Method(name string, args []Val) (Val, error) {
 	case ValStr:
 		return v.methodStr(name, args)
 
+	case ValBytes:
+		return v.methodBytes(name, args)
+
 	case ValList:
 		return v.List().Method(name, args)
 
@@ -1148,12 +1258,14 @@ func (v *Val) Method(name string, args []Val) (Val, error) {
 }
 
 func (v *Val) NewIterator() (Iter, error) {
-	switch v.Type {
+	switch v.Type() {
 	case ValInt, ValReal, ValBool, ValNull, ValRegexp, ValClosure, valFrame:
 		return nil, fmt.Errorf("type %s does not support iterator", v.Id())
 
 	case ValStr:
 		return newStrIter(v.String()), nil
+	case ValBytes:
+		return newBytesIter(v.Bytes()), nil
 	case ValList:
 		return v.List().NewIter(), nil
 	case ValMap:
@@ -1168,7 +1280,7 @@ func (v *Val) NewIterator() (Iter, error) {
 }
 
 func (v *Val) TypeName() string {
-	switch v.Type {
+	switch v.Type() {
 	case ValInt:
 		return "int"
 	case ValReal:
@@ -1179,6 +1291,8 @@ func (v *Val) TypeName() string {
 		return "null"
 	case ValStr:
 		return "string"
+	case ValBytes:
+		return "bytes"
 	case ValList:
 		return "list"
 	case ValMap:
@@ -1199,7 +1313,7 @@ func (v *Val) TypeName() string {
 }
 
 func (v *Val) Id() string {
-	switch v.Type {
+	switch v.Type() {
 	case ValInt:
 		return "int"
 	case ValReal:
@@ -1210,6 +1324,8 @@ func (v *Val) Id() string {
 		return "null"
 	case ValStr:
 		return "string"
+	case ValBytes:
+		return "bytes"
 	case ValList:
 		return "list"
 	case ValMap:
@@ -1230,7 +1346,7 @@ func (v *Val) Id() string {
 }
 
 func (v *Val) Info() string {
-	switch v.Type {
+	switch v.Type() {
 	case ValInt:
 		return fmt.Sprintf("[int: %d]", v.Int())
 	case ValReal:
@@ -1245,6 +1361,8 @@ func (v *Val) Info() string {
 		return "[null]"
 	case ValStr:
 		return fmt.Sprintf("[string: %s]", v.String())
+	case ValBytes:
+		return fmt.Sprintf("[bytes: %d]", len(v.Bytes()))
 	case ValList:
 		return v.List().Info()
 	case ValMap:
@@ -1266,8 +1384,8 @@ func (v *Val) Info() string {
 }
 
 func (v *Val) IsThreadSafe() bool {
-	switch v.Type {
-	case ValInt, ValReal, ValNull, ValStr, ValBool:
+	switch v.Type() {
+	case ValInt, ValReal, ValNull, ValStr, ValBytes, ValBool:
 		return true
 	case ValUsr:
 		return v.Usr().IsThreadSafe()
@@ -0,0 +1,147 @@
+package pl
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testBoxClosure struct {
+	*nativeFunc
+}
+
+func TestValLayout(t *testing.T) {
+	assert.Equal(t, 2*unsafe.Sizeof(uintptr(0)), unsafe.Sizeof(Val{}))
+	assert.Equal(t, ValNull, Val{}.Type())
+
+	{
+		v := NewValInt64(-1 << 40)
+		assert.Equal(t, int64(-1<<40), v.Int())
+		v.SetInt(math.MaxInt64)
+		assert.Equal(t, int64(math.MaxInt64), v.Int())
+	}
+	{
+		v := NewValReal(-1.5)
+		assert.Equal(t, -1.5, v.Real())
+		v.SetReal(math.Inf(1))
+		assert.True(t, math.IsInf(v.Real(), 1))
+	}
+	{
+		v := NewValBool(true)
+		assert.True(t, v.Bool())
+		v.SetBool(false)
+		assert.False(t, v.Bool())
+		assert.True(t, v.IsBool())
+	}
+	{
+		v := NewValStr("")
+		assert.Equal(t, "", v.String())
+		v.SetString("hello")
+		assert.Equal(t, "hello", v.String())
+		assert.True(t, v.IsString())
+	}
+	{
+		v := NewValBytes(nil)
+		assert.True(t, v.Bytes() == nil)
+		v = NewValBytes([]byte{1, 2, 3})
+		assert.Equal(t, []byte{1, 2, 3}, v.Bytes())
+		assert.Equal(t, 3, cap(v.Bytes()))
+	}
+	{
+		v := NewValNativeFunction("x", func(_ []Val) (Val, error) {
+			return NewValInt(1), nil
+		})
+		assert.Equal(t, ClosureNative, v.Closure().Type())
+
+		v = NewValMethodFunction(func(_ string, _ []Val) (Val, error) {
+			return NewValNull(), nil
+		}, "m")
+		assert.Equal(t, ClosureMethod, v.Closure().Type())
+
+		v.SetClosure(v.Closure())
+		assert.Equal(t, ClosureMethod, v.Closure().Type())
+	}
+	{
+		u := &testSwitchObj{n: 10}
+		v := NewValUsr(u)
+		assert.True(t, v.Usr() == Usr(u))
+		assert.Equal(t, "counter", v.Id())
+
+		l := NewValList()
+		it := l.List().NewIter()
+		v.SetIter(it)
+		assert.True(t, v.Iter() == Iter(it))
+		assert.Equal(t, ValIter, v.Type())
+	}
+	{
+		// closure that is not implemented by the runtime keeps its interface
+		var c Closure = &testBoxClosure{newNativeFunc("box", nil)}
+		v := NewValNull()
+		v.SetClosure(c)
+		assert.Equal(t, ValClosure, v.Type())
+		assert.True(t, v.Closure() == c)
+		assert.Equal(t, "box", v.Closure().Id())
+	}
+}
+
+func TestValNoAlloc(t *testing.T) {
+	str := "hello world"
+	usr := Usr(&testSwitchObj{n: 10})
+	iter := Iter(NewList().NewIter())
+	var sink Val
+
+	for _, c := range []struct {
+		name string
+		fn   func()
+	}{
+		{"int", func() { sink = NewValInt64(1 << 40) }},
+		{"real", func() { sink = NewValReal(3.1415) }},
+		{"bool", func() { sink = NewValBool(true) }},
+		{"str", func() { sink = NewValStr(str) }},
+		{"usr", func() { sink = NewValUsr(usr) }},
+		{"iter", func() { sink = NewValIter(iter) }},
+		{"usr_dot", func() {
+			v := NewValUsr(usr)
+			sink = NewValStr(v.Usr().Id())
+		}},
+		{"arith", func() {
+			a, b := NewValInt(1000), NewValInt(2000)
+			sink = NewValInt64(a.Int() + b.Int())
+		}},
+	} {
+		assert.Equal(t, 0.0, testing.AllocsPerRun(100, c.fn), c.name)
+	}
+	_ = sink
+}
+
+// allocation of arithmetic heavy and string heavy scripts of assets/test
+func BenchmarkValAlloc(b *testing.B) {
+	for _, file := range []string{
+		"arithmetic.pl",
+		"val_primitive.pl",
+		"val_string.pl",
+	} {
+		data, err := os.ReadFile(filepath.Join(getTestPath(), file))
+		if err != nil {
+			b.Fatal(err)
+		}
+		module, err := CompileModule(string(data), nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		b.Run(file, func(b *testing.B) {
+			b.ReportAllocs()
+			eval := NewEvaluatorSimple()
+			for i := 0; i < b.N; i++ {
+				if _, err := eval.Eval("test", module); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
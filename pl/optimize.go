@@ -0,0 +1,535 @@
+package pl
+
+// Bytecode optimizer. The parser is single pass and emits bytecode directly,
+// so the generated code contains lots of constant expression, jump to jump and
+// push followed by pop. The optimizer is an optional pipeline that runs over
+// the bcList of each program after the module is compiled, and before it is
+// executed.
+//
+// Each pass rewrites the bytecode in place by replacing instructions and
+// marking instructions as removed, then the program is compacted and all the
+// pc related information, ie jump target, debug information and parser meta,
+// are remapped. Rewriting is only performed inside of a basic block, ie only
+// the first instruction of a rewritten sequence can be a jump target, so
+// jumping into the sequence still observes the same behavior.
+
+const (
+	// fold arithmetic, string concatenation and comparison of constants
+	OptConstFold = 1 << iota
+
+	// eliminate branch with constant condition and the unreachable code
+	OptDeadBranch
+
+	// jump to jump is redirected to the final target
+	OptJumpThread
+
+	// fuse common instruction sequence, ie load-local + dot
+	OptPeephole
+
+	// remove value that is pushed and then popped immediately
+	OptPopElim
+
+	OptNone = 0
+	OptAll  = OptConstFold | OptDeadBranch | OptJumpThread | OptPeephole | OptPopElim
+)
+
+// the pipeline is repeated until nothing can be changed, one pass may expose
+// new opportunities to the others
+const optMaxIteration = 16
+
+// Optimize all the programs of the module with the passes specified by flag.
+// The module must not be executed concurrently while optimizing
+func (m *Module) Optimize(flag int) {
+	if flag == OptNone {
+		return
+	}
+	for _, prog := range m.allProgram() {
+		optimizeProgram(prog, flag)
+	}
+}
+
+func optimizeProgram(prog *program, flag int) {
+	o := &optimizer{
+		prog: prog,
+	}
+
+	for i := 0; i < optMaxIteration; i++ {
+		changed := false
+		if flag&OptConstFold != 0 {
+			changed = o.run(o.constFold) || changed
+		}
+		if flag&OptDeadBranch != 0 {
+			changed = o.run(o.deadBranch) || changed
+			changed = o.run(o.unreachable) || changed
+		}
+		if flag&OptJumpThread != 0 {
+			changed = o.run(o.jumpThread) || changed
+		}
+		if flag&OptPeephole != 0 {
+			changed = o.run(o.peephole) || changed
+		}
+		if flag&OptPopElim != 0 {
+			changed = o.run(o.popElim) || changed
+		}
+		if !changed {
+			break
+		}
+	}
+
+	// the pc of instructions are changed
+	prog.initInlineCache()
+}
+
+type optimizer struct {
+	prog    *program
+	target  []bool
+	removed []bool
+}
+
+func isJumpBytecode(op int) bool {
+	switch op {
+	case bcJump, bcJfalse, bcJtrue, bcAnd, bcOr, bcTernary,
+		bcPushException, bcPopException:
+		return true
+	default:
+		return false
+	}
+}
+
+// the instruction never falls through to the next one
+func isTerminalBytecode(op int) bool {
+	switch op {
+	case bcJump, bcPopException, bcReturn, bcHalt:
+		return true
+	default:
+		return false
+	}
+}
+
+// run a pass and compact the program if the pass changed anything
+func (o *optimizer) run(pass func() bool) bool {
+	sz := len(o.prog.bcList)
+	o.target = make([]bool, sz+1)
+	o.removed = make([]bool, sz)
+	for _, bc := range o.prog.bcList {
+		if isJumpBytecode(bc.opcode) {
+			o.target[bc.argument] = true
+		}
+	}
+	if !pass() {
+		return false
+	}
+	o.compact()
+	return true
+}
+
+func (o *optimizer) remove(pc ...int) {
+	for _, x := range pc {
+		o.removed[x] = true
+	}
+}
+
+// whether the sequence [pc, pc+n) can be rewritten, ie none of them has been
+// touched and only the first one can be a jump target
+func (o *optimizer) block(pc int, n int) bool {
+	if pc+n > len(o.prog.bcList) {
+		return false
+	}
+	for i := pc; i < pc+n; i++ {
+		if o.removed[i] || (i != pc && o.target[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (o *optimizer) compact() {
+	prog := o.prog
+	sz := len(prog.bcList)
+
+	// the removed instruction is mapped to the next live instruction
+	remap := make([]int, sz+1)
+	n := 0
+	for pc := 0; pc < sz; pc++ {
+		remap[pc] = n
+		if !o.removed[pc] {
+			n++
+		}
+	}
+	remap[sz] = n
+
+	bcList := make(bytecodeList, 0, n)
+	dbgList := make(sourcelocList, 0, n)
+	for pc, bc := range prog.bcList {
+		if o.removed[pc] {
+			continue
+		}
+		if isJumpBytecode(bc.opcode) {
+			bc.argument = remap[bc.argument]
+		}
+		bcList = append(bcList, bc)
+		dbgList = append(dbgList, prog.dbgList[pc])
+	}
+
+	remapMeta := func(pc int) (int, bool) {
+		if o.removed[pc] {
+			return 0, false
+		}
+		return remap[pc], true
+	}
+
+	if prog.meta.icall != nil {
+		icall := make(map[int]int)
+		for pc, v := range prog.meta.icall {
+			if x, ok := remapMeta(pc); ok {
+				icall[x] = v
+			}
+		}
+		prog.meta.icall = icall
+	}
+	if prog.meta.config != nil {
+		config := make(map[int]string)
+		for pc, v := range prog.meta.config {
+			if x, ok := remapMeta(pc); ok {
+				config[x] = v
+			}
+		}
+		prog.meta.config = config
+	}
+	if prog.meta.emit != nil {
+		emit := make(map[int]metaVar)
+		for pc, v := range prog.meta.emit {
+			if x, ok := remapMeta(pc); ok {
+				emit[x] = v
+			}
+		}
+		prog.meta.emit = emit
+	}
+
+	prog.bcList = bcList
+	prog.dbgList = dbgList
+}
+
+// constant value loaded by the instruction
+func (o *optimizer) constant(bc bytecode) (Val, bool) {
+	switch bc.opcode {
+	case bcLoadInt:
+		return NewValInt64(o.prog.idxInt(bc.argument)), true
+	case bcLoadReal:
+		return NewValReal(o.prog.idxReal(bc.argument)), true
+	case bcLoadStr:
+		return NewValStr(o.prog.idxStr(bc.argument)), true
+	case bcLoadTrue:
+		return NewValBool(true), true
+	case bcLoadFalse:
+		return NewValBool(false), true
+	case bcLoadNull:
+		return NewValNull(), true
+	default:
+		return NewValNull(), false
+	}
+}
+
+func (o *optimizer) load(v Val) (bytecode, bool) {
+	switch v.Type() {
+	case ValInt:
+		return bytecode{opcode: bcLoadInt, argument: o.prog.addInt(v.Int())}, true
+	case ValReal:
+		return bytecode{opcode: bcLoadReal, argument: o.prog.addReal(v.Real())}, true
+	case ValStr:
+		return bytecode{opcode: bcLoadStr, argument: o.prog.addStr(v.String())}, true
+	case ValBool:
+		if v.Bool() {
+			return bytecode{opcode: bcLoadTrue}, true
+		}
+		return bytecode{opcode: bcLoadFalse}, true
+	case ValNull:
+		return bytecode{opcode: bcLoadNull}, true
+	default:
+		return bytecode{}, false
+	}
+}
+
+func isFoldableBin(op int) bool {
+	switch op {
+	case bcAdd, bcSub, bcMul, bcDiv, bcMod, bcPow,
+		bcLt, bcLe, bcGt, bcGe, bcEq, bcNe:
+		return true
+	default:
+		return false
+	}
+}
+
+// pass: constant folding -----------------------------------------------------
+func (o *optimizer) constFold() bool {
+	code := o.prog.bcList
+	changed := false
+	e := &Evaluator{}
+
+	for pc := 0; pc < len(code); pc++ {
+		// binary, const const op
+		if o.block(pc, 3) && isFoldableBin(code[pc+2].opcode) {
+			lhs, ok1 := o.constant(code[pc])
+			rhs, ok2 := o.constant(code[pc+1])
+			if ok1 && ok2 {
+				// error is left to runtime, ie division by zero
+				if v, err := e.doBin(lhs, rhs, code[pc+2].opcode); err == nil {
+					if bc, ok := o.load(v); ok {
+						code[pc] = bc
+						o.remove(pc+1, pc+2)
+						changed = true
+						pc += 2
+						continue
+					}
+				}
+			}
+		}
+
+		// unary, const op
+		if o.block(pc, 2) {
+			v, ok := o.constant(code[pc])
+			if !ok {
+				continue
+			}
+
+			var r Val
+			switch code[pc+1].opcode {
+			case bcNot:
+				r = NewValBool(!v.ToBoolean())
+			case bcNegate:
+				x, err := e.doNegate(v)
+				if err != nil {
+					continue
+				}
+				r = x
+			default:
+				continue
+			}
+
+			if bc, ok := o.load(r); ok {
+				code[pc] = bc
+				o.remove(pc + 1)
+				changed = true
+				pc++
+			}
+		}
+	}
+	return changed
+}
+
+// pass: dead branch elimination ----------------------------------------------
+func (o *optimizer) deadBranch() bool {
+	code := o.prog.bcList
+	changed := false
+
+	for pc := 0; pc < len(code); pc++ {
+		if !o.block(pc, 2) {
+			continue
+		}
+		v, ok := o.constant(code[pc])
+		if !ok {
+			continue
+		}
+		cond := v.ToBoolean()
+		next := code[pc+1]
+
+		switch next.opcode {
+		case bcJfalse, bcJtrue:
+			if cond == (next.opcode == bcJtrue) {
+				code[pc] = bytecode{opcode: bcJump, argument: next.argument}
+				o.remove(pc + 1)
+			} else {
+				o.remove(pc, pc+1)
+			}
+
+		case bcAnd, bcOr:
+			// the condition is left on the stack when jump is taken
+			if cond == (next.opcode == bcOr) {
+				code[pc+1] = bytecode{opcode: bcJump, argument: next.argument}
+			} else {
+				o.remove(pc, pc+1)
+			}
+
+		case bcTernary:
+			// the value of the true branch is on the stack before the condition
+			if cond {
+				code[pc] = bytecode{opcode: bcJump, argument: next.argument}
+			} else {
+				code[pc] = bytecode{opcode: bcPop}
+			}
+			o.remove(pc + 1)
+
+		default:
+			continue
+		}
+
+		changed = true
+		pc++
+	}
+	return changed
+}
+
+// remove the code that cannot be reached from the entry
+func (o *optimizer) unreachable() bool {
+	code := o.prog.bcList
+	reached := make([]bool, len(code))
+	work := []int{0}
+
+	for len(work) != 0 {
+		pc := work[len(work)-1]
+		work = work[:len(work)-1]
+
+		for pc < len(code) && !reached[pc] {
+			reached[pc] = true
+			bc := code[pc]
+			if isJumpBytecode(bc.opcode) {
+				work = append(work, bc.argument)
+			}
+			if isTerminalBytecode(bc.opcode) {
+				break
+			}
+			pc++
+		}
+	}
+
+	changed := false
+	for pc, r := range reached {
+		if !r {
+			o.remove(pc)
+			changed = true
+		}
+	}
+	return changed
+}
+
+// pass: jump threading -------------------------------------------------------
+func (o *optimizer) jumpThread() bool {
+	code := o.prog.bcList
+	changed := false
+
+	// final target of jump, follow jump to jump or the same conditional jump
+	// since the condition is still on the stack
+	final := func(op int, target int) int {
+		for hop := 0; hop < len(code) && target < len(code); hop++ {
+			next := code[target]
+			if next.opcode == bcJump ||
+				((op == bcAnd || op == bcOr) && next.opcode == op) {
+				if next.argument == target {
+					break
+				}
+				target = next.argument
+			} else {
+				break
+			}
+		}
+		return target
+	}
+
+	for pc, bc := range code {
+		switch bc.opcode {
+		case bcJump, bcJfalse, bcJtrue, bcAnd, bcOr, bcTernary, bcPopException:
+			if t := final(bc.opcode, bc.argument); t != bc.argument {
+				code[pc].argument = t
+				changed = true
+			}
+		default:
+			continue
+		}
+
+		if code[pc].opcode != bcJump {
+			continue
+		}
+
+		t := code[pc].argument
+		switch {
+		case t == pc+1:
+			// jump to the next instruction
+			o.remove(pc)
+			changed = true
+
+		case t < len(code) && (code[t].opcode == bcHalt || code[t].opcode == bcReturn):
+			// jump to exit, just exit
+			code[pc] = code[t]
+			changed = true
+		}
+	}
+	return changed
+}
+
+// pass: peephole -------------------------------------------------------------
+const localDotMax = 1 << 16
+
+func packLocalDot(local int, name int) int {
+	return local<<16 | name
+}
+
+func unpackLocalDot(arg int) (int, int) {
+	return arg >> 16, arg & (localDotMax - 1)
+}
+
+func (o *optimizer) peephole() bool {
+	code := o.prog.bcList
+	changed := false
+
+	for pc := 0; pc < len(code); pc++ {
+		if !o.block(pc, 2) {
+			continue
+		}
+		cur := code[pc]
+		next := code[pc+1]
+
+		switch {
+		case cur.opcode == bcLoadLocal && next.opcode == bcDot:
+			if cur.argument >= localDotMax || next.argument >= localDotMax {
+				continue
+			}
+			code[pc] = bytecode{
+				opcode:   bcLoadLocalDot,
+				argument: packLocalDot(cur.argument, next.argument),
+			}
+
+		case cur.opcode == bcNot && next.opcode == bcJfalse:
+			code[pc] = bytecode{opcode: bcJtrue, argument: next.argument}
+
+		case cur.opcode == bcNot && next.opcode == bcJtrue:
+			code[pc] = bytecode{opcode: bcJfalse, argument: next.argument}
+
+		default:
+			continue
+		}
+
+		// the fused instruction reports error with the location of the second
+		// one, ie the dot
+		o.prog.dbgList[pc] = o.prog.dbgList[pc+1]
+		o.remove(pc + 1)
+		changed = true
+		pc++
+	}
+	return changed
+}
+
+// pass: pop elimination ------------------------------------------------------
+func isPureLoad(op int) bool {
+	switch op {
+	case bcLoadInt, bcLoadReal, bcLoadStr, bcLoadTrue, bcLoadFalse, bcLoadNull,
+		bcLoadBytes, bcLoadRegexp, bcLoadLocal, bcLoadUpvalue, bcLoadDollar,
+		bcLoadException, bcNewList, bcNewMap, bcDup1:
+		return true
+	default:
+		return false
+	}
+}
+
+func (o *optimizer) popElim() bool {
+	code := o.prog.bcList
+	changed := false
+
+	for pc := 0; pc < len(code); pc++ {
+		if o.block(pc, 2) && isPureLoad(code[pc].opcode) && code[pc+1].opcode == bcPop {
+			o.remove(pc, pc+1)
+			changed = true
+			pc++
+		}
+	}
+	return changed
+}
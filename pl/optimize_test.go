@@ -0,0 +1,216 @@
+package pl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// cases of eval_test.go, runs with each optimization pass
+var optimizeDiffSuite = []func(*testing.T){
+	TestTryExpr,
+	TestTryStatement,
+	TestMCall,
+	TestVCall,
+	TestUpvalue,
+	TestEval1,
+	TestStrInterpo,
+	TestLocal,
+	TestICall,
+	TestArith1,
+	TestComp1,
+	TestLogic1,
+	TestTernary1,
+	TestIf,
+	TestAssign1,
+	TestAssign2,
+	TestAssign3,
+	TestMatch,
+	TestRegex,
+	TestSCall,
+	TestTemplate,
+	TestTripCountLoopStatement,
+	TestForeverLoopStatement,
+	TestIfStatment,
+}
+
+var optimizePass = []struct {
+	name string
+	flag int
+}{
+	{"const_fold", OptConstFold},
+	{"dead_branch", OptDeadBranch},
+	{"jump_thread", OptJumpThread},
+	{"peephole", OptPeephole},
+	{"pop_elim", OptPopElim},
+	{"all", OptAll},
+}
+
+func TestOptimizeDiff(t *testing.T) {
+	for _, pass := range optimizePass {
+		t.Run(pass.name, func(t *testing.T) {
+			testOptimize = pass.flag
+			defer func() {
+				testOptimize = OptNone
+			}()
+			for _, c := range optimizeDiffSuite {
+				c(t)
+			}
+		})
+	}
+}
+
+func testOptimizeProgram(t *testing.T, code string, flag int) *program {
+	module, err := CompileModule(code, nil)
+	assert.True(t, err == nil, "%s", err)
+	module.Optimize(flag)
+	return module.p[0]
+}
+
+func testHasOpcode(prog *program, op int) bool {
+	for _, bc := range prog.bcList {
+		if bc.opcode == op {
+			return true
+		}
+	}
+	return false
+}
+
+func TestOptimizePass(t *testing.T) {
+	{
+		prog := testOptimizeProgram(t, `test { let a = 1 + 2 * 3 - -1; let b = "a" + "b"; let c = 1 < 2; }`,
+			OptConstFold)
+		assert.False(t, testHasOpcode(prog, bcAdd))
+		assert.False(t, testHasOpcode(prog, bcMul))
+		assert.False(t, testHasOpcode(prog, bcLt))
+		assert.False(t, testHasOpcode(prog, bcNegate))
+		assert.Equal(t, []int64{8}, prog.tbInt[len(prog.tbInt)-1:])
+	}
+	{
+		// division by zero is left to the runtime
+		prog := testOptimizeProgram(t, `test { let a = 1 / 0; }`, OptConstFold)
+		assert.True(t, testHasOpcode(prog, bcDiv))
+	}
+	{
+		prog := testOptimizeProgram(t, `test { let a = 1; if false { a = 2; } elif 0 { a = 3; } for false { a = 4; } }`,
+			OptConstFold|OptDeadBranch|OptJumpThread)
+		assert.False(t, testHasOpcode(prog, bcJfalse))
+		assert.False(t, testHasOpcode(prog, bcJump))
+		assert.Equal(t, 4, len(prog.bcList))
+		assert.Equal(t, len(prog.bcList), len(prog.dbgList))
+	}
+	{
+		prog := testOptimizeProgram(t, `test { for let i = 0; i < 10; i++ { if i == 3 { break; } continue; } }`,
+			OptJumpThread)
+		for pc, bc := range prog.bcList {
+			if bc.opcode == bcJump {
+				assert.NotEqual(t, pc+1, bc.argument)
+				assert.NotEqual(t, bcJump, prog.bcList[bc.argument].opcode)
+			}
+		}
+	}
+	{
+		prog := testOptimizeProgram(t, `test { let a = {}; let b = a.b; if !a { b = 1; } a; 1; }`, OptPeephole|OptPopElim)
+		assert.True(t, testHasOpcode(prog, bcLoadLocalDot))
+		assert.False(t, testHasOpcode(prog, bcNot))
+		assert.False(t, testHasOpcode(prog, bcPop))
+	}
+}
+
+func TestOptimizeError(t *testing.T) {
+	// the error location of fused instruction still points to the field access
+	module, err := CompileModule(`
+test {
+  let a = 1;
+  let b = a.field;
+}
+`, nil)
+	assert.True(t, err == nil)
+	module.Optimize(OptAll)
+	assert.True(t, testHasOpcode(module.p[0], bcLoadLocalDot))
+
+	_, err = NewEvaluatorSimple().Eval("test", module)
+	assert.True(t, err != nil)
+	assert.Contains(t, err.Error(), "around (4, ")
+}
+
+// sample rules, the same shape of rules in assets/test
+var optimizeBenchRule = []struct {
+	name string
+	code string
+}{
+	{
+		"arithmetic",
+		`
+test {
+  let sum = 0;
+  for let i = 0; i < 1000; i++ {
+    sum = sum + i * (2 + 3) - (10 / 2) + (2 if false else 1);
+  }
+  return sum;
+}
+`,
+	},
+	{
+		"branch",
+		`
+test {
+  let cnt = 0;
+  for let i = 0; i < 1000; i++ {
+    if false {
+      cnt = cnt + 100;
+    } elif i > 500 && true {
+      cnt = cnt + 1;
+    } else {
+      cnt = cnt + 2;
+    }
+    if !(i == 10) {
+      continue;
+    }
+  }
+  return cnt;
+}
+`,
+	},
+	{
+		"field",
+		`
+test {
+  let obj = {"a": 1, "b": {"c": 2}};
+  let sum = 0;
+  for let i = 0; i < 1000; i++ {
+    sum = sum + obj.a + obj.b.c;
+    "unused" + "expression";
+  }
+  return sum;
+}
+`,
+	},
+}
+
+func BenchmarkOptimize(b *testing.B) {
+	for _, rule := range optimizeBenchRule {
+		for _, opt := range []struct {
+			name string
+			flag int
+		}{
+			{"plain", OptNone},
+			{"optimized", OptAll},
+		} {
+			module, err := CompileModule(rule.code, nil)
+			if err != nil {
+				b.Fatal(err)
+			}
+			module.Optimize(opt.flag)
+
+			b.Run(rule.name+"/"+opt.name, func(b *testing.B) {
+				eval := NewEvaluatorSimple()
+				for i := 0; i < b.N; i++ {
+					if _, err := eval.Eval("test", module); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"io/fs"
+	"os"
 	"strings"
 
 	"github.com/dianpeng/mono-service/util"
@@ -46,6 +47,12 @@ const (
 type syminfo struct {
 	name string
 	dec  int
+
+	// static analysis, whether the symbol is declared via let/const and
+	// whether it has been read
+	let  bool
+	used bool
+	loc  sourceloc
 }
 
 type lexicalScope struct {
@@ -204,6 +211,16 @@ func (s *lexicalScope) findConst(x string) int {
 	return s.find(x)
 }
 
+func (s *lexicalScope) markLet(idx int, loc sourceloc) {
+	info := &s.tbl[s.idx(idx)]
+	info.let = true
+	info.loc = loc
+}
+
+func (s *lexicalScope) markUsed(idx int) {
+	s.tbl[s.idx(idx)].used = true
+}
+
 const (
 	unboundCallNormal = iota
 	unboundCallPipe
@@ -262,6 +279,9 @@ type parser struct {
 	modModName    string
 	modImportPath string
 
+	// path of the file been parsed, empty for the main module
+	srcFile string
+
 	// helpers
 	fs fs.FS
 }
@@ -274,6 +294,12 @@ func newParser(input string, fs fs.FS) *parser {
 	}
 }
 
+func (p *parser) newProgram(name string, t int) *program {
+	prog := newProgram(p.module, name, t)
+	prog.file = p.srcFile
+	return prog
+}
+
 func (p *parser) parsingMod() bool {
 	return len(p.mlist) != 0
 }
@@ -319,6 +345,17 @@ func (p *parser) enterNormalScope() *lexicalScope {
 }
 
 func (p *parser) leaveScope() *lexicalScope {
+	if prog := p.stbl.top.prog; prog != nil {
+		for _, x := range p.stbl.tbl {
+			if x.let && !x.used {
+				prog.meta.unused = append(prog.meta.unused, metaVar{
+					name: x.name,
+					loc:  x.loc,
+				})
+			}
+		}
+	}
+
 	pp := p.stbl.parent
 	p.stbl = pp
 	if p.stbl != nil {
@@ -477,6 +514,7 @@ func (p *parser) findUpval(n string) int {
 	for cur != nil {
 		i, top := p.findLocalAt(n, cur)
 		if i >= 0 {
+			top.markUsed(i)
 			idx = i
 			break
 		}
@@ -546,8 +584,9 @@ func (p *parser) resolveSymbol(xx string, forWrite bool) (int, int) {
 			return symLocal, local
 		}
 	} else {
-		local := p.findLocal(xx)
+		local, scp := p.findLocalAt(xx, p.stbl)
 		if local != symError {
+			scp.markUsed(local)
 			return symLocal, local
 		}
 	}
@@ -772,11 +811,14 @@ func (p *parser) doImport() error {
 
 	// save the lexer
 	savedL := p.l
+	savedFile := p.srcFile
 	defer func() {
 		p.l = savedL
+		p.srcFile = savedFile
 	}()
 
 	p.l = newLexer(data)
+	p.srcFile = p.modImportPath
 	p.l.next()
 
 	// start to parse the imported module
@@ -1092,7 +1134,7 @@ func (p *parser) parseVarScope(rulename string,
 	}
 	p.l.next()
 
-	prog := newProgram(p.module, rulename, progSession)
+	prog := p.newProgram(rulename, progSession)
 
 	p.enterScopeTop(entryVar, prog)
 	defer func() {
@@ -1178,7 +1220,7 @@ func (p *parser) parseFunction(anony bool) (string, error) {
 		return "", p.errf("iterator/function %s is already existed", funcName)
 	}
 
-	prog := newProgram(p.module, funcName, progFunc)
+	prog := p.newProgram(funcName, progFunc)
 	p.enterScopeTop(entryFunc, prog)
 	defer func() {
 		p.leaveScope()
@@ -1286,7 +1328,7 @@ func (p *parser) parseIterator(anony bool) (string, error) {
 		return "", p.errf("iterator/function %s is already existed", iterName)
 	}
 
-	prog := newProgram(p.module, iterName, progIter)
+	prog := p.newProgram(iterName, progIter)
 	p.enterScopeTop(entryIter, prog)
 	defer func() {
 		p.leaveScope()
@@ -1353,7 +1395,15 @@ func (p *parser) parseRule() error {
 		return p.err("invalid rule name, cannot start with @ which is builtin name")
 	}
 
-	prog := newProgram(p.module, name, progRule)
+	prog := p.newProgram(name, progRule)
+
+	// optional guard and priority, notes multiple rules can be defined for the
+	// same event
+	if err := p.parseRuleOption(prog); err != nil {
+		return err
+	}
+	p.module.addEvent(name, prog)
+
 	p.enterScopeTop(entryRule, prog)
 
 	p.mustAddLocalVar("#event")
@@ -1364,10 +1414,6 @@ func (p *parser) parseRule() error {
 
 	localR := prog.patch(p.l)
 
-	if !p.module.addEvent(name, prog) {
-		return p.err("invalid rule name, the rule has already been existed")
-	}
-
 	// Allow an optional arrow to indicate this is a rule, this is the preferred
 	// grammar to indicate the rule definition inside
 	if p.l.token == tkArrow {
@@ -1389,6 +1435,76 @@ func (p *parser) parseRule() error {
 	return nil
 }
 
+// rule options after the rule name, in any order, ie
+//
+// rule name when $.method == "GET" priority 10 { ... }
+func (p *parser) parseRuleOption(prog *program) error {
+	for p.l.token == tkId {
+		switch p.l.valueText {
+		case "when":
+			if prog.guard != nil {
+				return p.err("the rule's when guard has been specified already")
+			}
+			p.l.next()
+			if err := p.parseRuleGuard(prog); err != nil {
+				return err
+			}
+			break
+
+		case "priority":
+			p.l.next()
+			neg := false
+			if p.l.token == tkSub {
+				neg = true
+				p.l.next()
+			}
+			if p.l.token != tkInt {
+				return p.err("expect an integer literal as rule priority")
+			}
+			prog.priority = p.l.valueInt
+			if neg {
+				prog.priority = -prog.priority
+			}
+			p.l.next()
+			break
+
+		default:
+			return p.errf("unexpected identifier %s, expect when or priority "+
+				"after the rule name", p.l.valueText)
+		}
+	}
+	return nil
+}
+
+// The guard is compiled as a separate program, it is evaluated with the same
+// event context before the rule and the rule is skipped if the guard is false
+func (p *parser) parseRuleGuard(prog *program) error {
+	start := p.l.tokenStart
+
+	guard := p.newProgram(prog.name+"#when", progRule)
+	p.enterScopeTop(entryRule, guard)
+
+	p.mustAddLocalVar("#event")
+	p.mustAddLocalVar("#frame")
+	defer func() {
+		p.leaveScope()
+	}()
+
+	localR := guard.patch(p.l)
+
+	if err := p.parseExpr(guard); err != nil {
+		return err
+	}
+	guard.emit0(p.l, bcReturn)
+
+	guard.localSize = p.stbl.topMaxLocal() - 1
+	guard.emit1At(p.l, localR, bcReserveLocal, p.stbl.topMaxLocal()-1)
+
+	prog.guard = guard
+	prog.guardSrc = strings.TrimSpace(string(p.l.input[start:p.l.tokenStart]))
+	return nil
+}
+
 // parse basic statement, ie
 
 // 1) a function call
@@ -2027,6 +2143,9 @@ func (p *parser) parseVarDecl(prog *program, symt int) error {
 	if idx == symError {
 		return p.err("duplicate local variable via let")
 	}
+	if p.l.valueText != varPlaceholder {
+		p.stbl.markLet(idx, p.l.dbg())
+	}
 	p.l.next()
 
 	if p.l.token == tkAssign {
@@ -2482,6 +2601,7 @@ func (p *parser) parseEmit(prog *program) error {
 
 	// emit a local variable contain an eventName string
 	prog.emit1(p.l, bcLoadStr, prog.addStr(eventName))
+	loc := p.l.dbg()
 
 	// optionally we may have a ',' to indicate there's an arugment
 	if p.l.token == tkComma {
@@ -2495,6 +2615,7 @@ func (p *parser) parseEmit(prog *program) error {
 
 	// this is just for convinience, emit should not carry argument, but put a 1
 	// there to indicate one argument is easy for us to do simulation
+	prog.meta.addEmit(prog.label(), eventName, loc)
 	prog.emit1(p.l, bcEmit, 1)
 	return nil
 }
@@ -3113,6 +3234,11 @@ func (p *parser) parsePrimary(prog *program, l lexeme) error {
 		prog.emit1(p.l, bcLoadIterator, siterIdx)
 		break
 
+	// bytes literal, the raw bytes is stored inside of the string table
+	case tkBytes:
+		prog.emit1(p.l, bcLoadBytes, prog.addStr(l.sval))
+		break
+
 	case tkRegex:
 		idx, err := prog.addRegexp(l.sval)
 		if err != nil {
@@ -3447,6 +3573,7 @@ func (p *parser) patchAllCall() {
 			e.prog.emit1At(p.l, e.entryPos, bcLoadInt, idx)
 
 			e.prog.emit1At(p.l, e.callPos, bcICall, e.arg)
+			e.prog.meta.addICall(e.callPos, intrinsicIdx)
 		} else {
 			// Since iterator and function share the same namespace, so if user tries
 			// to invoke an iterator, it should cause an error
@@ -3811,6 +3938,26 @@ func (p *parser) parseTemplateSelector(x string) (string, Val, error) {
 	}
 }
 
+// template loaded from file, the path is given by the file option of the
+// selector, ie template "pongo[file='page.html']", context
+func (p *parser) templateFile(opt Val) (string, bool) {
+	if opt.Type() != ValMap {
+		return "", false
+	}
+	v, ok := opt.Map().Get("file")
+	if !ok || !v.IsString() {
+		return "", false
+	}
+	return v.String(), true
+}
+
+func (p *parser) templateFS() fs.FS {
+	if p.fs != nil {
+		return p.fs
+	}
+	return os.DirFS(".")
+}
+
 func (p *parser) parseTemplate(prog *program) error {
 
 	// (1) template type
@@ -3818,7 +3965,8 @@ func (p *parser) parseTemplate(prog *program) error {
 		return p.l.toError()
 	}
 
-	templateType, templateOpt, err := p.parseTemplateSelector(p.l.valueText)
+	selector := p.l.valueText
+	templateType, templateOpt, err := p.parseTemplateSelector(selector)
 	if err != nil {
 		return err
 	}
@@ -3831,16 +3979,40 @@ func (p *parser) parseTemplate(prog *program) error {
 	if err := p.parseExpr(prog); err != nil {
 		return err
 	}
+
+	// (3) template file, which does not have inline string blob
+	if file, ok := p.templateFile(templateOpt); ok {
+		idx, err := prog.addTemplateFile(
+			selector,
+			templateType,
+			file,
+			templateOpt,
+			p.templateFS(),
+		)
+		if err != nil {
+			return p.errf("cannot load template file %s: %s", file, err.Error())
+		}
+
+		prog.emit1(p.l, bcTemplate, idx)
+		return nil
+	}
+
 	if !p.l.expectCurrent(tkComma) {
 		return p.l.toError()
 	}
 	p.l.next()
 
-	// (3) string blob
+	// (4) string blob
 	if content, err := p.parseStringBlob(); err != nil {
 		return err
 	} else {
-		idx, err := prog.addTemplate(templateType, content, templateOpt)
+		idx, err := prog.addTemplate(
+			selector,
+			templateType,
+			content,
+			templateOpt,
+			p.templateFS(),
+		)
 		if err != nil {
 			return err
 		}
@@ -4067,7 +4239,7 @@ func (p *parser) parseConfig() error {
 	// try to find an existed config scope
 	prog := p.module.config
 	if prog == nil {
-		prog = newProgram(p.module, ConfigRule, progConfig)
+		prog = p.newProgram(ConfigRule, progConfig)
 		p.module.config = prog
 	}
 	return p.parseConfigScope(prog)
@@ -4126,10 +4298,13 @@ func (p *parser) parseConfigScopeFor(prog *program) error {
 // this syntax is desigend to distinguish normal statement from configuration
 // statement and also keep the bare minimum syntax needs to be learnt from.
 func (p *parser) parseConfigScopeCmd(prog *program) error {
+	name := ""
+
 	if p.l.token == tkDot {
 		if !p.l.expect(tkId) {
 			return p.l.toError()
 		}
+		name = p.l.valueText
 		prog.emit1(p.l, bcLoadStr, prog.addStr(p.l.valueText))
 		p.l.next()
 	} else if p.l.token == tkLSqr {
@@ -4144,6 +4319,7 @@ func (p *parser) parseConfigScopeCmd(prog *program) error {
 		}
 		p.l.next()
 	} else if p.l.token == tkId {
+		name = p.l.valueText
 		prog.emit1(p.l, bcLoadStr, prog.addStr(p.l.valueText))
 		p.l.next()
 	} else {
@@ -4157,6 +4333,9 @@ func (p *parser) parseConfigScopeCmd(prog *program) error {
 			return err
 		}
 
+		if name != "" {
+			prog.meta.addConfig(prog.label(), name)
+		}
 		prog.emit0(
 			p.l,
 			bcConfigPropertySet,
@@ -4168,6 +4347,9 @@ func (p *parser) parseConfigScopeCmd(prog *program) error {
 		if cnt, err := p.parseCallArgs(prog); err != nil {
 			return err
 		} else {
+			if name != "" {
+				prog.meta.addConfig(prog.label(), name)
+			}
 			prog.emit1(
 				p.l,
 				bcConfigCommand,
@@ -0,0 +1,362 @@
+package pl
+
+import (
+	"compress/gzip"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Profiler of the script code. Each Evaluator records the bytecode executed
+// and the time elapsed into a local call stack tree while a rule is running,
+// and merges it into the shared Profile once the rule is done. The Profile is
+// shared by all the Evaluator of a vhost and can be exported as pprof format,
+// so go tool pprof and flamegraph tools work on the script code.
+//
+// The time is sampled every profTimeTick bytecode and whenever the current
+// frame changes, time spent inside of native function is attributed to the
+// script frame that calls it.
+
+const profTimeTick = 64
+
+type profNode struct {
+	prog     *program
+	count    int64 // bytecode executed
+	nanos    int64 // time elapsed
+	children map[*program]*profNode
+}
+
+func (n *profNode) child(prog *program) *profNode {
+	if c, ok := n.children[prog]; ok {
+		return c
+	}
+	if n.children == nil {
+		n.children = make(map[*program]*profNode)
+	}
+	c := &profNode{
+		prog: prog,
+	}
+	n.children[prog] = c
+	return c
+}
+
+// merge counter of that into n and clear the counter of that
+func (n *profNode) merge(that *profNode) {
+	n.count += that.count
+	n.nanos += that.nanos
+	that.count = 0
+	that.nanos = 0
+
+	for prog, c := range that.children {
+		n.child(prog).merge(c)
+	}
+}
+
+type Profile struct {
+	enabled int32
+	sync.Mutex
+	root  profNode
+	start time.Time
+}
+
+func NewProfile() *Profile {
+	return &Profile{
+		start: time.Now(),
+	}
+}
+
+// Enable turns on/off the profiling, the Evaluator checks it before running a
+// rule, so it takes effect for the next rule
+func (p *Profile) Enable(enable bool) {
+	if enable {
+		atomic.StoreInt32(&p.enabled, 1)
+	} else {
+		atomic.StoreInt32(&p.enabled, 0)
+	}
+}
+
+func (p *Profile) Enabled() bool {
+	return atomic.LoadInt32(&p.enabled) == 1
+}
+
+// Reset drops all the recorded samples
+func (p *Profile) Reset() {
+	p.Lock()
+	defer p.Unlock()
+	p.root = profNode{}
+	p.start = time.Now()
+}
+
+func (p *Profile) merge(root *profNode) {
+	p.Lock()
+	defer p.Unlock()
+	p.root.merge(root)
+}
+
+// per Evaluator recording state
+type profState struct {
+	profile *Profile
+	root    profNode
+	cur     *profNode
+	curProg *program
+	curFp   int
+	tick    int
+	last    time.Time
+	buf     []*program
+}
+
+func (s *profState) begin(p *Profile) {
+	s.profile = p
+	s.cur = nil
+	s.curProg = nil
+	s.tick = 0
+	s.last = time.Now()
+}
+
+func (s *profState) end() {
+	s.flushTime()
+	s.profile.merge(&s.root)
+	s.cur = nil
+	s.curProg = nil
+}
+
+func (s *profState) flushTime() {
+	now := time.Now()
+	if s.cur != nil {
+		s.cur.nanos += int64(now.Sub(s.last))
+	}
+	s.last = now
+}
+
+// walk the frames from the current one up to the top frame and find the node
+// of the call stack
+func (s *profState) lookup(e *Evaluator) *profNode {
+	s.buf = s.buf[:0]
+
+	ff := &e.curframe
+	for !ff.isTop() {
+		if ff.prog != nil {
+			s.buf = append(s.buf, ff.prog)
+		}
+		pos := ff.framep + ff.farg + 1
+		if pos >= len(e.Stack) {
+			break
+		}
+		if !e.Stack[pos].isFrame() {
+			break
+		}
+		ff = e.Stack[pos].frame()
+	}
+
+	n := &s.root
+	for i := len(s.buf) - 1; i >= 0; i-- {
+		n = n.child(s.buf[i])
+	}
+	return n
+}
+
+// invoked for every bytecode
+func (s *profState) onBytecode(e *Evaluator) {
+	if prog := e.curframe.prog; s.cur == nil || prog != s.curProg ||
+		e.curframe.framep != s.curFp {
+		s.flushTime()
+		s.cur = s.lookup(e)
+		s.curProg = prog
+		s.curFp = e.curframe.framep
+	}
+
+	s.cur.count++
+	if s.tick++; s.tick%profTimeTick == 0 {
+		s.flushTime()
+	}
+}
+
+// profiling starts, if enabled, at the outermost rule execution
+func (e *Evaluator) profBegin() bool {
+	if e.prof != nil || e.Profile == nil || !e.Profile.Enabled() {
+		return false
+	}
+	if e.profBuf == nil {
+		e.profBuf = &profState{}
+	}
+	e.prof = e.profBuf
+	e.prof.begin(e.Profile)
+	return true
+}
+
+func (e *Evaluator) profEnd() {
+	e.prof.end()
+	e.prof = nil
+}
+
+// ----------------------------------------------------------------------------
+// pprof output, see github.com/google/pprof/proto/profile.proto
+
+type protoBuffer struct {
+	data []byte
+}
+
+func (b *protoBuffer) varint(x uint64) {
+	for x >= 0x80 {
+		b.data = append(b.data, byte(x)|0x80)
+		x >>= 7
+	}
+	b.data = append(b.data, byte(x))
+}
+
+func (b *protoBuffer) key(field int, wire int) {
+	b.varint(uint64(field)<<3 | uint64(wire))
+}
+
+func (b *protoBuffer) int64(field int, x int64) {
+	if x == 0 {
+		return
+	}
+	b.key(field, 0)
+	b.varint(uint64(x))
+}
+
+func (b *protoBuffer) string(field int, x string) {
+	b.key(field, 2)
+	b.varint(uint64(len(x)))
+	b.data = append(b.data, x...)
+}
+
+func (b *protoBuffer) packed(field int, x []int64) {
+	if len(x) == 0 {
+		return
+	}
+	var p protoBuffer
+	for _, v := range x {
+		p.varint(uint64(v))
+	}
+	b.key(field, 2)
+	b.varint(uint64(len(p.data)))
+	b.data = append(b.data, p.data...)
+}
+
+func (b *protoBuffer) message(field int, f func(*protoBuffer)) {
+	var p protoBuffer
+	f(&p)
+	b.key(field, 2)
+	b.varint(uint64(len(p.data)))
+	b.data = append(b.data, p.data...)
+}
+
+type pprofWriter struct {
+	b      protoBuffer
+	str    []string
+	strIdx map[string]int64
+	loc    map[*program]int64
+	locs   []*program
+}
+
+func (w *pprofWriter) strid(x string) int64 {
+	if idx, ok := w.strIdx[x]; ok {
+		return idx
+	}
+	idx := int64(len(w.str))
+	w.str = append(w.str, x)
+	w.strIdx[x] = idx
+	return idx
+}
+
+func (w *pprofWriter) locid(prog *program) int64 {
+	if id, ok := w.loc[prog]; ok {
+		return id
+	}
+	w.locs = append(w.locs, prog)
+	id := int64(len(w.locs))
+	w.loc[prog] = id
+	return id
+}
+
+func (w *pprofWriter) valueType(field int, t string, unit string) {
+	w.b.message(field, func(b *protoBuffer) {
+		b.int64(1, w.strid(t))
+		b.int64(2, w.strid(unit))
+	})
+}
+
+// stack is the location list from root to n
+func (w *pprofWriter) sample(n *profNode, stack []int64) {
+	if n.prog != nil {
+		stack = append(stack, w.locid(n.prog))
+	}
+
+	if n.count != 0 || n.nanos != 0 {
+		// pprof location list starts from the leaf
+		loc := make([]int64, len(stack))
+		for i, id := range stack {
+			loc[len(stack)-1-i] = id
+		}
+		w.b.message(2, func(b *protoBuffer) {
+			b.packed(1, loc)
+			b.packed(2, []int64{n.count, n.nanos})
+		})
+	}
+
+	for _, c := range n.children {
+		w.sample(c, stack)
+	}
+}
+
+func (p *program) startLine() int64 {
+	if len(p.dbgList) == 0 {
+		return 0
+	}
+	return int64(p.dbgList[0].line)
+}
+
+// WritePprof writes out the gzip compressed pprof protobuf of all the samples
+// recorded so far, the sample value is the bytecode executed and the cpu time
+func (p *Profile) WritePprof(out io.Writer) error {
+	p.Lock()
+	defer p.Unlock()
+
+	w := &pprofWriter{
+		str:    []string{""},
+		strIdx: map[string]int64{"": 0},
+		loc:    make(map[*program]int64),
+	}
+
+	w.valueType(1, "bytecode", "count")
+	w.valueType(1, "cpu", "nanoseconds")
+	w.sample(&p.root, nil)
+
+	for idx, prog := range w.locs {
+		id := int64(idx + 1)
+		line := prog.startLine()
+		w.b.message(4, func(b *protoBuffer) {
+			b.int64(1, id)
+			b.message(4, func(b *protoBuffer) {
+				b.int64(1, id)
+				b.int64(2, line)
+			})
+		})
+		name := w.strid(prog.displayName())
+		w.b.message(5, func(b *protoBuffer) {
+			b.int64(1, id)
+			b.int64(2, name)
+			b.int64(3, name)
+			b.int64(5, line)
+		})
+	}
+
+	w.b.int64(9, p.start.UnixNano())
+	w.b.int64(10, int64(time.Since(p.start)))
+	w.valueType(11, "cpu", "nanoseconds")
+	w.b.int64(12, 1)
+
+	// string table must be emitted after all the strings are interned
+	for _, s := range w.str {
+		w.b.string(6, s)
+	}
+
+	gz := gzip.NewWriter(out)
+	if _, err := gz.Write(w.b.data); err != nil {
+		return err
+	}
+	return gz.Close()
+}
@@ -0,0 +1,161 @@
+package pl
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func profFind(n *profNode, path ...string) *profNode {
+	for _, name := range path {
+		var next *profNode
+		for prog, c := range n.children {
+			if prog.displayName() == name {
+				next = c
+				break
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		n = next
+	}
+	return n
+}
+
+func testProfile(t *testing.T, code string, event string) *Profile {
+	module, err := CompileModule(code, nil)
+	assert.True(t, err == nil, "%s", err)
+
+	profile := NewProfile()
+	profile.Enable(true)
+
+	eval := NewEvaluatorSimple()
+	eval.Profile = profile
+	_, err = eval.Eval(event, module)
+	assert.True(t, err == nil, "%s", err)
+	return profile
+}
+
+func TestProfileStack(t *testing.T) {
+	profile := testProfile(t, `
+fn leaf(a) {
+  return a + 1;
+}
+fn mid(a) {
+  let x = 0;
+  for let i = 0; i < a; i++ {
+    x = leaf(x);
+  }
+  return x;
+}
+test {
+  mid(10);
+  leaf(1);
+}
+`, "test")
+
+	top := profFind(&profile.root, "rule test")
+	assert.True(t, top != nil)
+	assert.True(t, top.count > 0)
+
+	mid := profFind(top, "fn mid")
+	assert.True(t, mid != nil)
+	assert.True(t, mid.count > 0)
+
+	// leaf is called from both rule and mid, recorded as different stack
+	l0 := profFind(mid, "fn leaf")
+	l1 := profFind(top, "fn leaf")
+	assert.True(t, l0 != nil && l1 != nil)
+	assert.Equal(t, int64(10), l0.count/l1.count)
+}
+
+func TestProfileDisabled(t *testing.T) {
+	module, err := CompileModule(`test { let a = 1; }`, nil)
+	assert.True(t, err == nil)
+
+	profile := NewProfile()
+	eval := NewEvaluatorSimple()
+	eval.Profile = profile
+	_, err = eval.Eval("test", module)
+	assert.True(t, err == nil)
+	assert.Equal(t, 0, len(profile.root.children))
+
+	profile.Enable(true)
+	_, err = eval.Eval("test", module)
+	assert.True(t, err == nil)
+	assert.Equal(t, 1, len(profile.root.children))
+
+	profile.Reset()
+	assert.Equal(t, 0, len(profile.root.children))
+}
+
+// minimal protobuf reader, returns the fields of a message
+func testProtoFields(data []byte) map[int][][]byte {
+	out := map[int][][]byte{}
+	varint := func() uint64 {
+		var x uint64
+		var s uint
+		for {
+			b := data[0]
+			data = data[1:]
+			x |= uint64(b&0x7f) << s
+			if b < 0x80 {
+				return x
+			}
+			s += 7
+		}
+	}
+	for len(data) > 0 {
+		key := varint()
+		field := int(key >> 3)
+		switch key & 7 {
+		case 0:
+			varint()
+			out[field] = append(out[field], nil)
+		case 2:
+			l := varint()
+			out[field] = append(out[field], data[:l])
+			data = data[l:]
+		}
+	}
+	return out
+}
+
+func TestProfilePprof(t *testing.T) {
+	profile := testProfile(t, `
+fn foo() {
+  return 1;
+}
+test {
+  foo();
+}
+`, "test")
+
+	var b bytes.Buffer
+	assert.True(t, profile.WritePprof(&b) == nil)
+
+	gz, err := gzip.NewReader(&b)
+	assert.True(t, err == nil)
+	data, err := io.ReadAll(gz)
+	assert.True(t, err == nil)
+
+	msg := testProtoFields(data)
+	assert.Equal(t, 2, len(msg[1])) // sample type
+	assert.Equal(t, 2, len(msg[2])) // sample of rule and function
+	assert.Equal(t, 2, len(msg[4])) // location
+	assert.Equal(t, 2, len(msg[5])) // function
+
+	str := []string{}
+	for _, x := range msg[6] {
+		str = append(str, string(x))
+	}
+	assert.Equal(t, "", str[0])
+	assert.Contains(t, str, "rule test")
+	assert.Contains(t, str, "fn foo")
+	assert.Contains(t, str, "bytecode")
+	assert.Contains(t, str, "nanoseconds")
+}
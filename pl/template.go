@@ -2,9 +2,16 @@ package pl
 
 import (
 	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
 
 	// go template
+	htemplate "html/template"
 	"text/template"
+	"text/template/parse"
 
 	// pongo
 	"github.com/flosch/pongo2"
@@ -19,6 +26,21 @@ type Template interface {
 	Execute(context Val) (string, error)
 }
 
+// Template engine which supports loading template from file. The template
+// referenced by the loaded file, ie pongo's include/extends and go's template
+// action, is resolved against the same file system. Engine does not implement
+// this interface is compiled from the file content via Template.Compile
+type TemplateFS interface {
+	CompileFS(fsys fs.FS, file string, opt Val) error
+}
+
+// Template engine whose inline template can reference template from the file
+// system, ie pongo's include/extends. Inline template is compiled via this
+// interface with the module's file system when the engine implements it
+type TemplateInlineFS interface {
+	CompileInlineFS(fsys fs.FS, name, input string, opt Val) error
+}
+
 type TemplateFactory interface {
 	Create() Template
 }
@@ -36,6 +58,15 @@ func (t *goTemplate) Compile(name, input string, _ Val) error {
 	return nil
 }
 
+func (t *goTemplate) CompileFS(fsys fs.FS, file string, _ Val) error {
+	root, err := goTemplateLoadFS(fsys, file)
+	if err != nil {
+		return err
+	}
+	t.goT = root
+	return nil
+}
+
 func (t *goTemplate) Execute(ctx Val) (string, error) {
 	x := new(bytes.Buffer)
 	err := t.goT.Execute(x, ctx.ToNative())
@@ -45,6 +76,108 @@ func (t *goTemplate) Execute(ctx Val) (string, error) {
 	return x.String(), nil
 }
 
+// html/template flavor of go template, ie contextual auto escaping for html
+// output
+type htmlTemplate struct {
+	htmlT *htemplate.Template
+}
+
+func (t *htmlTemplate) Compile(name, input string, _ Val) error {
+	tp, err := htemplate.New(name).Parse(input)
+	if err != nil {
+		return err
+	}
+	t.htmlT = tp
+	return nil
+}
+
+// templates are loaded as text/template and then escaped by html/template
+func (t *htmlTemplate) CompileFS(fsys fs.FS, file string, _ Val) error {
+	tt, err := goTemplateLoadFS(fsys, file)
+	if err != nil {
+		return err
+	}
+	root := htemplate.New(file)
+	for _, x := range tt.Templates() {
+		if x.Tree == nil {
+			continue
+		}
+		if _, err := root.AddParseTree(x.Name(), x.Tree); err != nil {
+			return err
+		}
+	}
+	// the tree added with root's name is not attached to root itself
+	t.htmlT = root.Lookup(file)
+	return nil
+}
+
+func (t *htmlTemplate) Execute(ctx Val) (string, error) {
+	x := new(bytes.Buffer)
+	err := t.htmlT.Execute(x, ctx.ToNative())
+	if err != nil {
+		return "", err
+	}
+	return x.String(), nil
+}
+
+// Go template refers other template by name, ie {{template "name" .}}. When
+// loaded from file system, the template's name is its path inside of the file
+// system, and any referenced template which is not defined is loaded from the
+// file system by using the name as path until all of them are resolved
+func goTemplateLoadFS(fsys fs.FS, file string) (*template.Template, error) {
+	root := template.New(file)
+	pending := []string{file}
+	for len(pending) > 0 {
+		name := pending[0]
+		pending = pending[1:]
+		if x := root.Lookup(name); x != nil && x.Tree != nil {
+			continue
+		}
+
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, fmt.Errorf("template %s cannot be loaded: %s", name, err.Error())
+		}
+		tp := root
+		if name != file {
+			tp = root.New(name)
+		}
+		if _, err := tp.Parse(string(data)); err != nil {
+			return nil, err
+		}
+
+		for _, x := range root.Templates() {
+			if x.Tree != nil {
+				pending = goTemplateRef(x.Tree.Root, pending)
+			}
+		}
+	}
+	return root, nil
+}
+
+func goTemplateRef(n parse.Node, out []string) []string {
+	switch x := n.(type) {
+	case *parse.ListNode:
+		if x != nil {
+			for _, c := range x.Nodes {
+				out = goTemplateRef(c, out)
+			}
+		}
+	case *parse.IfNode:
+		out = goTemplateRef(x.List, out)
+		out = goTemplateRef(x.ElseList, out)
+	case *parse.RangeNode:
+		out = goTemplateRef(x.List, out)
+		out = goTemplateRef(x.ElseList, out)
+	case *parse.WithNode:
+		out = goTemplateRef(x.List, out)
+		out = goTemplateRef(x.ElseList, out)
+	case *parse.TemplateNode:
+		out = append(out, x.Name)
+	}
+	return out
+}
+
 // for now markdown is static at all, ie no runtime rendering what's so ever
 type mdTemplate struct {
 	md string
@@ -76,8 +209,53 @@ func (t *pongoTemplate) Compile(_, input string, _ Val) error {
 	return nil
 }
 
+// include/extends of the inline template is relative to the root of the file
+// system
+func (t *pongoTemplate) CompileInlineFS(fsys fs.FS, name, input string, _ Val) error {
+	set := pongo2.NewSet(name, &pongoFSLoader{fsys: fsys})
+	r, err := set.FromString(input)
+	if err != nil {
+		return err
+	}
+	t.tpl = r
+	return nil
+}
+
+func (t *pongoTemplate) CompileFS(fsys fs.FS, file string, _ Val) error {
+	set := pongo2.NewSet(file, &pongoFSLoader{fsys: fsys})
+	r, err := set.FromFile(file)
+	if err != nil {
+		return err
+	}
+	t.tpl = r
+	return nil
+}
+
+// pongo2 template loader backed by fs.FS. Same as pongo2's file system loader,
+// path is relative to the directory of the template which includes or extends
+// it, and path starts with '/' is relative to the root of the file system
+type pongoFSLoader struct {
+	fsys fs.FS
+}
+
+func (l *pongoFSLoader) Abs(base, name string) string {
+	if base == "" || strings.HasPrefix(name, "/") {
+		return path.Clean(strings.TrimPrefix(name, "/"))
+	}
+	return path.Join(path.Dir(base), name)
+}
+
+// path of the inline template's include/extends is not resolved via Abs
+func (l *pongoFSLoader) Get(file string) (io.Reader, error) {
+	data, err := fs.ReadFile(l.fsys, path.Clean(strings.TrimPrefix(file, "/")))
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(data), nil
+}
+
 func (t *pongoTemplate) tocontext(v Val) pongo2.Context {
-	switch v.Type {
+	switch v.Type() {
 	case ValPair:
 		return pongo2.Context{
 			"first":  v.Pair().First.ToNative(),
@@ -109,6 +287,12 @@ func (f *gotempfac) Create() Template {
 	return &goTemplate{}
 }
 
+type htmltempfac struct{}
+
+func (f *htmltempfac) Create() Template {
+	return &htmlTemplate{}
+}
+
 type mdtempfac struct{}
 
 func (f *mdtempfac) Create() Template {
@@ -131,6 +315,7 @@ func AddTemplateFactory(name string, f TemplateFactory) {
 
 func init() {
 	AddTemplateFactory("go", &gotempfac{})
+	AddTemplateFactory("html", &htmltempfac{})
 	AddTemplateFactory("md", &mdtempfac{})
 	AddTemplateFactory("pongo", &pongotempfac{})
 }
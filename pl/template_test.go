@@ -0,0 +1,105 @@
+package pl
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testTemplateFS = fstest.MapFS{
+	"go/page.tmpl":   {Data: []byte(`{{template "go/header.tmpl" .}}|{{.body}}`)},
+	"go/header.tmpl": {Data: []byte(`<h1>{{.title}}</h1>{{if .sub}}{{template "go/sub.tmpl" .}}{{end}}`)},
+	"go/sub.tmpl":    {Data: []byte(`sub`)},
+	"go/bad.tmpl":    {Data: []byte(`{{template "go/none.tmpl" .}}`)},
+
+	"pongo/base.html":      {Data: []byte(`[{% block content %}base{% endblock %}]`)},
+	"pongo/page.html":      {Data: []byte(`{% extends "base.html" %}{% block content %}{% include "part/item.html" %}{% endblock %}`)},
+	"pongo/part/item.html": {Data: []byte(`{{ title }}`)},
+
+	"md/doc.md": {Data: []byte("# Title")},
+}
+
+func testTemplateEval(code string) (Val, error) {
+	module, err := CompileModule(code, testTemplateFS)
+	if err != nil {
+		return NewValNull(), err
+	}
+	return NewEvaluatorSimple().Eval("test", module)
+}
+
+func TestTemplateFile(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, c := range []struct {
+		code   string
+		expect string
+	}{
+		{
+			`rule test { return template "go[file='go/page.tmpl']", {'title': '<a>', 'body': 'b', 'sub': true}; }`,
+			`<h1><a></h1>sub|b`,
+		},
+		{
+			`rule test { return template "html[file='go/page.tmpl']", {'title': '<a>', 'body': 'b', 'sub': false}; }`,
+			`<h1>&lt;a&gt;</h1>|b`,
+		},
+		{
+			`rule test { return template "pongo[file='pongo/page.html']", {'title': 'hello'}; }`,
+			`[hello]`,
+		},
+		{
+			`rule test { return template "md[file='md/doc.md']", {}; }`,
+			"<h1>Title</h1>\n",
+		},
+		{
+			"rule test { return template \"html\", {'a': '<b>'}, ```\n<p>{{.a}}</p>\n```; }",
+			`<p>&lt;b&gt;</p>`,
+		},
+
+		// inline template references the template of the module's file system
+		{
+			"rule test { return template \"pongo\", {'title': 'hi'}, ```\n{% include \"pongo/part/item.html\" %}!\n```; }",
+			`hi!`,
+		},
+		{
+			"rule test { return template \"pongo\", {}, ```\n{% extends \"/pongo/base.html\" %}{% block content %}inline{% endblock %}\n```; }",
+			`[inline]`,
+		},
+	} {
+		v, err := testTemplateEval(c.code)
+		if assert.True(err == nil, "%s: %s", c.code, err) {
+			assert.Equal(c.expect, v.String(), c.code)
+		}
+	}
+
+	for _, code := range []string{
+		`rule test { return template "go[file='go/none.tmpl']", {}; }`,
+		`rule test { return template "go[file='go/bad.tmpl']", {}; }`,
+		`rule test { return template "pongo[file='pongo/none.html']", {}; }`,
+	} {
+		_, err := CompileModule(code, testTemplateFS)
+		assert.True(err != nil, code)
+	}
+}
+
+func TestTemplateCache(t *testing.T) {
+	assert := assert.New(t)
+
+	module, err := CompileModule(`
+fn f1(x) {
+  return template "pongo[file='pongo/page.html']", x;
+}
+fn f2(x) {
+  return template "pongo[file='pongo/page.html']", x;
+}
+rule test {
+  return template "go", {}, `+"```\ninline\n```"+` + template "go", {}, `+"```\ninline\n```"+`;
+}
+`, testTemplateFS)
+	assert.True(err == nil, "%s", err)
+	assert.Equal(2, len(module.templateCache))
+
+	v, err := NewEvaluatorSimple().Eval("test", module)
+	assert.True(err == nil, "%s", err)
+	assert.Equal("inlineinline", v.String())
+}
@@ -0,0 +1,133 @@
+package pubsub
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/dianpeng/mono-service/util"
+)
+
+// Broker fans out the published message to the subscribers of the channel and
+// of the pattern matching the channel. It knows nothing about the protocol of
+// the subscriber, the redis subscriber is in redis/pubsub.
+//
+// The host creates one broker and injects it into every vhost via the
+// manifest, so the message published by pubsub::publish of a http vhost
+// reaches the redis subscribers of the same process.
+
+// Subscriber receives the message of the channel it subscribes, Message is
+// called by the publisher goroutine
+type Subscriber interface {
+	// pattern is empty if the message is delivered by channel subscription
+	Message(pattern string, channel string, msg []byte)
+}
+
+type Broker struct {
+	sync.RWMutex
+	channel map[string]map[Subscriber]bool
+	pattern map[string]map[Subscriber]bool
+}
+
+func NewBroker() *Broker {
+	return &Broker{
+		channel: make(map[string]map[Subscriber]bool),
+		pattern: make(map[string]map[Subscriber]bool),
+	}
+}
+
+func (b *Broker) add(table map[string]map[Subscriber]bool, name string, s Subscriber) {
+	b.Lock()
+	defer b.Unlock()
+	set, ok := table[name]
+	if !ok {
+		set = make(map[Subscriber]bool)
+		table[name] = set
+	}
+	set[s] = true
+}
+
+func (b *Broker) del(table map[string]map[Subscriber]bool, name string, s Subscriber) {
+	b.Lock()
+	defer b.Unlock()
+	if set, ok := table[name]; ok {
+		delete(set, s)
+		if len(set) == 0 {
+			delete(table, name)
+		}
+	}
+}
+
+func (b *Broker) Subscribe(s Subscriber, channel string) {
+	b.add(b.channel, channel, s)
+}
+
+func (b *Broker) Unsubscribe(s Subscriber, channel string) {
+	b.del(b.channel, channel, s)
+}
+
+func (b *Broker) PSubscribe(s Subscriber, pattern string) {
+	b.add(b.pattern, pattern, s)
+}
+
+func (b *Broker) PUnsubscribe(s Subscriber, pattern string) {
+	b.del(b.pattern, pattern, s)
+}
+
+type delivery struct {
+	s       Subscriber
+	pattern string
+}
+
+// Publish the message to the channel, returns the number of receivers
+func (b *Broker) Publish(channel string, msg []byte) int {
+	var target []delivery
+
+	b.RLock()
+	for s := range b.channel[channel] {
+		target = append(target, delivery{s: s})
+	}
+	for p, set := range b.pattern {
+		if !util.GlobMatch(p, channel) {
+			continue
+		}
+		for s := range set {
+			target = append(target, delivery{s: s, pattern: p})
+		}
+	}
+	b.RUnlock()
+
+	for _, d := range target {
+		d.s.Message(d.pattern, channel, msg)
+	}
+	return len(target)
+}
+
+// Channels returns the active channels matching the pattern in order, all the
+// active channels if pattern is empty
+func (b *Broker) Channels(pattern string) []string {
+	b.RLock()
+	defer b.RUnlock()
+	o := []string{}
+	for ch := range b.channel {
+		if pattern != "" && !util.GlobMatch(pattern, ch) {
+			continue
+		}
+		o = append(o, ch)
+	}
+	sort.Strings(o)
+	return o
+}
+
+// NumSub returns the number of subscribers of the channel
+func (b *Broker) NumSub(channel string) int {
+	b.RLock()
+	defer b.RUnlock()
+	return len(b.channel[channel])
+}
+
+// NumPat returns the number of the subscribed patterns
+func (b *Broker) NumPat() int {
+	b.RLock()
+	defer b.RUnlock()
+	return len(b.pattern)
+}
@@ -0,0 +1,63 @@
+package pubsub
+
+import (
+	"testing"
+
+	"github.com/dianpeng/mono-service/pl"
+	"github.com/stretchr/testify/assert"
+)
+
+type testSubscriber struct {
+	msg []string
+}
+
+func (s *testSubscriber) Message(pattern string, channel string, msg []byte) {
+	s.msg = append(s.msg, pattern+"|"+channel+"|"+string(msg))
+}
+
+func TestBroker(t *testing.T) {
+	assert := assert.New(t)
+	b := NewBroker()
+	s1 := &testSubscriber{}
+	s2 := &testSubscriber{}
+
+	b.Subscribe(s1, "news")
+	b.Subscribe(s2, "news")
+	b.Subscribe(s2, "sport")
+	b.PSubscribe(s1, "n*")
+
+	assert.Equal(3, b.Publish("news", []byte("a")))
+	assert.Equal(1, b.Publish("sport", []byte("b")))
+	assert.Equal(0, b.Publish("weather", []byte("c")))
+	assert.Equal([]string{"|news|a", "n*|news|a"}, s1.msg)
+	assert.Equal([]string{"|news|a", "|sport|b"}, s2.msg)
+
+	assert.Equal([]string{"news", "sport"}, b.Channels(""))
+	assert.Equal([]string{"sport"}, b.Channels("s*"))
+	assert.Equal(2, b.NumSub("news"))
+	assert.Equal(0, b.NumSub("weather"))
+	assert.Equal(1, b.NumPat())
+
+	b.Unsubscribe(s2, "news")
+	b.Unsubscribe(s2, "sport")
+	b.PUnsubscribe(s1, "n*")
+	assert.Equal([]string{"news"}, b.Channels(""))
+	assert.Equal(1, b.NumSub("news"))
+	assert.Equal(0, b.NumPat())
+	assert.Equal(1, b.Publish("news", []byte("d")))
+}
+
+func TestFnPublish(t *testing.T) {
+	assert := assert.New(t)
+	b := NewBroker()
+	s := &testSubscriber{}
+	b.Subscribe(s, "ch")
+
+	v, err := FnPublish(b, []pl.Val{pl.NewValStr("ch"), pl.NewValStr("hi")})
+	assert.True(err == nil)
+	assert.Equal(int64(1), v.Int())
+	assert.Equal([]string{"|ch|hi"}, s.msg)
+
+	_, err = FnPublish(nil, []pl.Val{pl.NewValStr("ch"), pl.NewValStr("hi")})
+	assert.True(err != nil)
+}
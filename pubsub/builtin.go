@@ -0,0 +1,26 @@
+package pubsub
+
+import (
+	"fmt"
+
+	"github.com/dianpeng/mono-service/pl"
+)
+
+// pubsub::publish(channel, message) publishes the message via the broker of the
+// vhost and returns the number of receivers. It is exposed by both the http
+// and redis vhost, so the http endpoint can push to the redis clients
+
+var (
+	fnProtoPubSubPublish = pl.MustNewModFuncProto("pubsub", "publish", "%s(%s|%B)")
+)
+
+func FnPublish(b *Broker, argument []pl.Val) (pl.Val, error) {
+	if _, err := fnProtoPubSubPublish.Check(argument); err != nil {
+		return pl.NewValNull(), err
+	}
+	if b == nil {
+		return pl.NewValNull(), fmt.Errorf("pubsub::publish: broker is not setup")
+	}
+	msg, _ := pl.ToBytes(argument[1])
+	return pl.NewValInt(b.Publish(argument[0].String(), msg)), nil
+}
@@ -0,0 +1,1229 @@
+package kv
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dianpeng/mono-service/util"
+)
+
+// Reply of the command is one of the following Go value, which maps to RESP
+//
+//   nil          null bulk string
+//   Status       simple string, ie OK
+//   []byte       bulk string
+//   int64        integer
+//   []interface{} array of the above
+//
+// Error is returned as Go error whose message follows redis, ie starts with
+// ERR or WRONGTYPE.
+
+type Status string
+
+const (
+	statusOK = Status("OK")
+)
+
+var (
+	errWrongType = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+	errSyntax    = errors.New("ERR syntax error")
+	errNotInt    = errors.New("ERR value is not an integer or out of range")
+	errNotFloat  = errors.New("ERR value is not a valid float")
+	errNoKey     = errors.New("ERR no such key")
+	errIndex     = errors.New("ERR index out of range")
+	errOverflow  = errors.New("ERR increment or decrement would overflow")
+)
+
+type commandFn func(*Keyspace, [][]byte) (interface{}, error)
+
+type command struct {
+	// same as redis, includes the command name and negative means at least
+	arity int
+	fn    commandFn
+}
+
+var commandTable map[string]command
+
+func init() {
+	commandTable = map[string]command{
+		// connection and server
+		"PING":     {-1, cmdPing},
+		"ECHO":     {2, cmdEcho},
+		"SELECT":   {2, cmdSelect},
+		"DBSIZE":   {1, cmdDBSize},
+		"FLUSHDB":  {-1, cmdFlush},
+		"FLUSHALL": {-1, cmdFlush},
+		"SAVE":     {1, cmdSave},
+
+		// generic
+		"DEL":       {-2, cmdDel},
+		"UNLINK":    {-2, cmdDel},
+		"EXISTS":    {-2, cmdExists},
+		"EXPIRE":    {3, cmdExpire},
+		"PEXPIRE":   {3, cmdExpire},
+		"EXPIREAT":  {3, cmdExpire},
+		"PEXPIREAT": {3, cmdExpire},
+		"TTL":       {2, cmdTTL},
+		"PTTL":      {2, cmdTTL},
+		"PERSIST":   {2, cmdPersist},
+		"TYPE":      {2, cmdType},
+		"KEYS":      {2, cmdKeys},
+		"RENAME":    {3, cmdRename},
+
+		// string
+		"GET":         {2, cmdGet},
+		"SET":         {-3, cmdSet},
+		"SETNX":       {3, cmdSetNX},
+		"SETEX":       {4, cmdSetEX},
+		"PSETEX":      {4, cmdSetEX},
+		"GETSET":      {3, cmdGetSet},
+		"GETDEL":      {2, cmdGetDel},
+		"MGET":        {-2, cmdMGet},
+		"MSET":        {-3, cmdMSet},
+		"APPEND":      {3, cmdAppend},
+		"STRLEN":      {2, cmdStrlen},
+		"INCR":        {2, cmdIncr},
+		"DECR":        {2, cmdIncr},
+		"INCRBY":      {3, cmdIncr},
+		"DECRBY":      {3, cmdIncr},
+		"INCRBYFLOAT": {3, cmdIncrByFloat},
+
+		// hash
+		"HSET":    {-4, cmdHSet},
+		"HMSET":   {-4, cmdHSet},
+		"HSETNX":  {4, cmdHSetNX},
+		"HGET":    {3, cmdHGet},
+		"HMGET":   {-3, cmdHMGet},
+		"HDEL":    {-3, cmdHDel},
+		"HEXISTS": {3, cmdHExists},
+		"HLEN":    {2, cmdHLen},
+		"HKEYS":   {2, cmdHGetAll},
+		"HVALS":   {2, cmdHGetAll},
+		"HGETALL": {2, cmdHGetAll},
+		"HINCRBY": {4, cmdHIncrBy},
+
+		// list
+		"LPUSH":  {-3, cmdPush},
+		"RPUSH":  {-3, cmdPush},
+		"LPOP":   {-2, cmdPop},
+		"RPOP":   {-2, cmdPop},
+		"LLEN":   {2, cmdLLen},
+		"LRANGE": {4, cmdLRange},
+		"LINDEX": {3, cmdLIndex},
+		"LSET":   {4, cmdLSet},
+		"LTRIM":  {4, cmdLTrim},
+
+		// set
+		"SADD":      {-3, cmdSAdd},
+		"SREM":      {-3, cmdSRem},
+		"SMEMBERS":  {2, cmdSMembers},
+		"SISMEMBER": {3, cmdSIsMember},
+		"SCARD":     {2, cmdSCard},
+
+		// sorted set
+		"ZADD":      {-4, cmdZAdd},
+		"ZREM":      {-3, cmdZRem},
+		"ZSCORE":    {3, cmdZScore},
+		"ZCARD":     {2, cmdZCard},
+		"ZINCRBY":   {4, cmdZIncrBy},
+		"ZRANK":     {3, cmdZRank},
+		"ZREVRANK":  {3, cmdZRank},
+		"ZRANGE":    {-4, cmdZRange},
+		"ZREVRANGE": {-4, cmdZRange},
+	}
+}
+
+// whether the command, in upper case, is implemented by the keyspace
+func HasCommand(name string) bool {
+	_, ok := commandTable[name]
+	return ok
+}
+
+// Execute the command against the keyspace, argv[0] is the command name
+func (k *Keyspace) Exec(argv [][]byte) (interface{}, error) {
+	if len(argv) == 0 {
+		return nil, fmt.Errorf("ERR empty command")
+	}
+	name := strings.ToUpper(string(argv[0]))
+	cmd, ok := commandTable[name]
+	if !ok {
+		return nil, fmt.Errorf("ERR unknown command '%s'", string(argv[0]))
+	}
+	if (cmd.arity >= 0 && len(argv) != cmd.arity) ||
+		(cmd.arity < 0 && len(argv) < -cmd.arity) {
+		return nil, fmt.Errorf("ERR wrong number of arguments for '%s' command", strings.ToLower(name))
+	}
+
+	// the command name is normalized without touching caller's argv
+	x := make([][]byte, 0, len(argv))
+	x = append(x, []byte(name))
+	x = append(x, argv[1:]...)
+
+	// save writes the file outside of the lock
+	if name == "SAVE" {
+		return cmd.fn(k, x)
+	}
+
+	k.Lock()
+	defer k.Unlock()
+	return cmd.fn(k, x)
+}
+
+// -----------------------------------------------------------------------------
+// helpers
+
+func parseInt(b []byte) (int64, error) {
+	v, err := strconv.ParseInt(string(b), 10, 64)
+	if err != nil {
+		return 0, errNotInt
+	}
+	return v, nil
+}
+
+func parseFloat(b []byte) (float64, error) {
+	s := strings.ToLower(string(b))
+	switch s {
+	case "+inf", "inf":
+		return math.Inf(1), nil
+	case "-inf":
+		return math.Inf(-1), nil
+	default:
+		break
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(v) {
+		return 0, errNotFloat
+	}
+	return v, nil
+}
+
+func formatFloat(v float64) []byte {
+	switch {
+	case math.IsInf(v, 1):
+		return []byte("inf")
+	case math.IsInf(v, -1):
+		return []byte("-inf")
+	default:
+		return []byte(strconv.FormatFloat(v, 'f', -1, 64))
+	}
+}
+
+func boolInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func copyBytes(b []byte) []byte {
+	o := make([]byte, len(b))
+	copy(o, b)
+	return o
+}
+
+// normalize redis style range, negative index counts from the end. Returns
+// false if the range is empty
+func normRange(start, stop int64, size int) (int, int, bool) {
+	n := int64(size)
+	if start < 0 {
+		start += n
+	}
+	if stop < 0 {
+		stop += n
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= n {
+		stop = n - 1
+	}
+	if start > stop || start >= n {
+		return 0, 0, false
+	}
+	return int(start), int(stop), true
+}
+
+func sortedKeys(m map[string][]byte) []string {
+	o := make([]string, 0, len(m))
+	for x := range m {
+		o = append(o, x)
+	}
+	sort.Strings(o)
+	return o
+}
+
+// -----------------------------------------------------------------------------
+// connection and server
+
+func cmdPing(_ *Keyspace, argv [][]byte) (interface{}, error) {
+	switch len(argv) {
+	case 1:
+		return Status("PONG"), nil
+	case 2:
+		return copyBytes(argv[1]), nil
+	default:
+		return nil, fmt.Errorf("ERR wrong number of arguments for 'ping' command")
+	}
+}
+
+func cmdEcho(_ *Keyspace, argv [][]byte) (interface{}, error) {
+	return copyBytes(argv[1]), nil
+}
+
+// only the default database exists
+func cmdSelect(_ *Keyspace, argv [][]byte) (interface{}, error) {
+	db, err := parseInt(argv[1])
+	if err != nil {
+		return nil, err
+	}
+	if db != 0 {
+		return nil, fmt.Errorf("ERR DB index is out of range")
+	}
+	return statusOK, nil
+}
+
+func cmdDBSize(k *Keyspace, _ [][]byte) (interface{}, error) {
+	return int64(len(k.data)), nil
+}
+
+func cmdFlush(k *Keyspace, _ [][]byte) (interface{}, error) {
+	k.data = make(map[string]*entry)
+	k.volatile = make(map[string]bool)
+	return statusOK, nil
+}
+
+func cmdSave(k *Keyspace, _ [][]byte) (interface{}, error) {
+	if err := k.Snapshot(); err != nil {
+		return nil, fmt.Errorf("ERR %s", err.Error())
+	}
+	return statusOK, nil
+}
+
+// -----------------------------------------------------------------------------
+// generic
+
+func cmdDel(k *Keyspace, argv [][]byte) (interface{}, error) {
+	cnt := int64(0)
+	for _, key := range argv[1:] {
+		if k.lookup(string(key)) != nil {
+			k.remove(string(key))
+			cnt++
+		}
+	}
+	return cnt, nil
+}
+
+func cmdExists(k *Keyspace, argv [][]byte) (interface{}, error) {
+	cnt := int64(0)
+	for _, key := range argv[1:] {
+		if k.lookup(string(key)) != nil {
+			cnt++
+		}
+	}
+	return cnt, nil
+}
+
+func cmdExpire(k *Keyspace, argv [][]byte) (interface{}, error) {
+	v, err := parseInt(argv[2])
+	if err != nil {
+		return nil, err
+	}
+
+	var at int64
+	switch string(argv[0]) {
+	case "EXPIRE":
+		at = nowMs() + v*1000
+	case "PEXPIRE":
+		at = nowMs() + v
+	case "EXPIREAT":
+		at = v * 1000
+	default:
+		at = v
+	}
+
+	key := string(argv[1])
+	e := k.lookup(key)
+	if e == nil {
+		return int64(0), nil
+	}
+	if at <= nowMs() {
+		k.remove(key)
+	} else {
+		k.setExpire(key, e, at)
+	}
+	return int64(1), nil
+}
+
+func cmdTTL(k *Keyspace, argv [][]byte) (interface{}, error) {
+	e := k.lookup(string(argv[1]))
+	if e == nil {
+		return int64(-2), nil
+	}
+	if e.ExpireAt == 0 {
+		return int64(-1), nil
+	}
+	left := e.ExpireAt - nowMs()
+	if string(argv[0]) == "TTL" {
+		return (left + 500) / 1000, nil
+	}
+	return left, nil
+}
+
+func cmdPersist(k *Keyspace, argv [][]byte) (interface{}, error) {
+	key := string(argv[1])
+	e := k.lookup(key)
+	if e == nil || e.ExpireAt == 0 {
+		return int64(0), nil
+	}
+	k.setExpire(key, e, 0)
+	return int64(1), nil
+}
+
+func cmdType(k *Keyspace, argv [][]byte) (interface{}, error) {
+	e := k.lookup(string(argv[1]))
+	if e == nil {
+		return Status(TypeName(TypeNone)), nil
+	}
+	return Status(TypeName(e.Type)), nil
+}
+
+func cmdKeys(k *Keyspace, argv [][]byte) (interface{}, error) {
+	pattern := string(argv[1])
+	keys := []string{}
+	now := nowMs()
+	for key, e := range k.data {
+		if e.ExpireAt > 0 && e.ExpireAt <= now {
+			continue
+		}
+		if util.GlobMatch(pattern, key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	o := make([]interface{}, 0, len(keys))
+	for _, key := range keys {
+		o = append(o, []byte(key))
+	}
+	return o, nil
+}
+
+func cmdRename(k *Keyspace, argv [][]byte) (interface{}, error) {
+	from := string(argv[1])
+	to := string(argv[2])
+	e := k.lookup(from)
+	if e == nil {
+		return nil, errNoKey
+	}
+	k.remove(from)
+	k.remove(to)
+	k.data[to] = e
+	if e.ExpireAt > 0 {
+		k.volatile[to] = true
+	}
+	return statusOK, nil
+}
+
+// -----------------------------------------------------------------------------
+// string
+
+func (k *Keyspace) getString(key string) ([]byte, bool, error) {
+	e, err := k.lookupType(key, TypeString)
+	if err != nil {
+		return nil, false, err
+	}
+	if e == nil {
+		return nil, false, nil
+	}
+	return e.Str, true, nil
+}
+
+func cmdGet(k *Keyspace, argv [][]byte) (interface{}, error) {
+	v, ok, err := k.getString(string(argv[1]))
+	if err != nil || !ok {
+		return nil, err
+	}
+	return copyBytes(v), nil
+}
+
+// SET key value [NX|XX] [GET] [EX seconds|PX milliseconds|KEEPTTL]
+func cmdSet(k *Keyspace, argv [][]byte) (interface{}, error) {
+	key := string(argv[1])
+	nx, xx, get, keepTTL := false, false, false, false
+	expireAt := int64(0)
+
+	for i := 3; i < len(argv); i++ {
+		switch strings.ToUpper(string(argv[i])) {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "GET":
+			get = true
+		case "KEEPTTL":
+			keepTTL = true
+		case "EX", "PX":
+			if i+1 >= len(argv) || expireAt != 0 {
+				return nil, errSyntax
+			}
+			v, err := parseInt(argv[i+1])
+			if err != nil {
+				return nil, err
+			}
+			if v <= 0 {
+				return nil, fmt.Errorf("ERR invalid expire time in 'set' command")
+			}
+			if strings.ToUpper(string(argv[i])) == "EX" {
+				v *= 1000
+			}
+			expireAt = nowMs() + v
+			i++
+		default:
+			return nil, errSyntax
+		}
+	}
+	if (nx && xx) || (keepTTL && expireAt != 0) {
+		return nil, errSyntax
+	}
+
+	old := k.lookup(key)
+	if get && old != nil && old.Type != TypeString {
+		return nil, errWrongType
+	}
+
+	var reply interface{}
+	if get {
+		if old != nil {
+			reply = copyBytes(old.Str)
+		}
+	} else {
+		reply = statusOK
+	}
+
+	if (nx && old != nil) || (xx && old == nil) {
+		if get {
+			return reply, nil
+		}
+		return nil, nil
+	}
+
+	oldExpire := int64(0)
+	if old != nil {
+		oldExpire = old.ExpireAt
+	}
+	e := k.setString(key, copyBytes(argv[2]))
+	if expireAt != 0 {
+		k.setExpire(key, e, expireAt)
+	} else if keepTTL {
+		k.setExpire(key, e, oldExpire)
+	}
+	return reply, nil
+}
+
+func cmdSetNX(k *Keyspace, argv [][]byte) (interface{}, error) {
+	key := string(argv[1])
+	if k.lookup(key) != nil {
+		return int64(0), nil
+	}
+	k.setString(key, copyBytes(argv[2]))
+	return int64(1), nil
+}
+
+func cmdSetEX(k *Keyspace, argv [][]byte) (interface{}, error) {
+	v, err := parseInt(argv[2])
+	if err != nil {
+		return nil, err
+	}
+	if v <= 0 {
+		return nil, fmt.Errorf("ERR invalid expire time in '%s' command", strings.ToLower(string(argv[0])))
+	}
+	if string(argv[0]) == "SETEX" {
+		v *= 1000
+	}
+	key := string(argv[1])
+	e := k.setString(key, copyBytes(argv[3]))
+	k.setExpire(key, e, nowMs()+v)
+	return statusOK, nil
+}
+
+func cmdGetSet(k *Keyspace, argv [][]byte) (interface{}, error) {
+	key := string(argv[1])
+	v, ok, err := k.getString(key)
+	if err != nil {
+		return nil, err
+	}
+	k.setString(key, copyBytes(argv[2]))
+	if !ok {
+		return nil, nil
+	}
+	return v, nil
+}
+
+func cmdGetDel(k *Keyspace, argv [][]byte) (interface{}, error) {
+	key := string(argv[1])
+	v, ok, err := k.getString(key)
+	if err != nil || !ok {
+		return nil, err
+	}
+	k.remove(key)
+	return v, nil
+}
+
+func cmdMGet(k *Keyspace, argv [][]byte) (interface{}, error) {
+	o := make([]interface{}, 0, len(argv)-1)
+	for _, key := range argv[1:] {
+		e := k.lookup(string(key))
+		if e == nil || e.Type != TypeString {
+			o = append(o, nil)
+		} else {
+			o = append(o, copyBytes(e.Str))
+		}
+	}
+	return o, nil
+}
+
+func cmdMSet(k *Keyspace, argv [][]byte) (interface{}, error) {
+	if len(argv)%2 != 1 {
+		return nil, fmt.Errorf("ERR wrong number of arguments for 'mset' command")
+	}
+	for i := 1; i < len(argv); i += 2 {
+		k.setString(string(argv[i]), copyBytes(argv[i+1]))
+	}
+	return statusOK, nil
+}
+
+func cmdAppend(k *Keyspace, argv [][]byte) (interface{}, error) {
+	e, err := k.lookupOrCreate(string(argv[1]), TypeString)
+	if err != nil {
+		return nil, err
+	}
+	e.Str = append(e.Str, argv[2]...)
+	return int64(len(e.Str)), nil
+}
+
+func cmdStrlen(k *Keyspace, argv [][]byte) (interface{}, error) {
+	v, _, err := k.getString(string(argv[1]))
+	if err != nil {
+		return nil, err
+	}
+	return int64(len(v)), nil
+}
+
+func cmdIncr(k *Keyspace, argv [][]byte) (interface{}, error) {
+	delta := int64(1)
+	if len(argv) == 3 {
+		v, err := parseInt(argv[2])
+		if err != nil {
+			return nil, err
+		}
+		delta = v
+	}
+	switch string(argv[0]) {
+	case "DECR", "DECRBY":
+		if delta == math.MinInt64 {
+			return nil, errOverflow
+		}
+		delta = -delta
+	default:
+		break
+	}
+
+	e, err := k.lookupOrCreate(string(argv[1]), TypeString)
+	if err != nil {
+		return nil, err
+	}
+	cur := int64(0)
+	if len(e.Str) != 0 {
+		if cur, err = parseInt(e.Str); err != nil {
+			return nil, err
+		}
+	}
+	if (delta > 0 && cur > math.MaxInt64-delta) ||
+		(delta < 0 && cur < math.MinInt64-delta) {
+		return nil, errOverflow
+	}
+	cur += delta
+	e.Str = []byte(strconv.FormatInt(cur, 10))
+	return cur, nil
+}
+
+func cmdIncrByFloat(k *Keyspace, argv [][]byte) (interface{}, error) {
+	delta, err := parseFloat(argv[2])
+	if err != nil {
+		return nil, err
+	}
+	e, err := k.lookupOrCreate(string(argv[1]), TypeString)
+	if err != nil {
+		return nil, err
+	}
+	cur := 0.0
+	if len(e.Str) != 0 {
+		if cur, err = parseFloat(e.Str); err != nil {
+			return nil, err
+		}
+	}
+	cur += delta
+	if math.IsInf(cur, 0) || math.IsNaN(cur) {
+		return nil, fmt.Errorf("ERR increment would produce NaN or Infinity")
+	}
+	e.Str = formatFloat(cur)
+	return copyBytes(e.Str), nil
+}
+
+// -----------------------------------------------------------------------------
+// hash
+
+func cmdHSet(k *Keyspace, argv [][]byte) (interface{}, error) {
+	if len(argv)%2 != 0 {
+		return nil, fmt.Errorf("ERR wrong number of arguments for '%s' command", strings.ToLower(string(argv[0])))
+	}
+	e, err := k.lookupOrCreate(string(argv[1]), TypeHash)
+	if err != nil {
+		return nil, err
+	}
+	cnt := int64(0)
+	for i := 2; i < len(argv); i += 2 {
+		f := string(argv[i])
+		if _, ok := e.Hash[f]; !ok {
+			cnt++
+		}
+		e.Hash[f] = copyBytes(argv[i+1])
+	}
+	if string(argv[0]) == "HMSET" {
+		return statusOK, nil
+	}
+	return cnt, nil
+}
+
+func cmdHSetNX(k *Keyspace, argv [][]byte) (interface{}, error) {
+	e, err := k.lookupOrCreate(string(argv[1]), TypeHash)
+	if err != nil {
+		return nil, err
+	}
+	f := string(argv[2])
+	if _, ok := e.Hash[f]; ok {
+		return int64(0), nil
+	}
+	e.Hash[f] = copyBytes(argv[3])
+	return int64(1), nil
+}
+
+func cmdHGet(k *Keyspace, argv [][]byte) (interface{}, error) {
+	e, err := k.lookupType(string(argv[1]), TypeHash)
+	if err != nil || e == nil {
+		return nil, err
+	}
+	v, ok := e.Hash[string(argv[2])]
+	if !ok {
+		return nil, nil
+	}
+	return copyBytes(v), nil
+}
+
+func cmdHMGet(k *Keyspace, argv [][]byte) (interface{}, error) {
+	e, err := k.lookupType(string(argv[1]), TypeHash)
+	if err != nil {
+		return nil, err
+	}
+	o := make([]interface{}, 0, len(argv)-2)
+	for _, f := range argv[2:] {
+		if e == nil {
+			o = append(o, nil)
+			continue
+		}
+		if v, ok := e.Hash[string(f)]; ok {
+			o = append(o, copyBytes(v))
+		} else {
+			o = append(o, nil)
+		}
+	}
+	return o, nil
+}
+
+func cmdHDel(k *Keyspace, argv [][]byte) (interface{}, error) {
+	key := string(argv[1])
+	e, err := k.lookupType(key, TypeHash)
+	if err != nil || e == nil {
+		return int64(0), err
+	}
+	cnt := int64(0)
+	for _, f := range argv[2:] {
+		if _, ok := e.Hash[string(f)]; ok {
+			delete(e.Hash, string(f))
+			cnt++
+		}
+	}
+	k.removeIfEmpty(key, e)
+	return cnt, nil
+}
+
+func cmdHExists(k *Keyspace, argv [][]byte) (interface{}, error) {
+	e, err := k.lookupType(string(argv[1]), TypeHash)
+	if err != nil || e == nil {
+		return int64(0), err
+	}
+	_, ok := e.Hash[string(argv[2])]
+	return boolInt(ok), nil
+}
+
+func cmdHLen(k *Keyspace, argv [][]byte) (interface{}, error) {
+	e, err := k.lookupType(string(argv[1]), TypeHash)
+	if err != nil || e == nil {
+		return int64(0), err
+	}
+	return int64(len(e.Hash)), nil
+}
+
+// HKEYS, HVALS and HGETALL, fields are in sorted order
+func cmdHGetAll(k *Keyspace, argv [][]byte) (interface{}, error) {
+	o := []interface{}{}
+	e, err := k.lookupType(string(argv[1]), TypeHash)
+	if err != nil || e == nil {
+		return o, err
+	}
+	for _, f := range sortedKeys(e.Hash) {
+		switch string(argv[0]) {
+		case "HKEYS":
+			o = append(o, []byte(f))
+		case "HVALS":
+			o = append(o, copyBytes(e.Hash[f]))
+		default:
+			o = append(o, []byte(f), copyBytes(e.Hash[f]))
+		}
+	}
+	return o, nil
+}
+
+func cmdHIncrBy(k *Keyspace, argv [][]byte) (interface{}, error) {
+	delta, err := parseInt(argv[3])
+	if err != nil {
+		return nil, err
+	}
+	e, err := k.lookupOrCreate(string(argv[1]), TypeHash)
+	if err != nil {
+		return nil, err
+	}
+	f := string(argv[2])
+	cur := int64(0)
+	if v, ok := e.Hash[f]; ok {
+		if cur, err = parseInt(v); err != nil {
+			return nil, fmt.Errorf("ERR hash value is not an integer")
+		}
+	}
+	if (delta > 0 && cur > math.MaxInt64-delta) ||
+		(delta < 0 && cur < math.MinInt64-delta) {
+		return nil, errOverflow
+	}
+	cur += delta
+	e.Hash[f] = []byte(strconv.FormatInt(cur, 10))
+	return cur, nil
+}
+
+// -----------------------------------------------------------------------------
+// list
+
+func cmdPush(k *Keyspace, argv [][]byte) (interface{}, error) {
+	e, err := k.lookupOrCreate(string(argv[1]), TypeList)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range argv[2:] {
+		if string(argv[0]) == "LPUSH" {
+			e.List = append([][]byte{copyBytes(v)}, e.List...)
+		} else {
+			e.List = append(e.List, copyBytes(v))
+		}
+	}
+	return int64(len(e.List)), nil
+}
+
+// LPOP/RPOP key [count]
+func cmdPop(k *Keyspace, argv [][]byte) (interface{}, error) {
+	if len(argv) > 3 {
+		return nil, errSyntax
+	}
+	count := int64(-1)
+	if len(argv) == 3 {
+		v, err := parseInt(argv[2])
+		if err != nil || v < 0 {
+			return nil, fmt.Errorf("ERR value is out of range, must be positive")
+		}
+		count = v
+	}
+
+	key := string(argv[1])
+	e, err := k.lookupType(key, TypeList)
+	if err != nil || e == nil {
+		return nil, err
+	}
+
+	n := count
+	if n < 0 {
+		n = 1
+	}
+	if n > int64(len(e.List)) {
+		n = int64(len(e.List))
+	}
+	o := make([]interface{}, 0, n)
+	for i := int64(0); i < n; i++ {
+		if string(argv[0]) == "LPOP" {
+			o = append(o, e.List[0])
+			e.List = e.List[1:]
+		} else {
+			last := len(e.List) - 1
+			o = append(o, e.List[last])
+			e.List = e.List[:last]
+		}
+	}
+	k.removeIfEmpty(key, e)
+
+	if count < 0 {
+		return o[0], nil
+	}
+	return o, nil
+}
+
+func cmdLLen(k *Keyspace, argv [][]byte) (interface{}, error) {
+	e, err := k.lookupType(string(argv[1]), TypeList)
+	if err != nil || e == nil {
+		return int64(0), err
+	}
+	return int64(len(e.List)), nil
+}
+
+func cmdLRange(k *Keyspace, argv [][]byte) (interface{}, error) {
+	start, err := parseInt(argv[2])
+	if err != nil {
+		return nil, err
+	}
+	stop, err := parseInt(argv[3])
+	if err != nil {
+		return nil, err
+	}
+	o := []interface{}{}
+	e, err := k.lookupType(string(argv[1]), TypeList)
+	if err != nil || e == nil {
+		return o, err
+	}
+	s, t, ok := normRange(start, stop, len(e.List))
+	if !ok {
+		return o, nil
+	}
+	for _, v := range e.List[s : t+1] {
+		o = append(o, copyBytes(v))
+	}
+	return o, nil
+}
+
+func listIndex(e *entry, idx int64) (int, bool) {
+	if idx < 0 {
+		idx += int64(len(e.List))
+	}
+	if idx < 0 || idx >= int64(len(e.List)) {
+		return 0, false
+	}
+	return int(idx), true
+}
+
+func cmdLIndex(k *Keyspace, argv [][]byte) (interface{}, error) {
+	idx, err := parseInt(argv[2])
+	if err != nil {
+		return nil, err
+	}
+	e, err := k.lookupType(string(argv[1]), TypeList)
+	if err != nil || e == nil {
+		return nil, err
+	}
+	i, ok := listIndex(e, idx)
+	if !ok {
+		return nil, nil
+	}
+	return copyBytes(e.List[i]), nil
+}
+
+func cmdLSet(k *Keyspace, argv [][]byte) (interface{}, error) {
+	idx, err := parseInt(argv[2])
+	if err != nil {
+		return nil, err
+	}
+	e, err := k.lookupType(string(argv[1]), TypeList)
+	if err != nil {
+		return nil, err
+	}
+	if e == nil {
+		return nil, errNoKey
+	}
+	i, ok := listIndex(e, idx)
+	if !ok {
+		return nil, errIndex
+	}
+	e.List[i] = copyBytes(argv[3])
+	return statusOK, nil
+}
+
+func cmdLTrim(k *Keyspace, argv [][]byte) (interface{}, error) {
+	start, err := parseInt(argv[2])
+	if err != nil {
+		return nil, err
+	}
+	stop, err := parseInt(argv[3])
+	if err != nil {
+		return nil, err
+	}
+	key := string(argv[1])
+	e, err := k.lookupType(key, TypeList)
+	if err != nil || e == nil {
+		return statusOK, err
+	}
+	s, t, ok := normRange(start, stop, len(e.List))
+	if !ok {
+		e.List = nil
+	} else {
+		e.List = e.List[s : t+1]
+	}
+	k.removeIfEmpty(key, e)
+	return statusOK, nil
+}
+
+// -----------------------------------------------------------------------------
+// set
+
+func cmdSAdd(k *Keyspace, argv [][]byte) (interface{}, error) {
+	e, err := k.lookupOrCreate(string(argv[1]), TypeSet)
+	if err != nil {
+		return nil, err
+	}
+	cnt := int64(0)
+	for _, m := range argv[2:] {
+		if !e.Set[string(m)] {
+			e.Set[string(m)] = true
+			cnt++
+		}
+	}
+	return cnt, nil
+}
+
+func cmdSRem(k *Keyspace, argv [][]byte) (interface{}, error) {
+	key := string(argv[1])
+	e, err := k.lookupType(key, TypeSet)
+	if err != nil || e == nil {
+		return int64(0), err
+	}
+	cnt := int64(0)
+	for _, m := range argv[2:] {
+		if e.Set[string(m)] {
+			delete(e.Set, string(m))
+			cnt++
+		}
+	}
+	k.removeIfEmpty(key, e)
+	return cnt, nil
+}
+
+func cmdSMembers(k *Keyspace, argv [][]byte) (interface{}, error) {
+	o := []interface{}{}
+	e, err := k.lookupType(string(argv[1]), TypeSet)
+	if err != nil || e == nil {
+		return o, err
+	}
+	m := make([]string, 0, len(e.Set))
+	for x := range e.Set {
+		m = append(m, x)
+	}
+	sort.Strings(m)
+	for _, x := range m {
+		o = append(o, []byte(x))
+	}
+	return o, nil
+}
+
+func cmdSIsMember(k *Keyspace, argv [][]byte) (interface{}, error) {
+	e, err := k.lookupType(string(argv[1]), TypeSet)
+	if err != nil || e == nil {
+		return int64(0), err
+	}
+	return boolInt(e.Set[string(argv[2])]), nil
+}
+
+func cmdSCard(k *Keyspace, argv [][]byte) (interface{}, error) {
+	e, err := k.lookupType(string(argv[1]), TypeSet)
+	if err != nil || e == nil {
+		return int64(0), err
+	}
+	return int64(len(e.Set)), nil
+}
+
+// -----------------------------------------------------------------------------
+// sorted set, the members are sorted when it is ranged which is O(NlogN)
+
+type zmember struct {
+	member string
+	score  float64
+}
+
+func zsorted(e *entry) []zmember {
+	o := make([]zmember, 0, len(e.ZSet))
+	for m, s := range e.ZSet {
+		o = append(o, zmember{m, s})
+	}
+	sort.Slice(o, func(i, j int) bool {
+		if o[i].score != o[j].score {
+			return o[i].score < o[j].score
+		}
+		return o[i].member < o[j].member
+	})
+	return o
+}
+
+// ZADD key score member [score member ...]
+func cmdZAdd(k *Keyspace, argv [][]byte) (interface{}, error) {
+	if len(argv)%2 != 0 {
+		return nil, errSyntax
+	}
+	scores := make([]float64, 0, len(argv)/2)
+	for i := 2; i < len(argv); i += 2 {
+		s, err := parseFloat(argv[i])
+		if err != nil {
+			return nil, err
+		}
+		scores = append(scores, s)
+	}
+
+	e, err := k.lookupOrCreate(string(argv[1]), TypeSortedSet)
+	if err != nil {
+		return nil, err
+	}
+	cnt := int64(0)
+	for i := 2; i < len(argv); i += 2 {
+		m := string(argv[i+1])
+		if _, ok := e.ZSet[m]; !ok {
+			cnt++
+		}
+		e.ZSet[m] = scores[(i-2)/2]
+	}
+	return cnt, nil
+}
+
+func cmdZRem(k *Keyspace, argv [][]byte) (interface{}, error) {
+	key := string(argv[1])
+	e, err := k.lookupType(key, TypeSortedSet)
+	if err != nil || e == nil {
+		return int64(0), err
+	}
+	cnt := int64(0)
+	for _, m := range argv[2:] {
+		if _, ok := e.ZSet[string(m)]; ok {
+			delete(e.ZSet, string(m))
+			cnt++
+		}
+	}
+	k.removeIfEmpty(key, e)
+	return cnt, nil
+}
+
+func cmdZScore(k *Keyspace, argv [][]byte) (interface{}, error) {
+	e, err := k.lookupType(string(argv[1]), TypeSortedSet)
+	if err != nil || e == nil {
+		return nil, err
+	}
+	s, ok := e.ZSet[string(argv[2])]
+	if !ok {
+		return nil, nil
+	}
+	return formatFloat(s), nil
+}
+
+func cmdZCard(k *Keyspace, argv [][]byte) (interface{}, error) {
+	e, err := k.lookupType(string(argv[1]), TypeSortedSet)
+	if err != nil || e == nil {
+		return int64(0), err
+	}
+	return int64(len(e.ZSet)), nil
+}
+
+func cmdZIncrBy(k *Keyspace, argv [][]byte) (interface{}, error) {
+	delta, err := parseFloat(argv[2])
+	if err != nil {
+		return nil, err
+	}
+	e, err := k.lookupOrCreate(string(argv[1]), TypeSortedSet)
+	if err != nil {
+		return nil, err
+	}
+	m := string(argv[3])
+	s := e.ZSet[m] + delta
+	if math.IsNaN(s) {
+		return nil, fmt.Errorf("ERR resulting score is not a number (NaN)")
+	}
+	e.ZSet[m] = s
+	return formatFloat(s), nil
+}
+
+func cmdZRank(k *Keyspace, argv [][]byte) (interface{}, error) {
+	e, err := k.lookupType(string(argv[1]), TypeSortedSet)
+	if err != nil || e == nil {
+		return nil, err
+	}
+	m := string(argv[2])
+	if _, ok := e.ZSet[m]; !ok {
+		return nil, nil
+	}
+	l := zsorted(e)
+	for i, x := range l {
+		if x.member == m {
+			if string(argv[0]) == "ZREVRANK" {
+				return int64(len(l) - 1 - i), nil
+			}
+			return int64(i), nil
+		}
+	}
+	return nil, nil
+}
+
+// ZRANGE/ZREVRANGE key start stop [WITHSCORES]
+func cmdZRange(k *Keyspace, argv [][]byte) (interface{}, error) {
+	withScores := false
+	if len(argv) == 5 {
+		if strings.ToUpper(string(argv[4])) != "WITHSCORES" {
+			return nil, errSyntax
+		}
+		withScores = true
+	} else if len(argv) > 5 {
+		return nil, errSyntax
+	}
+
+	start, err := parseInt(argv[2])
+	if err != nil {
+		return nil, err
+	}
+	stop, err := parseInt(argv[3])
+	if err != nil {
+		return nil, err
+	}
+
+	o := []interface{}{}
+	e, err := k.lookupType(string(argv[1]), TypeSortedSet)
+	if err != nil || e == nil {
+		return o, err
+	}
+	l := zsorted(e)
+	if string(argv[0]) == "ZREVRANGE" {
+		for i, j := 0, len(l)-1; i < j; i, j = i+1, j-1 {
+			l[i], l[j] = l[j], l[i]
+		}
+	}
+	s, t, ok := normRange(start, stop, len(l))
+	if !ok {
+		return o, nil
+	}
+	for _, x := range l[s : t+1] {
+		o = append(o, []byte(x.member))
+		if withScores {
+			o = append(o, formatFloat(x.score))
+		}
+	}
+	return o, nil
+}
@@ -0,0 +1,279 @@
+package kv
+
+import (
+	"sync"
+	"time"
+)
+
+// Keyspace is a concurrent in-memory store which implements the common redis
+// data types, ie string, hash, list, set and sorted set, with TTL expiry. All
+// the operations are serialized by a single lock, which is fine for the
+// gateway/mock usage. Expired key is removed lazily when it is accessed and
+// also by a background sweeper.
+
+const (
+	TypeNone = iota
+	TypeString
+	TypeHash
+	TypeList
+	TypeSet
+	TypeSortedSet
+)
+
+const (
+	sweepInterval = 100 * time.Millisecond
+	sweepSample   = 64
+)
+
+func TypeName(t int) string {
+	switch t {
+	case TypeString:
+		return "string"
+	case TypeHash:
+		return "hash"
+	case TypeList:
+		return "list"
+	case TypeSet:
+		return "set"
+	case TypeSortedSet:
+		return "zset"
+	default:
+		return "none"
+	}
+}
+
+// fields are exported for snapshot encoding
+type entry struct {
+	Type int
+	Str  []byte
+	Hash map[string][]byte
+	List [][]byte
+	Set  map[string]bool
+	ZSet map[string]float64
+
+	// unix time in millisecond, 0 means never expire
+	ExpireAt int64
+}
+
+type Keyspace struct {
+	sync.Mutex
+	data map[string]*entry
+
+	// keys which have TTL, used by the sweeper
+	volatile map[string]bool
+
+	snapshot snapshotConfig
+
+	// stops the sweeper, nil once the keyspace is closed
+	stop chan struct{}
+}
+
+func nowMs() int64 {
+	return time.Now().UnixNano() / int64(time.Millisecond)
+}
+
+func New() *Keyspace {
+	k := &Keyspace{
+		data:     make(map[string]*entry),
+		volatile: make(map[string]bool),
+		stop:     make(chan struct{}),
+	}
+	go k.sweeper(k.stop)
+	return k
+}
+
+var (
+	registry   = make(map[string]*Keyspace)
+	registryMu sync.Mutex
+)
+
+// Open the keyspace of the name, the same keyspace is returned for the same
+// name, ie the data survives the vhost reload. The closed keyspace is reopened
+func Open(name string) *Keyspace {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if k, ok := registry[name]; ok {
+		k.reopen()
+		return k
+	}
+	k := New()
+	registry[name] = k
+	return k
+}
+
+// Stop the background routines, the keyspace is still usable
+func (k *Keyspace) Close() {
+	k.Lock()
+	defer k.Unlock()
+	if k.stop != nil {
+		close(k.stop)
+		k.stop = nil
+	}
+	k.stopSnapshot()
+}
+
+// restart the sweeper of the closed keyspace, the snapshot is restarted by
+// SetSnapshot
+func (k *Keyspace) reopen() {
+	k.Lock()
+	defer k.Unlock()
+	if k.stop == nil {
+		k.stop = make(chan struct{})
+		go k.sweeper(k.stop)
+	}
+}
+
+func (k *Keyspace) closed() bool {
+	k.Lock()
+	defer k.Unlock()
+	return k.stop == nil
+}
+
+func (k *Keyspace) sweeper(stop chan struct{}) {
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			k.sweep(sweepSample)
+		}
+	}
+}
+
+// remove expired keys, at most n keys are examined
+func (k *Keyspace) sweep(n int) int {
+	k.Lock()
+	defer k.Unlock()
+
+	now := nowMs()
+	cnt := 0
+	for key := range k.volatile {
+		if n == 0 {
+			break
+		}
+		n--
+		if e, ok := k.data[key]; ok && e.ExpireAt > 0 && e.ExpireAt <= now {
+			k.remove(key)
+			cnt++
+		}
+	}
+	return cnt
+}
+
+// the following functions must be called with lock held
+
+func (k *Keyspace) lookup(key string) *entry {
+	e, ok := k.data[key]
+	if !ok {
+		return nil
+	}
+	if e.ExpireAt > 0 && e.ExpireAt <= nowMs() {
+		k.remove(key)
+		return nil
+	}
+	return e
+}
+
+// lookup the key with the expected type, nil if not existed
+func (k *Keyspace) lookupType(key string, t int) (*entry, error) {
+	e := k.lookup(key)
+	if e == nil {
+		return nil, nil
+	}
+	if e.Type != t {
+		return nil, errWrongType
+	}
+	return e, nil
+}
+
+// lookup the key with the expected type, a new empty one is created if not
+// existed
+func (k *Keyspace) lookupOrCreate(key string, t int) (*entry, error) {
+	e, err := k.lookupType(key, t)
+	if err != nil {
+		return nil, err
+	}
+	if e != nil {
+		return e, nil
+	}
+
+	e = &entry{
+		Type: t,
+	}
+	switch t {
+	case TypeHash:
+		e.Hash = make(map[string][]byte)
+	case TypeSet:
+		e.Set = make(map[string]bool)
+	case TypeSortedSet:
+		e.ZSet = make(map[string]float64)
+	default:
+		break
+	}
+	k.data[key] = e
+	return e, nil
+}
+
+func (k *Keyspace) remove(key string) bool {
+	if _, ok := k.data[key]; !ok {
+		return false
+	}
+	delete(k.data, key)
+	delete(k.volatile, key)
+	return true
+}
+
+// container becomes empty is removed as redis does
+func (k *Keyspace) removeIfEmpty(key string, e *entry) {
+	empty := false
+	switch e.Type {
+	case TypeHash:
+		empty = len(e.Hash) == 0
+	case TypeList:
+		empty = len(e.List) == 0
+	case TypeSet:
+		empty = len(e.Set) == 0
+	case TypeSortedSet:
+		empty = len(e.ZSet) == 0
+	default:
+		break
+	}
+	if empty {
+		k.remove(key)
+	}
+}
+
+func (k *Keyspace) setExpire(key string, e *entry, at int64) {
+	e.ExpireAt = at
+	if at > 0 {
+		k.volatile[key] = true
+	} else {
+		delete(k.volatile, key)
+	}
+}
+
+func (k *Keyspace) setString(key string, v []byte) *entry {
+	e := &entry{
+		Type: TypeString,
+		Str:  v,
+	}
+	delete(k.volatile, key)
+	k.data[key] = e
+	return e
+}
+
+// Number of keys, including the expired one not been removed yet
+func (k *Keyspace) Size() int {
+	k.Lock()
+	defer k.Unlock()
+	return len(k.data)
+}
+
+func (k *Keyspace) Flush() {
+	k.Lock()
+	defer k.Unlock()
+	k.data = make(map[string]*entry)
+	k.volatile = make(map[string]bool)
+}
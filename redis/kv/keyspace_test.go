@@ -0,0 +1,165 @@
+package kv
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testExec(k *Keyspace, cmd string) (interface{}, error) {
+	argv := [][]byte{}
+	for _, x := range strings.Fields(cmd) {
+		argv = append(argv, []byte(x))
+	}
+	return k.Exec(argv)
+}
+
+// flatten the reply into string for comparison
+func testReply(v interface{}, err error) string {
+	if err != nil {
+		return "error: " + err.Error()
+	}
+	switch x := v.(type) {
+	case nil:
+		return "(nil)"
+	case Status:
+		return string(x)
+	case []byte:
+		return string(x)
+	case int64:
+		return "(" + strconv.FormatInt(x, 10) + ")"
+	case []interface{}:
+		o := []string{}
+		for _, e := range x {
+			o = append(o, testReply(e, nil))
+		}
+		return "[" + strings.Join(o, " ") + "]"
+	default:
+		return "?"
+	}
+}
+
+func TestKeyspaceCommand(t *testing.T) {
+	assert := assert.New(t)
+	k := New()
+	defer k.Close()
+
+	for _, c := range []struct {
+		cmd    string
+		expect string
+	}{
+		{"ping", "PONG"},
+		{"get a", "(nil)"},
+		{"set a 1", "OK"},
+		{"set a 2 nx", "(nil)"},
+		{"set a 2 xx get", "1"},
+		{"incrby a 10", "(12)"},
+		{"decr a", "(11)"},
+		{"append a x", "(3)"},
+		{"incr a", "error: ERR value is not an integer or out of range"},
+		{"mset b 1 c 2", "OK"},
+		{"mget a b c d", "[11x 1 2 (nil)]"},
+		{"hset h f1 v1 f2 v2", "(2)"},
+		{"hget h f1", "v1"},
+		{"hgetall h", "[f1 v1 f2 v2]"},
+		{"hincrby h n 5", "(5)"},
+		{"hdel h f1 f2 n", "(3)"},
+		{"exists h", "(0)"},
+		{"get b", "1"},
+		{"hget b f", "error: WRONGTYPE Operation against a key holding the wrong kind of value"},
+		{"rpush l 1 2 3", "(3)"},
+		{"lpush l 0", "(4)"},
+		{"lrange l 0 -1", "[0 1 2 3]"},
+		{"lpop l", "0"},
+		{"rpop l 2", "[3 2]"},
+		{"lindex l -1", "1"},
+		{"sadd s b a b", "(2)"},
+		{"smembers s", "[a b]"},
+		{"sismember s c", "(0)"},
+		{"zadd z 2 b 1 a 3 c", "(3)"},
+		{"zrange z 0 -1 withscores", "[a 1 b 2 c 3]"},
+		{"zrevrange z 0 1", "[c b]"},
+		{"zincrby z 10 a", "11"},
+		{"zrank z a", "(2)"},
+		{"type z", "zset"},
+		{"keys ?", "[a b c l s z]"},
+		{"keys [ab]", "[a b]"},
+		{"del a b c", "(3)"},
+		{"rename l l2", "OK"},
+		{"dbsize", "(3)"},
+		{"get", "error: ERR wrong number of arguments for 'get' command"},
+		{"nope", "error: ERR unknown command 'nope'"},
+	} {
+		assert.Equal(c.expect, testReply(testExec(k, c.cmd)), c.cmd)
+	}
+}
+
+func TestKeyspaceExpire(t *testing.T) {
+	assert := assert.New(t)
+	k := New()
+	defer k.Close()
+
+	assert.Equal("OK", testReply(testExec(k, "set a 1 px 20")))
+	assert.Equal("OK", testReply(testExec(k, "set b 1")))
+	assert.Equal("(1)", testReply(testExec(k, "pexpire b 20")))
+	assert.Equal("OK", testReply(testExec(k, "set c 1")))
+	assert.Equal("(-1)", testReply(testExec(k, "ttl c")))
+	assert.Equal("(-2)", testReply(testExec(k, "ttl d")))
+
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal("(nil)", testReply(testExec(k, "get a")))
+
+	// removed by the sweeper without being accessed
+	time.Sleep(2 * sweepInterval)
+	assert.Equal(1, k.Size())
+}
+
+func TestKeyspaceSnapshot(t *testing.T) {
+	assert := assert.New(t)
+	file := filepath.Join(t.TempDir(), "dump.kv")
+
+	k := New()
+	defer k.Close()
+	assert.Equal("error: ERR snapshot is not configured", testReply(testExec(k, "save")))
+	assert.True(k.SetSnapshot(file, 0, true) == nil)
+
+	testExec(k, "set a 1")
+	testExec(k, "set gone 1 px 1")
+	testExec(k, "hset h f v")
+	testExec(k, "zadd z 1.5 m")
+	testExec(k, "set ttl 1 ex 100")
+	time.Sleep(5 * time.Millisecond)
+	assert.Equal("OK", testReply(testExec(k, "save")))
+
+	x := New()
+	defer x.Close()
+	assert.True(x.SetSnapshot(file, 0, true) == nil)
+	assert.Equal(4, x.Size())
+	assert.Equal("1", testReply(testExec(x, "get a")))
+	assert.Equal("v", testReply(testExec(x, "hget h f")))
+	assert.Equal("1.5", testReply(testExec(x, "zscore z m")))
+	assert.Equal("(100)", testReply(testExec(x, "ttl ttl")))
+	assert.Equal("(nil)", testReply(testExec(x, "get gone")))
+}
+
+func TestKeyspaceOpen(t *testing.T) {
+	assert := assert.New(t)
+
+	k := Open("test_open")
+	testExec(k, "set a 1")
+	assert.True(k == Open("test_open"))
+
+	// reload closes the keyspace, opening it again restarts the sweeper and
+	// keeps the data
+	k.Close()
+	assert.True(k.closed())
+	x := Open("test_open")
+	defer x.Close()
+	assert.True(k == x)
+	assert.False(x.closed())
+	assert.Equal("1", testReply(testExec(x, "get a")))
+}
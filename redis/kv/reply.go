@@ -0,0 +1,65 @@
+package kv
+
+import (
+	"fmt"
+
+	"github.com/tidwall/redcon"
+)
+
+// Write the reply of Exec back to the connection in RESP
+func WriteReply(conn redcon.Conn, v interface{}, err error) {
+	if err != nil {
+		conn.WriteError(err.Error())
+		return
+	}
+	writeValue(conn, v)
+}
+
+// Append the reply of Exec in RESP
+func AppendReply(b []byte, v interface{}, err error) []byte {
+	if err != nil {
+		return redcon.AppendError(b, err.Error())
+	}
+	return appendValue(b, v)
+}
+
+func appendValue(b []byte, v interface{}) []byte {
+	switch x := v.(type) {
+	case nil:
+		return redcon.AppendNull(b)
+	case Status:
+		return redcon.AppendString(b, string(x))
+	case []byte:
+		return redcon.AppendBulk(b, x)
+	case int64:
+		return redcon.AppendInt(b, x)
+	case []interface{}:
+		b = redcon.AppendArray(b, len(x))
+		for _, e := range x {
+			b = appendValue(b, e)
+		}
+		return b
+	default:
+		return redcon.AppendError(b, fmt.Sprintf("ERR unknown reply type %T", v))
+	}
+}
+
+func writeValue(conn redcon.Conn, v interface{}) {
+	switch x := v.(type) {
+	case nil:
+		conn.WriteNull()
+	case Status:
+		conn.WriteString(string(x))
+	case []byte:
+		conn.WriteBulk(x)
+	case int64:
+		conn.WriteInt64(x)
+	case []interface{}:
+		conn.WriteArray(len(x))
+		for _, e := range x {
+			writeValue(conn, e)
+		}
+	default:
+		conn.WriteError(fmt.Sprintf("ERR unknown reply type %T", v))
+	}
+}
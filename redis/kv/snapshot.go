@@ -0,0 +1,174 @@
+package kv
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Snapshot is a point in time dump of the keyspace into a local file, which is
+// similar to redis RDB. The file starts with a magic header followed by the gob
+// encoded entries. The file is written into a temporary file and renamed, so a
+// crash during saving never corrupts the previous snapshot.
+
+const snapshotMagic = "MONOKV01"
+
+type snapshotConfig struct {
+	file     string
+	interval time.Duration
+	stop     chan struct{}
+}
+
+type snapshotData struct {
+	Data map[string]*entry
+}
+
+// encode the keyspace with lock held, expired entries are skipped
+func (k *Keyspace) encode(w io.Writer) error {
+	now := nowMs()
+	d := snapshotData{
+		Data: make(map[string]*entry, len(k.data)),
+	}
+	for key, e := range k.data {
+		if e.ExpireAt > 0 && e.ExpireAt <= now {
+			continue
+		}
+		d.Data[key] = e
+	}
+	if _, err := io.WriteString(w, snapshotMagic); err != nil {
+		return err
+	}
+	return gob.NewEncoder(w).Encode(&d)
+}
+
+// Save the keyspace into the file
+func (k *Keyspace) Save(file string) error {
+	var buf bytes.Buffer
+
+	k.Lock()
+	err := k.encode(&buf)
+	k.Unlock()
+	if err != nil {
+		return fmt.Errorf("snapshot encode: %s", err.Error())
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(file), filepath.Base(file)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+// Load the snapshot file and replace all the data inside of the keyspace
+func (k *Keyspace) Load(file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	magic := make([]byte, len(snapshotMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != snapshotMagic {
+		return fmt.Errorf("snapshot %s: invalid file header", file)
+	}
+
+	d := snapshotData{}
+	if err := gob.NewDecoder(r).Decode(&d); err != nil {
+		return fmt.Errorf("snapshot %s: %s", file, err.Error())
+	}
+
+	k.Lock()
+	defer k.Unlock()
+
+	k.data = make(map[string]*entry, len(d.Data))
+	k.volatile = make(map[string]bool)
+	now := nowMs()
+	for key, e := range d.Data {
+		if e.ExpireAt > 0 && e.ExpireAt <= now {
+			continue
+		}
+		k.data[key] = e
+		if e.ExpireAt > 0 {
+			k.volatile[key] = true
+		}
+	}
+	return nil
+}
+
+// Configure the snapshot file. The existed snapshot file is loaded if
+// load is true. If interval is not zero, the keyspace is saved periodically.
+// Empty file disables the snapshot
+func (k *Keyspace) SetSnapshot(file string, interval time.Duration, load bool) error {
+	if file != "" && load {
+		if err := k.Load(file); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	k.Lock()
+	defer k.Unlock()
+
+	k.stopSnapshot()
+	k.snapshot = snapshotConfig{
+		file:     file,
+		interval: interval,
+	}
+	if file != "" && interval > 0 {
+		k.snapshot.stop = make(chan struct{})
+		go k.snapshotLoop(file, interval, k.snapshot.stop)
+	}
+	return nil
+}
+
+// with lock held
+func (k *Keyspace) stopSnapshot() {
+	if k.snapshot.stop != nil {
+		close(k.snapshot.stop)
+		k.snapshot.stop = nil
+	}
+}
+
+func (k *Keyspace) snapshotLoop(file string, interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			// error is retried in next round
+			k.Save(file)
+		}
+	}
+}
+
+// Save the keyspace into the configured snapshot file, ie the SAVE command
+func (k *Keyspace) Snapshot() error {
+	k.Lock()
+	file := k.snapshot.file
+	k.Unlock()
+
+	if file == "" {
+		return fmt.Errorf("snapshot is not configured")
+	}
+	return k.Save(file)
+}
@@ -7,8 +7,6 @@ import (
 
 	"crypto/tls"
 	"strings"
-	"sync/atomic"
-	"unsafe"
 
 	"github.com/dianpeng/mono-service/redis/vhost"
 	"github.com/dianpeng/mono-service/server"
@@ -38,7 +36,7 @@ type listener struct {
 	name       string
 	server     redconServer
 	clientPool *util.HClientPool
-	vhost      *server.VHost
+	vlist      vhostlist
 }
 
 type fac struct{}
@@ -160,34 +158,64 @@ func (l *listener) onEvent(
 	conn redcon.Conn,
 	cmd redcon.Command,
 ) {
-	vhs := l.vhs()
-	if vhs != nil {
-		(*vhs).OnEvent(conn, cmd)
-	} else {
+	if l.vlist.empty() {
 		conn.WriteError("redis_vhost is not setup")
 		conn.Close()
+		return
+	}
+
+	// AUTH routes the connection to the vhost declaring the user, the HELLO
+	// without credential is dispatched to the vhost afterwards
+	cred, rest, err := vhost.ParseAuth(cmd.Args)
+	if err != nil {
+		conn.WriteError(err.Error())
+		return
+	}
+	if cred != nil {
+		vhs, u := l.vlist.login(cred)
+		if vhs == nil {
+			vhost.AuthFailed(conn, l.vlist.hasUser())
+			return
+		}
+		if !vhs.Login(conn, u) {
+			conn.Close()
+			return
+		}
+		if rest == nil {
+			conn.WriteString("OK")
+			return
+		}
+		cmd.Args = rest
+	}
+
+	if vhs := vhost.Bound(conn); vhs != nil {
+		vhs.OnEvent(conn, cmd)
+	} else {
+		vhost.NoAuth(conn, cmd.Args)
 	}
 }
 
+// the connection is bound to the public vhost if there is one, otherwise it
+// waits for AUTH
 func (l *listener) onAccept(
 	conn redcon.Conn,
 ) bool {
-	vhs := l.vhs()
-	if vhs != nil {
-		return (*vhs).OnAccept(conn)
-	} else {
+	if l.vlist.empty() {
 		conn.WriteError("redis_vhost is not setup")
 		return false
 	}
+	if vhs := l.vlist.getPublic(); vhs != nil {
+		return vhs.OnAccept(conn)
+	}
+	return true
 }
 
 func (l *listener) onClose(
 	conn redcon.Conn,
 	err error,
 ) {
-	vhs := l.vhs()
-	if vhs != nil {
-		(*vhs).OnClose(conn, err)
+	if vhs := vhost.Bound(conn); vhs != nil {
+		vhs.OnClose(conn, err)
 	}
 }
 
@@ -195,7 +223,8 @@ func newListener(c *listenerConfig) (*listener, error) {
 	var s redconServer
 
 	l := &listener{
-		name: c.Name,
+		name:  c.Name,
+		vlist: newvhostlist(),
 	}
 
 	if c.TLSKey != "" && c.TLSCertificate != "" {
@@ -243,62 +272,23 @@ func (l *listener) Type() string {
 	return "redis"
 }
 
-func (l *listener) vhs() *vhost.VHost {
-	vptr := l.vhostPtr()
-	if vptr == nil {
-		return nil
-	} else {
-		return (*vptr).(*vhost.VHost)
-	}
-}
-
-func (l *listener) vhostPtr() *server.VHost {
-	x := (*server.VHost)(atomic.LoadPointer(
-		(*unsafe.Pointer)(unsafe.Pointer(&l.vhost)),
-	))
-	return x
-}
-
+// the following functions are thread safe, the vhost can be added, updated and
+// removed while the listener is running. The connection already bound to a
+// vhost stays with it until it is closed or authenticated again
 func (l *listener) AddVHost(x server.VHost) error {
-	y := l.vhs()
-	if y != nil && y.Name() == x.Name() {
-		return fmt.Errorf("vhost has already been added")
-	}
-
-	atomic.StorePointer(
-		(*unsafe.Pointer)(unsafe.Pointer(&l.vhost)),
-		unsafe.Pointer(&x),
-	)
-	return nil
+	return l.vlist.add(x.(*vhost.VHost))
 }
 
 func (l *listener) UpdateVHost(x server.VHost) {
-	atomic.StorePointer(
-		(*unsafe.Pointer)(unsafe.Pointer(&l.vhost)),
-		unsafe.Pointer(&x),
-	)
+	l.vlist.update(x.(*vhost.VHost))
 }
 
 func (l *listener) RemoveVHost(n string) {
-	for {
-		x := l.vhostPtr()
-		if (*x).Name() == n {
-			if atomic.CompareAndSwapPointer(
-				(*unsafe.Pointer)(unsafe.Pointer(&l.vhost)),
-				unsafe.Pointer(x),
-				nil,
-			) {
-				break
-			}
-		} else {
-			break
-		}
-	}
+	l.vlist.remove(n)
 }
 
 func (l *listener) GetVHost(name string) server.VHost {
-	x := l.vhs()
-	if x.Name() == name {
+	if x := l.vlist.get(name); x != nil {
 		return x
 	}
 	return nil
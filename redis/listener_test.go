@@ -0,0 +1,236 @@
+package redis
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/dianpeng/mono-service/manifest"
+	"github.com/dianpeng/mono-service/redis/vhost"
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/redcon"
+)
+
+// vhost replies WHOAMI with <vhost>:<user>:<protocol>
+func testVHost(t *testing.T, name string, user string) *vhost.VHost {
+	src := `
+config redis_vhost {
+  .name = "` + name + `";
+  .listener = "test";
+  .keyspace = true;
+` + user + `
+}
+rule "redis.WHOAMI" {
+  conn:writeString("` + name + `:" + conn.user:to_string() + ":" + conn.protocol:to_string());
+}
+`
+	v, err := vhost.CreateVHost(&manifest.Manifest{
+		Main: "main.pl",
+		FS: fstest.MapFS{
+			"main.pl": &fstest.MapFile{
+				Data: []byte(src),
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+type testClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func testServe(t *testing.T, l *listener) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go redcon.Serve(ln, l.onEvent, l.onAccept, l.onClose)
+	return ln.Addr().String()
+}
+
+func dial(t *testing.T, addr string) *testClient {
+	c, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testClient{
+		conn: c,
+		r:    bufio.NewReader(c),
+	}
+}
+
+// one reply with CRLF replaced by space, map reply is only checked by its head
+func (c *testClient) do(cmd string) string {
+	args := strings.Fields(cmd)
+	b := redcon.AppendArray(nil, len(args))
+	for _, a := range args {
+		b = redcon.AppendBulkString(b, a)
+	}
+	c.conn.Write(b)
+
+	c.conn.SetReadDeadline(time.Now().Add(time.Second))
+	var o []string
+	if err := c.readReply(&o); err != nil {
+		return "error: " + err.Error()
+	}
+	return strings.Join(o, " ")
+}
+
+func (c *testClient) readReply(o *[]string) error {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	*o = append(*o, line)
+
+	switch line[0] {
+	case '$':
+		n, _ := strconv.Atoi(line[1:])
+		if n < 0 {
+			return nil
+		}
+		b := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, b); err != nil {
+			return err
+		}
+		*o = append(*o, string(b[:n]))
+	case '*', '%':
+		n, _ := strconv.Atoi(line[1:])
+		if line[0] == '%' {
+			n *= 2
+		}
+		var skip []string
+		for i := 0; i < n; i++ {
+			if err := c.readReply(&skip); err != nil {
+				return err
+			}
+		}
+		if line[0] == '*' {
+			*o = append(*o, skip...)
+		}
+	}
+	return nil
+}
+
+func TestVHostList(t *testing.T) {
+	assert := assert.New(t)
+
+	pub := testVHost(t, "pub", "")
+	tena := testVHost(t, "tena", `.user("alice", "s1", "string", "app:*"); .user("bob", "s2");`)
+	tenb := testVHost(t, "tenb", `.user("carol", "s3");`)
+
+	l := newvhostlist()
+	for _, c := range []struct {
+		vhost *vhost.VHost
+		err   string
+	}{
+		{pub, ""},
+		{tena, ""},
+		{tenb, ""},
+		{testVHost(t, "tena", ""), "vhost name tena already existed"},
+		{testVHost(t, "pub2", ""), "vhost pub without user already existed"},
+		{testVHost(t, "dup", `.user("bob", "x");`), "user bob already existed in vhost tena"},
+	} {
+		err := l.add(c.vhost)
+		if c.err == "" {
+			assert.True(err == nil, "%s", err)
+		} else if assert.True(err != nil, c.err) {
+			assert.Equal(c.err, err.Error())
+		}
+	}
+
+	for _, c := range []struct {
+		cred  vhost.Credential
+		vhost *vhost.VHost
+		user  string
+	}{
+		{vhost.Credential{User: "alice", Password: "s1"}, tena, "alice"},
+		{vhost.Credential{User: "bob", Password: "s2"}, tena, "bob"},
+		{vhost.Credential{User: "carol", Password: "s3"}, tenb, "carol"},
+		{vhost.Credential{User: "alice", Password: "s3"}, nil, ""},
+		{vhost.Credential{User: "nobody", Password: "s1"}, nil, ""},
+	} {
+		v, u := l.login(&c.cred)
+		assert.True(v == c.vhost, c.cred.User)
+		if c.user == "" {
+			assert.True(u == nil, c.cred.User)
+		} else {
+			assert.Equal(c.user, u.Name)
+		}
+	}
+
+	assert.True(l.getPublic() == pub)
+	assert.True(l.hasUser())
+
+	// removed vhost takes its users with it
+	assert.True(l.remove("tena"))
+	assert.False(l.remove("tena"))
+	v, _ := l.login(&vhost.Credential{User: "alice", Password: "s1"})
+	assert.True(v == nil)
+	assert.True(l.remove("tenb"))
+	assert.False(l.hasUser())
+}
+
+func TestListenerRouting(t *testing.T) {
+	assert := assert.New(t)
+
+	l := &listener{vlist: newvhostlist()}
+	assert.True(l.AddVHost(testVHost(t, "pub", "")) == nil)
+	assert.True(l.AddVHost(testVHost(t, "tena", `.user("alice", "s1", ["string", "unknown"], "app:*"); .user("bob", "s2");`)) == nil)
+	assert.True(l.AddVHost(testVHost(t, "tenb", `.user("carol", "s3", "unknown");`)) == nil)
+	addr := testServe(t, l)
+
+	c := dial(t, addr)
+	for _, x := range []struct {
+		cmd    string
+		expect string
+	}{
+		{"WHOAMI", "+pub:null:2"},
+		{"AUTH alice bad", "-WRONGPASS invalid username-password pair or user is disabled."},
+		{"AUTH nobody s1", "-WRONGPASS invalid username-password pair or user is disabled."},
+		{"WHOAMI", "+pub:null:2"},
+		{"AUTH alice s1", "+OK"},
+		{"WHOAMI", "+tena:alice:2"},
+		{"SET app:1 v", "+OK"},
+		{"SET other v", "-NOPERM No permissions to access a key"},
+		{"GET app:2", "$-1"},
+		{"HSET app:h f v", "-NOPERM User alice has no permissions to run the 'hset' command"},
+
+		// protocol is kept while switching the vhost
+		{"HELLO 3 AUTH carol s3", "%7"},
+		{"WHOAMI", "+tenb:carol:3"},
+		{"GET app:1", "-NOPERM User carol has no permissions to run the 'get' command"},
+		{"AUTH bob s2", "+OK"},
+		{"WHOAMI", "+tena:bob:3"},
+		{"GET app:1", "$1 v"},
+	} {
+		assert.Equal(x.expect, c.do(x.cmd), x.cmd)
+	}
+
+	// without public vhost, the connection is pending for AUTH
+	l.RemoveVHost("pub")
+	c = dial(t, addr)
+	for _, x := range []struct {
+		cmd    string
+		expect string
+	}{
+		{"WHOAMI", "-NOAUTH Authentication required."},
+		{"HELLO 3", "-NOAUTH HELLO must be called with the client already authenticated, otherwise the HELLO <proto> AUTH <user> <pass> option can be used to authenticate the client and select the RESP protocol version at the same time"},
+		{"AUTH", "-ERR wrong number of arguments for 'auth' command"},
+		{"AUTH carol bad", "-WRONGPASS invalid username-password pair or user is disabled."},
+		{"AUTH carol s3", "+OK"},
+		{"WHOAMI", "+tenb:carol:2"},
+	} {
+		assert.Equal(x.expect, c.do(x.cmd), x.cmd)
+	}
+}
@@ -0,0 +1,354 @@
+package proxy
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dianpeng/mono-service/redis/kv"
+	"github.com/tidwall/redcon"
+)
+
+// Backend executes a batch of commands and returns the raw RESP reply of each
+// command in order. Error is only returned when the backend is not reachable,
+// the redis error is part of the reply
+type Backend interface {
+	Do([][][]byte) ([][]byte, error)
+	Name() string
+	Close()
+}
+
+const (
+	localScheme = "kv://"
+)
+
+// backend address is either host:port of a redis server, or kv://name which
+// is the built-in keyspace of the name, see kv.Open
+func newBackend(addr string, poolSize int, timeout time.Duration) Backend {
+	if strings.HasPrefix(addr, localScheme) {
+		return &localBackend{
+			name: addr,
+			ks:   kv.Open(addr[len(localScheme):]),
+		}
+	}
+	if poolSize <= 0 {
+		poolSize = 1
+	}
+	return &tcpBackend{
+		addr:    addr,
+		timeout: timeout,
+		pool:    make([]*pipeConn, poolSize),
+	}
+}
+
+// -----------------------------------------------------------------------------
+// local keyspace
+
+type localBackend struct {
+	name string
+	ks   *kv.Keyspace
+}
+
+func (l *localBackend) Do(cmds [][][]byte) ([][]byte, error) {
+	o := make([][]byte, 0, len(cmds))
+	for _, cmd := range cmds {
+		v, err := l.ks.Exec(cmd)
+		o = append(o, kv.AppendReply(nil, v, err))
+	}
+	return o, nil
+}
+
+func (l *localBackend) Name() string {
+	return l.name
+}
+
+func (l *localBackend) Close() {
+}
+
+// -----------------------------------------------------------------------------
+// redis server, a fixed size pool of connections is used in round robin. Each
+// connection is pipelined, ie the commands of different clients are written
+// without waiting for the previous reply, and the replies are dispatched back
+// in the order of the commands. Broken connection is redialed lazily.
+
+type tcpBackend struct {
+	addr    string
+	timeout time.Duration
+	next    uint32
+
+	sync.Mutex
+	pool []*pipeConn
+}
+
+func (t *tcpBackend) Name() string {
+	return t.addr
+}
+
+func (t *tcpBackend) conn() (*pipeConn, error) {
+	idx := int(atomic.AddUint32(&t.next, 1)) % len(t.pool)
+
+	t.Lock()
+	defer t.Unlock()
+	if c := t.pool[idx]; c != nil && !c.isBroken() {
+		return c, nil
+	}
+	c, err := dialPipeConn(t.addr, t.timeout)
+	if err != nil {
+		return nil, err
+	}
+	t.pool[idx] = c
+	return c, nil
+}
+
+func (t *tcpBackend) Do(cmds [][][]byte) ([][]byte, error) {
+	c, err := t.conn()
+	if err != nil {
+		return nil, fmt.Errorf("backend %s: %s", t.addr, err.Error())
+	}
+	o, err := c.do(cmds)
+	if err != nil {
+		return nil, fmt.Errorf("backend %s: %s", t.addr, err.Error())
+	}
+	return o, nil
+}
+
+func (t *tcpBackend) Close() {
+	t.Lock()
+	defer t.Unlock()
+	for i, c := range t.pool {
+		if c != nil {
+			c.fail(fmt.Errorf("closed"))
+			t.pool[i] = nil
+		}
+	}
+}
+
+type pipeRequest struct {
+	reply []byte
+	err   error
+	done  chan struct{}
+
+	// set once the request is completed, guarded by the lock of pipeConn. The
+	// timed out request is completed with error before its reply arrives, and
+	// the reply is dropped by the reader
+	finished bool
+}
+
+var errTimeout = fmt.Errorf("timeout")
+
+type pipeConn struct {
+	conn    net.Conn
+	timeout time.Duration
+
+	// guards writer, pending and err. Request is queued before its bytes are
+	// written, so the reader always finds the request of the reply
+	sync.Mutex
+	w       *bufio.Writer
+	pending []*pipeRequest
+	err     error
+}
+
+func dialPipeConn(addr string, timeout time.Duration) (*pipeConn, error) {
+	var conn net.Conn
+	var err error
+	if timeout > 0 {
+		conn, err = net.DialTimeout("tcp", addr, timeout)
+	} else {
+		conn, err = net.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c := &pipeConn{
+		conn:    conn,
+		timeout: timeout,
+		w:       bufio.NewWriter(conn),
+	}
+	go c.reader(bufio.NewReader(conn))
+	return c, nil
+}
+
+func (c *pipeConn) isBroken() bool {
+	c.Lock()
+	defer c.Unlock()
+	return c.err != nil
+}
+
+// the connection is not usable anymore, all the pending requests fail
+func (c *pipeConn) fail(err error) {
+	c.Lock()
+	defer c.Unlock()
+	if c.err != nil {
+		return
+	}
+	c.err = err
+	c.conn.Close()
+	for _, r := range c.pending {
+		c.complete(r, nil, err)
+	}
+	c.pending = nil
+}
+
+// must be called with the lock held
+func (c *pipeConn) complete(r *pipeRequest, reply []byte, err error) {
+	if r.finished {
+		return
+	}
+	r.finished = true
+	r.reply = reply
+	r.err = err
+	close(r.done)
+}
+
+func (c *pipeConn) reader(r *bufio.Reader) {
+	for {
+		raw, err := readReply(r)
+		if err != nil {
+			c.fail(err)
+			return
+		}
+
+		c.Lock()
+		if len(c.pending) == 0 {
+			c.Unlock()
+			c.fail(fmt.Errorf("unexpected reply"))
+			return
+		}
+		req := c.pending[0]
+		c.pending = c.pending[1:]
+		c.complete(req, raw, nil)
+		c.Unlock()
+	}
+}
+
+func (c *pipeConn) do(cmds [][][]byte) ([][]byte, error) {
+	reqs := make([]*pipeRequest, 0, len(cmds))
+
+	c.Lock()
+	if c.err != nil {
+		err := c.err
+		c.Unlock()
+		return nil, err
+	}
+	if c.timeout > 0 {
+		c.conn.SetWriteDeadline(time.Now().Add(c.timeout))
+	}
+	var werr error
+	for _, cmd := range cmds {
+		req := &pipeRequest{
+			done: make(chan struct{}),
+		}
+		c.pending = append(c.pending, req)
+		reqs = append(reqs, req)
+
+		if _, werr = c.w.Write(appendCommand(nil, cmd)); werr != nil {
+			break
+		}
+	}
+	if werr == nil {
+		werr = c.w.Flush()
+	}
+	c.Unlock()
+
+	if werr != nil {
+		c.fail(werr)
+		return nil, werr
+	}
+
+	var timer <-chan time.Time
+	if c.timeout > 0 {
+		t := time.NewTimer(c.timeout)
+		defer t.Stop()
+		timer = t.C
+	}
+
+	o := make([][]byte, 0, len(reqs))
+	for _, req := range reqs {
+		select {
+		case <-req.done:
+			break
+		case <-timer:
+			// only the requests of this call fail, the connection is still shared
+			// by the others and the late replies are dropped
+			c.Lock()
+			for _, r := range reqs {
+				c.complete(r, nil, errTimeout)
+			}
+			c.Unlock()
+		}
+		if req.err != nil {
+			return nil, req.err
+		}
+		o = append(o, req.reply)
+	}
+	return o, nil
+}
+
+func appendCommand(b []byte, cmd [][]byte) []byte {
+	b = redcon.AppendArray(b, len(cmd))
+	for _, arg := range cmd {
+		b = redcon.AppendBulk(b, arg)
+	}
+	return b
+}
+
+// read one complete reply, the raw bytes are returned. Both RESP2 and RESP3
+// types are understood
+func readReply(r *bufio.Reader) ([]byte, error) {
+	line, err := r.ReadBytes('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("invalid reply line")
+	}
+
+	switch line[0] {
+	case '+', '-', ':', '_', '#', ',', '(':
+		return line, nil
+
+	// bulk string, blob error and verbatim string
+	case '$', '!', '=':
+		n, err := strconv.Atoi(string(line[1 : len(line)-2]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid bulk length")
+		}
+		if n < 0 {
+			return line, nil
+		}
+		o := make([]byte, len(line)+n+2)
+		copy(o, line)
+		if _, err := io.ReadFull(r, o[len(line):]); err != nil {
+			return nil, err
+		}
+		return o, nil
+
+	// array, set, push and map whose element is a key value pair
+	case '*', '~', '>', '%':
+		n, err := strconv.Atoi(string(line[1 : len(line)-2]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid array length")
+		}
+		if line[0] == '%' {
+			n *= 2
+		}
+		o := line
+		for i := 0; i < n; i++ {
+			x, err := readReply(r)
+			if err != nil {
+				return nil, err
+			}
+			o = append(o, x...)
+		}
+		return o, nil
+
+	default:
+		return nil, fmt.Errorf("invalid reply type %c", line[0])
+	}
+}
@@ -0,0 +1,128 @@
+package proxy
+
+import (
+	"fmt"
+	"hash/crc32"
+	"sort"
+	"strconv"
+)
+
+// Key is mapped to a backend by either consistent hashing or redis cluster
+// slot. In both cases only the hash tag, ie the part between the first { and
+// the following }, is hashed if it is not empty, so keys like {user1}.name
+// and {user1}.age always go to the same backend.
+
+const (
+	HashConsistent = "consistent"
+	HashCluster    = "cluster"
+
+	clusterSlots   = 16384
+	consistentVNum = 160
+)
+
+type hasher interface {
+	// index of the backend
+	locate(key []byte) int
+}
+
+func newHasher(name string, backend []string) (hasher, error) {
+	switch name {
+	case "", HashConsistent:
+		return newConsistentHash(backend), nil
+	case HashCluster:
+		return &clusterHash{
+			size: len(backend),
+		}, nil
+	default:
+		return nil, fmt.Errorf("proxy: unknown hash method %s", name)
+	}
+}
+
+func hashTag(key []byte) []byte {
+	for i, c := range key {
+		if c != '{' {
+			continue
+		}
+		for j := i + 1; j < len(key); j++ {
+			if key[j] == '}' {
+				if j > i+1 {
+					return key[i+1 : j]
+				}
+				return key
+			}
+		}
+		return key
+	}
+	return key
+}
+
+// -----------------------------------------------------------------------------
+// redis cluster, slots are evenly assigned to the backend in order
+
+type clusterHash struct {
+	size int
+}
+
+func (c *clusterHash) locate(key []byte) int {
+	return int(Slot(key)) * c.size / clusterSlots
+}
+
+// Slot of the key as redis cluster, ie CRC16(XMODEM) of the hash tag
+func Slot(key []byte) uint16 {
+	return crc16(hashTag(key)) % clusterSlots
+}
+
+func crc16(b []byte) uint16 {
+	crc := uint16(0)
+	for _, c := range b {
+		crc ^= uint16(c) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = (crc << 1) ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// -----------------------------------------------------------------------------
+// consistent hashing, each backend has fixed number of virtual nodes on the
+// ring and the key goes to the first node clockwise
+
+type ringNode struct {
+	hash  uint32
+	index int
+}
+
+type consistentHash struct {
+	ring []ringNode
+}
+
+func newConsistentHash(backend []string) *consistentHash {
+	c := &consistentHash{}
+	for i, name := range backend {
+		for v := 0; v < consistentVNum; v++ {
+			c.ring = append(c.ring, ringNode{
+				hash:  crc32.ChecksumIEEE([]byte(name + "#" + strconv.Itoa(v))),
+				index: i,
+			})
+		}
+	}
+	sort.Slice(c.ring, func(i, j int) bool {
+		return c.ring[i].hash < c.ring[j].hash
+	})
+	return c
+}
+
+func (c *consistentHash) locate(key []byte) int {
+	h := crc32.ChecksumIEEE(hashTag(key))
+	i := sort.Search(len(c.ring), func(i int) bool {
+		return c.ring[i].hash >= h
+	})
+	if i == len(c.ring) {
+		i = 0
+	}
+	return c.ring[i].index
+}
@@ -0,0 +1,368 @@
+package proxy
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dianpeng/mono-service/redis/util"
+	"github.com/tidwall/redcon"
+)
+
+// Proxy forwards redis commands to a set of backends, the backend is chosen by
+// the key of the command. Multi-key commands whose keys are on different
+// backends are fanned out and the replies are merged when the command can be
+// split, otherwise a CROSSSLOT error is replied as redis cluster does.
+//
+// Since the backend connection is shared by clients, the command which changes
+// the connection state, ie blocking, transaction and subscribe, is rejected.
+
+type Config struct {
+	Backend  []string
+	Hash     string
+	PoolSize int
+	Timeout  time.Duration
+}
+
+type Proxy struct {
+	backend []Backend
+	hasher  hasher
+}
+
+// how the multi-key command is split and merged
+const (
+	fanoutNone = iota
+	fanoutArray
+	fanoutSum
+	fanoutOK
+	fanoutAll
+)
+
+type fanout struct {
+	kind int
+
+	// number of arguments belongs to each key, ie 2 for MSET
+	step int
+}
+
+var fanoutTable = map[string]fanout{
+	"MGET":   {fanoutArray, 1},
+	"DEL":    {fanoutSum, 1},
+	"UNLINK": {fanoutSum, 1},
+	"EXISTS": {fanoutSum, 1},
+	"TOUCH":  {fanoutSum, 1},
+	"MSET":   {fanoutOK, 2},
+
+	// keyless commands which are executed by all the backends
+	"KEYS":     {fanoutAll, 0},
+	"DBSIZE":   {fanoutAll, 0},
+	"FLUSHDB":  {fanoutAll, 0},
+	"FLUSHALL": {fanoutAll, 0},
+}
+
+// commands change the state of the backend connection besides blocking and
+// transaction commands
+var connStateTable = map[string]bool{
+	"SELECT":       true,
+	"MONITOR":      true,
+	"SUBSCRIBE":    true,
+	"PSUBSCRIBE":   true,
+	"SSUBSCRIBE":   true,
+	"UNSUBSCRIBE":  true,
+	"PUNSUBSCRIBE": true,
+	"SUNSUBSCRIBE": true,
+}
+
+func New(c *Config) (*Proxy, error) {
+	if len(c.Backend) == 0 {
+		return nil, fmt.Errorf("proxy: no backend")
+	}
+	h, err := newHasher(c.Hash, c.Backend)
+	if err != nil {
+		return nil, err
+	}
+	p := &Proxy{
+		hasher: h,
+	}
+	for _, addr := range c.Backend {
+		p.backend = append(p.backend, newBackend(addr, c.PoolSize, c.Timeout))
+	}
+	return p, nil
+}
+
+var registry = struct {
+	sync.Mutex
+	proxy  map[string]*Proxy
+	config map[string]Config
+}{
+	proxy:  make(map[string]*Proxy),
+	config: make(map[string]Config),
+}
+
+// Open the proxy of the name. The proxy is reused when the config is not
+// changed, ie vhost reload keeps the backend connections, otherwise the
+// previous proxy of the name is closed
+func Open(name string, c *Config) (*Proxy, error) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	if p, ok := registry.proxy[name]; ok {
+		if reflect.DeepEqual(registry.config[name], *c) {
+			return p, nil
+		}
+		p.Close()
+		delete(registry.proxy, name)
+		delete(registry.config, name)
+	}
+
+	p, err := New(c)
+	if err != nil {
+		return nil, err
+	}
+	registry.proxy[name] = p
+	registry.config[name] = *c
+	return p, nil
+}
+
+func (p *Proxy) Close() {
+	for _, b := range p.backend {
+		b.Close()
+	}
+}
+
+// backend index of the key
+func (p *Proxy) Locate(key []byte) int {
+	return p.hasher.locate(key)
+}
+
+func (p *Proxy) Backend(idx int) Backend {
+	return p.backend[idx]
+}
+
+func errorReply(msg string) []byte {
+	return redcon.AppendError(nil, msg)
+}
+
+// Forward the command, argv[0] is the command name. The raw RESP reply is
+// returned, error means the backend is not reachable
+func (p *Proxy) Do(argv [][]byte) ([]byte, error) {
+	if len(argv) == 0 {
+		return errorReply("ERR empty command"), nil
+	}
+	name := strings.ToUpper(string(argv[0]))
+
+	info, known := util.CommandLookup(name)
+	if connStateTable[name] || (known && (info.IsBlocking() || util.CommandIsTransaction(name))) {
+		return errorReply(fmt.Sprintf("ERR command '%s' is not supported by proxy", strings.ToLower(name))), nil
+	}
+
+	fan, canFan := fanoutTable[name]
+	if canFan && fan.kind == fanoutAll {
+		return p.doAll(argv)
+	}
+
+	var keys []int
+	if known {
+		keys = info.KeyIndex(argv)
+	}
+
+	// keyless command goes to the first backend
+	if len(keys) == 0 {
+		return p.doOne(0, argv)
+	}
+
+	target := p.Locate(argv[keys[0]])
+	same := true
+	for _, k := range keys[1:] {
+		if p.Locate(argv[k]) != target {
+			same = false
+			break
+		}
+	}
+	if same {
+		return p.doOne(target, argv)
+	}
+
+	if !canFan {
+		return errorReply("CROSSSLOT Keys in request don't hash to the same slot"), nil
+	}
+	return p.doFanout(fan, argv, keys)
+}
+
+func (p *Proxy) doOne(idx int, argv [][]byte) ([]byte, error) {
+	o, err := p.backend[idx].Do([][][]byte{argv})
+	if err != nil {
+		return nil, err
+	}
+	return o[0], nil
+}
+
+type subReply struct {
+	reply []byte
+	err   error
+}
+
+// execute the command of each backend concurrently, nil command is skipped
+func (p *Proxy) doEach(cmds [][][]byte) []subReply {
+	o := make([]subReply, len(cmds))
+	wg := sync.WaitGroup{}
+	for i, cmd := range cmds {
+		if cmd == nil {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, cmd [][]byte) {
+			defer wg.Done()
+			r, err := p.backend[i].Do([][][]byte{cmd})
+			if err != nil {
+				o[i].err = err
+			} else {
+				o[i].reply = r[0]
+			}
+		}(i, cmd)
+	}
+	wg.Wait()
+	return o
+}
+
+func (p *Proxy) doAll(argv [][]byte) ([]byte, error) {
+	cmds := make([][][]byte, len(p.backend))
+	for i := range cmds {
+		cmds[i] = argv
+	}
+	replies := p.doEach(cmds)
+	for _, r := range replies {
+		if r.err != nil {
+			return nil, r.err
+		}
+	}
+
+	switch strings.ToUpper(string(argv[0])) {
+	case "KEYS":
+		return mergeConcat(replies), nil
+	case "DBSIZE":
+		return mergeSum(replies), nil
+	default:
+		return mergeOK(replies), nil
+	}
+}
+
+func (p *Proxy) doFanout(fan fanout, argv [][]byte, keys []int) ([]byte, error) {
+	// the sub command of each backend, and the position of each key inside of
+	// the original command
+	cmds := make([][][]byte, len(p.backend))
+	pos := make([][]int, len(p.backend))
+
+	for i, k := range keys {
+		idx := p.Locate(argv[k])
+		if cmds[idx] == nil {
+			cmds[idx] = [][]byte{argv[0]}
+		}
+		cmds[idx] = append(cmds[idx], argv[k:k+fan.step]...)
+		pos[idx] = append(pos[idx], i)
+	}
+
+	replies := p.doEach(cmds)
+	for _, r := range replies {
+		if r.err != nil {
+			return nil, r.err
+		}
+	}
+
+	switch fan.kind {
+	case fanoutArray:
+		return mergeArray(replies, pos, len(keys)), nil
+	case fanoutSum:
+		return mergeSum(replies), nil
+	default:
+		return mergeOK(replies), nil
+	}
+}
+
+// the first error reply, nil if none
+func firstError(replies []subReply) []byte {
+	for _, r := range replies {
+		if len(r.reply) > 0 && r.reply[0] == '-' {
+			return r.reply
+		}
+	}
+	return nil
+}
+
+func mergeArray(replies []subReply, pos [][]int, size int) []byte {
+	if e := firstError(replies); e != nil {
+		return e
+	}
+	elem := make([][]byte, size)
+	for i, r := range replies {
+		if r.reply == nil {
+			continue
+		}
+		_, resp := redcon.ReadNextRESP(r.reply)
+		j := 0
+		resp.ForEach(func(x redcon.RESP) bool {
+			if j < len(pos[i]) {
+				elem[pos[i][j]] = x.Raw
+			}
+			j++
+			return true
+		})
+	}
+	o := redcon.AppendArray(nil, size)
+	for _, e := range elem {
+		if e == nil {
+			o = redcon.AppendNull(o)
+		} else {
+			o = append(o, e...)
+		}
+	}
+	return o
+}
+
+func mergeConcat(replies []subReply) []byte {
+	if e := firstError(replies); e != nil {
+		return e
+	}
+	var elem [][]byte
+	for _, r := range replies {
+		_, resp := redcon.ReadNextRESP(r.reply)
+		resp.ForEach(func(x redcon.RESP) bool {
+			elem = append(elem, x.Raw)
+			return true
+		})
+	}
+	o := redcon.AppendArray(nil, len(elem))
+	for _, e := range elem {
+		o = append(o, e...)
+	}
+	return o
+}
+
+func mergeSum(replies []subReply) []byte {
+	if e := firstError(replies); e != nil {
+		return e
+	}
+	sum := int64(0)
+	for _, r := range replies {
+		if r.reply == nil {
+			continue
+		}
+		_, resp := redcon.ReadNextRESP(r.reply)
+		v, err := strconv.ParseInt(string(resp.Data), 10, 64)
+		if resp.Type != redcon.Integer || err != nil {
+			return errorReply("ERR unexpected backend reply")
+		}
+		sum += v
+	}
+	return redcon.AppendInt(nil, sum)
+}
+
+func mergeOK(replies []subReply) []byte {
+	if e := firstError(replies); e != nil {
+		return e
+	}
+	return redcon.AppendOK(nil)
+}
@@ -0,0 +1,333 @@
+package proxy
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dianpeng/mono-service/redis/kv"
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/redcon"
+)
+
+func testArgv(cmd string) [][]byte {
+	o := [][]byte{}
+	for _, x := range strings.Fields(cmd) {
+		o = append(o, []byte(x))
+	}
+	return o
+}
+
+func testDo(p *Proxy, cmd string) string {
+	raw, err := p.Do(testArgv(cmd))
+	if err != nil {
+		return "error: " + err.Error()
+	}
+	return string(raw)
+}
+
+func TestSlot(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal(uint16(12739), Slot([]byte("123456789")))
+	assert.Equal(uint16(12182), Slot([]byte("foo")))
+	assert.Equal(Slot([]byte("{user1000}.following")), Slot([]byte("{user1000}.followers")))
+	assert.Equal(Slot([]byte("{}foo")), Slot([]byte("{}foo")))
+	assert.NotEqual(Slot([]byte("{}foo")), Slot([]byte("foo")))
+
+	c := newConsistentHash([]string{"a", "b", "c"})
+	assert.Equal(c.locate([]byte("{tag}x")), c.locate([]byte("{tag}y")))
+}
+
+func TestProxyLocal(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, hash := range []string{HashConsistent, HashCluster} {
+		backend := []string{}
+		for i := 0; i < 3; i++ {
+			name := fmt.Sprintf("proxy-test-%s-%d", hash, i)
+			kv.Open(name).Flush()
+			backend = append(backend, "kv://"+name)
+		}
+		p, err := New(&Config{
+			Backend: backend,
+			Hash:    hash,
+		})
+		assert.True(err == nil, "%s", err)
+
+		// keys are spread over the backends
+		keys := []string{}
+		used := map[int]bool{}
+		for i := 0; i < 20; i++ {
+			key := fmt.Sprintf("key%d", i)
+			keys = append(keys, key)
+			assert.Equal("+OK\r\n", testDo(p, "set "+key+" "+fmt.Sprint(i)))
+			used[p.Locate([]byte(key))] = true
+
+			ks := kv.Open(backend[p.Locate([]byte(key))][len("kv://"):])
+			v, _ := ks.Exec(testArgv("get " + key))
+			assert.Equal(fmt.Sprint(i), string(v.([]byte)))
+		}
+		assert.Equal(3, len(used))
+
+		// fan out and merge in order
+		assert.Equal("*3\r\n$1\r\n3\r\n$-1\r\n$1\r\n1\r\n", testDo(p, "mget key3 nope key1"))
+		assert.Equal(":20\r\n", testDo(p, "dbsize"))
+		assert.Equal(":3\r\n", testDo(p, "exists key0 key1 key2 nope"))
+		assert.Equal(":2\r\n", testDo(p, "del key0 key1 nope"))
+		assert.Equal("+OK\r\n", testDo(p, "mset a 1 b 2 c 3"))
+		assert.Equal("*3\r\n$1\r\n1\r\n$1\r\n2\r\n$1\r\n3\r\n", testDo(p, "mget a b c"))
+		assert.True(strings.HasPrefix(testDo(p, "keys *"), "*21\r\n"))
+
+		// same hash tag goes to the same backend
+		assert.Equal("+OK\r\n", testDo(p, "mset {u}a 1 {u}b 2"))
+		assert.Equal(":2\r\n", testDo(p, "sadd {u}s x y"))
+		assert.Equal(":2\r\n", testDo(p, "scard {u}s"))
+
+		other := ""
+		for _, k := range keys {
+			if p.Locate([]byte(k)) != p.Locate([]byte("key2")) {
+				other = k
+				break
+			}
+		}
+		assert.Equal("-CROSSSLOT Keys in request don't hash to the same slot\r\n",
+			testDo(p, "rename key2 "+other))
+		assert.Equal("-ERR command 'blpop' is not supported by proxy\r\n", testDo(p, "blpop a 0"))
+		assert.Equal("-ERR command 'multi' is not supported by proxy\r\n", testDo(p, "multi"))
+		assert.Equal("-WRONGTYPE Operation against a key holding the wrong kind of value\r\n",
+			testDo(p, "hget a f"))
+
+		assert.Equal("+OK\r\n", testDo(p, "flushdb"))
+		assert.Equal(":0\r\n", testDo(p, "dbsize"))
+		p.Close()
+	}
+}
+
+// in-process RESP server backed by a keyspace
+func testServer(t *testing.T) (net.Listener, *kv.Keyspace) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ks := kv.New()
+	go redcon.Serve(ln,
+		func(conn redcon.Conn, cmd redcon.Command) {
+			v, err := ks.Exec(cmd.Args)
+			kv.WriteReply(conn, v, err)
+		},
+		nil,
+		nil,
+	)
+	return ln, ks
+}
+
+func TestProxyPipeline(t *testing.T) {
+	assert := assert.New(t)
+
+	// the server is left running, redcon races on closing the listener with
+	// active connections
+	ln0, ks0 := testServer(t)
+	ln1, ks1 := testServer(t)
+	defer ks0.Close()
+	defer ks1.Close()
+
+	p, err := New(&Config{
+		Backend:  []string{ln0.Addr().String(), ln1.Addr().String()},
+		PoolSize: 2,
+	})
+	assert.True(err == nil, "%s", err)
+	defer p.Close()
+
+	// concurrent clients share the pipelined connections
+	wg := sync.WaitGroup{}
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				key := fmt.Sprintf("k%d-%d", i, j)
+				assert.Equal("+OK\r\n", testDo(p, "set "+key+" "+key))
+				assert.Equal(fmt.Sprintf("$%d\r\n%s\r\n", len(key), key), testDo(p, "get "+key))
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(640, ks0.Size()+ks1.Size())
+	assert.True(ks0.Size() > 0 && ks1.Size() > 0)
+	assert.Equal(":640\r\n", testDo(p, "dbsize"))
+
+	// backend is not reachable
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+	addr := ln.Addr().String()
+	ln.Close()
+
+	down, err := New(&Config{
+		Backend: []string{addr},
+	})
+	assert.True(err == nil, "%s", err)
+	defer down.Close()
+	assert.True(strings.HasPrefix(testDo(down, "get a"), "error: backend "+addr))
+}
+
+func TestReadReply(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, raw := range []string{
+		"+OK\r\n",
+		"-ERR x\r\n",
+		":1\r\n",
+		"$3\r\nabc\r\n",
+		"$-1\r\n",
+		"*2\r\n$1\r\na\r\n:1\r\n",
+		"*-1\r\n",
+
+		// RESP3
+		"_\r\n",
+		"#t\r\n",
+		",1.5\r\n",
+		"(12345678901234567890\r\n",
+		"!5\r\nERR x\r\n",
+		"=8\r\ntxt:abcd\r\n",
+		"%2\r\n+a\r\n:1\r\n+b\r\n%1\r\n+c\r\n_\r\n",
+		"~2\r\n+a\r\n#f\r\n",
+		">3\r\n$7\r\nmessage\r\n$1\r\nc\r\n$1\r\nm\r\n",
+	} {
+		// the following reply is not consumed
+		r := bufio.NewReader(strings.NewReader(raw + "+next\r\n"))
+		x, err := readReply(r)
+		if assert.True(err == nil, "%q: %s", raw, err) {
+			assert.Equal(raw, string(x))
+		}
+		x, _ = readReply(r)
+		assert.Equal("+next\r\n", string(x), raw)
+	}
+
+	for _, raw := range []string{
+		"?1\r\n",
+		"$x\r\n",
+		"%x\r\n",
+		"+OK\n",
+		"$3\r\nab",
+		"%1\r\n+a\r\n",
+	} {
+		_, err := readReply(bufio.NewReader(strings.NewReader(raw)))
+		assert.True(err != nil, "%q", raw)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	assert := assert.New(t)
+
+	// keys of backend 0 are at 0 and 2, backend 2 has no key
+	replies := []subReply{
+		{reply: []byte("*2\r\n$1\r\na\r\n$-1\r\n")},
+		{reply: []byte("*1\r\n$1\r\nb\r\n")},
+		{},
+	}
+	pos := [][]int{{0, 2}, {1}, nil}
+	assert.Equal("*3\r\n$1\r\na\r\n$1\r\nb\r\n$-1\r\n", string(mergeArray(replies, pos, 3)))
+
+	replies[1].reply = []byte("-ERR x\r\n")
+	assert.Equal("-ERR x\r\n", string(mergeArray(replies, pos, 3)))
+
+	assert.Equal(":5\r\n", string(mergeSum([]subReply{
+		{reply: []byte(":2\r\n")},
+		{},
+		{reply: []byte(":3\r\n")},
+	})))
+	assert.Equal("-WRONGTYPE x\r\n", string(mergeSum([]subReply{
+		{reply: []byte(":2\r\n")},
+		{reply: []byte("-WRONGTYPE x\r\n")},
+	})))
+	assert.Equal("-ERR unexpected backend reply\r\n", string(mergeSum([]subReply{
+		{reply: []byte("+OK\r\n")},
+	})))
+}
+
+func TestProxyCrossSlot(t *testing.T) {
+	assert := assert.New(t)
+
+	backend := []string{}
+	for i := 0; i < 2; i++ {
+		name := fmt.Sprintf("proxy-crossslot-%d", i)
+		kv.Open(name).Flush()
+		backend = append(backend, "kv://"+name)
+	}
+	p, err := New(&Config{
+		Backend: backend,
+		Hash:    HashCluster,
+	})
+	assert.True(err == nil, "%s", err)
+	defer p.Close()
+
+	// two keys on different backends
+	a, b := "a", ""
+	for i := 0; b == ""; i++ {
+		if k := fmt.Sprintf("b%d", i); p.Locate([]byte(k)) != p.Locate([]byte(a)) {
+			b = k
+		}
+	}
+
+	crossSlot := "-CROSSSLOT Keys in request don't hash to the same slot\r\n"
+	assert.Equal(crossSlot, testDo(p, "sunionstore "+a+" "+b))
+	assert.Equal(crossSlot, testDo(p, "rpoplpush "+a+" "+b))
+	assert.Equal(crossSlot, testDo(p, "rename "+a+" "+b))
+
+	// the same hash tag is on the same backend
+	assert.Equal("+OK\r\n", testDo(p, "set {t}"+a+" 1"))
+	assert.Equal("+OK\r\n", testDo(p, "rename {t}"+a+" {t}"+b))
+
+	// split and merged instead
+	assert.Equal("+OK\r\n", testDo(p, "mset "+a+" 1 "+b+" 2"))
+	assert.Equal("*2\r\n$1\r\n2\r\n$1\r\n1\r\n", testDo(p, "mget "+b+" "+a))
+	assert.Equal(":2\r\n", testDo(p, "del "+a+" "+b))
+}
+
+func TestProxyTimeout(t *testing.T) {
+	assert := assert.New(t)
+
+	// SLOW is replied after 100ms, other commands at once
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ks := kv.New()
+	defer ks.Close()
+	go redcon.Serve(ln,
+		func(conn redcon.Conn, cmd redcon.Command) {
+			if strings.ToUpper(string(cmd.Args[0])) == "SLOW" {
+				time.Sleep(100 * time.Millisecond)
+				conn.WriteString("SLOW")
+				return
+			}
+			v, err := ks.Exec(cmd.Args)
+			kv.WriteReply(conn, v, err)
+		},
+		nil,
+		nil,
+	)
+
+	p, err := New(&Config{
+		Backend: []string{ln.Addr().String()},
+		Timeout: 50 * time.Millisecond,
+	})
+	assert.True(err == nil, "%s", err)
+	defer p.Close()
+
+	assert.Equal("+OK\r\n", testDo(p, "set a 1"))
+	backend := p.Backend(0).(*tcpBackend)
+	conn := backend.pool[0]
+
+	assert.Equal("error: backend "+ln.Addr().String()+": timeout", testDo(p, "slow"))
+
+	// the late reply of SLOW is dropped, and the connection is still used
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal("$1\r\n1\r\n", testDo(p, "get a"))
+	assert.True(conn == backend.pool[0])
+	assert.False(conn.isBroken())
+}
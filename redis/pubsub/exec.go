@@ -0,0 +1,161 @@
+package pubsub
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dianpeng/mono-service/pubsub"
+	"github.com/tidwall/redcon"
+)
+
+// Exec serves the pub/sub commands of the redis connection with the broker of
+// the vhost, see pubsub/broker.go. The connection is detached from the redcon
+// server loop on its first subscription, and served by the Subscriber until it
+// is closed, see subscriber.go.
+
+const (
+	protoRESP3 = 3
+)
+
+var commandTable = map[string]bool{
+	"SUBSCRIBE":    true,
+	"PSUBSCRIBE":   true,
+	"UNSUBSCRIBE":  true,
+	"PUNSUBSCRIBE": true,
+	"PUBLISH":      true,
+	"PUBSUB":       true,
+}
+
+// IsCommand tells whether the command, in upper case, is served by the broker
+func IsCommand(name string) bool {
+	return commandTable[name]
+}
+
+// Exec the pub/sub command, argv[0] is the command name. The connection is
+// detached from the redcon server loop when it subscribes the first time, and
+// h serves the following commands. False is returned if the command is not a
+// pub/sub command
+func Exec(b *pubsub.Broker, conn redcon.Conn, argv [][]byte, h Handler) bool {
+	if len(argv) == 0 {
+		return false
+	}
+	name := strings.ToUpper(string(argv[0]))
+
+	switch name {
+	case "SUBSCRIBE", "PSUBSCRIBE":
+		pattern := name == "PSUBSCRIBE"
+		if sc, ok := conn.(*subConn); ok {
+			sc.s.subscribe(pattern, argv[1:])
+			return true
+		}
+		s := newSubscriber(b, conn, h)
+		s.subscribe(pattern, argv[1:])
+		s.flush()
+		go s.serve()
+		return true
+
+	case "UNSUBSCRIBE", "PUNSUBSCRIBE":
+		pattern := name == "PUNSUBSCRIBE"
+		if sc, ok := conn.(*subConn); ok {
+			sc.s.unsubscribe(pattern, argv[1:])
+			return true
+		}
+		// connection never subscribed, ie nothing to unsubscribe
+		kind := "unsubscribe"
+		if pattern {
+			kind = "punsubscribe"
+		}
+		proto := h.Protocol()
+		if len(argv) == 1 {
+			conn.WriteRaw(appendNotify(nil, proto, kind, nil, 0))
+			return true
+		}
+		var o []byte
+		for _, x := range argv[1:] {
+			o = appendNotify(o, proto, kind, x, 0)
+		}
+		conn.WriteRaw(o)
+		return true
+
+	case "PUBLISH":
+		if len(argv) != 3 {
+			conn.WriteError("ERR wrong number of arguments for 'publish' command")
+			return true
+		}
+		conn.WriteInt(b.Publish(string(argv[1]), argv[2]))
+		return true
+
+	case "PUBSUB":
+		command(b, conn, argv)
+		return true
+
+	default:
+		return false
+	}
+}
+
+// PUBSUB CHANNELS [pattern] | NUMSUB [channel ...] | NUMPAT
+func command(b *pubsub.Broker, conn redcon.Conn, argv [][]byte) {
+	if len(argv) < 2 {
+		conn.WriteError("ERR wrong number of arguments for 'pubsub' command")
+		return
+	}
+
+	switch sub := strings.ToUpper(string(argv[1])); sub {
+	case "CHANNELS":
+		if len(argv) > 3 {
+			conn.WriteError("ERR wrong number of arguments for 'pubsub|channels' command")
+			return
+		}
+		pattern := ""
+		if len(argv) == 3 {
+			pattern = string(argv[2])
+		}
+		o := b.Channels(pattern)
+		conn.WriteArray(len(o))
+		for _, ch := range o {
+			conn.WriteBulkString(ch)
+		}
+
+	case "NUMSUB":
+		conn.WriteArray((len(argv) - 2) * 2)
+		for _, ch := range argv[2:] {
+			conn.WriteBulk(ch)
+			conn.WriteInt(b.NumSub(string(ch)))
+		}
+
+	case "NUMPAT":
+		if len(argv) != 2 {
+			conn.WriteError("ERR wrong number of arguments for 'pubsub|numpat' command")
+			return
+		}
+		conn.WriteInt(b.NumPat())
+
+	default:
+		conn.WriteError(fmt.Sprintf("ERR unknown subcommand '%s'. Try PUBSUB HELP.", string(argv[1])))
+	}
+}
+
+// subscribe and unsubscribe notification, and the message are pushed under
+// RESP3, or written as array under RESP2
+func appendHeader(b []byte, proto int, n int) []byte {
+	if proto == protoRESP3 {
+		return append(b, fmt.Sprintf(">%d\r\n", n)...)
+	}
+	return redcon.AppendArray(b, n)
+}
+
+func appendNotify(b []byte, proto int, kind string, name []byte, count int) []byte {
+	b = appendHeader(b, proto, 3)
+	b = redcon.AppendBulkString(b, kind)
+	if name == nil {
+		if proto == protoRESP3 {
+			b = append(b, '_', '\r', '\n')
+		} else {
+			b = redcon.AppendNull(b)
+		}
+	} else {
+		b = redcon.AppendBulk(b, name)
+	}
+	return redcon.AppendInt(b, int64(count))
+}
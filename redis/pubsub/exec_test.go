@@ -0,0 +1,290 @@
+package pubsub
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dianpeng/mono-service/pubsub"
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/redcon"
+)
+
+// per connection handler of the test server, HELLO switches to RESP3 and any
+// other command replies +OK:<name>
+type testHandler struct {
+	b     *pubsub.Broker
+	proto int
+}
+
+func (h *testHandler) OnDetach(_ redcon.Conn) {
+}
+
+func (h *testHandler) OnCommand(conn redcon.Conn, cmd redcon.Command) {
+	if Exec(h.b, conn, cmd.Args, h) {
+		return
+	}
+	name := strings.ToUpper(string(cmd.Args[0]))
+	if name == "HELLO" {
+		h.proto = protoRESP3
+	}
+	conn.WriteString("OK:" + name)
+}
+
+func (h *testHandler) OnClose(_ redcon.Conn, _ error) {
+}
+
+func (h *testHandler) Protocol() int {
+	return h.proto
+}
+
+func testServer(t *testing.T, b *pubsub.Broker) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go redcon.Serve(ln,
+		func(conn redcon.Conn, cmd redcon.Command) {
+			h, ok := conn.Context().(*testHandler)
+			if !ok {
+				h = &testHandler{b: b, proto: 2}
+				conn.SetContext(h)
+			}
+			h.OnCommand(conn, cmd)
+		},
+		nil,
+		nil,
+	)
+	return ln.Addr().String()
+}
+
+type testClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func dial(t *testing.T, addr string) *testClient {
+	c, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testClient{
+		conn: c,
+		r:    bufio.NewReader(c),
+	}
+}
+
+func (c *testClient) send(cmd string) {
+	args := strings.Fields(cmd)
+	b := redcon.AppendArray(nil, len(args))
+	for _, a := range args {
+		b = redcon.AppendBulkString(b, a)
+	}
+	c.conn.Write(b)
+}
+
+// one reply with CRLF replaced by space
+func (c *testClient) read() string {
+	c.conn.SetReadDeadline(time.Now().Add(time.Second))
+	var o []string
+	if err := c.readReply(&o); err != nil {
+		return "error: " + err.Error()
+	}
+	return strings.Join(o, " ")
+}
+
+func (c *testClient) readReply(o *[]string) error {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	*o = append(*o, line)
+
+	switch line[0] {
+	case '$':
+		n, _ := strconv.Atoi(line[1:])
+		if n < 0 {
+			return nil
+		}
+		b := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, b); err != nil {
+			return err
+		}
+		*o = append(*o, string(b[:n]))
+	case '*', '>':
+		n, _ := strconv.Atoi(line[1:])
+		for i := 0; i < n; i++ {
+			if err := c.readReply(o); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (c *testClient) do(cmd string) string {
+	c.send(cmd)
+	return c.read()
+}
+
+func waitFor(f func() bool) bool {
+	for i := 0; i < 100; i++ {
+		if f() {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false
+}
+
+func TestPubSub(t *testing.T) {
+	assert := assert.New(t)
+	b := pubsub.NewBroker()
+	addr := testServer(t, b)
+
+	sub := dial(t, addr)
+	pub := dial(t, addr)
+
+	assert.Equal("+OK:GET", sub.do("get a"))
+	assert.Equal("*3 $9 subscribe $4 news :1", sub.do("subscribe news"))
+	assert.Equal("*3 $10 psubscribe $2 n* :2", sub.do("psubscribe n*"))
+
+	assert.Equal(":2", pub.do("publish news hi"))
+	assert.Equal("*3 $7 message $4 news $2 hi", sub.read())
+	assert.Equal("*4 $8 pmessage $2 n* $4 news $2 hi", sub.read())
+	assert.Equal(":1", pub.do("publish nope x"))
+	assert.Equal("*4 $8 pmessage $2 n* $4 nope $1 x", sub.read())
+	assert.Equal(":0", pub.do("publish other x"))
+
+	// only the pub/sub commands are allowed while subscribing
+	assert.Equal("-ERR Can't execute 'get': only (P)SUBSCRIBE / (P)UNSUBSCRIBE / PING / QUIT are allowed in this context",
+		sub.do("get a"))
+	assert.Equal("*2 $4 pong $0 ", sub.do("ping"))
+
+	assert.Equal("*1 $4 news", pub.do("pubsub channels"))
+	assert.Equal("*0", pub.do("pubsub channels x*"))
+	assert.Equal("*4 $4 news :1 $1 x :0", pub.do("pubsub numsub news x"))
+	assert.Equal(":1", pub.do("pubsub numpat"))
+
+	// back to the normal context after all the subscriptions are gone
+	assert.Equal("*3 $11 unsubscribe $4 news :1", sub.do("unsubscribe"))
+	assert.Equal("*3 $12 punsubscribe $2 n* :0", sub.do("punsubscribe n*"))
+	assert.Equal("*3 $12 punsubscribe $-1 :0", sub.do("punsubscribe"))
+	assert.Equal("+OK:GET", sub.do("get a"))
+	assert.Equal(":0", pub.do("publish news hi"))
+
+	// never subscribed
+	assert.Equal("*3 $11 unsubscribe $1 a :0", pub.do("unsubscribe a"))
+
+	assert.Equal("*3 $9 subscribe $4 news :1", sub.do("subscribe news"))
+	assert.Equal("+OK", sub.do("quit"))
+	assert.Equal("error: EOF", sub.read())
+	assert.True(waitFor(func() bool {
+		return pub.do("pubsub numsub news") == "*2 $4 news :0"
+	}))
+}
+
+func TestPubSubRESP3(t *testing.T) {
+	assert := assert.New(t)
+	b := pubsub.NewBroker()
+	addr := testServer(t, b)
+
+	sub := dial(t, addr)
+	assert.Equal("+OK:HELLO", sub.do("hello 3"))
+	assert.Equal(">3 $9 subscribe $1 x :1", sub.do("subscribe x"))
+
+	// any command is allowed under RESP3
+	assert.Equal("+OK:GET", sub.do("get a"))
+
+	assert.Equal(1, b.Publish("x", []byte("m")))
+	assert.Equal(">3 $7 message $1 x $1 m", sub.read())
+
+	// the subscriber is removed when the connection is gone
+	sub.conn.Close()
+	assert.True(waitFor(func() bool {
+		return b.Publish("x", []byte("m")) == 0
+	}))
+}
+
+func TestPubSubConcurrent(t *testing.T) {
+	assert := assert.New(t)
+	b := pubsub.NewBroker()
+	addr := testServer(t, b)
+
+	subs := []*testClient{}
+	for i := 0; i < 4; i++ {
+		c := dial(t, addr)
+		assert.Equal("*3 $9 subscribe $1 c :1", c.do("subscribe c"))
+		subs = append(subs, c)
+	}
+
+	done := make(chan bool)
+	go func() {
+		for i := 0; i < 100; i++ {
+			b.Publish("c", []byte(fmt.Sprint(i)))
+		}
+		done <- true
+	}()
+
+	// replies and messages do not interleave
+	for _, c := range subs {
+		c.send("ping x")
+	}
+	for _, c := range subs {
+		pong := 0
+		for i := 0; i < 101; i++ {
+			o := c.read()
+			if o == "*2 $4 pong $1 x" {
+				pong++
+			} else {
+				assert.True(strings.HasPrefix(o, "*3 $7 message $1 c $"), o)
+			}
+		}
+		assert.Equal(1, pong)
+	}
+	<-done
+}
+
+func TestCloseError(t *testing.T) {
+	assert := assert.New(t)
+	b := pubsub.NewBroker()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := make(chan error, 2)
+	go redcon.Serve(ln,
+		func(conn redcon.Conn, cmd redcon.Command) {
+			h, ok := conn.Context().(*testHandler)
+			if !ok {
+				h = &testHandler{b: b, proto: 2}
+				conn.SetContext(h)
+			}
+			h.OnCommand(conn, cmd)
+		},
+		nil,
+		func(conn redcon.Conn, err error) {
+			closed <- CloseError(conn, err)
+		},
+	)
+
+	// detached by the subscription
+	sub := dial(t, ln.Addr().String())
+	assert.Equal("*3 $9 subscribe $1 x :1", sub.do("subscribe x"))
+	assert.True(errors.Is(<-closed, ErrDetached))
+
+	// closed by the client
+	c := dial(t, ln.Addr().String())
+	assert.Equal("+OK:GET", c.do("get a"))
+	c.conn.Close()
+	assert.False(errors.Is(<-closed, ErrDetached))
+	sub.conn.Close()
+}
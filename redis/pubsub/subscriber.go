@@ -0,0 +1,328 @@
+package pubsub
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/dianpeng/mono-service/pubsub"
+	"github.com/tidwall/redcon"
+)
+
+// Handler serves the detached connection besides the subscription itself
+type Handler interface {
+	// connection is detached, conn should be used from now on
+	OnDetach(conn redcon.Conn)
+
+	// command which is not served by the subscriber, including the pub/sub
+	// command which should be passed back to Broker.Exec with the same conn
+	OnCommand(conn redcon.Conn, cmd redcon.Command)
+
+	// detached connection is closed
+	OnClose(conn redcon.Conn, err error)
+
+	// RESP protocol of the connection, under RESP3 the message is pushed and
+	// any command is allowed while subscribing
+	Protocol() int
+}
+
+// ErrDetached is the close error of the connection detached by the Subscriber,
+// see CloseError
+var ErrDetached = errors.New("pubsub: connection is detached")
+
+// connections detached by the Subscriber whose close is not reported yet
+var detached sync.Map
+
+// CloseError translates the error redcon closes the connection with. Redcon
+// reports the detached connection with its own unexported error, the
+// connection detached by the Subscriber is reported as ErrDetached, since the
+// Subscriber owns it and closes it later
+func CloseError(conn redcon.Conn, err error) error {
+	if _, ok := detached.LoadAndDelete(conn); ok {
+		return ErrDetached
+	}
+	return err
+}
+
+// commands allowed while subscribing under RESP2
+var contextTable = map[string]bool{
+	"SUBSCRIBE":    true,
+	"PSUBSCRIBE":   true,
+	"UNSUBSCRIBE":  true,
+	"PUNSUBSCRIBE": true,
+	"PING":         true,
+	"QUIT":         true,
+}
+
+// Subscriber serves a detached connection until it is closed. The channel and
+// pattern sets are only touched by the serving goroutine, the message is
+// written by the publisher goroutine
+type Subscriber struct {
+	broker  *pubsub.Broker
+	dconn   redcon.DetachedConn
+	conn    *subConn
+	handler Handler
+	proto   int32
+
+	channel map[string]bool
+	pattern map[string]bool
+
+	// guards the writes of the detached connection
+	sync.Mutex
+	closed bool
+}
+
+func newSubscriber(b *pubsub.Broker, conn redcon.Conn, h Handler) *Subscriber {
+	s := &Subscriber{
+		broker:  b,
+		handler: h,
+		proto:   int32(h.Protocol()),
+		channel: make(map[string]bool),
+		pattern: make(map[string]bool),
+	}
+	detached.Store(conn, true)
+	s.dconn = conn.Detach()
+	s.conn = &subConn{
+		DetachedConn: s.dconn,
+		s:            s,
+	}
+	h.OnDetach(s.conn)
+	return s
+}
+
+func (s *Subscriber) protocol() int {
+	return int(atomic.LoadInt32(&s.proto))
+}
+
+// number of subscriptions, both channel and pattern
+func (s *Subscriber) count() int {
+	return len(s.channel) + len(s.pattern)
+}
+
+func (s *Subscriber) subscribe(pattern bool, names [][]byte) {
+	kind, set, add := "subscribe", s.channel, s.broker.Subscribe
+	if pattern {
+		kind, set, add = "psubscribe", s.pattern, s.broker.PSubscribe
+	}
+	for _, n := range names {
+		name := string(n)
+		if !set[name] {
+			set[name] = true
+			add(s, name)
+		}
+		s.conn.buf = appendNotify(s.conn.buf, s.protocol(), kind, n, s.count())
+	}
+}
+
+// unsubscribe all when names is empty
+func (s *Subscriber) unsubscribe(pattern bool, names [][]byte) {
+	kind, set, del := "unsubscribe", s.channel, s.broker.Unsubscribe
+	if pattern {
+		kind, set, del = "punsubscribe", s.pattern, s.broker.PUnsubscribe
+	}
+
+	if len(names) == 0 {
+		if len(set) == 0 {
+			s.conn.buf = appendNotify(s.conn.buf, s.protocol(), kind, nil, s.count())
+			return
+		}
+		all := make([]string, 0, len(set))
+		for name := range set {
+			all = append(all, name)
+		}
+		sort.Strings(all)
+		for _, name := range all {
+			names = append(names, []byte(name))
+		}
+	}
+
+	for _, n := range names {
+		name := string(n)
+		if set[name] {
+			delete(set, name)
+			del(s, name)
+		}
+		s.conn.buf = appendNotify(s.conn.buf, s.protocol(), kind, n, s.count())
+	}
+}
+
+// Message writes the message of the channel, pubsub.Subscriber
+func (s *Subscriber) Message(pattern string, channel string, msg []byte) {
+	proto := s.protocol()
+	var b []byte
+	if pattern == "" {
+		b = appendHeader(b, proto, 3)
+		b = redcon.AppendBulkString(b, "message")
+	} else {
+		b = appendHeader(b, proto, 4)
+		b = redcon.AppendBulkString(b, "pmessage")
+		b = redcon.AppendBulkString(b, pattern)
+	}
+	b = redcon.AppendBulkString(b, channel)
+	b = redcon.AppendBulk(b, msg)
+
+	s.Lock()
+	defer s.Unlock()
+	if s.closed {
+		return
+	}
+	s.dconn.WriteRaw(b)
+	s.dconn.Flush()
+}
+
+func (s *Subscriber) serve() {
+	var err error
+	for {
+		var cmd redcon.Command
+		if cmd, err = s.dconn.ReadCommand(); err != nil {
+			break
+		}
+		if len(cmd.Args) == 0 {
+			continue
+		}
+		s.exec(cmd)
+		if !s.flush() {
+			break
+		}
+	}
+	s.close(err)
+}
+
+func (s *Subscriber) exec(cmd redcon.Command) {
+	name := strings.ToUpper(string(cmd.Args[0]))
+	subscribing := s.count() > 0 && s.protocol() != protoRESP3
+
+	switch {
+	case name == "QUIT":
+		s.conn.WriteString("OK")
+		s.conn.Close()
+
+	case subscribing && name == "PING":
+		var msg []byte
+		if len(cmd.Args) > 1 {
+			msg = cmd.Args[1]
+		}
+		s.conn.WriteArray(2)
+		s.conn.WriteBulkString("pong")
+		s.conn.WriteBulk(msg)
+
+	case subscribing && !contextTable[name]:
+		s.conn.WriteError(fmt.Sprintf(
+			"ERR Can't execute '%s': only (P)SUBSCRIBE / (P)UNSUBSCRIBE / PING / QUIT are allowed in this context",
+			strings.ToLower(name),
+		))
+
+	default:
+		s.handler.OnCommand(s.conn, cmd)
+		atomic.StoreInt32(&s.proto, int32(s.handler.Protocol()))
+	}
+}
+
+// write the buffered reply, false if the connection should be closed
+func (s *Subscriber) flush() bool {
+	s.Lock()
+	defer s.Unlock()
+	if len(s.conn.buf) > 0 {
+		s.dconn.WriteRaw(s.conn.buf)
+		s.conn.buf = s.conn.buf[:0]
+	}
+	if err := s.dconn.Flush(); err != nil {
+		return false
+	}
+	return !s.conn.closing
+}
+
+func (s *Subscriber) close(err error) {
+	for name := range s.channel {
+		s.broker.Unsubscribe(s, name)
+	}
+	for name := range s.pattern {
+		s.broker.PUnsubscribe(s, name)
+	}
+
+	s.Lock()
+	s.closed = true
+	s.dconn.Close()
+	s.Unlock()
+
+	if err == io.EOF {
+		err = nil
+	}
+	s.handler.OnClose(s.conn, err)
+}
+
+// subConn is handed to the Handler after the connection is detached. The reply
+// is buffered and written by the Subscriber after the command, so it never
+// interleaves with the message written by the publisher
+type subConn struct {
+	redcon.DetachedConn
+	s       *Subscriber
+	buf     []byte
+	closing bool
+}
+
+func (c *subConn) Close() error {
+	c.closing = true
+	return nil
+}
+
+func (c *subConn) WriteError(msg string) {
+	c.buf = redcon.AppendError(c.buf, msg)
+}
+
+func (c *subConn) WriteString(str string) {
+	c.buf = redcon.AppendString(c.buf, str)
+}
+
+func (c *subConn) WriteBulk(bulk []byte) {
+	c.buf = redcon.AppendBulk(c.buf, bulk)
+}
+
+func (c *subConn) WriteBulkString(bulk string) {
+	c.buf = redcon.AppendBulkString(c.buf, bulk)
+}
+
+func (c *subConn) WriteInt(num int) {
+	c.buf = redcon.AppendInt(c.buf, int64(num))
+}
+
+func (c *subConn) WriteInt64(num int64) {
+	c.buf = redcon.AppendInt(c.buf, num)
+}
+
+func (c *subConn) WriteUint64(num uint64) {
+	c.buf = redcon.AppendUint(c.buf, num)
+}
+
+func (c *subConn) WriteArray(count int) {
+	c.buf = redcon.AppendArray(c.buf, count)
+}
+
+func (c *subConn) WriteNull() {
+	c.buf = redcon.AppendNull(c.buf)
+}
+
+func (c *subConn) WriteRaw(data []byte) {
+	c.buf = append(c.buf, data...)
+}
+
+func (c *subConn) WriteAny(v interface{}) {
+	c.buf = redcon.AppendAny(c.buf, v)
+}
+
+func (c *subConn) Detach() redcon.DetachedConn {
+	panic("pubsub: connection is already detached")
+}
+
+// pipelined commands are read one by one by the Subscriber
+func (c *subConn) ReadPipeline() []redcon.Command {
+	return nil
+}
+
+func (c *subConn) PeekPipeline() []redcon.Command {
+	return nil
+}
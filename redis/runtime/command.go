@@ -15,8 +15,12 @@ const (
 )
 
 type command struct {
+	argv [][]byte
 	args [][]byte
 	name string
+
+	// nil if the command is unknown
+	info *util.CommandInfo
 }
 
 func ValIsCommand(v pl.Val) bool {
@@ -57,6 +61,26 @@ func (c *command) property(prop string) (pl.Val, bool) {
 		return pl.NewValStr(
 			util.CommandCategoryName(c.Name()),
 		), true
+
+	// arity of the command as redis COMMAND, 0 if the command is unknown
+	case "arity":
+		if c.info == nil {
+			return pl.NewValInt(0), true
+		}
+		return pl.NewValInt(c.info.Arity), true
+
+	case "isWrite":
+		return pl.NewValBool(c.info != nil && c.info.IsWrite()), true
+
+	// keys of the command resolved by the key spec, empty if unknown
+	case "keys":
+		l := pl.NewValList()
+		if c.info != nil {
+			for _, k := range c.info.Keys(c.argv) {
+				l.AddList(pl.NewValStr(string(k)))
+			}
+		}
+		return l, true
 	default:
 		break
 	}
@@ -124,6 +148,7 @@ func (c *command) ToJSON() (pl.Val, error) {
 
 var (
 	methodProtoCommandAsString = pl.MustNewFuncProto("redis.command.asString", "%d")
+	methodProtoCommandAsBytes  = pl.MustNewFuncProto("redis.command.asBytes", "%d")
 	methodProtoCommandAsInt    = pl.MustNewFuncProto("redis.command.asInt", "%d")
 	methodProtoCommandAsReal   = pl.MustNewFuncProto("redis.command.asReal", "%d")
 	methodProtoCommandAsBool   = pl.MustNewFuncProto("redis.command.asBool", "%d")
@@ -279,6 +304,20 @@ func (c *command) Method(name string, arg []pl.Val) (pl.Val, error) {
 		}
 		return pl.NewValStr(string(c.args[idx])), nil
 
+	// the argument is returned without going through string, the returned
+	// bytes is a copy since redcon reuses its read buffer
+	case "asBytes":
+		if _, err := methodProtoCommandAsBytes.Check(arg); err != nil {
+			return pl.NewValNull(), err
+		}
+		idx, err := c.toindex("asBytes", arg[0])
+		if err != nil {
+			return pl.NewValNull(), err
+		}
+		b := make([]byte, len(c.args[idx]))
+		copy(b, c.args[idx])
+		return pl.NewValBytes(b), nil
+
 	case "asInt":
 		if _, err := methodProtoCommandAsInt.Check(arg); err != nil {
 			return pl.NewValNull(), err
@@ -382,9 +421,13 @@ func (c *command) NewIterator() (pl.Iter, error) {
 }
 
 func newCommand(raw *redcon.Command) *command {
+	name := strings.ToUpper(string(raw.Args[0]))
+	info, _ := util.CommandLookup(name)
 	return &command{
+		argv: raw.Args,
 		args: raw.Args[1:],
-		name: strings.ToUpper(string(raw.Args[0])),
+		name: name,
+		info: info,
 	}
 }
 
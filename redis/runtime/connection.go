@@ -2,6 +2,10 @@ package runtime
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
 	"github.com/dianpeng/mono-service/pl"
 	"github.com/tidwall/redcon"
 )
@@ -16,6 +20,8 @@ var (
 
 	methodProtoConnWriteError  = pl.MustNewFuncProto("redis.conn.writeError", "%s")
 	methodProtoConnWriteString = pl.MustNewFuncProto("redis.conn.writeString", "%s")
+	methodProtoConnWriteBulk   = pl.MustNewFuncProto("redis.conn.writeBulk", "(%s|%B)")
+	methodProtoConnWriteRaw    = pl.MustNewFuncProto("redis.conn.writeRaw", "(%s|%B)")
 
 	methodProtoConnWriteInt    = pl.MustNewFuncProto("redis.conn.writeInt", "%d")
 	methodProtoConnWriteInt64  = pl.MustNewFuncProto("redis.conn.writeInt64", "%d")
@@ -23,10 +29,37 @@ var (
 
 	methodProtoConnWriteList = pl.MustNewFuncProto("redis.conn.writeList", "%l")
 	methodProtoConnWriteNull = pl.MustNewFuncProto("redis.conn.writeNull", "%0")
+
+	methodProtoConnWriteArray    = pl.MustNewFuncProto("redis.conn.writeArray", "%l")
+	methodProtoConnWriteMap      = pl.MustNewFuncProto("redis.conn.writeMap", "%m")
+	methodProtoConnWriteSet      = pl.MustNewFuncProto("redis.conn.writeSet", "%l")
+	methodProtoConnWritePush     = pl.MustNewFuncProto("redis.conn.writePush", "%l")
+	methodProtoConnWriteDouble   = pl.MustNewFuncProto("redis.conn.writeDouble", "(%f|%d)")
+	methodProtoConnWriteBool     = pl.MustNewFuncProto("redis.conn.writeBool", "%b")
+	methodProtoConnWriteVerbatim = pl.MustNewFuncProto("redis.conn.writeVerbatim", "{%s}{%s%s}")
+	methodProtoConnWriteAny      = pl.MustNewFuncProto("redis.conn.writeAny", "%a")
+	methodProtoConnSetProtocol   = pl.MustNewFuncProto("redis.conn.setProtocol", "%d")
 )
 
+// the redis version reported by HELLO, whose protocol is implemented
+const helloVersion = "7.0.0"
+
+var connId int64
+
 type conn struct {
 	c redcon.Conn
+
+	// script owned map lives as long as the connection, ie conn.context
+	context pl.Val
+
+	id   int64
+	name string
+
+	// user authenticated by AUTH, empty for the public vhost
+	user string
+
+	// RESP protocol negotiated by HELLO
+	resp resp
 }
 
 func ValIsConn(c pl.Val) bool {
@@ -51,9 +84,26 @@ func (c *conn) IndexSet(
 }
 
 func (c *conn) Dot(
-	_ string,
+	name string,
 ) (pl.Val, error) {
-	return pl.NewValNull(), fmt.Errorf("%s index: unsupported operation", c.Id())
+	switch name {
+	case "context":
+		return c.context, nil
+	case "id":
+		return pl.NewValInt64(c.id), nil
+	case "name":
+		return pl.NewValStr(c.name), nil
+	case "protocol":
+		return pl.NewValInt(c.resp.proto), nil
+	case "user":
+		if c.user == "" {
+			return pl.NewValNull(), nil
+		}
+		return pl.NewValStr(c.user), nil
+	default:
+		break
+	}
+	return pl.NewValNull(), fmt.Errorf("%s dot: unknown field %s", c.Id(), name)
 }
 
 func (c *conn) DotSet(
@@ -72,6 +122,11 @@ func (c *conn) ToJSON() (pl.Val, error) {
 		map[string]interface{}{
 			"type":       c.Id(),
 			"remoteAddr": c.Conn().RemoteAddr(),
+			"context":    c.context.ToNative(),
+			"id":         c.id,
+			"name":       c.name,
+			"protocol":   c.resp.proto,
+			"user":       c.user,
 		},
 	)
 }
@@ -125,6 +180,24 @@ func (c *conn) Method(name string, arg []pl.Val) (pl.Val, error) {
 		c.c.WriteString(arg[0].String())
 		return pl.NewValNull(), nil
 
+	case "writeBulk":
+		if _, err := methodProtoConnWriteBulk.Check(arg); err != nil {
+			return pl.NewValNull(), err
+		}
+		b, _ := pl.ToBytes(arg[0])
+		c.c.WriteBulk(b)
+		return pl.NewValNull(), nil
+
+	// raw bytes are written as it is, the script is responsible for the RESP
+	// framing
+	case "writeRaw":
+		if _, err := methodProtoConnWriteRaw.Check(arg); err != nil {
+			return pl.NewValNull(), err
+		}
+		b, _ := pl.ToBytes(arg[0])
+		c.c.WriteRaw(b)
+		return pl.NewValNull(), nil
+
 	case "writeInt":
 		if _, err := methodProtoConnWriteInt.Check(arg); err != nil {
 			return pl.NewValNull(), err
@@ -154,7 +227,7 @@ func (c *conn) Method(name string, arg []pl.Val) (pl.Val, error) {
 		if _, err := methodProtoConnWriteNull.Check(arg); err != nil {
 			return pl.NewValNull(), nil
 		}
-		c.c.WriteNull()
+		c.c.WriteRaw(c.resp.appendNull(nil))
 		return pl.NewValNull(), nil
 
 	case "writeList":
@@ -167,6 +240,10 @@ func (c *conn) Method(name string, arg []pl.Val) (pl.Val, error) {
 		c.c.WriteArray(l)
 		for i := 0; i < l; i++ {
 			v := list.At(i)
+			if v.IsBytes() {
+				c.c.WriteBulk(v.Bytes())
+				continue
+			}
 			str, err := v.ToString()
 			if err != nil {
 				return pl.NewValNull(), fmt.Errorf("%s method writeList: %dth element is not string", c.Id(), i)
@@ -177,6 +254,93 @@ func (c *conn) Method(name string, arg []pl.Val) (pl.Val, error) {
 
 		return pl.NewValNull(), nil
 
+	// the following methods write nested value recursively, see resp.go for
+	// how each type is written under RESP2 and RESP3
+	case "writeArray":
+		if _, err := methodProtoConnWriteArray.Check(arg); err != nil {
+			return pl.NewValNull(), err
+		}
+		l := arg[0].List()
+		b, err := c.resp.appendList(redcon.AppendArray(nil, l.Length()), l)
+		return c.writeEncoded(name, b, err)
+
+	case "writeSet":
+		if _, err := methodProtoConnWriteSet.Check(arg); err != nil {
+			return pl.NewValNull(), err
+		}
+		l := arg[0].List()
+		b, err := c.resp.appendList(c.resp.appendSetHeader(nil, l.Length()), l)
+		return c.writeEncoded(name, b, err)
+
+	case "writePush":
+		if _, err := methodProtoConnWritePush.Check(arg); err != nil {
+			return pl.NewValNull(), err
+		}
+		l := arg[0].List()
+		b, err := c.resp.appendList(c.resp.appendPushHeader(nil, l.Length()), l)
+		return c.writeEncoded(name, b, err)
+
+	case "writeMap":
+		if _, err := methodProtoConnWriteMap.Check(arg); err != nil {
+			return pl.NewValNull(), err
+		}
+		b, err := c.resp.appendMap(nil, arg[0].Map())
+		return c.writeEncoded(name, b, err)
+
+	case "writeAny":
+		if _, err := methodProtoConnWriteAny.Check(arg); err != nil {
+			return pl.NewValNull(), err
+		}
+		b, err := c.resp.appendAny(nil, arg[0])
+		return c.writeEncoded(name, b, err)
+
+	case "writeDouble":
+		if _, err := methodProtoConnWriteDouble.Check(arg); err != nil {
+			return pl.NewValNull(), err
+		}
+		f := float64(0)
+		if arg[0].IsInt() {
+			f = float64(arg[0].Int())
+		} else {
+			f = arg[0].Real()
+		}
+		c.c.WriteRaw(c.resp.appendDouble(nil, f))
+		return pl.NewValNull(), nil
+
+	case "writeBool":
+		if _, err := methodProtoConnWriteBool.Check(arg); err != nil {
+			return pl.NewValNull(), err
+		}
+		c.c.WriteRaw(c.resp.appendBool(nil, arg[0].Bool()))
+		return pl.NewValNull(), nil
+
+	// writeVerbatim(text) or writeVerbatim(format, text), format is txt by
+	// default
+	case "writeVerbatim":
+		if _, err := methodProtoConnWriteVerbatim.Check(arg); err != nil {
+			return pl.NewValNull(), err
+		}
+		format, text := "txt", arg[0].String()
+		if len(arg) == 2 {
+			format, text = arg[0].String(), arg[1].String()
+		}
+		if len(format) != 3 {
+			return pl.NewValNull(), fmt.Errorf("%s method writeVerbatim: format must be 3 characters", c.Id())
+		}
+		c.c.WriteRaw(c.resp.appendVerbatim(nil, format, text))
+		return pl.NewValNull(), nil
+
+	case "setProtocol":
+		if _, err := methodProtoConnSetProtocol.Check(arg); err != nil {
+			return pl.NewValNull(), err
+		}
+		v := int(arg[0].Int())
+		if v != ProtoRESP2 && v != ProtoRESP3 {
+			return pl.NewValNull(), fmt.Errorf("%s method setProtocol: unsupported protocol %d", c.Id(), v)
+		}
+		c.resp.proto = v
+		return pl.NewValNull(), nil
+
 	default:
 		break
 	}
@@ -184,9 +348,78 @@ func (c *conn) Method(name string, arg []pl.Val) (pl.Val, error) {
 	return pl.NewValNull(), fmt.Errorf("%s method %s: unknown method", c.Id(), name)
 }
 
+// the reply is written only when it is fully encoded, so error does not leave
+// a partial reply on the connection
+func (c *conn) writeEncoded(method string, b []byte, err error) (pl.Val, error) {
+	if err != nil {
+		return pl.NewValNull(), fmt.Errorf("%s method %s: %s", c.Id(), method, err.Error())
+	}
+	c.c.WriteRaw(b)
+	return pl.NewValNull(), nil
+}
+
+// HELLO [protover [AUTH username password] [SETNAME clientname]]
+func (c *conn) hello(argv [][]byte) {
+	proto := c.resp.proto
+	name := c.name
+
+	if len(argv) > 1 {
+		v, err := strconv.Atoi(string(argv[1]))
+		if err != nil {
+			c.c.WriteError("ERR Protocol version is not an integer or out of range")
+			return
+		}
+		if v != ProtoRESP2 && v != ProtoRESP3 {
+			c.c.WriteError("NOPROTO unsupported protocol version")
+			return
+		}
+		proto = v
+
+		for i := 2; i < len(argv); i++ {
+			opt := strings.ToUpper(string(argv[i]))
+			switch {
+			case opt == "AUTH" && i+2 < len(argv):
+				// the credential is verified by the vhost before dispatching
+				i += 2
+			case opt == "SETNAME" && i+1 < len(argv):
+				name = string(argv[i+1])
+				i++
+			default:
+				c.c.WriteError(fmt.Sprintf("ERR Syntax error in option '%s'", string(argv[i])))
+				return
+			}
+		}
+	}
+
+	c.resp.proto = proto
+	c.name = name
+
+	b := c.resp.appendMapHeader(nil, 7)
+	b = redcon.AppendBulkString(b, "server")
+	b = redcon.AppendBulkString(b, "mono")
+	b = redcon.AppendBulkString(b, "version")
+	b = redcon.AppendBulkString(b, helloVersion)
+	b = redcon.AppendBulkString(b, "proto")
+	b = redcon.AppendInt(b, int64(proto))
+	b = redcon.AppendBulkString(b, "id")
+	b = redcon.AppendInt(b, c.id)
+	b = redcon.AppendBulkString(b, "mode")
+	b = redcon.AppendBulkString(b, "standalone")
+	b = redcon.AppendBulkString(b, "role")
+	b = redcon.AppendBulkString(b, "master")
+	b = redcon.AppendBulkString(b, "modules")
+	b = redcon.AppendArray(b, 0)
+	c.c.WriteRaw(b)
+}
+
 func newConnection(c redcon.Conn) *conn {
 	return &conn{
-		c: c,
+		c:       c,
+		context: pl.NewValMap(),
+		id:      atomic.AddInt64(&connId, 1),
+		resp: resp{
+			proto: ProtoRESP2,
+		},
 	}
 }
 
@@ -0,0 +1,159 @@
+package runtime
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dianpeng/mono-service/pl"
+	"github.com/dianpeng/mono-service/redis/kv"
+)
+
+// kv:: module exposes the built-in keyspace of the vhost to the script
+//
+//   kv::<command>(args...) executes the command, ie kv::hset("h", "f", 1),
+//                          and returns the reply as PL value
+//   kv::fallthrough($)     executes the command been handled by the rule with
+//                          the built-in behavior and writes the reply back,
+//                          HELLO negotiates the protocol of the connection
+//                          and pub/sub command is served by the broker.
+//                          Without keyspace, the command is replied as
+//                          unknown command
+//   kv::save()             saves the keyspace into the snapshot file
+
+const kvPrefix = "kv::"
+
+func kvArg(v pl.Val) ([]byte, error) {
+	if v.IsBytes() {
+		return v.Bytes(), nil
+	}
+	str, err := v.ToString()
+	if err != nil {
+		return nil, err
+	}
+	return []byte(str), nil
+}
+
+func kvReplyVal(v interface{}) pl.Val {
+	switch x := v.(type) {
+	case kv.Status:
+		return pl.NewValStr(string(x))
+	case []byte:
+		// bulk reply is binary safe
+		return pl.NewValBytes(x)
+	case int64:
+		return pl.NewValInt64(x)
+	case []interface{}:
+		l := pl.NewValList()
+		for _, e := range x {
+			l.AddList(kvReplyVal(e))
+		}
+		return l
+	default:
+		return pl.NewValNull()
+	}
+}
+
+func (h *Runtime) getKeyspace() (*kv.Keyspace, error) {
+	if h.resource == nil {
+		return nil, fmt.Errorf("keyspace is not setup")
+	}
+	ks := h.resource.Keyspace()
+	if ks == nil {
+		return nil, fmt.Errorf("keyspace is not setup")
+	}
+	return ks, nil
+}
+
+func (h *Runtime) kvExec(cmd string, args []pl.Val) (pl.Val, error) {
+	ks, err := h.getKeyspace()
+	if err != nil {
+		return pl.NewValNull(), fmt.Errorf("kv::%s: %s", cmd, err.Error())
+	}
+
+	argv := make([][]byte, 0, len(args)+1)
+	argv = append(argv, []byte(cmd))
+	for i, a := range args {
+		b, err := kvArg(a)
+		if err != nil {
+			return pl.NewValNull(), fmt.Errorf("kv::%s: %dth argument %s", cmd, i, err.Error())
+		}
+		argv = append(argv, b)
+	}
+
+	reply, err := ks.Exec(argv)
+	if err != nil {
+		return pl.NewValNull(), fmt.Errorf("kv::%s: %s", cmd, err.Error())
+	}
+	return kvReplyVal(reply), nil
+}
+
+func (h *Runtime) kvFallthrough(args []pl.Val) (pl.Val, error) {
+	if len(args) != 1 || !ValIsCommand(args[0]) {
+		return pl.NewValNull(), fmt.Errorf("kv::fallthrough: expect the redis command, ie $")
+	}
+	if !ValIsConn(h.conn) {
+		return pl.NewValNull(), fmt.Errorf("kv::fallthrough: connection is not setup")
+	}
+
+	cmd := args[0].Usr().(*command)
+	c := h.conn.Usr().(*conn)
+	if cmd.name == "HELLO" {
+		c.hello(cmd.argv)
+		return pl.NewValNull(), nil
+	}
+	if h.resource.PubSub(c.Conn(), cmd.argv) {
+		return pl.NewValNull(), nil
+	}
+
+	// without keyspace the command is unknown to the vhost, which is replied
+	// as redis does instead of failing the rule
+	ks, err := h.getKeyspace()
+	if err != nil {
+		c.Conn().WriteError(fmt.Sprintf("ERR unknown command '%s'", string(cmd.argv[0])))
+		return pl.NewValNull(), nil
+	}
+	reply, err := ks.Exec(cmd.argv)
+	kv.WriteReply(c.Conn(), reply, err)
+	return pl.NewValNull(), nil
+}
+
+func (h *Runtime) kvSave(args []pl.Val) (pl.Val, error) {
+	if len(args) != 0 {
+		return pl.NewValNull(), fmt.Errorf("kv::save: expect no argument")
+	}
+	ks, err := h.getKeyspace()
+	if err != nil {
+		return pl.NewValNull(), fmt.Errorf("kv::save: %s", err.Error())
+	}
+	if err := ks.Snapshot(); err != nil {
+		return pl.NewValNull(), fmt.Errorf("kv::save: %s", err.Error())
+	}
+	return pl.NewValNull(), nil
+}
+
+func (h *Runtime) loadKVVar(n string) (pl.Val, bool) {
+	if !strings.HasPrefix(n, kvPrefix) {
+		return pl.NewValNull(), false
+	}
+	name := n[len(kvPrefix):]
+
+	switch name {
+	case "fallthrough":
+		return pl.NewValNativeFunction(n, h.kvFallthrough), true
+	case "save":
+		return pl.NewValNativeFunction(n, h.kvSave), true
+	default:
+		break
+	}
+
+	cmd := strings.ToUpper(name)
+	if !kv.HasCommand(cmd) {
+		return pl.NewValNull(), false
+	}
+	return pl.NewValNativeFunction(
+		n,
+		func(args []pl.Val) (pl.Val, error) {
+			return h.kvExec(cmd, args)
+		},
+	), true
+}
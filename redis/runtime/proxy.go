@@ -0,0 +1,283 @@
+package runtime
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dianpeng/mono-service/pl"
+	"github.com/dianpeng/mono-service/redis/proxy"
+	"github.com/tidwall/redcon"
+)
+
+// proxy:: module exposes the backends of the proxy vhost to the script
+//
+//   proxy::<command>(args...) forwards the command, ie proxy::get("a"), and
+//                             returns the reply as PL value
+//   proxy::forward($)         forwards the command been handled by the rule
+//                             and writes the reply back as it is, HELLO and
+//                             pub/sub command are served locally and never
+//                             forwarded
+//
+// The command without rule is forwarded by Forward, which is hooked by the
+// following rules if they are defined
+//
+//   redis.@forward, $ is the command, before forwarding. The rule can
+//     deny => "ERR message"    reply the error without forwarding
+//     reply => value           reply the value without forwarding, ie cache
+//     rewrite => ["SET", ...]  forward the rewritten command instead
+//
+//   redis.@reply, $ is a map of command, reply and error, after forwarding.
+//   The rule can
+//     reply => value           reply the value instead of the backend reply
+
+const (
+	proxyPrefix = "proxy::"
+
+	EventForward = "redis.@forward"
+	EventReply   = "redis.@reply"
+
+	actionDeny    = "deny"
+	actionReply   = "reply"
+	actionRewrite = "rewrite"
+)
+
+// RESP reply to PL value, the error reply is returned as error
+func respVal(raw []byte) (pl.Val, error) {
+	_, resp := redcon.ReadNextRESP(raw)
+	return respToVal(resp)
+}
+
+func respToVal(resp redcon.RESP) (pl.Val, error) {
+	switch resp.Type {
+	case redcon.Error:
+		return pl.NewValNull(), fmt.Errorf("%s", string(resp.Data))
+	case redcon.Integer:
+		v, err := strconv.ParseInt(string(resp.Data), 10, 64)
+		if err != nil {
+			return pl.NewValNull(), err
+		}
+		return pl.NewValInt64(v), nil
+	case redcon.Bulk:
+		if resp.Data == nil {
+			return pl.NewValNull(), nil
+		}
+		return pl.NewValStr(string(resp.Data)), nil
+	case redcon.Array:
+		if resp.Count < 0 {
+			return pl.NewValNull(), nil
+		}
+		l := pl.NewValList()
+		var err error
+		resp.ForEach(func(x redcon.RESP) bool {
+			var v pl.Val
+			if v, err = respToVal(x); err != nil {
+				return false
+			}
+			l.AddList(v)
+			return true
+		})
+		return l, err
+	default:
+		return pl.NewValStr(string(resp.Data)), nil
+	}
+}
+
+func proxyArgv(cmd string, args []pl.Val) ([][]byte, error) {
+	argv := make([][]byte, 0, len(args)+1)
+	argv = append(argv, []byte(cmd))
+	for i, a := range args {
+		b, err := kvArg(a)
+		if err != nil {
+			return nil, fmt.Errorf("%dth argument %s", i, err.Error())
+		}
+		argv = append(argv, b)
+	}
+	return argv, nil
+}
+
+func (h *Runtime) getProxy() (*proxy.Proxy, error) {
+	if h.resource == nil {
+		return nil, fmt.Errorf("proxy is not setup")
+	}
+	p := h.resource.Proxy()
+	if p == nil {
+		return nil, fmt.Errorf("proxy is not setup")
+	}
+	return p, nil
+}
+
+func (h *Runtime) proxyExec(cmd string, args []pl.Val) (pl.Val, error) {
+	p, err := h.getProxy()
+	if err != nil {
+		return pl.NewValNull(), fmt.Errorf("proxy::%s: %s", cmd, err.Error())
+	}
+	argv, err := proxyArgv(cmd, args)
+	if err != nil {
+		return pl.NewValNull(), fmt.Errorf("proxy::%s: %s", cmd, err.Error())
+	}
+	raw, err := p.Do(argv)
+	if err != nil {
+		return pl.NewValNull(), fmt.Errorf("proxy::%s: %s", cmd, err.Error())
+	}
+	v, err := respVal(raw)
+	if err != nil {
+		return pl.NewValNull(), fmt.Errorf("proxy::%s: %s", cmd, err.Error())
+	}
+	return v, nil
+}
+
+func (h *Runtime) proxyForward(args []pl.Val) (pl.Val, error) {
+	if len(args) != 1 || !ValIsCommand(args[0]) {
+		return pl.NewValNull(), fmt.Errorf("proxy::forward: expect the redis command, ie $")
+	}
+	if !ValIsConn(h.conn) {
+		return pl.NewValNull(), fmt.Errorf("proxy::forward: connection is not setup")
+	}
+	p, err := h.getProxy()
+	if err != nil {
+		return pl.NewValNull(), fmt.Errorf("proxy::forward: %s", err.Error())
+	}
+
+	cmd := args[0].Usr().(*command)
+	c := h.conn.Usr().(*conn)
+	if cmd.name == "HELLO" {
+		c.hello(cmd.argv)
+		return pl.NewValNull(), nil
+	}
+	if h.resource.PubSub(c.Conn(), cmd.argv) {
+		return pl.NewValNull(), nil
+	}
+	raw, err := p.Do(cmd.argv)
+	if err != nil {
+		return pl.NewValNull(), fmt.Errorf("proxy::forward: %s", err.Error())
+	}
+	c.Conn().WriteRaw(raw)
+	return pl.NewValNull(), nil
+}
+
+func (h *Runtime) loadProxyVar(n string) (pl.Val, bool) {
+	if !strings.HasPrefix(n, proxyPrefix) {
+		return pl.NewValNull(), false
+	}
+	name := n[len(proxyPrefix):]
+
+	if name == "forward" {
+		return pl.NewValNativeFunction(n, h.proxyForward), true
+	}
+
+	cmd := strings.ToUpper(name)
+	return pl.NewValNativeFunction(
+		n,
+		func(args []pl.Val) (pl.Val, error) {
+			return h.proxyExec(cmd, args)
+		},
+	), true
+}
+
+// emit the hook rule and collect its actions
+func (h *Runtime) emitHook(event string, context pl.Val) (map[string]pl.Val, error) {
+	h.hookAction = make(map[string]pl.Val)
+	defer func() {
+		h.hookAction = nil
+	}()
+
+	if _, err := h.Emit(event, context); err != nil {
+		return nil, err
+	}
+	return h.hookAction, nil
+}
+
+func (h *Runtime) onHookAction(n string, v pl.Val) bool {
+	if h.hookAction == nil {
+		return false
+	}
+	switch n {
+	case actionDeny, actionReply, actionRewrite:
+		h.hookAction[n] = v
+		return true
+	default:
+		return false
+	}
+}
+
+func (h *Runtime) writeReplyAction(c *conn, event string, v pl.Val) error {
+	b, err := c.resp.appendAny(nil, v)
+	if err != nil {
+		return fmt.Errorf("%s: reply %s", event, err.Error())
+	}
+	c.Conn().WriteRaw(b)
+	return nil
+}
+
+// Forward the command via the proxy and write the reply back, hooked by the
+// redis.@forward and redis.@reply rule
+func (h *Runtime) Forward(cmdVal pl.Val) error {
+	p, err := h.getProxy()
+	if err != nil {
+		return err
+	}
+	if !ValIsConn(h.conn) {
+		return fmt.Errorf("connection is not setup")
+	}
+	cc := h.conn.Usr().(*conn)
+	c := cc.Conn()
+	argv := cmdVal.Usr().(*command).argv
+
+	if h.Module.HasEvent(EventForward) {
+		act, err := h.emitHook(EventForward, cmdVal)
+		if err != nil {
+			return err
+		}
+		if v, ok := act[actionDeny]; ok {
+			c.WriteError(v.String())
+			return nil
+		}
+		if v, ok := act[actionReply]; ok {
+			return h.writeReplyAction(cc, EventForward, v)
+		}
+		if v, ok := act[actionRewrite]; ok {
+			if !v.IsList() || v.List().Length() == 0 {
+				return fmt.Errorf("%s: rewrite expects a non-empty list", EventForward)
+			}
+			l := v.List()
+			argv = make([][]byte, 0, l.Length())
+			for i := 0; i < l.Length(); i++ {
+				b, err := kvArg(l.At(i))
+				if err != nil {
+					return fmt.Errorf("%s: rewrite %dth element %s", EventForward, i, err.Error())
+				}
+				argv = append(argv, b)
+			}
+		}
+	}
+
+	raw, fwdErr := p.Do(argv)
+	if fwdErr != nil {
+		raw = redcon.AppendError(nil, "ERR proxy: "+fwdErr.Error())
+	}
+
+	if h.Module.HasEvent(EventReply) {
+		ctx := pl.NewValMap()
+		ctx.AddMap("command", cmdVal)
+
+		reply, err := respVal(raw)
+		ctx.AddMap("reply", reply)
+		if err != nil {
+			ctx.AddMap("error", pl.NewValStr(err.Error()))
+		} else {
+			ctx.AddMap("error", pl.NewValNull())
+		}
+
+		act, err := h.emitHook(EventReply, ctx)
+		if err != nil {
+			return err
+		}
+		if v, ok := act[actionReply]; ok {
+			return h.writeReplyAction(cc, EventReply, v)
+		}
+	}
+
+	c.WriteRaw(raw)
+	return nil
+}
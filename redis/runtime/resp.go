@@ -0,0 +1,179 @@
+package runtime
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/dianpeng/mono-service/pl"
+	"github.com/tidwall/redcon"
+)
+
+// RESP protocol of the connection, negotiated by HELLO. Connection starts with
+// RESP2, and the RESP3 only types are written as their RESP2 counterpart when
+// RESP3 is not negotiated
+//
+//   RESP3      RESP2
+//   map        flat array of key and value
+//   set        array
+//   push       array
+//   double     bulk string
+//   boolean    integer 1 or 0
+//   verbatim   bulk string without the format
+//   null       null bulk string
+
+const (
+	ProtoRESP2 = 2
+	ProtoRESP3 = 3
+)
+
+type resp struct {
+	proto int
+}
+
+func (r resp) is3() bool {
+	return r.proto == ProtoRESP3
+}
+
+func (r resp) appendNull(b []byte) []byte {
+	if r.is3() {
+		return append(b, '_', '\r', '\n')
+	}
+	return redcon.AppendNull(b)
+}
+
+func (r resp) appendBool(b []byte, v bool) []byte {
+	if r.is3() {
+		if v {
+			return append(b, '#', 't', '\r', '\n')
+		}
+		return append(b, '#', 'f', '\r', '\n')
+	}
+	if v {
+		return redcon.AppendInt(b, 1)
+	}
+	return redcon.AppendInt(b, 0)
+}
+
+func formatDouble(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	case math.IsNaN(f):
+		return "nan"
+	default:
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+}
+
+func (r resp) appendDouble(b []byte, f float64) []byte {
+	if r.is3() {
+		b = append(b, ',')
+		b = append(b, formatDouble(f)...)
+		return append(b, '\r', '\n')
+	}
+	return redcon.AppendBulkString(b, formatDouble(f))
+}
+
+// format is 3 bytes, ie txt or mkd
+func (r resp) appendVerbatim(b []byte, format string, text string) []byte {
+	if r.is3() {
+		b = append(b, '=')
+		b = strconv.AppendInt(b, int64(len(text)+4), 10)
+		b = append(b, '\r', '\n')
+		b = append(b, format...)
+		b = append(b, ':')
+		b = append(b, text...)
+		return append(b, '\r', '\n')
+	}
+	return redcon.AppendBulkString(b, text)
+}
+
+func (r resp) appendAggregate(b []byte, kind byte, n int) []byte {
+	if r.is3() {
+		b = append(b, kind)
+		b = strconv.AppendInt(b, int64(n), 10)
+		return append(b, '\r', '\n')
+	}
+	return redcon.AppendArray(b, n)
+}
+
+// number of pairs of the map
+func (r resp) appendMapHeader(b []byte, n int) []byte {
+	if r.is3() {
+		return r.appendAggregate(b, '%', n)
+	}
+	return redcon.AppendArray(b, n*2)
+}
+
+func (r resp) appendSetHeader(b []byte, n int) []byte {
+	return r.appendAggregate(b, '~', n)
+}
+
+func (r resp) appendPushHeader(b []byte, n int) []byte {
+	return r.appendAggregate(b, '>', n)
+}
+
+func (r resp) appendList(b []byte, l *pl.List) ([]byte, error) {
+	var err error
+	for i := 0; i < l.Length(); i++ {
+		if b, err = r.appendAny(b, l.At(i)); err != nil {
+			return nil, fmt.Errorf("%dth element %s", i, err.Error())
+		}
+	}
+	return b, nil
+}
+
+func (r resp) appendMap(b []byte, m *pl.Map) ([]byte, error) {
+	var err error
+	b = r.appendMapHeader(b, m.Length())
+	m.ForeachOrdered(func(k string, v pl.Val) bool {
+		b = redcon.AppendBulkString(b, k)
+		if b, err = r.appendAny(b, v); err != nil {
+			err = fmt.Errorf("field %s %s", k, err.Error())
+			return false
+		}
+		return true
+	})
+	return b, err
+}
+
+// PL value to RESP recursively, string is written as bulk string since it is
+// binary safe
+func (r resp) appendAny(b []byte, v pl.Val) ([]byte, error) {
+	switch {
+	case v.IsNull():
+		return r.appendNull(b), nil
+	case v.IsBool():
+		return r.appendBool(b, v.Bool()), nil
+	case v.IsInt():
+		return redcon.AppendInt(b, v.Int()), nil
+	case v.IsReal():
+		return r.appendDouble(b, v.Real()), nil
+	case v.IsString():
+		return redcon.AppendBulkString(b, v.String()), nil
+	case v.IsBytes():
+		return redcon.AppendBulk(b, v.Bytes()), nil
+	case v.IsPair():
+		p := v.Pair()
+		b = redcon.AppendArray(b, 2)
+		b, err := r.appendAny(b, p.First)
+		if err != nil {
+			return nil, err
+		}
+		return r.appendAny(b, p.Second)
+	case v.IsList():
+		l := v.List()
+		return r.appendList(redcon.AppendArray(b, l.Length()), l)
+	case v.IsMap():
+		return r.appendMap(b, v.Map())
+	default:
+		str, err := v.ToString()
+		if err != nil {
+			return nil, fmt.Errorf("type %s cannot be written as RESP", v.Id())
+		}
+		return redcon.AppendBulkString(b, str), nil
+	}
+}
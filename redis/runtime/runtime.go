@@ -7,11 +7,29 @@ import (
 	"github.com/dianpeng/mono-service/alog"
 	"github.com/dianpeng/mono-service/hpl"
 	"github.com/dianpeng/mono-service/pl"
+	"github.com/dianpeng/mono-service/pubsub"
+	"github.com/dianpeng/mono-service/redis/kv"
+	"github.com/dianpeng/mono-service/redis/proxy"
+	"github.com/tidwall/redcon"
 )
 
 type Resource interface {
 	// special function used for exposing other utilities
 	hpl.HttpClientFactory
+
+	// built-in keyspace of the vhost, backs the kv:: module
+	Keyspace() *kv.Keyspace
+
+	// backends of the proxy vhost, backs the proxy:: module
+	Proxy() *proxy.Proxy
+
+	// pub/sub broker of the vhost, backs pubsub::publish. Nil if the broker is
+	// not available
+	Broker() *pubsub.Broker
+
+	// executes the pub/sub command with the broker, false if argv is not a
+	// pub/sub command
+	PubSub(conn redcon.Conn, argv [][]byte) bool
 }
 
 type Runtime struct {
@@ -21,6 +39,9 @@ type Runtime struct {
 	conn     pl.Val
 	log      pl.Val
 	resource Resource
+
+	// actions of the redis.@forward and redis.@reply rule, see Forward
+	hookAction map[string]pl.Val
 }
 
 func NewRuntime() *Runtime {
@@ -53,6 +74,13 @@ func (h *Runtime) getHttpClientFactory() hpl.HttpClientFactory {
 	return h.resource
 }
 
+func (h *Runtime) getBroker() *pubsub.Broker {
+	if h.resource == nil {
+		return nil
+	}
+	return h.resource.Broker()
+}
+
 func (h *Runtime) fnHttp(args []pl.Val,
 	entry func(hpl.HttpClientFactory, []pl.Val) (pl.Val, error)) (pl.Val, error) {
 
@@ -89,11 +117,22 @@ func (p *Runtime) loadFnVar(_ *pl.Evaluator, n string) (pl.Val, bool) {
 			},
 		), true
 
+	case "pubsub::publish":
+		return pl.NewValNativeFunction(
+			"pubsub::publish",
+			func(args []pl.Val) (pl.Val, error) {
+				return pubsub.FnPublish(p.getBroker(), args)
+			},
+		), true
+
 	default:
 		break
 	}
 
-	return pl.NewValNull(), false
+	if v, ok := p.loadKVVar(n); ok {
+		return v, true
+	}
+	return p.loadProxyVar(n)
 }
 
 func (p *Runtime) loadVar(
@@ -128,8 +167,11 @@ func (p *Runtime) storeVar(
 func (p *Runtime) action(
 	_ *pl.Evaluator,
 	n string,
-	_ pl.Val,
+	v pl.Val,
 ) error {
+	if p.onHookAction(n, v) {
+		return nil
+	}
 	return fmt.Errorf("Runtime: action %s is unknown", n)
 }
 
@@ -218,6 +260,71 @@ func (h *Runtime) OnInit(
 	return h.Eval.EvalSession(h.Module)
 }
 
+// OnCommand reuses the session evaluated by OnInit, ie the connection is bound
+// to the runtime from redis.@accept to redis.@close, and only the access log
+// is refreshed for each command
+func (h *Runtime) OnCommand(
+	log *alog.Log,
+) error {
+	if h.Module == nil {
+		return fmt.Errorf("Runtime engine does not have any module binded")
+	}
+	h.log = hpl.NewAccessLogVal(log)
+	return nil
+}
+
+// Rebind the connection to the detached one after the connection subscribes,
+// the session and conn.context are kept
+func (h *Runtime) Rebind(c redcon.Conn) {
+	if ValIsConn(h.conn) {
+		h.conn.Usr().(*conn).c = c
+	}
+}
+
+// Protocol is the RESP protocol negotiated by HELLO
+func (h *Runtime) Protocol() int {
+	if ValIsConn(h.conn) {
+		return h.conn.Usr().(*conn).resp.proto
+	}
+	return ProtoRESP2
+}
+
+// Negotiated returns the RESP protocol and the name negotiated by HELLO
+func (h *Runtime) Negotiated() (int, string) {
+	if ValIsConn(h.conn) {
+		c := h.conn.Usr().(*conn)
+		return c.resp.proto, c.name
+	}
+	return ProtoRESP2, ""
+}
+
+// Negotiate restores the RESP protocol and the name of the connection, ie the
+// connection switches to another vhost by AUTH
+func (h *Runtime) Negotiate(proto int, name string) {
+	if ValIsConn(h.conn) {
+		c := h.conn.Usr().(*conn)
+		c.resp.proto = proto
+		c.name = name
+	}
+}
+
+// SetUser records the user the connection is authenticated as, ie conn.user
+func (h *Runtime) SetUser(name string) {
+	if ValIsConn(h.conn) {
+		h.conn.Usr().(*conn).user = name
+	}
+}
+
+// Hello negotiates the RESP protocol of the connection, the HELLO command
+// without rule is handled by it
+func (h *Runtime) Hello(argv [][]byte) error {
+	if !ValIsConn(h.conn) {
+		return fmt.Errorf("connection is not setup")
+	}
+	h.conn.Usr().(*conn).hello(argv)
+	return nil
+}
+
 func (h *Runtime) Emit(
 	name string,
 	context pl.Val,
@@ -21,8 +21,13 @@ const (
 	RedisCommandUnknown
 )
 
-type CommandInfo struct {
-}
+const (
+	flagW = CommandFlagWrite
+	flagR = CommandFlagReadOnly
+	flagA = CommandFlagAdmin
+	flagB = CommandFlagBlocking
+	flagP = CommandFlagPubSub
+)
 
 type commandInfoMap map[string]CommandInfo
 
@@ -34,242 +39,241 @@ type commandInfoMapStruct struct {
 var commandInfoMapList []commandInfoMapStruct
 
 var redisCommandBitmap = commandInfoMap{
-	"BITCOUNT":    CommandInfo{},
-	"BITFIELD":    CommandInfo{},
-	"BITFIELD_RO": CommandInfo{},
-	"BITOP":       CommandInfo{},
-	"BITOPS":      CommandInfo{},
-	"GETBIT":      CommandInfo{},
-	"SETBIT":      CommandInfo{},
+	"BITCOUNT":    info(-2, flagR, 1, 1, 1),
+	"BITFIELD":    info(-2, flagW, 1, 1, 1),
+	"BITFIELD_RO": info(-2, flagR, 1, 1, 1),
+	"BITOP":       info(-4, flagW, 2, -1, 1),
+	"BITPOS":      info(-3, flagR, 1, 1, 1),
+	"GETBIT":      info(3, flagR, 1, 1, 1),
+	"SETBIT":      info(4, flagW, 1, 1, 1),
 }
 
 var redisCommandGeneric = commandInfoMap{
-	"COPY":        CommandInfo{},
-	"DEL":         CommandInfo{},
-	"DUMP":        CommandInfo{},
-	"EXISTS":      CommandInfo{},
-	"EXPIRE":      CommandInfo{},
-	"EXPIREAT":    CommandInfo{},
-	"EXPIRETIME":  CommandInfo{},
-	"KEYS":        CommandInfo{},
-	"MIGRATE":     CommandInfo{},
-	"MOVE":        CommandInfo{},
-	"OBJECT":      CommandInfo{},
-	"PERSISTENT":  CommandInfo{},
-	"PEXPIRE":     CommandInfo{},
-	"PEXPIREAT":   CommandInfo{},
-	"PEXPIRETIME": CommandInfo{},
-	"PTTL":        CommandInfo{},
-	"RANDOMKEY":   CommandInfo{},
-	"RENAME":      CommandInfo{},
-	"RENAMENX":    CommandInfo{},
-	"RESTORE":     CommandInfo{},
-	"SCAN":        CommandInfo{},
-	"SORT":        CommandInfo{},
-	"SORT_RO":     CommandInfo{},
-	"TOUCH":       CommandInfo{},
-	"TTL":         CommandInfo{},
-	"TYPE":        CommandInfo{},
-	"UNLINK":      CommandInfo{},
-	"WAIT":        CommandInfo{},
+	"COPY":        info(-3, flagW, 1, 2, 1),
+	"DEL":         info(-2, flagW, 1, -1, 1),
+	"DUMP":        info(2, flagR, 1, 1, 1),
+	"EXISTS":      info(-2, flagR, 1, -1, 1),
+	"EXPIRE":      info(-3, flagW, 1, 1, 1),
+	"EXPIREAT":    info(-3, flagW, 1, 1, 1),
+	"EXPIRETIME":  info(2, flagR, 1, 1, 1),
+	"KEYS":        info(2, flagR, 0, 0, 0),
+	"MIGRATE":     info(-6, flagW, 3, 3, 1),
+	"MOVE":        info(3, flagW, 1, 1, 1),
+	"OBJECT":      info(-2, flagR, 2, 2, 1),
+	"PERSIST":     info(2, flagW, 1, 1, 1),
+	"PEXPIRE":     info(-3, flagW, 1, 1, 1),
+	"PEXPIREAT":   info(-3, flagW, 1, 1, 1),
+	"PEXPIRETIME": info(2, flagR, 1, 1, 1),
+	"PTTL":        info(2, flagR, 1, 1, 1),
+	"RANDOMKEY":   info(1, flagR, 0, 0, 0),
+	"RENAME":      info(3, flagW, 1, 2, 1),
+	"RENAMENX":    info(3, flagW, 1, 2, 1),
+	"RESTORE":     info(-4, flagW, 1, 1, 1),
+	"SCAN":        info(-2, flagR, 0, 0, 0),
+	"SORT":        info(-2, flagW, 1, 1, 1),
+	"SORT_RO":     info(-2, flagR, 1, 1, 1),
+	"TOUCH":       info(-2, flagR, 1, -1, 1),
+	"TTL":         info(2, flagR, 1, 1, 1),
+	"TYPE":        info(2, flagR, 1, 1, 1),
+	"UNLINK":      info(-2, flagW, 1, -1, 1),
+	"WAIT":        info(3, 0, 0, 0, 0),
 }
 
 var redisCommandGeo = commandInfoMap{
-	"GEOADD":               CommandInfo{},
-	"GEODIST":              CommandInfo{},
-	"GEOHASH":              CommandInfo{},
-	"GEOPOS":               CommandInfo{},
-	"GEORADIUS":            CommandInfo{},
-	"GEORADIUS_RO":         CommandInfo{},
-	"GEORADIUSBYMEMBER":    CommandInfo{},
-	"GEORADIUSBYMEMBER_RO": CommandInfo{},
-	"GEOSERACH":            CommandInfo{},
-	"GEOSEARCHSTORE":       CommandInfo{},
+	"GEOADD":               info(-5, flagW, 1, 1, 1),
+	"GEODIST":              info(-4, flagR, 1, 1, 1),
+	"GEOHASH":              info(-2, flagR, 1, 1, 1),
+	"GEOPOS":               info(-2, flagR, 1, 1, 1),
+	"GEORADIUS":            info(-6, flagW, 1, 1, 1),
+	"GEORADIUS_RO":         info(-6, flagR, 1, 1, 1),
+	"GEORADIUSBYMEMBER":    info(-5, flagW, 1, 1, 1),
+	"GEORADIUSBYMEMBER_RO": info(-5, flagR, 1, 1, 1),
+	"GEOSEARCH":            info(-7, flagR, 1, 1, 1),
+	"GEOSEARCHSTORE":       info(-8, flagW, 1, 2, 1),
 }
 
 var redisCommandHash = commandInfoMap{
-
-	"HDEL":         CommandInfo{},
-	"HEXISTS":      CommandInfo{},
-	"HGET":         CommandInfo{},
-	"HGETALL":      CommandInfo{},
-	"HINCRBY":      CommandInfo{},
-	"HINCRBYFLOAT": CommandInfo{},
-	"HKEYS":        CommandInfo{},
-	"HLEN":         CommandInfo{},
-	"HMGET":        CommandInfo{},
-	"HMSET":        CommandInfo{},
-	"HRANDFIELD":   CommandInfo{},
-	"HSCAN":        CommandInfo{},
-	"HSET":         CommandInfo{},
-	"HSETNX":       CommandInfo{},
-	"HSTRLEN":      CommandInfo{},
-	"HVALS":        CommandInfo{},
+	"HDEL":         info(-3, flagW, 1, 1, 1),
+	"HEXISTS":      info(3, flagR, 1, 1, 1),
+	"HGET":         info(3, flagR, 1, 1, 1),
+	"HGETALL":      info(2, flagR, 1, 1, 1),
+	"HINCRBY":      info(4, flagW, 1, 1, 1),
+	"HINCRBYFLOAT": info(4, flagW, 1, 1, 1),
+	"HKEYS":        info(2, flagR, 1, 1, 1),
+	"HLEN":         info(2, flagR, 1, 1, 1),
+	"HMGET":        info(-3, flagR, 1, 1, 1),
+	"HMSET":        info(-4, flagW, 1, 1, 1),
+	"HRANDFIELD":   info(-2, flagR, 1, 1, 1),
+	"HSCAN":        info(-3, flagR, 1, 1, 1),
+	"HSET":         info(-4, flagW, 1, 1, 1),
+	"HSETNX":       info(4, flagW, 1, 1, 1),
+	"HSTRLEN":      info(3, flagR, 1, 1, 1),
+	"HVALS":        info(2, flagR, 1, 1, 1),
 }
 
 var redisCommandHyperLogLog = commandInfoMap{
-	"PFADD":      CommandInfo{},
-	"PFCOUNT":    CommandInfo{},
-	"PFDEBUG":    CommandInfo{},
-	"PFMERGE":    CommandInfo{},
-	"PFSELFTEST": CommandInfo{},
+	"PFADD":      info(-2, flagW, 1, 1, 1),
+	"PFCOUNT":    info(-2, flagR, 1, -1, 1),
+	"PFDEBUG":    info(3, flagW|flagA, 2, 2, 1),
+	"PFMERGE":    info(-2, flagW, 1, -1, 1),
+	"PFSELFTEST": info(1, flagA, 0, 0, 0),
 }
 
 var redisCommandList = commandInfoMap{
-	"BLMOVE":     CommandInfo{},
-	"BLMPOP":     CommandInfo{},
-	"BLPOP":      CommandInfo{},
-	"BRPOP":      CommandInfo{},
-	"BRPOPFLUSH": CommandInfo{},
-	"LINDEX":     CommandInfo{},
-	"LINSERT":    CommandInfo{},
-	"LLEN":       CommandInfo{},
-	"LMOVE":      CommandInfo{},
-	"LMPOP":      CommandInfo{},
-	"LPOP":       CommandInfo{},
-	"LPOS":       CommandInfo{},
-	"LPUSH":      CommandInfo{},
-	"LPUSHX":     CommandInfo{},
-	"LRANGE":     CommandInfo{},
-	"LREM":       CommandInfo{},
-	"LSET":       CommandInfo{},
-	"LTRIM":      CommandInfo{},
-	"RPOP":       CommandInfo{},
-	"RPOPLPUSH":  CommandInfo{},
-	"RPUSH":      CommandInfo{},
-	"RPUSHX":     CommandInfo{},
+	"BLMOVE":     info(6, flagW|flagB, 1, 2, 1),
+	"BLMPOP":     info(-5, flagW|flagB, 0, 0, 0).withNumKeys(2),
+	"BLPOP":      info(-3, flagW|flagB, 1, -2, 1),
+	"BRPOP":      info(-3, flagW|flagB, 1, -2, 1),
+	"BRPOPLPUSH": info(4, flagW|flagB, 1, 2, 1),
+	"LINDEX":     info(3, flagR, 1, 1, 1),
+	"LINSERT":    info(5, flagW, 1, 1, 1),
+	"LLEN":       info(2, flagR, 1, 1, 1),
+	"LMOVE":      info(5, flagW, 1, 2, 1),
+	"LMPOP":      info(-4, flagW, 0, 0, 0).withNumKeys(1),
+	"LPOP":       info(-2, flagW, 1, 1, 1),
+	"LPOS":       info(-3, flagR, 1, 1, 1),
+	"LPUSH":      info(-3, flagW, 1, 1, 1),
+	"LPUSHX":     info(-3, flagW, 1, 1, 1),
+	"LRANGE":     info(4, flagR, 1, 1, 1),
+	"LREM":       info(4, flagW, 1, 1, 1),
+	"LSET":       info(4, flagW, 1, 1, 1),
+	"LTRIM":      info(4, flagW, 1, 1, 1),
+	"RPOP":       info(-2, flagW, 1, 1, 1),
+	"RPOPLPUSH":  info(3, flagW, 1, 2, 1),
+	"RPUSH":      info(-3, flagW, 1, 1, 1),
+	"RPUSHX":     info(-3, flagW, 1, 1, 1),
 }
 
 var redisCommandPubSub = commandInfoMap{
-	"PSUBSCRIBE":   CommandInfo{},
-	"PUBLISH":      CommandInfo{},
-	"PUBSUB":       CommandInfo{},
-	"PUNSUBSCRIBE": CommandInfo{},
-	"SPUBLISH":     CommandInfo{},
-	"SSUBSCRIBE":   CommandInfo{},
-	"SUBSCRIBE":    CommandInfo{},
-	"SUNSUBSCRIBE": CommandInfo{},
-	"UNSUBSCRIBE":  CommandInfo{},
+	"PSUBSCRIBE":   info(-2, flagP, 0, 0, 0),
+	"PUBLISH":      info(3, flagP, 0, 0, 0),
+	"PUBSUB":       info(-2, flagP, 0, 0, 0),
+	"PUNSUBSCRIBE": info(-1, flagP, 0, 0, 0),
+	"SPUBLISH":     info(3, flagP, 1, 1, 1),
+	"SSUBSCRIBE":   info(-2, flagP, 1, -1, 1),
+	"SUBSCRIBE":    info(-2, flagP, 0, 0, 0),
+	"SUNSUBSCRIBE": info(-1, flagP, 1, -1, 1),
+	"UNSUBSCRIBE":  info(-1, flagP, 0, 0, 0),
 }
 
 var redisCommandScript = commandInfoMap{
-	"EVAL":       CommandInfo{},
-	"EVAL_RO":    CommandInfo{},
-	"EVALSHA":    CommandInfo{},
-	"EVALSHA_RO": CommandInfo{},
-	"FCALL":      CommandInfo{},
-	"FCALL_RO":   CommandInfo{},
-	"FUNCTION":   CommandInfo{},
-	"SCRIPT":     CommandInfo{},
+	"EVAL":       info(-3, 0, 0, 0, 0).withNumKeys(2),
+	"EVAL_RO":    info(-3, flagR, 0, 0, 0).withNumKeys(2),
+	"EVALSHA":    info(-3, 0, 0, 0, 0).withNumKeys(2),
+	"EVALSHA_RO": info(-3, flagR, 0, 0, 0).withNumKeys(2),
+	"FCALL":      info(-3, 0, 0, 0, 0).withNumKeys(2),
+	"FCALL_RO":   info(-3, flagR, 0, 0, 0).withNumKeys(2),
+	"FUNCTION":   info(-2, 0, 0, 0, 0),
+	"SCRIPT":     info(-2, 0, 0, 0, 0),
 }
 
 var redisCommandSet = commandInfoMap{
-	"SADD":        CommandInfo{},
-	"SCARD":       CommandInfo{},
-	"SDIFF":       CommandInfo{},
-	"SDIFFSTORE":  CommandInfo{},
-	"SINTER":      CommandInfo{},
-	"SINTERCARD":  CommandInfo{},
-	"SINTERSTORE": CommandInfo{},
-	"SISMEMBER":   CommandInfo{},
-	"SMEMEBERS":   CommandInfo{},
-	"SMISMEMBER":  CommandInfo{},
-	"SMOVE":       CommandInfo{},
-	"SPOP":        CommandInfo{},
-	"SRANDMEMBER": CommandInfo{},
-	"SREM":        CommandInfo{},
-	"SSCAN":       CommandInfo{},
-	"SUNION":      CommandInfo{},
-	"SUNIONSTORE": CommandInfo{},
+	"SADD":        info(-3, flagW, 1, 1, 1),
+	"SCARD":       info(2, flagR, 1, 1, 1),
+	"SDIFF":       info(-2, flagR, 1, -1, 1),
+	"SDIFFSTORE":  info(-3, flagW, 1, -1, 1),
+	"SINTER":      info(-2, flagR, 1, -1, 1),
+	"SINTERCARD":  info(-3, flagR, 0, 0, 0).withNumKeys(1),
+	"SINTERSTORE": info(-3, flagW, 1, -1, 1),
+	"SISMEMBER":   info(3, flagR, 1, 1, 1),
+	"SMEMBERS":    info(2, flagR, 1, 1, 1),
+	"SMISMEMBER":  info(-3, flagR, 1, 1, 1),
+	"SMOVE":       info(4, flagW, 1, 2, 1),
+	"SPOP":        info(-2, flagW, 1, 1, 1),
+	"SRANDMEMBER": info(-2, flagR, 1, 1, 1),
+	"SREM":        info(-3, flagW, 1, 1, 1),
+	"SSCAN":       info(-3, flagR, 1, 1, 1),
+	"SUNION":      info(-2, flagR, 1, -1, 1),
+	"SUNIONSTORE": info(-3, flagW, 1, -1, 1),
 }
 
 var redisCommandSortedSet = commandInfoMap{
-	"BZMPOP":           CommandInfo{},
-	"BZPOPMAX":         CommandInfo{},
-	"BZPOPMIN":         CommandInfo{},
-	"ZADD":             CommandInfo{},
-	"ZCARD":            CommandInfo{},
-	"ZCOUNT":           CommandInfo{},
-	"ZDIFF":            CommandInfo{},
-	"ZDIFFSTORE":       CommandInfo{},
-	"ZINCRBY":          CommandInfo{},
-	"ZINTER":           CommandInfo{},
-	"ZINTERCARD":       CommandInfo{},
-	"ZINTERSTORE":      CommandInfo{},
-	"ZLEXCOUNT":        CommandInfo{},
-	"ZMPOP":            CommandInfo{},
-	"ZMSCORE":          CommandInfo{},
-	"ZPOPMAX":          CommandInfo{},
-	"ZPOPMIN":          CommandInfo{},
-	"ZRANDMEMBER":      CommandInfo{},
-	"ZRANGE":           CommandInfo{},
-	"ZRANGEBYLEX":      CommandInfo{},
-	"ZRANGEBYSCORE":    CommandInfo{},
-	"ZRANGESTORE":      CommandInfo{},
-	"ZRANK":            CommandInfo{},
-	"ZREM":             CommandInfo{},
-	"ZREMRANGEBYLEX":   CommandInfo{},
-	"ZREMRANGEBYRANK":  CommandInfo{},
-	"ZREMRANGEBYSCORE": CommandInfo{},
-	"ZREVRANGE":        CommandInfo{},
-	"ZREVRANGEBYLEX":   CommandInfo{},
-	"ZREVRANGEBYSCORE": CommandInfo{},
-	"ZREVRANK":         CommandInfo{},
-	"ZSCAN":            CommandInfo{},
-	"ZSCORE":           CommandInfo{},
-	"ZUNION":           CommandInfo{},
-	"ZUNIONSTORE":      CommandInfo{},
+	"BZMPOP":           info(-5, flagW|flagB, 0, 0, 0).withNumKeys(2),
+	"BZPOPMAX":         info(-3, flagW|flagB, 1, -2, 1),
+	"BZPOPMIN":         info(-3, flagW|flagB, 1, -2, 1),
+	"ZADD":             info(-4, flagW, 1, 1, 1),
+	"ZCARD":            info(2, flagR, 1, 1, 1),
+	"ZCOUNT":           info(4, flagR, 1, 1, 1),
+	"ZDIFF":            info(-3, flagR, 0, 0, 0).withNumKeys(1),
+	"ZDIFFSTORE":       info(-4, flagW, 1, 1, 1).withNumKeys(2),
+	"ZINCRBY":          info(4, flagW, 1, 1, 1),
+	"ZINTER":           info(-3, flagR, 0, 0, 0).withNumKeys(1),
+	"ZINTERCARD":       info(-3, flagR, 0, 0, 0).withNumKeys(1),
+	"ZINTERSTORE":      info(-4, flagW, 1, 1, 1).withNumKeys(2),
+	"ZLEXCOUNT":        info(4, flagR, 1, 1, 1),
+	"ZMPOP":            info(-4, flagW, 0, 0, 0).withNumKeys(1),
+	"ZMSCORE":          info(-3, flagR, 1, 1, 1),
+	"ZPOPMAX":          info(-2, flagW, 1, 1, 1),
+	"ZPOPMIN":          info(-2, flagW, 1, 1, 1),
+	"ZRANDMEMBER":      info(-2, flagR, 1, 1, 1),
+	"ZRANGE":           info(-4, flagR, 1, 1, 1),
+	"ZRANGEBYLEX":      info(-4, flagR, 1, 1, 1),
+	"ZRANGEBYSCORE":    info(-4, flagR, 1, 1, 1),
+	"ZRANGESTORE":      info(-5, flagW, 1, 2, 1),
+	"ZRANK":            info(-3, flagR, 1, 1, 1),
+	"ZREM":             info(-3, flagW, 1, 1, 1),
+	"ZREMRANGEBYLEX":   info(4, flagW, 1, 1, 1),
+	"ZREMRANGEBYRANK":  info(4, flagW, 1, 1, 1),
+	"ZREMRANGEBYSCORE": info(4, flagW, 1, 1, 1),
+	"ZREVRANGE":        info(-4, flagR, 1, 1, 1),
+	"ZREVRANGEBYLEX":   info(-4, flagR, 1, 1, 1),
+	"ZREVRANGEBYSCORE": info(-4, flagR, 1, 1, 1),
+	"ZREVRANK":         info(-3, flagR, 1, 1, 1),
+	"ZSCAN":            info(-3, flagR, 1, 1, 1),
+	"ZSCORE":           info(3, flagR, 1, 1, 1),
+	"ZUNION":           info(-3, flagR, 0, 0, 0).withNumKeys(1),
+	"ZUNIONSTORE":      info(-4, flagW, 1, 1, 1).withNumKeys(2),
 }
 
 var redisCommandStream = commandInfoMap{
-	"XACK":       CommandInfo{},
-	"XADD":       CommandInfo{},
-	"XAUTOCLAIM": CommandInfo{},
-	"XCLAIM":     CommandInfo{},
-	"XDEL":       CommandInfo{},
-	"XGROUP":     CommandInfo{},
-	"XINFO":      CommandInfo{},
-	"XLEN":       CommandInfo{},
-	"XPENDING":   CommandInfo{},
-	"XRANGE":     CommandInfo{},
-	"XREAD":      CommandInfo{},
-	"XREADGROUP": CommandInfo{},
-	"XREVRANGE":  CommandInfo{},
-	"XSETID":     CommandInfo{},
-	"XTRIM":      CommandInfo{},
+	"XACK":       info(-4, flagW, 1, 1, 1),
+	"XADD":       info(-5, flagW, 1, 1, 1),
+	"XAUTOCLAIM": info(-6, flagW, 1, 1, 1),
+	"XCLAIM":     info(-6, flagW, 1, 1, 1),
+	"XDEL":       info(-3, flagW, 1, 1, 1),
+	"XGROUP":     info(-2, flagW, 2, 2, 1),
+	"XINFO":      info(-2, flagR, 2, 2, 1),
+	"XLEN":       info(2, flagR, 1, 1, 1),
+	"XPENDING":   info(-3, flagR, 1, 1, 1),
+	"XRANGE":     info(-4, flagR, 1, 1, 1),
+	"XREAD":      info(-4, flagR|flagB, 0, 0, 0).withKeyKeyword("STREAMS"),
+	"XREADGROUP": info(-7, flagW|flagB, 0, 0, 0).withKeyKeyword("STREAMS"),
+	"XREVRANGE":  info(-4, flagR, 1, 1, 1),
+	"XSETID":     info(-3, flagW, 1, 1, 1),
+	"XTRIM":      info(-4, flagW, 1, 1, 1),
 }
 
 var redisCommandString = commandInfoMap{
-	"APPEND":      CommandInfo{},
-	"DECR":        CommandInfo{},
-	"DECRBY":      CommandInfo{},
-	"GET":         CommandInfo{},
-	"GETDEL":      CommandInfo{},
-	"GETEX":       CommandInfo{},
-	"GETRANGE":    CommandInfo{},
-	"GETSET":      CommandInfo{},
-	"INCR":        CommandInfo{},
-	"INCRBY":      CommandInfo{},
-	"INCRBYFLOAT": CommandInfo{},
-	"LCS":         CommandInfo{},
-	"MGET":        CommandInfo{},
-	"MSET":        CommandInfo{},
-	"MSETNX":      CommandInfo{},
-	"PSETEX":      CommandInfo{},
-	"SET":         CommandInfo{},
-	"SETEX":       CommandInfo{},
-	"SETNX":       CommandInfo{},
-	"SETRANGE":    CommandInfo{},
-	"STRLEN":      CommandInfo{},
-	"SUBSTR":      CommandInfo{},
+	"APPEND":      info(3, flagW, 1, 1, 1),
+	"DECR":        info(2, flagW, 1, 1, 1),
+	"DECRBY":      info(3, flagW, 1, 1, 1),
+	"GET":         info(2, flagR, 1, 1, 1),
+	"GETDEL":      info(2, flagW, 1, 1, 1),
+	"GETEX":       info(-2, flagW, 1, 1, 1),
+	"GETRANGE":    info(4, flagR, 1, 1, 1),
+	"GETSET":      info(3, flagW, 1, 1, 1),
+	"INCR":        info(2, flagW, 1, 1, 1),
+	"INCRBY":      info(3, flagW, 1, 1, 1),
+	"INCRBYFLOAT": info(3, flagW, 1, 1, 1),
+	"LCS":         info(-3, flagR, 1, 2, 1),
+	"MGET":        info(-2, flagR, 1, -1, 1),
+	"MSET":        info(-3, flagW, 1, -1, 2),
+	"MSETNX":      info(-3, flagW, 1, -1, 2),
+	"PSETEX":      info(4, flagW, 1, 1, 1),
+	"SET":         info(-3, flagW, 1, 1, 1),
+	"SETEX":       info(4, flagW, 1, 1, 1),
+	"SETNX":       info(3, flagW, 1, 1, 1),
+	"SETRANGE":    info(4, flagW, 1, 1, 1),
+	"STRLEN":      info(2, flagR, 1, 1, 1),
+	"SUBSTR":      info(4, flagR, 1, 1, 1),
 }
 
 var redisCommandTransaction = commandInfoMap{
-	"DISCARD": CommandInfo{},
-	"EXEC":    CommandInfo{},
-	"MULTI":   CommandInfo{},
-	"UNWATCH": CommandInfo{},
-	"WATCH":   CommandInfo{},
+	"DISCARD": info(1, 0, 0, 0, 0),
+	"EXEC":    info(1, 0, 0, 0, 0),
+	"MULTI":   info(1, 0, 0, 0, 0),
+	"UNWATCH": info(1, 0, 0, 0, 0),
+	"WATCH":   info(-2, 0, 1, -1, 1),
 }
 
 func init() {
@@ -445,7 +449,7 @@ func CommandIsTransaction(name string) bool {
 }
 
 func CommandIsUnknown(name string) bool {
-	return CommandCategory(name) == RedisCommandTransaction
+	return CommandCategory(name) == RedisCommandUnknown
 }
 
 func RedisCommandTypeName(t int) string {
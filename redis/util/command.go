@@ -0,0 +1,172 @@
+package util
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Command information mirrors the output of redis COMMAND, ie arity counts the
+// command name itself and key position is the index of argv whose first
+// element is the command name.
+
+const (
+	CommandFlagWrite = 1 << iota
+	CommandFlagReadOnly
+	CommandFlagAdmin
+	CommandFlagBlocking
+	CommandFlagPubSub
+)
+
+type CommandInfo struct {
+	// exact number of arguments, or at least -Arity arguments if negative
+	Arity int
+	Flags int
+
+	// first key, last key and the step between keys. Last key can be negative
+	// which is counted from the end of argv, ie -1 means the last argument.
+	// 0 means the command does not have key at fixed position
+	FirstKey int
+	LastKey  int
+	Step     int
+
+	// index of the numkeys argument, the keys follow it, ie EVAL and ZUNION.
+	// 0 means the command does not have numkeys argument
+	NumKeys int
+
+	// keys start after the keyword and take half of the rest arguments, ie
+	// XREAD ... STREAMS key1 key2 id1 id2
+	KeyKeyword string
+}
+
+func info(arity, flags, first, last, step int) CommandInfo {
+	return CommandInfo{
+		Arity:    arity,
+		Flags:    flags,
+		FirstKey: first,
+		LastKey:  last,
+		Step:     step,
+	}
+}
+
+func (c CommandInfo) withNumKeys(idx int) CommandInfo {
+	c.NumKeys = idx
+	return c
+}
+
+func (c CommandInfo) withKeyKeyword(kw string) CommandInfo {
+	c.KeyKeyword = kw
+	return c
+}
+
+func (c *CommandInfo) IsWrite() bool {
+	return c.Flags&CommandFlagWrite != 0
+}
+
+func (c *CommandInfo) IsReadOnly() bool {
+	return c.Flags&CommandFlagReadOnly != 0
+}
+
+func (c *CommandInfo) IsAdmin() bool {
+	return c.Flags&CommandFlagAdmin != 0
+}
+
+func (c *CommandInfo) IsBlocking() bool {
+	return c.Flags&CommandFlagBlocking != 0
+}
+
+func (c *CommandInfo) IsPubSub() bool {
+	return c.Flags&CommandFlagPubSub != 0
+}
+
+// argc includes the command name
+func (c *CommandInfo) CheckArity(argc int) bool {
+	if c.Arity >= 0 {
+		return argc == c.Arity
+	}
+	return argc >= -c.Arity
+}
+
+// Index of the keys inside of argv, argv[0] is the command name. Malformed
+// numkeys is treated as no keys, the arity check is the first line of defense
+func (c *CommandInfo) KeyIndex(argv [][]byte) []int {
+	argc := len(argv)
+	out := []int{}
+
+	if c.FirstKey > 0 && c.FirstKey < argc {
+		last := c.LastKey
+		if last < 0 {
+			last = argc + last
+		}
+		if last >= argc {
+			last = argc - 1
+		}
+		step := c.Step
+		if step <= 0 {
+			step = 1
+		}
+		for i := c.FirstKey; i <= last; i += step {
+			out = append(out, i)
+		}
+	}
+
+	if c.NumKeys > 0 && c.NumKeys < argc {
+		n, err := strconv.Atoi(string(argv[c.NumKeys]))
+		if err == nil && n > 0 && c.NumKeys+n < argc {
+			for i := 0; i < n; i++ {
+				out = append(out, c.NumKeys+1+i)
+			}
+		}
+	}
+
+	if c.KeyKeyword != "" {
+		for i := 1; i < argc; i++ {
+			if strings.EqualFold(string(argv[i]), c.KeyKeyword) {
+				rest := argc - i - 1
+				for j := 0; j < rest/2; j++ {
+					out = append(out, i+1+j)
+				}
+				break
+			}
+		}
+	}
+
+	return out
+}
+
+// Keys of the command, argv[0] is the command name
+func (c *CommandInfo) Keys(argv [][]byte) [][]byte {
+	idx := c.KeyIndex(argv)
+	out := make([][]byte, 0, len(idx))
+	for _, i := range idx {
+		out = append(out, argv[i])
+	}
+	return out
+}
+
+// information of the command, name must be upper case
+func CommandLookup(name string) (*CommandInfo, bool) {
+	for _, x := range commandInfoMapList {
+		if v, ok := x.infoMap[name]; ok {
+			return &v, true
+		}
+	}
+	return nil, false
+}
+
+// Validate the arity of known command, argv[0] is the command name. Unknown
+// command is not checked. The error message is the same as redis
+func CommandCheckArity(argv [][]byte) error {
+	if len(argv) == 0 {
+		return fmt.Errorf("ERR empty command")
+	}
+	name := string(argv[0])
+	info, ok := CommandLookup(strings.ToUpper(name))
+	if !ok {
+		return nil
+	}
+	if !info.CheckArity(len(argv)) {
+		return fmt.Errorf("ERR wrong number of arguments for '%s' command", strings.ToLower(name))
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package util
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testArgv(cmd string) [][]byte {
+	argv := [][]byte{}
+	for _, x := range strings.Fields(cmd) {
+		argv = append(argv, []byte(x))
+	}
+	return argv
+}
+
+func TestCommandCheckArity(t *testing.T) {
+	assert := assert.New(t)
+	for _, c := range []struct {
+		cmd string
+		err string
+	}{
+		{"GET a", ""},
+		{"get a", ""},
+		{"GET", "ERR wrong number of arguments for 'get' command"},
+		{"get a b", "ERR wrong number of arguments for 'get' command"},
+		{"SET a 1", ""},
+		{"SET a 1 EX 10", ""},
+		{"SET a", "ERR wrong number of arguments for 'set' command"},
+		{"DEL a b c", ""},
+		{"Del", "ERR wrong number of arguments for 'del' command"},
+		{"MSET a", "ERR wrong number of arguments for 'mset' command"},
+		{"XREAD STREAMS", "ERR wrong number of arguments for 'xread' command"},
+
+		// unknown command is not checked
+		{"WHOAMI", ""},
+		{"WHOAMI a b c", ""},
+	} {
+		err := CommandCheckArity(testArgv(c.cmd))
+		if c.err == "" {
+			assert.True(err == nil, "%s: %s", c.cmd, err)
+		} else if assert.True(err != nil, c.cmd) {
+			assert.Equal(c.err, err.Error(), c.cmd)
+		}
+	}
+	assert.Equal("ERR empty command", CommandCheckArity(nil).Error())
+}
+
+func TestCommandKeyIndex(t *testing.T) {
+	assert := assert.New(t)
+	for _, c := range []struct {
+		cmd string
+		idx []int
+	}{
+		{"GET a", []int{1}},
+		{"SET a 1 EX 10", []int{1}},
+		{"DEL a b c", []int{1, 2, 3}},
+		{"MSET a 1 b 2", []int{1, 3}},
+		{"BLPOP a b 0", []int{1, 2}},
+		{"ZUNIONSTORE d 2 a b WEIGHTS 1 2", []int{1, 3, 4}},
+		{"ZUNION 2 a b", []int{2, 3}},
+		{"EVAL script 2 a b x", []int{3, 4}},
+		{"EVAL script 0 x", []int{}},
+		{"XREAD COUNT 2 STREAMS a b 0 0", []int{4, 5}},
+		{"KEYS *", []int{}},
+
+		// malformed numkeys is treated as no keys
+		{"EVAL script x a", []int{}},
+		{"EVAL script 5 a", []int{}},
+	} {
+		argv := testArgv(c.cmd)
+		info, ok := CommandLookup(strings.ToUpper(string(argv[0])))
+		if !assert.True(ok, c.cmd) {
+			continue
+		}
+		assert.Equal(c.idx, info.KeyIndex(argv), c.cmd)
+	}
+
+	info, _ := CommandLookup("MSET")
+	keys := info.Keys(testArgv("MSET a 1 b 2"))
+	assert.Equal([][]byte{[]byte("a"), []byte("b")}, keys)
+}
+
+func TestCommandFlag(t *testing.T) {
+	assert := assert.New(t)
+
+	info, _ := CommandLookup("GET")
+	assert.True(info.IsReadOnly())
+	assert.False(info.IsWrite())
+	info, _ = CommandLookup("SET")
+	assert.True(info.IsWrite())
+	info, _ = CommandLookup("BLPOP")
+	assert.True(info.IsBlocking())
+
+	_, ok := CommandLookup("WHOAMI")
+	assert.False(ok)
+}
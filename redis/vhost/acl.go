@@ -0,0 +1,134 @@
+package vhost
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dianpeng/mono-service/pl"
+	redisutil "github.com/dianpeng/mono-service/redis/util"
+	"github.com/dianpeng/mono-service/util"
+)
+
+// ACL of the redis vhost, the users are declared in the redis_vhost config
+//
+//   .user(name, password)                  any command and key
+//   .user(name, password, category)        commands of the category
+//   .user(name, password, category, key)   and keys matching the pattern
+//
+// category and key are either a string or a list of string. Category is the
+// command category of redis/util, ie "string", "hash", "pubsub", "unknown" for
+// the command without category, ie custom command, or "all". Key is a glob
+// pattern checked against every key of the command, the command without key
+// spec, ie KEYS, is only checked by its category.
+//
+// The connection commands, ie AUTH, HELLO, PING, ECHO and QUIT, are always
+// allowed once the connection is authenticated.
+
+// User of the vhost and its ACL
+type User struct {
+	Name     string
+	Password string
+	Category []string
+	Key      []string
+
+	allCategory bool
+	category    map[int]bool
+}
+
+const categoryAll = "all"
+
+var connectionCommand = map[string]bool{
+	"AUTH":  true,
+	"HELLO": true,
+	"PING":  true,
+	"ECHO":  true,
+	"QUIT":  true,
+}
+
+func categoryOf(name string) (int, bool) {
+	for i := 0; i <= redisutil.RedisCommandUnknown; i++ {
+		if redisutil.RedisCommandTypeName(i) == name {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// .user(name, password, [category], [key])
+func newUser(arg []pl.Val) (*User, error) {
+	if len(arg) < 2 || len(arg) > 4 {
+		return nil, fmt.Errorf("redis_vhost.user: expect name, password, [category] and [key]")
+	}
+	if !arg[0].IsString() || arg[0].String() == "" {
+		return nil, fmt.Errorf("redis_vhost.user: name must be non-empty string")
+	}
+	if !arg[1].IsString() {
+		return nil, fmt.Errorf("redis_vhost.user: password must be string")
+	}
+
+	u := &User{
+		Name:     arg[0].String(),
+		Password: arg[1].String(),
+		Category: []string{categoryAll},
+		category: make(map[int]bool),
+	}
+	if len(arg) > 2 {
+		if err := propSetStringList(arg[2], &u.Category, "redis_vhost.user.category"); err != nil {
+			return nil, err
+		}
+	}
+	if len(arg) > 3 {
+		if err := propSetStringList(arg[3], &u.Key, "redis_vhost.user.key"); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, c := range u.Category {
+		if c == categoryAll {
+			u.allCategory = true
+			continue
+		}
+		cat, ok := categoryOf(c)
+		if !ok {
+			return nil, fmt.Errorf("redis_vhost.user: unknown command category %s", c)
+		}
+		u.category[cat] = true
+	}
+	return u, nil
+}
+
+func (u *User) matchKey(key string) bool {
+	for _, p := range u.Key {
+		if util.GlobMatch(p, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// check the command against the ACL of the user, name is in upper case. The
+// error message is the same as redis
+func (u *User) check(name string, argv [][]byte) error {
+	if connectionCommand[name] {
+		return nil
+	}
+	if !u.allCategory && !u.category[redisutil.CommandCategory(name)] {
+		return fmt.Errorf("NOPERM User %s has no permissions to run the '%s' command",
+			u.Name,
+			strings.ToLower(name),
+		)
+	}
+	if len(u.Key) == 0 {
+		return nil
+	}
+	info, ok := redisutil.CommandLookup(name)
+	if !ok {
+		return nil
+	}
+	for _, k := range info.Keys(argv) {
+		if !u.matchKey(string(k)) {
+			return fmt.Errorf("NOPERM No permissions to access a key")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package vhost
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dianpeng/mono-service/pl"
+	"github.com/stretchr/testify/assert"
+)
+
+func testArgv(cmd string) [][]byte {
+	argv := [][]byte{}
+	for _, x := range strings.Fields(cmd) {
+		argv = append(argv, []byte(x))
+	}
+	return argv
+}
+
+func testStrList(x ...string) pl.Val {
+	l := pl.NewValList()
+	for _, s := range x {
+		l.AddList(pl.NewValStr(s))
+	}
+	return l
+}
+
+func TestNewUser(t *testing.T) {
+	assert := assert.New(t)
+	for _, c := range []struct {
+		arg []pl.Val
+		err string
+	}{
+		{[]pl.Val{pl.NewValStr("a"), pl.NewValStr("p")}, ""},
+		{[]pl.Val{pl.NewValStr("a"), pl.NewValStr("p"), pl.NewValStr("string")}, ""},
+		{[]pl.Val{pl.NewValStr("a"), pl.NewValStr("p"), testStrList("string", "hash"), pl.NewValStr("app:*")}, ""},
+		{[]pl.Val{pl.NewValStr("a")}, "redis_vhost.user: expect name, password, [category] and [key]"},
+		{[]pl.Val{pl.NewValStr(""), pl.NewValStr("p")}, "redis_vhost.user: name must be non-empty string"},
+		{[]pl.Val{pl.NewValStr("a"), pl.NewValInt(1)}, "redis_vhost.user: password must be string"},
+		{[]pl.Val{pl.NewValStr("a"), pl.NewValStr("p"), pl.NewValStr("nope")}, "redis_vhost.user: unknown command category nope"},
+	} {
+		_, err := newUser(c.arg)
+		if c.err == "" {
+			assert.True(err == nil, "%s", err)
+		} else {
+			assert.Equal(c.err, err.Error())
+		}
+	}
+}
+
+func TestUserCheck(t *testing.T) {
+	assert := assert.New(t)
+
+	admin, _ := newUser([]pl.Val{pl.NewValStr("admin"), pl.NewValStr("p")})
+	app, _ := newUser([]pl.Val{
+		pl.NewValStr("app"),
+		pl.NewValStr("p"),
+		testStrList("string", "unknown"),
+		pl.NewValStr("app:*"),
+	})
+
+	for _, c := range []struct {
+		user *User
+		cmd  string
+		err  string
+	}{
+		{admin, "SET a 1", ""},
+		{admin, "HSET h f v", ""},
+		{app, "GET app:1", ""},
+		{app, "MSET app:1 1 app:2 2", ""},
+		{app, "WHOAMI", ""},
+		{app, "PING", ""},
+		{app, "AUTH admin p", ""},
+		{app, "GET other", "NOPERM No permissions to access a key"},
+		{app, "MSET app:1 1 other 2", "NOPERM No permissions to access a key"},
+		{app, "HSET app:h f v", "NOPERM User app has no permissions to run the 'hset' command"},
+		{app, "SUBSCRIBE app:c", "NOPERM User app has no permissions to run the 'subscribe' command"},
+	} {
+		argv := testArgv(c.cmd)
+		err := c.user.check(strings.ToUpper(string(argv[0])), argv)
+		if c.err == "" {
+			assert.True(err == nil, "%s: %s", c.cmd, err)
+		} else if assert.True(err != nil, c.cmd) {
+			assert.Equal(c.err, err.Error(), c.cmd)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package vhost
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"strings"
+
+	"github.com/dianpeng/mono-service/util"
+	"github.com/tidwall/redcon"
+)
+
+// Authentication of the redis connection
+//
+//   AUTH password                                   user "default"
+//   AUTH username password
+//   HELLO protover AUTH username password [SETNAME name]
+//
+// The vhost without user is public, otherwise the connection must be
+// authenticated as one of its users before any command, and any rule, runs.
+// The listener routes the connection to the vhost declaring the user, so one
+// listener serves multiple tenants, see redis/vhost_list.go. The credential is
+// taken off from HELLO before it is dispatched, so the rule never sees it.
+
+const (
+	defaultUser = "default"
+
+	errNoAuth      = "NOAUTH Authentication required."
+	errHelloNoAuth = "NOAUTH HELLO must be called with the client already authenticated, otherwise the HELLO <proto> AUTH <user> <pass> option can be used to authenticate the client and select the RESP protocol version at the same time"
+	errWrongPass   = "WRONGPASS invalid username-password pair or user is disabled."
+	errNoPassword  = "ERR AUTH <password> called without any password configured for the default user. Are you sure your configuration is correct?"
+)
+
+// compared against the credential of the unknown user, so the failed AUTH
+// takes the same time whether the user exists or not
+var dummyPassword = util.RandomString(32)
+
+// the password is compared by its digest, so the time does not tell its length
+// either
+func checkPassword(password, input string) bool {
+	x := sha256.Sum256([]byte(password))
+	y := sha256.Sum256([]byte(input))
+	return subtle.ConstantTimeCompare(x[:], y[:]) == 1
+}
+
+type Credential struct {
+	User     string
+	Password string
+}
+
+// ParseAuth takes the credential off AUTH or HELLO, cred is nil if the
+// command has none. For HELLO, rest is the command without the AUTH option,
+// otherwise rest is nil
+func ParseAuth(argv [][]byte) (cred *Credential, rest [][]byte, err error) {
+	if len(argv) == 0 {
+		return nil, nil, nil
+	}
+
+	switch strings.ToUpper(string(argv[0])) {
+	case "AUTH":
+		switch len(argv) {
+		case 2:
+			return &Credential{User: defaultUser, Password: string(argv[1])}, nil, nil
+		case 3:
+			return &Credential{User: string(argv[1]), Password: string(argv[2])}, nil, nil
+		default:
+			return nil, nil, fmt.Errorf("ERR wrong number of arguments for 'auth' command")
+		}
+
+	case "HELLO":
+		for i := 2; i < len(argv); i++ {
+			opt := strings.ToUpper(string(argv[i]))
+			switch {
+			case opt == "AUTH" && i+2 < len(argv):
+				cred = &Credential{User: string(argv[i+1]), Password: string(argv[i+2])}
+				rest = make([][]byte, 0, len(argv)-3)
+				rest = append(rest, argv[:i]...)
+				rest = append(rest, argv[i+3:]...)
+				return cred, rest, nil
+			case opt == "SETNAME" && i+1 < len(argv):
+				i++
+			default:
+				// malformed option is reported by HELLO itself
+				return nil, nil, nil
+			}
+		}
+	}
+	return nil, nil, nil
+}
+
+// Public tells whether the vhost serves the connection without authentication
+func (x *VHost) Public() bool {
+	return len(x.Config.User) == 0
+}
+
+// Auth returns the user of the credential, nil if it does not match
+func (x *VHost) Auth(cred *Credential) *User {
+	var user *User
+	password := dummyPassword
+	for _, u := range x.Config.User {
+		if u.Name == cred.User {
+			user, password = u, u.Password
+			break
+		}
+	}
+	if !checkPassword(password, cred.Password) || user == nil {
+		return nil
+	}
+	return user
+}
+
+// AuthUnknown fails the credential of the unknown user as Auth does, it is used
+// when the user is not found by any vhost
+func AuthUnknown(cred *Credential) {
+	checkPassword(dummyPassword, cred.Password)
+}
+
+// Bound returns the vhost serving the connection, nil if the connection is not
+// authenticated yet
+func Bound(conn redcon.Conn) *VHost {
+	if h, ok := conn.Context().(*serviceHandler); ok {
+		return h.vhost
+	}
+	return nil
+}
+
+// Login binds the authenticated connection to the vhost as the user. The
+// connection leaves the vhost it is bound to, and the protocol and name
+// negotiated by HELLO are kept. False is returned if redis.@accept of the vhost
+// rejects the connection
+func (x *VHost) Login(conn redcon.Conn, u *User) bool {
+	prev, _ := conn.Context().(*serviceHandler)
+	if prev != nil && prev.vhost == x {
+		prev.login(u)
+		return true
+	}
+
+	setup := func(h *serviceHandler) {
+		h.login(u)
+	}
+	if prev != nil {
+		proto, name := prev.runtime.Negotiated()
+		setup = func(h *serviceHandler) {
+			h.runtime.Negotiate(proto, name)
+			h.login(u)
+		}
+		prev.vhost.OnClose(conn, nil)
+	}
+	return x.accept(conn, setup)
+}
+
+// AuthFailed replies the failed authentication, hasUser tells whether any user
+// is declared
+func AuthFailed(conn redcon.Conn, hasUser bool) {
+	if hasUser {
+		conn.WriteError(errWrongPass)
+	} else {
+		conn.WriteError(errNoPassword)
+	}
+}
+
+// NoAuth rejects the command of the connection which is not authenticated,
+// QUIT is still served
+func NoAuth(conn redcon.Conn, argv [][]byte) {
+	switch strings.ToUpper(string(argv[0])) {
+	case "QUIT":
+		conn.WriteString("OK")
+		conn.Close()
+	case "HELLO":
+		conn.WriteError(errHelloNoAuth)
+	default:
+		conn.WriteError(errNoAuth)
+	}
+}
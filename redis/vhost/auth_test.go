@@ -0,0 +1,74 @@
+package vhost
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dianpeng/mono-service/pl"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAuth(t *testing.T) {
+	assert := assert.New(t)
+	for _, c := range []struct {
+		cmd  string
+		cred *Credential
+		rest string
+		err  string
+	}{
+		{"GET a", nil, "", ""},
+		{"AUTH p", &Credential{User: "default", Password: "p"}, "", ""},
+		{"auth u p", &Credential{User: "u", Password: "p"}, "", ""},
+		{"AUTH", nil, "", "ERR wrong number of arguments for 'auth' command"},
+		{"AUTH u p x", nil, "", "ERR wrong number of arguments for 'auth' command"},
+		{"HELLO 3", nil, "", ""},
+		{"HELLO 3 AUTH u p", &Credential{User: "u", Password: "p"}, "HELLO 3", ""},
+		{"hello 2 auth u p setname n", &Credential{User: "u", Password: "p"}, "hello 2 setname n", ""},
+		{"HELLO 3 SETNAME n AUTH u p", &Credential{User: "u", Password: "p"}, "HELLO 3 SETNAME n", ""},
+		{"HELLO 3 AUTH u", nil, "", ""},
+	} {
+		cred, rest, err := ParseAuth(testArgv(c.cmd))
+		if c.err != "" {
+			if assert.True(err != nil, c.cmd) {
+				assert.Equal(c.err, err.Error(), c.cmd)
+			}
+			continue
+		}
+		assert.True(err == nil, c.cmd)
+		assert.Equal(c.cred, cred, c.cmd)
+
+		o := []string{}
+		for _, x := range rest {
+			o = append(o, string(x))
+		}
+		assert.Equal(c.rest, strings.Join(o, " "), c.cmd)
+	}
+}
+
+func TestVHostAuth(t *testing.T) {
+	assert := assert.New(t)
+
+	alice, _ := newUser([]pl.Val{pl.NewValStr("alice"), pl.NewValStr("s1")})
+	bob, _ := newUser([]pl.Val{pl.NewValStr("bob"), pl.NewValStr("s2")})
+	vhost := &VHost{
+		Config: &VHostConfig{
+			User: []*User{alice, bob},
+		},
+	}
+	assert.False(vhost.Public())
+	assert.True((&VHost{Config: &VHostConfig{}}).Public())
+
+	for _, c := range []struct {
+		cred Credential
+		user *User
+	}{
+		{Credential{User: "alice", Password: "s1"}, alice},
+		{Credential{User: "bob", Password: "s2"}, bob},
+		{Credential{User: "alice", Password: "s2"}, nil},
+		{Credential{User: "alice", Password: ""}, nil},
+		{Credential{User: "carol", Password: "s1"}, nil},
+		{Credential{User: "carol", Password: dummyPassword}, nil},
+	} {
+		assert.True(vhost.Auth(&c.cred) == c.user, "%s %s", c.cred.User, c.cred.Password)
+	}
+}
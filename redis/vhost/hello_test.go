@@ -0,0 +1,95 @@
+package vhost
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHello(t *testing.T) {
+	assert := assert.New(t)
+	v := testVHost(t, `
+config redis_vhost {
+  .name = "test";
+  .listener = "test";
+  .keyspace = true;
+}
+rule "redis.TYPES" {
+  conn:writeArray([null, true, 1.5, {"a": 1}]);
+}
+rule "redis.PROTO" {
+  conn:writeInt(conn.protocol);
+}
+`)
+	addr := testServe(t, v)
+	c := dial(t, addr)
+
+	// RESP2 until HELLO 3, the RESP3 types are written as their RESP2 counterpart
+	assert.Equal(":2", c.do("PROTO"))
+	assert.Equal("*4 $-1 :1 $3 1.5 *2 $1 a :1", c.do("TYPES"))
+	assert.Equal("$-1", c.do("GET a"))
+
+	hello := c.do("HELLO 2")
+	assert.True(strings.HasPrefix(hello, "*14 $6 server $4 mono "), hello)
+	assert.True(strings.Contains(hello, " $5 proto :2 "), hello)
+	assert.Equal(":2", c.do("PROTO"))
+
+	hello = c.do("HELLO 3 SETNAME n")
+	assert.True(strings.HasPrefix(hello, "%7 $6 server $4 mono "), hello)
+	assert.True(strings.Contains(hello, " $5 proto :3 "), hello)
+	assert.Equal(":3", c.do("PROTO"))
+	assert.Equal("*4 _ #t ,1.5 %1 $1 a :1", c.do("TYPES"))
+
+	// HELLO without version keeps the protocol
+	hello = c.do("HELLO")
+	assert.True(strings.HasPrefix(hello, "%7 "), hello)
+
+	// rejected HELLO keeps the protocol
+	assert.Equal("-NOPROTO unsupported protocol version", c.do("HELLO 4"))
+	assert.Equal("-ERR Protocol version is not an integer or out of range", c.do("HELLO x"))
+	assert.Equal("-ERR Syntax error in option 'nope'", c.do("HELLO 2 nope"))
+	assert.Equal(":3", c.do("PROTO"))
+
+	hello = c.do("HELLO 2")
+	assert.True(strings.HasPrefix(hello, "*14 "), hello)
+	assert.Equal("*4 $-1 :1 $3 1.5 *2 $1 a :1", c.do("TYPES"))
+}
+
+func TestHelloPatternRule(t *testing.T) {
+	assert := assert.New(t)
+
+	// pattern rule does not intercept HELLO and pub/sub
+	c := dial(t, testServe(t, testVHost(t, `
+config redis_vhost {
+  .name = "test";
+  .listener = "test";
+  .keyspace = true;
+}
+rule "redis.*" {
+  conn:writeString("rule:" + $.command);
+}
+`)))
+	assert.Equal("+rule:GET", c.do("GET a"))
+	assert.True(strings.HasPrefix(c.do("HELLO 3"), "%7 "))
+	assert.Equal(">3 $9 subscribe $1 x :1", c.do("SUBSCRIBE x"))
+	assert.Equal("+rule:GET", c.do("GET a"))
+
+	// the rule of the exact command does
+	c = dial(t, testServe(t, testVHost(t, `
+config redis_vhost {
+  .name = "test";
+  .listener = "test";
+  .keyspace = true;
+}
+rule "redis.HELLO" {
+  conn:writeString("hello");
+}
+rule "redis.SUBSCRIBE" {
+  conn:writeString("subscribe");
+}
+`)))
+	assert.Equal("+hello", c.do("HELLO 3"))
+	assert.Equal("+subscribe", c.do("SUBSCRIBE x"))
+	assert.Equal("$-1", c.do("GET a"))
+}
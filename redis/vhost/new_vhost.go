@@ -5,7 +5,11 @@ import (
 	"github.com/dianpeng/mono-service/hpl"
 	"github.com/dianpeng/mono-service/manifest"
 	"github.com/dianpeng/mono-service/pl"
+	"github.com/dianpeng/mono-service/pubsub"
+	"github.com/dianpeng/mono-service/redis/kv"
+	"github.com/dianpeng/mono-service/redis/proxy"
 	"github.com/dianpeng/mono-service/redis/runtime"
+	"github.com/tidwall/redcon"
 	"io/fs"
 	"net/http"
 	"time"
@@ -21,6 +25,23 @@ func (c *constHttpClientFactory) GetHttpClient(url string) (hpl.HttpClient, erro
 	}, nil
 }
 
+// keyspace, proxy and broker are not available during the global and config phase
+func (c *constHttpClientFactory) Keyspace() *kv.Keyspace {
+	return nil
+}
+
+func (c *constHttpClientFactory) Proxy() *proxy.Proxy {
+	return nil
+}
+
+func (c *constHttpClientFactory) Broker() *pubsub.Broker {
+	return nil
+}
+
+func (c *constHttpClientFactory) PubSub(_ redcon.Conn, _ [][]byte) bool {
+	return false
+}
+
 func initmodule(x string, config pl.EvalConfig, fs fs.FS) (*pl.Module, error) {
 	p, err := pl.CompileModule(x, fs)
 	if err != nil {
@@ -92,5 +113,8 @@ func CreateVHost(
 	if err != nil {
 		return nil, err
 	}
+	if manifest.Broker != nil {
+		vhost.broker = manifest.Broker
+	}
 	return vhost, nil
 }
@@ -5,7 +5,12 @@ import (
 	"github.com/dianpeng/mono-service/g"
 	"github.com/dianpeng/mono-service/hpl"
 	"github.com/dianpeng/mono-service/pl"
+	"github.com/dianpeng/mono-service/pubsub"
+	"github.com/dianpeng/mono-service/redis/kv"
+	"github.com/dianpeng/mono-service/redis/proxy"
+	redispubsub "github.com/dianpeng/mono-service/redis/pubsub"
 	"github.com/dianpeng/mono-service/redis/runtime"
+	redisutil "github.com/dianpeng/mono-service/redis/util"
 	"github.com/dianpeng/mono-service/util"
 
 	"github.com/tidwall/redcon"
@@ -16,9 +21,8 @@ import (
 )
 
 const (
-	eventAccept  = "redis.@accept"
-	eventClose   = "redis.@close"
-	eventCommand = "redis.*"
+	eventAccept = "redis.@accept"
+	eventClose  = "redis.@close"
 )
 
 type servicePool struct {
@@ -27,10 +31,18 @@ type servicePool struct {
 	sync.Mutex
 }
 
+// serviceHandler is bound to a redcon.Conn, via its context, from
+// redis.@accept to redis.@close. The session is evaluated once when the
+// connection is accepted, so the session variables persist across commands of
+// the same connection
 type serviceHandler struct {
 	runtime          *runtime.Runtime
 	vhost            *VHost
+	conn             pl.Val
 	activeHttpClient []*util.HClient
+
+	// authenticated user, nil for the public vhost or before AUTH
+	user *User
 }
 
 func newServicePool(cacheSize int) servicePool {
@@ -76,6 +88,10 @@ func newServiceHandler(vhost *VHost) *serviceHandler {
 		runtime: runtime.NewRuntimeWithModule(vhost.Module),
 		vhost:   vhost,
 	}
+	h.runtime.Eval.SetEventQueue(pl.NewEventQueue(vhost.eventOrder))
+	if vhost.EventTrace != nil {
+		h.runtime.Eval.Trace = vhost.EventTrace
+	}
 	return h
 }
 
@@ -88,6 +104,42 @@ func (s *serviceHandler) GetHttpClient(url string) (hpl.HttpClient, error) {
 	return &c, nil
 }
 
+func (s *serviceHandler) Keyspace() *kv.Keyspace {
+	return s.vhost.keyspace
+}
+
+func (s *serviceHandler) Proxy() *proxy.Proxy {
+	return s.vhost.proxy
+}
+
+func (s *serviceHandler) Broker() *pubsub.Broker {
+	return s.vhost.broker
+}
+
+func (s *serviceHandler) PubSub(conn redcon.Conn, argv [][]byte) bool {
+	return redispubsub.Exec(s.vhost.broker, conn, argv, s)
+}
+
+// redispubsub.Handler, the connection is detached from the redcon server loop once
+// it subscribes and served by the broker until it is closed
+
+func (s *serviceHandler) OnDetach(conn redcon.Conn) {
+	s.runtime.Rebind(conn)
+}
+
+func (s *serviceHandler) OnCommand(conn redcon.Conn, cmd redcon.Command) {
+	s.onEvent(conn, cmd)
+}
+
+func (s *serviceHandler) OnClose(conn redcon.Conn, err error) {
+	s.onClose(conn, err)
+	s.release()
+}
+
+func (s *serviceHandler) Protocol() int {
+	return s.runtime.Protocol()
+}
+
 func (s *serviceHandler) finish() {
 	if s.activeHttpClient != nil {
 		for _, c := range s.activeHttpClient {
@@ -97,6 +149,18 @@ func (s *serviceHandler) finish() {
 	}
 }
 
+func (s *serviceHandler) login(u *User) {
+	s.user = u
+	s.runtime.SetUser(u.Name)
+}
+
+// connection is gone, drop the reference and recycle the handler
+func (s *serviceHandler) release() {
+	s.conn = pl.NewValNull()
+	s.user = nil
+	s.vhost.servicePool.put(s)
+}
+
 func (s *serviceHandler) err(
 	c redcon.Conn,
 	event string,
@@ -118,22 +182,54 @@ func (s *serviceHandler) onEvent(
 		s.finish()
 	}()
 
-	cmdVal := runtime.NewCommandVal(
-		&cmd,
-	)
+	// malformed known command is rejected uniformly before dispatching
+	if err := redisutil.CommandCheckArity(cmd.Args); err != nil {
+		conn.WriteError(err.Error())
+		return
+	}
 
-	connVal := runtime.NewConnectionVal(
-		conn,
-	)
+	// credential is verified against this vhost only, the listener routes AUTH
+	// to the vhost declaring the user before the command reaches here, except
+	// for the subscribed connection which is detached from the listener
+	cred, rest, err := ParseAuth(cmd.Args)
+	if err != nil {
+		conn.WriteError(err.Error())
+		return
+	}
+	if cred != nil {
+		u := s.vhost.Auth(cred)
+		if u == nil {
+			AuthFailed(conn, !s.vhost.Public())
+			return
+		}
+		s.login(u)
+		if rest == nil {
+			conn.WriteString("OK")
+			return
+		}
+		cmd.Args = rest
+	}
 
 	cmdName := strings.ToUpper(string(cmd.Args[0]))
 	cmdEvent := fmt.Sprintf("redis.%s", cmdName)
 
-	var err error
+	// rejected before any rule runs
+	if !s.vhost.Public() {
+		if s.user == nil {
+			NoAuth(conn, cmd.Args)
+			return
+		}
+		if err = s.user.check(cmdName, cmd.Args); err != nil {
+			conn.WriteError(err.Error())
+			return
+		}
+	}
 
-	if err = s.runtime.OnInit(
-		connVal,
-		s,
+	cmdVal := runtime.NewCommandVal(
+		&cmd,
+	)
+
+	if err = s.runtime.OnCommand(
 		&log,
 	); err != nil {
 		s.err(
@@ -144,35 +240,68 @@ func (s *serviceHandler) onEvent(
 		return
 	}
 
-	if s.runtime.Module.HasEvent(cmdEvent) {
-		if _, err = s.runtime.Emit(
-			cmdEvent,
-			cmdVal,
-		); err != nil {
+	// HELLO and pub/sub are part of the connection, they are only intercepted
+	// by the rule of the exact command, ie redis.HELLO, never by the pattern
+	// rule such as redis.*, which would break the protocol negotiation and the
+	// subscription if the rule does not fall through. Protocol negotiation is
+	// never forwarded
+	if cmdName == "HELLO" && !s.vhost.Module.HasExactEvent(cmdEvent) {
+		if err = s.runtime.Hello(cmd.Args); err != nil {
 			s.err(
 				conn,
 				cmdEvent,
 				err,
 			)
+		}
+		return
+	}
+
+	// pub/sub is served by the broker of the host, the message published by
+	// any vhost reaches the subscribers
+	if redispubsub.IsCommand(cmdName) && !s.vhost.Module.HasExactEvent(cmdEvent) {
+		s.PubSub(conn, cmd.Args)
+		return
+	}
+
+	// command without any rule is forwarded by the proxy, or falls back to the
+	// built-in keyspace. The rule can intercept the command and fall through
+	// via proxy::forward($) or kv::fallthrough($)
+	if !s.vhost.Module.HasEvent(cmdEvent) {
+		if s.vhost.proxy != nil {
+			if err = s.runtime.Forward(cmdVal); err != nil {
+				s.err(
+					conn,
+					runtime.EventForward,
+					err,
+				)
+			}
 			return
 		}
-	} else {
-		if _, err = s.runtime.Emit(
-			eventCommand,
-			cmdVal,
-		); err != nil {
-			s.err(
-				conn,
-				cmdEvent,
-				err,
-			)
+		if s.vhost.Config.Keyspace {
+			reply, err := s.vhost.keyspace.Exec(cmd.Args)
+			kv.WriteReply(conn, reply, err)
 			return
 		}
 	}
+
+	// the command event, ie redis.GET, is resolved against the rule name
+	// pattern, ie rule "redis.*" handles all the commands without their own rule
+	if _, err = s.runtime.Emit(
+		cmdEvent,
+		cmdVal,
+	); err != nil {
+		s.err(
+			conn,
+			cmdEvent,
+			err,
+		)
+		return
+	}
 }
 
 func (s *serviceHandler) onAccept(
 	conn redcon.Conn,
+	setup func(*serviceHandler),
 ) bool {
 	log := alog.NewLog(s.vhost.LogFormat)
 
@@ -181,14 +310,14 @@ func (s *serviceHandler) onAccept(
 		s.finish()
 	}()
 
-	connVal := runtime.NewConnectionVal(
+	s.conn = runtime.NewConnectionVal(
 		conn,
 	)
 
 	var err error
 
 	if err = s.runtime.OnInit(
-		connVal,
+		s.conn,
 		s,
 		&log,
 	); err != nil {
@@ -200,6 +329,10 @@ func (s *serviceHandler) onAccept(
 		return false
 	}
 
+	if setup != nil {
+		setup(s)
+	}
+
 	if val, err := s.runtime.Emit(
 		eventAccept,
 		pl.NewValNull(),
@@ -230,19 +363,13 @@ func (s *serviceHandler) onClose(
 		s.finish()
 	}()
 
-	connVal := runtime.NewConnectionVal(
-		conn,
-	)
-
 	var err error
 	ctx := pl.NewValNull()
 	if connErr != nil {
 		ctx = pl.NewValStr(connErr.Error())
 	}
 
-	if err = s.runtime.OnInit(
-		connVal,
-		s,
+	if err = s.runtime.OnCommand(
 		&log,
 	); err != nil {
 		s.err(
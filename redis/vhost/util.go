@@ -24,7 +24,7 @@ func propSetInt(
 	name string,
 ) error {
 	if !v.IsInt() {
-		return fmt.Errorf("%s: set field error, value is not int")
+		return fmt.Errorf("%s: set field error, value is not int", name)
 	}
 
 	*ptr = int(v.Int())
@@ -37,9 +37,51 @@ func propSetInt64(
 	name string,
 ) error {
 	if !v.IsInt() {
-		return fmt.Errorf("%s: set field error, value is not int")
+		return fmt.Errorf("%s: set field error, value is not int", name)
 	}
 
 	*ptr = v.Int()
 	return nil
 }
+
+func propSetBool(
+	v pl.Val,
+	ptr *bool,
+	name string,
+) error {
+	if !v.IsBool() {
+		return fmt.Errorf("%s: set field error, value is not bool", name)
+	}
+
+	*ptr = v.Bool()
+	return nil
+}
+
+// either a list of string or a single string
+func propSetStringList(
+	v pl.Val,
+	ptr *[]string,
+	name string,
+) error {
+	if !v.IsList() {
+		str, err := v.ToString()
+		if err != nil {
+			return fmt.Errorf("%s: set field error, %s", name, err.Error())
+		}
+		*ptr = []string{str}
+		return nil
+	}
+
+	l := v.List()
+	o := make([]string, 0, l.Length())
+	for i := 0; i < l.Length(); i++ {
+		e := l.At(i)
+		str, err := e.ToString()
+		if err != nil {
+			return fmt.Errorf("%s: set field error, %dth element %s", name, i, err.Error())
+		}
+		o = append(o, str)
+	}
+	*ptr = o
+	return nil
+}
@@ -1,12 +1,18 @@
 package vhost
 
 import (
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/dianpeng/mono-service/alog"
 	"github.com/dianpeng/mono-service/g"
 	"github.com/dianpeng/mono-service/manifest"
 	"github.com/dianpeng/mono-service/pl"
+	"github.com/dianpeng/mono-service/pubsub"
+	"github.com/dianpeng/mono-service/redis/kv"
+	"github.com/dianpeng/mono-service/redis/proxy"
+	redispubsub "github.com/dianpeng/mono-service/redis/pubsub"
 	"github.com/dianpeng/mono-service/server"
 	"github.com/dianpeng/mono-service/util"
 	"github.com/tidwall/redcon"
@@ -23,6 +29,31 @@ type VHostConfig struct {
 	HttpClientPoolMaxSize      int64
 	HttpClientPoolTimeout      int64
 	HttpClientPoolMaxDrainSize int64
+
+	// command without rule is executed by the built-in keyspace
+	Keyspace bool
+
+	// snapshot file of the keyspace, and the interval in seconds to save it
+	// periodically. 0 interval means only saved by SAVE or kv::save()
+	KeyspaceSnapshot         string
+	KeyspaceSnapshotInterval int64
+
+	// command without rule is forwarded to the backends, sharded by the key of
+	// the command. Backend is host:port or kv://name of a built-in keyspace,
+	// hash is consistent or cluster, and the timeout is in milliseconds
+	ProxyBackend  []string
+	ProxyHash     string
+	ProxyPoolSize int
+	ProxyTimeout  int64
+
+	// users declared by .user(...), the vhost is public without user, otherwise
+	// the connection must be authenticated by AUTH, see acl.go and auth.go
+	User []*User
+
+	// order of the emitted events, ie "fifo" or "priority", and the number of
+	// the latest emitted events traced for debugging, 0 disables the tracing
+	EventOrder string
+	EventTrace int
 }
 
 type VHost struct {
@@ -31,6 +62,13 @@ type VHost struct {
 	LogFormat   *alog.Format
 	clientPool  *util.HClientPool
 	servicePool servicePool
+	keyspace    *kv.Keyspace
+	proxy       *proxy.Proxy
+	broker      *pubsub.Broker
+	eventOrder  int
+
+	// nil if the tracing is disabled
+	EventTrace *pl.EventTrace
 }
 
 type VHostConfigBuilder struct {
@@ -43,16 +81,37 @@ func (x *VHost) uploadLog(_ *alog.Log, _ alog.Provider) {
 
 func (x *VHost) OnAccept(
 	conn redcon.Conn,
+) bool {
+	return x.accept(conn, nil)
+}
+
+// setup prepares the connection before redis.@accept, ie the user of AUTH
+func (x *VHost) accept(
+	conn redcon.Conn,
+	setup func(*serviceHandler),
 ) bool {
 	handler := x.getServiceHandler()
-	return handler.onAccept(conn)
+	conn.SetContext(handler)
+
+	if !handler.onAccept(conn, setup) {
+		// redcon does not notify close for the rejected connection
+		conn.SetContext(nil)
+		handler.release()
+		return false
+	}
+	return true
 }
 
 func (x *VHost) OnEvent(
 	conn redcon.Conn,
 	cmd redcon.Command,
 ) {
-	handler := x.getServiceHandler()
+	handler, ok := conn.Context().(*serviceHandler)
+	if !ok {
+		conn.WriteError("redis_vhost: connection is not accepted")
+		conn.Close()
+		return
+	}
 	handler.onEvent(conn, cmd)
 }
 
@@ -60,8 +119,19 @@ func (x *VHost) OnClose(
 	conn redcon.Conn,
 	err error,
 ) {
-	handler := x.getServiceHandler()
+	// the subscribed connection is detached, and redcon notifies close with its
+	// detached error. The subscriber owns the connection and closes it later
+	if errors.Is(redispubsub.CloseError(conn, err), redispubsub.ErrDetached) {
+		return
+	}
+
+	handler, ok := conn.Context().(*serviceHandler)
+	if !ok {
+		return
+	}
+	conn.SetContext(nil)
 	handler.onClose(conn, err)
+	handler.release()
 }
 
 func (x *VHost) getServiceHandler() *serviceHandler {
@@ -125,6 +195,44 @@ func (config *VHostConfig) Compose(p *pl.Module) (*VHost, error) {
 		int(config.SessionCacheSize),
 	)
 
+	// replaced by the broker of the host, see CreateVHost
+	vhost.broker = pubsub.NewBroker()
+
+	if order, err := pl.ParseEventOrder(config.EventOrder); err != nil {
+		return nil, fmt.Errorf("redis_vhost.event_order: %s", err.Error())
+	} else {
+		vhost.eventOrder = order
+	}
+	if config.EventTrace > 0 {
+		vhost.EventTrace = pl.NewEventTrace(config.EventTrace)
+	}
+
+	// keyspace is shared by the vhost of the same name, so the data survives
+	// the reload
+	if config.Keyspace {
+		vhost.keyspace = kv.Open(config.Name)
+		if err := vhost.keyspace.SetSnapshot(
+			config.KeyspaceSnapshot,
+			time.Duration(config.KeyspaceSnapshotInterval)*time.Second,
+			true,
+		); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(config.ProxyBackend) > 0 {
+		p, err := proxy.Open(config.Name, &proxy.Config{
+			Backend:  config.ProxyBackend,
+			Hash:     config.ProxyHash,
+			PoolSize: config.ProxyPoolSize,
+			Timeout:  time.Duration(config.ProxyTimeout) * time.Millisecond,
+		})
+		if err != nil {
+			return nil, err
+		}
+		vhost.proxy = p
+	}
+
 	return vhost, nil
 }
 
@@ -197,6 +305,69 @@ func (x *VHostConfigBuilder) ConfigProperty(
 			"redis_vhost.SessionCacheSize",
 		)
 
+	case "keyspace":
+		return propSetBool(
+			value,
+			&x.config.Keyspace,
+			"redis_vhost.Keyspace",
+		)
+
+	case "keyspace_snapshot":
+		return propSetString(
+			value,
+			&x.config.KeyspaceSnapshot,
+			"redis_vhost.KeyspaceSnapshot",
+		)
+
+	case "keyspace_snapshot_interval":
+		return propSetInt64(
+			value,
+			&x.config.KeyspaceSnapshotInterval,
+			"redis_vhost.KeyspaceSnapshotInterval",
+		)
+
+	case "event_order":
+		return propSetString(
+			value,
+			&x.config.EventOrder,
+			"redis_vhost.EventOrder",
+		)
+
+	case "event_trace":
+		return propSetInt(
+			value,
+			&x.config.EventTrace,
+			"redis_vhost.EventTrace",
+		)
+
+	case "proxy_backend":
+		return propSetStringList(
+			value,
+			&x.config.ProxyBackend,
+			"redis_vhost.ProxyBackend",
+		)
+
+	case "proxy_hash":
+		return propSetString(
+			value,
+			&x.config.ProxyHash,
+			"redis_vhost.ProxyHash",
+		)
+
+	case "proxy_pool_size":
+		return propSetInt(
+			value,
+			&x.config.ProxyPoolSize,
+			"redis_vhost.ProxyPoolSize",
+		)
+
+	case "proxy_timeout":
+		return propSetInt64(
+			value,
+			&x.config.ProxyTimeout,
+			"redis_vhost.ProxyTimeout",
+		)
+
 	case "http_client_pool_max_size":
 		return propSetInt64(
 			value,
@@ -228,8 +399,30 @@ func (x *VHostConfigBuilder) ConfigProperty(
 func (x *VHostConfigBuilder) ConfigCommand(
 	_ *pl.Evaluator,
 	key string,
-	_ []pl.Val,
+	arg []pl.Val,
 	_ pl.Val,
 ) error {
+	if !x.configPush {
+		return fmt.Errorf("config command must be inside of redis_vhost scope")
+	}
+
+	switch key {
+	case "user":
+		u, err := newUser(arg)
+		if err != nil {
+			return err
+		}
+		for _, y := range x.config.User {
+			if y.Name == u.Name {
+				return fmt.Errorf("redis_vhost.user: user %s is already declared", u.Name)
+			}
+		}
+		x.config.User = append(x.config.User, u)
+		return nil
+
+	default:
+		break
+	}
+
 	return fmt.Errorf("redis_vhost: unknown command %s", key)
 }
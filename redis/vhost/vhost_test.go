@@ -0,0 +1,207 @@
+package vhost
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/redcon"
+)
+
+func testVHost(t *testing.T, src string) *VHost {
+	v, err := initVHost("main.pl", fstest.MapFS{
+		"main.pl": &fstest.MapFile{
+			Data: []byte(src),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func testServe(t *testing.T, v *VHost) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go redcon.Serve(ln, v.OnEvent, v.OnAccept, v.OnClose)
+	return ln.Addr().String()
+}
+
+type testClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func dial(t *testing.T, addr string) *testClient {
+	c, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testClient{
+		conn: c,
+		r:    bufio.NewReader(c),
+	}
+}
+
+// one reply with CRLF replaced by space
+func (c *testClient) do(cmd string) string {
+	args := strings.Fields(cmd)
+	b := redcon.AppendArray(nil, len(args))
+	for _, a := range args {
+		b = redcon.AppendBulkString(b, a)
+	}
+	c.conn.Write(b)
+
+	c.conn.SetReadDeadline(time.Now().Add(time.Second))
+	var o []string
+	if err := c.readReply(&o); err != nil {
+		return "error: " + err.Error()
+	}
+	return strings.Join(o, " ")
+}
+
+func (c *testClient) readReply(o *[]string) error {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	*o = append(*o, line)
+
+	switch line[0] {
+	case '$':
+		n, _ := strconv.Atoi(line[1:])
+		if n < 0 {
+			return nil
+		}
+		b := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, b); err != nil {
+			return err
+		}
+		*o = append(*o, string(b[:n]))
+	case '*', '%', '>', '~':
+		n, _ := strconv.Atoi(line[1:])
+		if line[0] == '%' {
+			n *= 2
+		}
+		for i := 0; i < n; i++ {
+			if err := c.readReply(o); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func waitFor(f func() bool) bool {
+	for i := 0; i < 100; i++ {
+		if f() {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false
+}
+
+func TestSessionPerConnection(t *testing.T) {
+	assert := assert.New(t)
+	v := testVHost(t, `
+session {
+  commands = 0;
+}
+config redis_vhost {
+  .name = "test";
+  .listener = "test";
+}
+rule "redis.@accept" {
+  conn.context["accept"] = "yes";
+}
+rule "redis.COUNT" {
+  commands++;
+  conn:writeInt(commands);
+}
+rule "redis.ACCEPTED" {
+  conn:writeString(conn.context["accept"]);
+}
+`)
+	addr := testServe(t, v)
+
+	// session persists across the commands of the same connection
+	c1 := dial(t, addr)
+	assert.Equal(":1", c1.do("COUNT"))
+	assert.Equal(":2", c1.do("COUNT"))
+	assert.Equal("+yes", c1.do("ACCEPTED"))
+
+	c2 := dial(t, addr)
+	assert.Equal(":1", c2.do("COUNT"))
+	assert.Equal(":3", c1.do("COUNT"))
+	assert.Equal(0, v.servicePool.idleSize())
+
+	// the session is released to the pool on close
+	c1.conn.Close()
+	assert.True(waitFor(func() bool {
+		return v.servicePool.idleSize() == 1
+	}))
+	c2.conn.Close()
+	assert.True(waitFor(func() bool {
+		return v.servicePool.idleSize() == 2
+	}))
+
+	// and reused by the new connection with fresh session
+	c3 := dial(t, addr)
+	assert.Equal(":1", c3.do("COUNT"))
+	assert.Equal(1, v.servicePool.idleSize())
+	c3.conn.Close()
+}
+
+func TestEventOrder(t *testing.T) {
+	assert := assert.New(t)
+	v := testVHost(t, `
+config redis_vhost {
+  .name = "test";
+  .listener = "test";
+  .event_order = "priority";
+  .event_trace = 10;
+}
+rule "redis.EMIT" {
+  emit "ev.low";
+  emit_priority(5, "ev.high");
+  conn:writeString("ok");
+}
+rule "ev.*" {
+}
+`)
+	c := dial(t, testServe(t, v))
+	assert.Equal("+ok", c.do("EMIT"))
+
+	// higher priority is handled first
+	records := v.EventTrace.Records()
+	if assert.Equal(2, len(records)) {
+		assert.Equal("ev.low", records[0].Name)
+		assert.Equal("ev.high", records[1].Name)
+		assert.Equal("redis.EMIT", records[1].Emitter)
+		assert.True(records[0].Handled && records[1].Handled)
+		assert.True(records[1].HandleAt.Before(records[0].HandleAt))
+	}
+
+	_, err := initVHost("main.pl", fstest.MapFS{
+		"main.pl": &fstest.MapFile{
+			Data: []byte(`
+config redis_vhost {
+  .name = "test";
+  .listener = "test";
+  .event_order = "lifo";
+}
+`),
+		},
+	})
+	assert.Equal("redis_vhost.event_order: unknown event order lifo, expect fifo or priority", err.Error())
+}
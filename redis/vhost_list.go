@@ -0,0 +1,142 @@
+package redis
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/dianpeng/mono-service/redis/vhost"
+)
+
+// vhostlist is the tenants of a redis listener. The connection is routed to the
+// vhost declaring the user it is authenticated as, or to the public vhost,
+// ie vhost without user, before it is authenticated. So user name is unique
+// within the listener and there is at most one public vhost
+type vhostlist struct {
+	public *vhost.VHost
+	user   map[string]*vhost.VHost
+	name   map[string]*vhost.VHost
+	lock   sync.RWMutex
+}
+
+func newvhostlist() vhostlist {
+	return vhostlist{
+		user: make(map[string]*vhost.VHost),
+		name: make(map[string]*vhost.VHost),
+	}
+}
+
+func (v *vhostlist) add(
+	vhost *vhost.VHost,
+) error {
+	vhostName := vhost.Config.Name
+
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	if _, ok := v.name[vhostName]; ok {
+		return fmt.Errorf("vhost name %s already existed", vhostName)
+	}
+	if vhost.Public() && v.public != nil {
+		return fmt.Errorf("vhost %s without user already existed", v.public.Name())
+	}
+	for _, u := range vhost.Config.User {
+		if x, ok := v.user[u.Name]; ok {
+			return fmt.Errorf("user %s already existed in vhost %s", u.Name, x.Name())
+		}
+	}
+
+	v.index(vhost)
+	return nil
+}
+
+func (v *vhostlist) index(
+	vhost *vhost.VHost,
+) {
+	v.name[vhost.Config.Name] = vhost
+	if vhost.Public() {
+		v.public = vhost
+	}
+	for _, u := range vhost.Config.User {
+		v.user[u.Name] = vhost
+	}
+}
+
+func (v *vhostlist) unindex(
+	vhostName string,
+) bool {
+	val, ok := v.name[vhostName]
+	if !ok {
+		return false
+	}
+	if v.public == val {
+		v.public = nil
+	}
+	for _, u := range val.Config.User {
+		if v.user[u.Name] == val {
+			delete(v.user, u.Name)
+		}
+	}
+	delete(v.name, vhostName)
+	return true
+}
+
+func (v *vhostlist) remove(
+	vhostName string,
+) bool {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	return v.unindex(vhostName)
+}
+
+// the updated vhost takes over the users, and the public role, of others
+func (v *vhostlist) update(
+	vhost *vhost.VHost,
+) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	v.unindex(vhost.Config.Name)
+	v.index(vhost)
+}
+
+func (v *vhostlist) get(
+	vhostName string,
+) *vhost.VHost {
+	v.lock.RLock()
+	defer v.lock.RUnlock()
+	return v.name[vhostName]
+}
+
+func (v *vhostlist) empty() bool {
+	v.lock.RLock()
+	defer v.lock.RUnlock()
+	return len(v.name) == 0
+}
+
+func (v *vhostlist) getPublic() *vhost.VHost {
+	v.lock.RLock()
+	defer v.lock.RUnlock()
+	return v.public
+}
+
+func (v *vhostlist) hasUser() bool {
+	v.lock.RLock()
+	defer v.lock.RUnlock()
+	return len(v.user) != 0
+}
+
+// resolve the vhost and the user of the credential, nil if it does not match
+func (v *vhostlist) login(
+	cred *vhost.Credential,
+) (*vhost.VHost, *vhost.User) {
+	v.lock.RLock()
+	val, ok := v.user[cred.User]
+	v.lock.RUnlock()
+	if !ok {
+		vhost.AuthUnknown(cred)
+		return nil, nil
+	}
+	if u := val.Auth(cred); u != nil {
+		return val, u
+	}
+	return nil, nil
+}
@@ -1,21 +1,50 @@
+session {
+  // evaluated once per connection, persists across the commands
+  commands = 0;
+}
+
 config redis_vhost {
   .name = "redis_test";
   .listener = "test";
+
+  // command without rule is served by the built-in keyspace
+  .keyspace = true;
 }
 
 rule "redis.HGET" {
   println("command info: (", $.command, ", ", $.length, ", ", $.category, ")");
-  if $.length != 1 {
-    conn:writeString("invalid command!");
-  } else {
-    let value = $:asString(0);
-    println("command HGET ", value);
-    // write the response back
-    conn:writeString("Always HGET yeah!");
-  }
+  // arity is validated before dispatching, ie HGET key field
+  let value = $:asString(0);
+  println("command HGET ", value);
+  // write the response back
+  conn:writeString("Always HGET yeah!");
+}
+
+rule "redis.NOTIFY" {
+  // delivered to the SUBSCRIBE/PSUBSCRIBE connections of any vhost, the
+  // subscription itself is served by the vhost, redis.* below never sees it
+  conn:writeInt(pubsub::publish("notify", $:asString(0)));
+}
+
+rule "redis.INFO" {
+  // verbatim string under RESP3 after HELLO 3, otherwise a bulk string
+  conn:writeVerbatim("# Server\r\nredis_mode:standalone\r\nprotocol:" + conn.protocol:to_string() + "\r\n");
+}
+
+rule "redis.@accept" {
+  conn.context["accept"] = "yes";
+}
+
+rule "redis.@close" {
+  println("connection closed after ", commands, " commands");
 }
 
 rule "redis.*" {
+  commands++;
   println("command info: (", $.command, ", ", $.length, ", ", $.category, ")");
-  conn:writeString("Hello World");
+  println("command #", commands, " accepted: ", conn.context["accept"]);
+  println("command keys: ", $.keys, ", write: ", $.isWrite, ", arity: ", $.arity);
+
+  // intercepted by the rule, and falls through to the built-in keyspace
+  kv::fallthrough($);
 }
@@ -0,0 +1,41 @@
+config redis_vhost {
+  .name = "redis_proxy";
+  .listener = "test";
+
+  // command without rule is forwarded to the backend owning its key, kv://name
+  // is an in-process keyspace which is handy for testing
+  .proxy_backend = ["127.0.0.1:6380", "127.0.0.1:6381", "kv://local"];
+  .proxy_hash = "consistent";
+  .proxy_pool_size = 4;
+  .proxy_timeout = 1000;
+}
+
+rule "redis.@accept" {
+  conn.context["denied"] = 0;
+}
+
+// before forwarding, the rule can deny, reply directly or rewrite the command
+rule "redis.@forward" {
+  let name = $.command;
+  if name == "FLUSHALL" || name == "FLUSHDB" {
+    conn.context["denied"]++;
+    deny => "ERR " + name + " is not allowed by the proxy";
+  } elif name == "GET" && $:asString(0) == "version" {
+    reply => "mono-proxy";
+  } elif name == "SETEX" {
+    // the backend only allows bounded expiration
+    rewrite => ["SET", $:asString(0), $:asString(2), "EX", "60"];
+  }
+}
+
+// after forwarding, the rule can inspect and replace the reply
+rule "redis.@reply" {
+  if type($.error) == "string" {
+    println("command ", $.command.command, " failed: ", $.error);
+  }
+}
+
+// rule handles the command itself and talks to the backends via proxy::
+rule "redis.INFO" {
+  conn:writeBulk("proxy dbsize: " + proxy::dbsize() + ", denied: " + conn.context["denied"]);
+}
@@ -0,0 +1,22 @@
+config redis_vhost {
+  .name = "redis_tenant";
+
+  // shares the listener with the public vhost, ie sample/redis1, the connection
+  // switches to this vhost by AUTH username password or HELLO 3 AUTH
+  .listener = "test";
+  .keyspace = true;
+
+  // name, password, command categories and key patterns
+  .user("admin", "admin_secret");
+  .user("app", "app_secret", ["string", "hash", "generic"], "app:*");
+  .user("reader", "reader_secret", ["string", "unknown"], ["app:*", "public:*"]);
+}
+
+rule "redis.@accept" {
+  println("connection accepted by ", conn.user);
+}
+
+rule "redis.WHOAMI" {
+  // the unauthenticated command is rejected before any rule runs
+  conn:writeString(conn.user);
+}
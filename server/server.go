@@ -5,6 +5,7 @@ import (
 	"sync"
 
 	"github.com/dianpeng/mono-service/manifest"
+	"github.com/dianpeng/mono-service/pubsub"
 
 	// for side effect
 	_ "github.com/dianpeng/mono-service/http/prelude"
@@ -13,11 +14,16 @@ import (
 type Server struct {
 	listener []Listener
 	wg       sync.WaitGroup
+
+	// pub/sub broker shared by all the vhosts of the server
+	broker *pubsub.Broker
 }
 
 // create a new server with corresponding
 func NewServer(cfgList []ListenerConfig) (*Server, error) {
-	s := &Server{}
+	s := &Server{
+		broker: pubsub.NewBroker(),
+	}
 	for _, x := range cfgList {
 		f := GetListenerFactory(x.TypeName())
 		if f == nil {
@@ -48,6 +54,9 @@ func (s *Server) AddVirtualHost(
 	if fac == nil {
 		return fmt.Errorf("listener: unknown manifest type %s", config.Type)
 	}
+	if config.Broker == nil {
+		config.Broker = s.broker
+	}
 	vhost, err := fac.New(config)
 	if err != nil {
 		return err
@@ -71,6 +80,7 @@ func (s *Server) Run() {
 	s.wg.Add(len(s.listener))
 
 	for _, vv := range s.listener {
+		vv := vv
 		go func() {
 			defer s.wg.Done()
 			err := vv.Run()
@@ -0,0 +1,128 @@
+package test
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteTAP writes the results as TAP version 13, the failure message, the
+// backtrace and the duration of each test is written as YAML block
+func WriteTAP(out io.Writer, result []Result) error {
+	total := 0
+	for _, r := range result {
+		total += len(r.Case)
+	}
+
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "TAP version 13\n")
+	fmt.Fprintf(b, "1..%d\n", total)
+
+	idx := 0
+	for _, r := range result {
+		fmt.Fprintf(b, "# %s\n", r.File)
+		for _, c := range r.Case {
+			idx++
+			status := "ok"
+			if !c.Pass {
+				status = "not ok"
+			}
+			fmt.Fprintf(b, "%s %d - %s\n", status, idx, c.Name)
+			fmt.Fprintf(b, "  ---\n")
+			fmt.Fprintf(b, "  duration_ms: %.3f\n", float64(c.Duration.Microseconds())/1000.0)
+			if !c.Pass {
+				fmt.Fprintf(b, "  file: %s\n", c.File)
+				fmt.Fprintf(b, "  message: |\n")
+				for _, line := range strings.Split(strings.TrimRight(c.Error, "\n"), "\n") {
+					fmt.Fprintf(b, "    %s\n", line)
+				}
+				if len(c.Backtrace) != 0 {
+					fmt.Fprintf(b, "  backtrace:\n")
+					for _, f := range c.Backtrace {
+						fmt.Fprintf(b, "    - %q\n", f)
+					}
+				}
+			}
+			fmt.Fprintf(b, "  ...\n")
+		}
+	}
+
+	_, err := io.WriteString(out, b.String())
+	return err
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Time     string      `xml:"time,attr"`
+	Case     []junitCase `xml:"testcase"`
+}
+
+type junitSuites struct {
+	XMLName xml.Name     `xml:"testsuites"`
+	Suite   []junitSuite `xml:"testsuite"`
+}
+
+func firstLine(x string) string {
+	if idx := strings.IndexByte(x, '\n'); idx >= 0 {
+		return x[:idx]
+	}
+	return x
+}
+
+// WriteJUnit writes the results as JUnit XML, each file is a testsuite
+func WriteJUnit(out io.Writer, result []Result) error {
+	doc := junitSuites{}
+	for _, r := range result {
+		suite := junitSuite{
+			Name:     r.File,
+			Tests:    len(r.Case),
+			Failures: r.Failed(),
+			Time:     fmt.Sprintf("%.3f", r.Duration.Seconds()),
+		}
+		for _, c := range r.Case {
+			jc := junitCase{
+				Name:      c.Name,
+				ClassName: c.File,
+				Time:      fmt.Sprintf("%.3f", c.Duration.Seconds()),
+			}
+			if !c.Pass {
+				text := c.Error
+				for _, f := range c.Backtrace {
+					text += "\n\tat " + f
+				}
+				jc.Failure = &junitFailure{
+					Message: firstLine(c.Error),
+					Text:    text,
+				}
+			}
+			suite.Case = append(suite.Case, jc)
+		}
+		doc.Suite = append(doc.Suite, suite)
+	}
+
+	if _, err := io.WriteString(out, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(out)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(out, "\n")
+	return err
+}
@@ -0,0 +1,241 @@
+package test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/dianpeng/mono-service/http/runtime"
+	"github.com/dianpeng/mono-service/pl"
+)
+
+// Test runner of PL code. A test is either a rule named as test or test_xxx,
+// or a script function named as test_xxx, function inside of imported module
+// is discovered as well, ie mod::test_xxx. Each test is executed inside of its
+// own runtime with a fresh fake Session, so tests cannot observe each other.
+//
+// A test can be table driven by providing a fixture function, named as the
+// test name with test_ prefix replaced by fixture_, ie test_add has fixture
+// function fixture_add. The fixture function returns a list and the test is
+// executed once per element, the element is passed as the only argument of
+// test function or as the $ of the test rule.
+
+const (
+	testPrefix    = "test_"
+	fixturePrefix = "fixture_"
+)
+
+type Case struct {
+	Name     string
+	File     string
+	Pass     bool
+	Error    string
+	Duration time.Duration
+
+	// PL frames on the stack when the test fails, innermost first
+	Backtrace []string
+}
+
+type Result struct {
+	File     string
+	Case     []Case
+	Duration time.Duration
+}
+
+func (r *Result) Failed() int {
+	cnt := 0
+	for _, c := range r.Case {
+		if !c.Pass {
+			cnt++
+		}
+	}
+	return cnt
+}
+
+type Runner struct {
+	// only test whose name matches the Filter is executed, nil means all
+	Filter *regexp.Regexp
+
+	// if set, the line coverage of all the executed tests is recorded into it
+	Coverage *pl.Coverage
+
+	// optimization passes applied to the compiled module, ie pl.OptAll
+	Optimize int
+}
+
+func basename(name string) (string, string) {
+	if idx := strings.LastIndex(name, "::"); idx >= 0 {
+		return name[:idx+2], name[idx+2:]
+	}
+	return "", name
+}
+
+func isTestName(name string) bool {
+	_, base := basename(name)
+	return strings.HasPrefix(base, testPrefix)
+}
+
+func fixtureName(name string) string {
+	prefix, base := basename(name)
+	return prefix + fixturePrefix + strings.TrimPrefix(base, testPrefix)
+}
+
+type testEntry struct {
+	name string
+	rule bool
+}
+
+func discover(m *pl.Module) []testEntry {
+	o := []testEntry{}
+	for _, name := range m.RuleList() {
+		if name == "test" || isTestName(name) {
+			o = append(o, testEntry{name: name, rule: true})
+		}
+	}
+	for _, name := range m.FunctionList() {
+		if isTestName(name) {
+			o = append(o, testEntry{name: name})
+		}
+	}
+	return o
+}
+
+func (r *Runner) newRuntime(m *pl.Module, s *Session) (*runtime.Runtime, error) {
+	rt := runtime.NewRuntimeWithModule(m)
+	rt.Eval.Coverage = r.Coverage
+	if err := rt.OnGlobal(s); err != nil {
+		return nil, fmt.Errorf("global: %s", err.Error())
+	}
+	if err := rt.OnTestSession(s); err != nil {
+		return nil, fmt.Errorf("session: %s", err.Error())
+	}
+	return rt, nil
+}
+
+func (r *Runner) fixture(m *pl.Module, name string) ([]pl.Val, bool, error) {
+	fn := m.GetFunction(fixtureName(name))
+	if !fn.IsClosure() {
+		return nil, false, nil
+	}
+	rt, err := r.newRuntime(m, NewSession())
+	if err != nil {
+		return nil, true, err
+	}
+	v, err := fn.Closure().Call(rt.Eval, nil)
+	if err != nil {
+		return nil, true, err
+	}
+	if !v.IsList() {
+		return nil, true, fmt.Errorf("fixture %s must return a list", fixtureName(name))
+	}
+	return v.List().Data, true, nil
+}
+
+func (r *Runner) runCase(m *pl.Module, t testEntry, arg []pl.Val) error {
+	rt, err := r.newRuntime(m, NewSession())
+	if err != nil {
+		return err
+	}
+	defer rt.CancelPending()
+
+	if t.rule {
+		ctx := pl.NewValNull()
+		if len(arg) != 0 {
+			ctx = arg[0]
+		}
+		_, err = rt.OnTest(t.name, ctx)
+	} else {
+		fn := m.GetFunction(t.name)
+		_, err = fn.Closure().Call(rt.Eval, arg)
+	}
+	return err
+}
+
+func (r *Runner) run(file string, m *pl.Module, t testEntry, out *Result) {
+	if r.Filter != nil && !r.Filter.MatchString(t.name) {
+		return
+	}
+
+	fixture, has, err := r.fixture(m, t.name)
+	if err != nil {
+		out.Case = append(out.Case, Case{
+			Name:  t.name,
+			File:  file,
+			Error: fmt.Sprintf("fixture: %s", err.Error()),
+		})
+		return
+	}
+
+	runOne := func(name string, arg []pl.Val) {
+		start := time.Now()
+		err := r.runCase(m, t, arg)
+		c := Case{
+			Name:     name,
+			File:     file,
+			Pass:     err == nil,
+			Duration: time.Since(start),
+		}
+		if err != nil {
+			c.Error = err.Error()
+			var perr *pl.Error
+			if errors.As(err, &perr) {
+				c.Error = perr.Err.Error()
+				for _, f := range perr.Backtrace {
+					c.Backtrace = append(c.Backtrace, f.String())
+				}
+			}
+		}
+		out.Case = append(out.Case, c)
+	}
+
+	if !has {
+		runOne(t.name, nil)
+		return
+	}
+	for idx, v := range fixture {
+		runOne(fmt.Sprintf("%s/%d", t.name, idx), []pl.Val{v})
+	}
+}
+
+// RunModule runs all the tests discovered inside of the module
+func (r *Runner) RunModule(file string, m *pl.Module) Result {
+	start := time.Now()
+	out := Result{
+		File: file,
+	}
+	if r.Coverage != nil {
+		r.Coverage.AddModule(m, file)
+	}
+	for _, t := range discover(m) {
+		r.run(file, m, t, &out)
+	}
+	out.Duration = time.Since(start)
+	return out
+}
+
+// RunFile compiles the file and runs all its tests, a compilation failure is
+// reported as a failed case
+func (r *Runner) RunFile(file string) Result {
+	data, err := os.ReadFile(file)
+	if err == nil {
+		var m *pl.Module
+		if m, err = pl.CompileModule(string(data), nil); err == nil {
+			m.Optimize(r.Optimize)
+			return r.RunModule(file, m)
+		}
+	}
+	return Result{
+		File: file,
+		Case: []Case{
+			{
+				Name:  filepath.Base(file),
+				File:  file,
+				Error: fmt.Sprintf("compile: %s", err.Error()),
+			},
+		},
+	}
+}
@@ -0,0 +1,284 @@
+package test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/dianpeng/mono-service/pl"
+)
+
+func testRun(t *testing.T, code string, filter string) Result {
+	m, err := pl.CompileModule(code, nil)
+	assert.True(t, err == nil, "%s", err)
+	r := &Runner{}
+	if filter != "" {
+		r.Filter = regexp.MustCompile(filter)
+	}
+	return r.RunModule("test.pl", m)
+}
+
+func caseByName(r Result, name string) *Case {
+	for idx := range r.Case {
+		if r.Case[idx].Name == name {
+			return &r.Case[idx]
+		}
+	}
+	return nil
+}
+
+func TestRunnerDiscover(t *testing.T) {
+	r := testRun(t, `
+fn helper() {
+  return 1;
+}
+fn test_fn() {
+  assert::eq(helper(), 1);
+}
+fn test_fail() {
+  assert::eq(helper(), 2);
+}
+rule test_rule {
+  assert::eq(test, "testing_driver");
+}
+rule other {
+}
+`, "")
+	assert.Equal(t, 3, len(r.Case))
+	assert.Equal(t, 1, r.Failed())
+	assert.True(t, caseByName(r, "test_fn").Pass)
+	assert.True(t, caseByName(r, "test_rule").Pass)
+
+	fail := caseByName(r, "test_fail")
+	assert.False(t, fail.Pass)
+	assert.Contains(t, fail.Error, "assert::eq")
+	assert.Equal(t, []string{"test_fail (9:27)"}, fail.Backtrace)
+
+	r = testRun(t, `
+fn test_a() {}
+fn test_b() {}
+`, "_b$")
+	assert.Equal(t, 1, len(r.Case))
+	assert.Equal(t, "test_b", r.Case[0].Name)
+}
+
+func TestRunnerIsolation(t *testing.T) {
+	// each test has its own session
+	r := testRun(t, `
+session {
+  counter = 0;
+}
+fn test_a() {
+  session::counter++;
+  assert::eq(session::counter, 1);
+}
+fn test_b() {
+  session::counter++;
+  assert::eq(session::counter, 1);
+}
+`, "")
+	assert.Equal(t, 2, len(r.Case))
+	assert.Equal(t, 0, r.Failed())
+}
+
+func TestRunnerFixture(t *testing.T) {
+	r := testRun(t, `
+fn fixture_add() {
+  return [[1, 2, 3], [2, 2, 4], [1, 1, 3]];
+}
+fn test_add(c) {
+  assert::eq(c[0] + c[1], c[2]);
+}
+fn fixture_rule() {
+  return ["a", "b"];
+}
+rule test_rule {
+  assert::eq(type($), "string");
+}
+`, "")
+	assert.Equal(t, 5, len(r.Case))
+	assert.True(t, caseByName(r, "test_add/0").Pass)
+	assert.True(t, caseByName(r, "test_add/1").Pass)
+	assert.False(t, caseByName(r, "test_add/2").Pass)
+	assert.True(t, caseByName(r, "test_rule/0").Pass)
+	assert.True(t, caseByName(r, "test_rule/1").Pass)
+
+	r = testRun(t, `
+fn fixture_x() {
+  return 1;
+}
+fn test_x(a) {}
+`, "")
+	assert.Equal(t, 1, len(r.Case))
+	assert.Contains(t, r.Case[0].Error, "must return a list")
+}
+
+func TestRunnerMock(t *testing.T) {
+	r := testRun(t, `
+fn test_http() {
+  mock::http("GET", "http://example.com/a", 201, "hello", {"x-a": "b"});
+  let resp = http::get("http://example.com/a");
+  assert::eq(resp.status, 201);
+  assert::eq(resp.body:string(), "hello");
+  assert::eq(mock::http_count("get", "http://example.com/a"), 1);
+}
+fn test_async() {
+  mock::http("GET", "http://example.com/a", 200, "a");
+  mock::http("GET", "http://example.com/b", 201, "b");
+
+  let fa = http::async("http://example.com/a", "GET");
+  let fb = http::async("http://example.com/b", "GET", null, null, 1000);
+  let all = http::all([fa, fb], time::seconds(1));
+  assert::eq(all[0].status, 200);
+  assert::eq(all[1].body:string(), "b");
+  assert::eq(fa:wait().status, 200);
+  assert::eq(fa:done(), true);
+
+  let bad = http::async("http://example.com/none", "GET");
+  assert::eq(http::any([bad, http::async("http://example.com/b", "GET")]).status, 201);
+  assert::throw(fn() {
+    http::all([http::async("http://example.com/b", "GET"), bad]);
+  });
+  assert::throw(fn() {
+    http::any([bad]);
+  });
+  assert::eq(type(bad.error), "string");
+  let r = http::race([http::async("http://example.com/a", "GET")]);
+  assert::eq(r.status, 200);
+  assert::eq(mock::http_count("get", "http://example.com/a"), 2);
+}
+fn test_http_unmatched() {
+  http::get("http://example.com/b");
+}
+fn test_request() {
+  assert::eq(request.method, "GET");
+  mock::request("POST", "http://example.com/x", {"a": "b"}, "body");
+  assert::eq(request.method, "POST");
+  assert::eq(request.header:getFirst("a"), "b");
+  assert::eq(request.body:string(), "body");
+}
+fn test_response() {
+  assert::eq(response.status, 200);
+  response.status = 404;
+  assert::eq(response.status, 404);
+}
+`, "")
+	assert.Equal(t, 5, len(r.Case))
+	assert.True(t, caseByName(r, "test_http").Pass, "%s", caseByName(r, "test_http").Error)
+	assert.True(t, caseByName(r, "test_async").Pass, "%s", caseByName(r, "test_async").Error)
+	assert.True(t, caseByName(r, "test_request").Pass, "%s", caseByName(r, "test_request").Error)
+	assert.True(t, caseByName(r, "test_response").Pass, "%s", caseByName(r, "test_response").Error)
+
+	unmatched := caseByName(r, "test_http_unmatched")
+	assert.False(t, unmatched.Pass)
+	assert.Contains(t, unmatched.Error, "no http mock")
+}
+
+func TestReport(t *testing.T) {
+	r := testRun(t, `
+fn test_ok() {}
+fn check(x) {
+  assert::eq(x, 2);
+}
+fn test_fail() {
+  check(1);
+}
+`, "")
+
+	var b bytes.Buffer
+	assert.True(t, WriteTAP(&b, []Result{r}) == nil)
+	tap := b.String()
+	assert.Contains(t, tap, "TAP version 13\n1..2\n")
+	assert.Contains(t, tap, "ok 1 - test_ok\n")
+	assert.Contains(t, tap, "not ok 2 - test_fail\n")
+	assert.Contains(t, tap, "  message: |\n")
+	assert.Contains(t, tap, "  backtrace:\n    - \"check (4:20)\"\n    - \"test_fail (7:12)\"\n")
+
+	b.Reset()
+	assert.True(t, WriteJUnit(&b, []Result{r}) == nil)
+	junit := b.String()
+	assert.Contains(t, junit, `<testsuite name="test.pl" tests="2" failures="1"`)
+	assert.Contains(t, junit, `<testcase name="test_ok" classname="test.pl"`)
+	assert.Contains(t, junit, `<failure message=`)
+	assert.Contains(t, junit, `&#xA;&#x9;at check (4:20)&#xA;&#x9;at test_fail (7:12)</failure>`)
+}
+
+func TestRunnerCoverage(t *testing.T) {
+	m, err := pl.CompileModule(`
+fn helper(a) {
+  if a {
+    return 1;
+  }
+  return 2;
+}
+fn test_a() {
+  assert::eq(helper(true), 1);
+}
+`, nil)
+	assert.True(t, err == nil, "%s", err)
+
+	r := &Runner{
+		Coverage: pl.NewCoverage(),
+	}
+	r.Coverage.Enable(true)
+	res := r.RunModule("test.pl", m)
+	assert.Equal(t, 0, res.Failed())
+
+	var b bytes.Buffer
+	assert.True(t, r.Coverage.WriteLCOV(&b) == nil)
+	lcov := b.String()
+	assert.Contains(t, lcov, "SF:test.pl\n")
+	assert.Contains(t, lcov, "FNDA:1,fn helper\n")
+	assert.Contains(t, lcov, "FNDA:1,fn test_a\n")
+	assert.Contains(t, lcov, "DA:4,1\n")
+	assert.Contains(t, lcov, "DA:6,0\n")
+}
+
+// runs the sample scripts of assets/test with and without optimization
+func BenchmarkAssets(b *testing.B) {
+	files, err := filepath.Glob("../assets/test/*.pl")
+	if err != nil || len(files) == 0 {
+		b.Fatal("no sample script")
+	}
+	for _, opt := range []struct {
+		name string
+		flag int
+	}{
+		{"plain", pl.OptNone},
+		{"optimized", pl.OptAll},
+	} {
+		type asset struct {
+			file   string
+			module *pl.Module
+		}
+		assets := []asset{}
+		for _, f := range files {
+			data, err := os.ReadFile(f)
+			if err != nil {
+				b.Fatal(err)
+			}
+			// import path of the sample is relative to the repository root
+			m, err := pl.CompileModule(string(data), os.DirFS(".."))
+			if err != nil {
+				b.Fatalf("%s: %s", f, err)
+			}
+			m.Optimize(opt.flag)
+			assets = append(assets, asset{f, m})
+		}
+
+		b.Run(opt.name, func(b *testing.B) {
+			r := &Runner{}
+			for i := 0; i < b.N; i++ {
+				for _, a := range assets {
+					if res := r.RunModule(a.file, a.module); res.Failed() != 0 {
+						b.Fatalf("%s failed", a.file)
+					}
+				}
+			}
+		})
+	}
+}
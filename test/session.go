@@ -0,0 +1,298 @@
+package test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"github.com/dianpeng/mono-service/hpl"
+	"github.com/dianpeng/mono-service/pl"
+	"github.com/dianpeng/mono-service/pubsub"
+)
+
+// Session is a fake runtime.SessionWrapper used by the test runner. It exposes
+// a fake request/response/params to the script, and all the http::* calls are
+// served by the mocks registered via mock::http, so no network is involved.
+//
+// The following functions are available to the script:
+//
+//	mock::request(method, url, [header], [body])   replace the request
+//	mock::http(method, url, status, [body], [header]) register a http mock
+//	mock::http_count(method, url)                  times the mock is hit
+type Session struct {
+	request  pl.Val
+	response pl.Val
+	params   pl.Val
+	mock     []*httpMock
+	vars     map[string]pl.Val
+
+	// http::async issues the mocked request from background goroutine
+	mockLock sync.Mutex
+
+	// pubsub::publish of the case, nobody subscribes it
+	broker *pubsub.Broker
+}
+
+type httpMock struct {
+	method string
+	url    string
+	status int
+	body   string
+	header http.Header
+	count  int
+}
+
+func NewSession() *Session {
+	s := &Session{
+		params: pl.NewValMap(),
+		broker: pubsub.NewBroker(),
+		vars: map[string]pl.Val{
+			"test":     pl.NewValStr("testing_driver"),
+			"version":  pl.NewValStr("1.0"),
+			"theValue": pl.NewValNull(),
+			"slot":     pl.NewValNull(),
+		},
+	}
+	s.setRequest(httptest.NewRequest("GET", "http://localhost/", nil))
+
+	// response is a plain map, so the script can set/get its status, header and
+	// body as if it is the real response writer
+	s.response = pl.NewValMap()
+	resp := s.response.Map()
+	resp.Set("status", pl.NewValInt(200))
+	resp.Set("header", hpl.NewHeaderVal(make(http.Header)))
+	resp.Set("body", pl.NewValStr(""))
+	return s
+}
+
+func (s *Session) setRequest(req *http.Request) {
+	s.request = hpl.NewRequestVal(req)
+}
+
+func toHeader(v pl.Val) (http.Header, error) {
+	hdr := make(http.Header)
+	if v.IsNull() {
+		return hdr, nil
+	}
+	if !v.IsMap() {
+		return nil, fmt.Errorf("header must be a map")
+	}
+	var err error
+	v.Map().Foreach(func(k string, v pl.Val) bool {
+		var str string
+		str, err = v.ToString()
+		if err != nil {
+			return false
+		}
+		hdr.Add(k, str)
+		return true
+	})
+	return hdr, err
+}
+
+func argStr(args []pl.Val, idx int, name string) (string, error) {
+	if len(args) <= idx {
+		return "", nil
+	}
+	str, err := args[idx].ToString()
+	if err != nil {
+		return "", fmt.Errorf("%s: argument %d must be string", name, idx)
+	}
+	return str, nil
+}
+
+func argVal(args []pl.Val, idx int) pl.Val {
+	if len(args) <= idx {
+		return pl.NewValNull()
+	}
+	return args[idx]
+}
+
+func (s *Session) mockRequest(args []pl.Val) (pl.Val, error) {
+	if len(args) < 2 {
+		return pl.NewValNull(), fmt.Errorf("mock::request: expect at least method and url")
+	}
+	method, err := argStr(args, 0, "mock::request")
+	if err != nil {
+		return pl.NewValNull(), err
+	}
+	url, err := argStr(args, 1, "mock::request")
+	if err != nil {
+		return pl.NewValNull(), err
+	}
+	hdr, err := toHeader(argVal(args, 2))
+	if err != nil {
+		return pl.NewValNull(), fmt.Errorf("mock::request: %s", err.Error())
+	}
+	body, err := argStr(args, 3, "mock::request")
+	if err != nil {
+		return pl.NewValNull(), err
+	}
+
+	req := httptest.NewRequest(method, url, strings.NewReader(body))
+	for k, v := range hdr {
+		req.Header[k] = v
+	}
+	s.setRequest(req)
+	return s.request, nil
+}
+
+func (s *Session) mockHttp(args []pl.Val) (pl.Val, error) {
+	if len(args) < 3 || !args[2].IsInt() {
+		return pl.NewValNull(), fmt.Errorf("mock::http: expect method, url and status")
+	}
+	method, err := argStr(args, 0, "mock::http")
+	if err != nil {
+		return pl.NewValNull(), err
+	}
+	url, err := argStr(args, 1, "mock::http")
+	if err != nil {
+		return pl.NewValNull(), err
+	}
+	body, err := argStr(args, 3, "mock::http")
+	if err != nil {
+		return pl.NewValNull(), err
+	}
+	hdr, err := toHeader(argVal(args, 4))
+	if err != nil {
+		return pl.NewValNull(), fmt.Errorf("mock::http: %s", err.Error())
+	}
+
+	s.mockLock.Lock()
+	defer s.mockLock.Unlock()
+	s.mock = append(s.mock, &httpMock{
+		method: strings.ToUpper(method),
+		url:    url,
+		status: int(args[2].Int()),
+		body:   body,
+		header: hdr,
+	})
+	return pl.NewValNull(), nil
+}
+
+func (s *Session) findMock(method string, url string) *httpMock {
+	for _, m := range s.mock {
+		if m.method == method && m.url == url {
+			return m
+		}
+	}
+	return nil
+}
+
+func (s *Session) mockHttpCount(args []pl.Val) (pl.Val, error) {
+	method, err := argStr(args, 0, "mock::http_count")
+	if err != nil {
+		return pl.NewValNull(), err
+	}
+	url, err := argStr(args, 1, "mock::http_count")
+	if err != nil {
+		return pl.NewValNull(), err
+	}
+	s.mockLock.Lock()
+	defer s.mockLock.Unlock()
+	if m := s.findMock(strings.ToUpper(method), url); m != nil {
+		return pl.NewValInt(m.count), nil
+	}
+	return pl.NewValInt(0), nil
+}
+
+// hpl.HttpClient
+func (s *Session) Do(req *http.Request) (*http.Response, error) {
+	s.mockLock.Lock()
+	defer s.mockLock.Unlock()
+	m := s.findMock(req.Method, req.URL.String())
+	if m == nil {
+		return nil, fmt.Errorf("no http mock for %s %s", req.Method, req.URL.String())
+	}
+	m.count++
+
+	hdr := make(http.Header)
+	for k, v := range m.header {
+		hdr[k] = v
+	}
+	return &http.Response{
+		Status:     fmt.Sprintf("%d %s", m.status, http.StatusText(m.status)),
+		StatusCode: m.status,
+		Header:     hdr,
+		Body:       io.NopCloser(strings.NewReader(m.body)),
+		Request:    req,
+	}, nil
+}
+
+// runtime.Resource
+func (s *Session) GetHttpClient(_ string) (hpl.HttpClient, error) {
+	return s, nil
+}
+
+func (s *Session) Broker() *pubsub.Broker {
+	return s.broker
+}
+
+// runtime.Context
+func (s *Session) OnLoadVar(e *pl.Evaluator, name string) (pl.Val, error) {
+	switch name {
+	case "request":
+		return s.request, nil
+	case "response":
+		return s.response, nil
+	case "params":
+		return s.params, nil
+
+	case "mock::request":
+		return pl.NewValNativeFunction("mock::request", s.mockRequest), nil
+	case "mock::http":
+		return pl.NewValNativeFunction("mock::http", s.mockHttp), nil
+	case "mock::http_count":
+		return pl.NewValNativeFunction("mock::http_count", s.mockHttpCount), nil
+
+	// call the closure with the rest arguments, for testing native to script
+	// frame interleaving
+	case "callback":
+		return pl.NewValNativeFunction(
+			"callback",
+			func(args []pl.Val) (pl.Val, error) {
+				if len(args) < 1 || !args[0].IsClosure() {
+					return pl.NewValNull(), fmt.Errorf("invalid type, must be closure")
+				}
+				return args[0].Closure().Call(
+					e,
+					args[1:],
+				)
+			},
+		), nil
+
+	case "return_something":
+		return pl.NewValNativeFunction(
+			"return_something",
+			func(_ []pl.Val) (pl.Val, error) {
+				return pl.NewValStr("Hello"), nil
+			},
+		), nil
+
+	default:
+		if v, ok := s.vars[name]; ok {
+			return v, nil
+		}
+		return pl.NewValNull(), fmt.Errorf("unknown variable: %s", name)
+	}
+}
+
+// only variable that is known to the session can be stored, otherwise the
+// store is an error, ie assigning to a function symbol
+func (s *Session) OnStoreVar(_ *pl.Evaluator, name string, val pl.Val) error {
+	if _, ok := s.vars[name]; !ok {
+		return fmt.Errorf("unknown variable: %s", name)
+	}
+	s.vars[name] = val
+	return nil
+}
+
+// runtime.Action, the action value is stored as variable, ie slot => 1 makes
+// slot loaded as 1
+func (s *Session) OnAction(_ *pl.Evaluator, name string, val pl.Val) error {
+	s.vars[name] = val
+	return nil
+}
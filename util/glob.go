@@ -0,0 +1,95 @@
+package util
+
+// GlobMatch is the redis style glob used by KEYS and PSUBSCRIBE, supports
+// * ? [abc] [^abc] [a-z] and \x
+func GlobMatch(pattern, str string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 1 && pattern[1] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(str); i++ {
+				if GlobMatch(pattern[1:], str[i:]) {
+					return true
+				}
+			}
+			return false
+
+		case '?':
+			if len(str) == 0 {
+				return false
+			}
+			str = str[1:]
+			pattern = pattern[1:]
+
+		case '[':
+			if len(str) == 0 {
+				return false
+			}
+			end, ok := globClass(pattern, str[0])
+			if !ok {
+				return false
+			}
+			str = str[1:]
+			pattern = pattern[end:]
+
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+
+		default:
+			if len(str) == 0 || str[0] != pattern[0] {
+				return false
+			}
+			str = str[1:]
+			pattern = pattern[1:]
+		}
+	}
+	return len(str) == 0
+}
+
+// match c against the class starts at pattern[0] == '[', returns the index
+// after the class and whether it matches
+func globClass(pattern string, c byte) (int, bool) {
+	i := 1
+	not := false
+	if i < len(pattern) && pattern[i] == '^' {
+		not = true
+		i++
+	}
+	match := false
+	for i < len(pattern) && pattern[i] != ']' {
+		switch {
+		case pattern[i] == '\\' && i+1 < len(pattern):
+			if pattern[i+1] == c {
+				match = true
+			}
+			i += 2
+		case i+2 < len(pattern) && pattern[i+1] == '-' && pattern[i+2] != ']':
+			lo, hi := pattern[i], pattern[i+2]
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			if c >= lo && c <= hi {
+				match = true
+			}
+			i += 3
+		default:
+			if pattern[i] == c {
+				match = true
+			}
+			i++
+		}
+	}
+	// skip the closing ]
+	if i < len(pattern) {
+		i++
+	}
+	return i, match != not
+}